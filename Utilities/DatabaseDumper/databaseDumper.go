@@ -67,12 +67,23 @@ func ExportDatabaseJSON(db interfaces.IDatabase, convertNames bool) error {
 	answer := map[string]interface{}{}
 	for _, bucket := range buckets {
 		m := map[string]interface{}{}
-		data, keys, err := db.GetAll(bucket, new(primitives.ByteSlice))
+		it, err := db.Iterate(bucket, interfaces.IteratorOptions{})
 		if err != nil {
 			return err
 		}
-		for i, key := range keys {
-			m[fmt.Sprintf("%x", key)] = data[i]
+		for it.Next() {
+			data := new(primitives.ByteSlice)
+			err = data.UnmarshalBinary(it.Value())
+			if err != nil {
+				it.Release()
+				return err
+			}
+			m[fmt.Sprintf("%x", it.Key())] = data
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			return err
 		}
 		if convertNames == true {
 			answer[KeyToName(bucket)] = m
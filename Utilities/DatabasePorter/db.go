@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/databaseOverlay"
 	"github.com/FactomProject/factomd/database/hybridDB"
 	"github.com/FactomProject/factomd/database/mapdb"
@@ -38,6 +39,18 @@ func InitLevelDB(cfg *util.FactomdConfig) interfaces.DBOverlay {
 	return databaseOverlay.NewOverlay(dbase)
 }
 
+func InitBadgerDB(cfg *util.FactomdConfig) interfaces.DBOverlay {
+	//fmt.Println("InitBadgerDB")
+	path := cfg.App.BadgerDBPath + "/" + "FactomBadger-Import.db"
+
+	dbase, err := badgerdb.NewBadgerDB(path, true)
+	if err != nil {
+		panic(err)
+	}
+
+	return databaseOverlay.NewOverlay(dbase)
+}
+
 func InitMapDB(cfg *util.FactomdConfig) interfaces.DBOverlay {
 	//fmt.Println("InitMapDB")
 	dbase := new(mapdb.MapDB)
@@ -0,0 +1,49 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/badgerdb"
+	"github.com/FactomProject/factomd/database/boltdb"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/leveldb"
+)
+
+// OpenDatabase opens an existing local database of the given type for migration
+func OpenDatabase(dbtype string, path string) (interfaces.IDatabase, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	switch dbtype {
+	case "LDB":
+		return leveldb.NewLevelDB(path, false)
+	case "Bolt":
+		return boltdb.NewBoltDB(nil, path), nil
+	case "Badger":
+		return badgerdb.NewBadgerDB(path, false)
+	}
+	return nil, fmt.Errorf("%s is not a valid database to migrate from. Expect 'LDB', 'Bolt', or 'Badger'", dbtype)
+}
+
+// MigrateDatabase copies every record of the source database into the destination database,
+// as is.  Returns the number of records copied.
+func MigrateDatabase(from interfaces.IDatabase, to interfaces.IDatabase) (int, error) {
+	return databaseOverlay.CopyDatabase(from, to, func(bucket []byte) {
+		fmt.Printf("Migrated bucket %v\n", KeyToName(bucket))
+	})
+}
+
+// KeyToName returns the name of a fixed bucket, or the bucket in hex
+func KeyToName(bucket []byte) string {
+	name, ok := databaseOverlay.ConstantNamesMap[string(bucket)]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("%x", bucket)
+}
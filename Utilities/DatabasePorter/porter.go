@@ -49,6 +49,9 @@ func main() {
 		completedBlock = flag.Int("completed", 0, "Will only do a random sampling of entries below 'completed' block if 'fast' enabled")
 
 		sampleRate = flag.Int("sampleRate", 10000, "Will sample 1/sampleRate entries below completedblock if fast enabled")
+
+		migrate     = flag.String("migrate", "", "Instead of importing from the network, copy the local database of this type (LDB, Bolt, or Badger) into the configured database")
+		migratePath = flag.String("migratepath", "", "Path of the local database to migrate from")
 	)
 	flag.Parse()
 
@@ -60,6 +63,11 @@ func main() {
 
 	cfg = util.ReadConfig("")
 
+	if *migrate != "" {
+		Migrate(*migrate, *migratePath)
+		return
+	}
+
 	if dbo != nil {
 		dbo.Close()
 	}
@@ -70,6 +78,9 @@ func main() {
 	case "LDB":
 		dbo = InitLevelDB(cfg)
 		break
+	case "Badger":
+		dbo = InitBadgerDB(cfg)
+		break
 	default:
 		dbo = InitMapDB(cfg)
 		break
@@ -168,6 +179,9 @@ func main() {
 	case "LDB":
 		dbo = InitLevelDB(cfg)
 		break
+	case "Badger":
+		dbo = InitBadgerDB(cfg)
+		break
 	default:
 		dbo = InitMapDB(cfg)
 		break
@@ -185,6 +199,9 @@ func main() {
 	case "LDB":
 		dbo = InitLevelDB(cfg)
 		break
+	case "Badger":
+		dbo = InitBadgerDB(cfg)
+		break
 	default:
 		dbo = InitMapDB(cfg)
 		break
@@ -317,6 +334,9 @@ func SaveToDBLoop(input chan []BlockSet, done chan int) {
 		case "LDB":
 			dbo = InitLevelDB(cfg)
 			break
+		case "Badger":
+			dbo = InitBadgerDB(cfg)
+			break
 		default:
 			dbo = InitMapDB(cfg)
 			break
@@ -775,3 +795,34 @@ func GetDBlockList() []string {
 
 		keymr}
 }
+
+// Migrate copies a local database into the database of the configured DBType
+func Migrate(dbtype string, path string) {
+	from, err := OpenDatabase(dbtype, path)
+	if err != nil {
+		panic(err)
+	}
+	defer from.Close()
+
+	switch cfg.App.DBType {
+	case "Bolt":
+		dbo = InitBolt(cfg)
+		break
+	case "LDB":
+		dbo = InitLevelDB(cfg)
+		break
+	case "Badger":
+		dbo = InitBadgerDB(cfg)
+		break
+	default:
+		panic(fmt.Sprintf("Can't migrate into a %v database", cfg.App.DBType))
+	}
+	defer dbo.Close()
+
+	fmt.Printf("Migrating the %v database %v into the %v database\n", dbtype, path, cfg.App.DBType)
+	count, err := MigrateDatabase(from, dbo)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Migrated %d records\n", count)
+}
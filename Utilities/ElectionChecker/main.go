@@ -0,0 +1,83 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/messages/electionMsgs/electionMsgTesting"
+)
+
+func main() {
+	d := electionMsgTesting.DefaultCheckConfig
+	var (
+		feds       = flag.Int("f", d.Feds, "Number of federated servers")
+		auds       = flag.Int("a", d.Auds, "Number of audit servers")
+		volunteers = flag.Int("v", d.Volunteers, "Number of audit servers volunteering")
+		electing   = flag.Int("e", d.Electing, "Index of the federated server being replaced")
+		faulty     = flag.Int("faulty", d.Faulty, "Number of nodes that may lose messages, a minority")
+		drops      = flag.Int("drops", d.Drops, "Maximum messages lost in a run")
+		dups       = flag.Int("dups", d.Dups, "Maximum messages duplicated in a run")
+		steps      = flag.Int("steps", d.MaxSteps, "Steps without a majority committed before a run fails")
+		seed       = flag.Int64("seed", 1, "Seed of the first run")
+		runs       = flag.Int("runs", 100, "Number of runs")
+		trace      = flag.String("trace", "election.trace", "File to save the trace of a failing run to")
+		replay     = flag.String("replay", "", "Trace file to replay, instead of checking")
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Println("ElectionChecker [-f=3 -a=2 -runs=100 ...]")
+		fmt.Println("ElectionChecker -replay=election.trace")
+		fmt.Println("Runs the elections code on random orderings, drops and duplications of the election messages,")
+		fmt.Println("checking that the nodes agree on the server elected and that a majority commits.  A failing")
+		fmt.Println("run is saved to a trace file that can be replayed.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *replay != "" {
+		v, err := electionMsgTesting.ReplayFromFile(*replay)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if v != nil {
+			fmt.Println("Violation:", v)
+			os.Exit(2)
+		}
+		fmt.Println("The trace does not break the invariants")
+		return
+	}
+
+	config := electionMsgTesting.CheckConfig{
+		Feds:       *feds,
+		Auds:       *auds,
+		Volunteers: *volunteers,
+		Electing:   *electing,
+		Faulty:     *faulty,
+		Drops:      *drops,
+		Dups:       *dups,
+		MaxSteps:   *steps,
+	}
+	c, err := electionMsgTesting.NewChecker(config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	v := c.Check(*seed, *runs)
+	if v == nil {
+		fmt.Printf("%d runs from seed %d passed\n", *runs, *seed)
+		return
+	}
+	fmt.Printf("Violation in the run of seed %d: %v\n", v.Trace.Seed, v)
+	if err := v.Trace.Save(*trace); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("Trace saved to", *trace)
+	os.Exit(2)
+}
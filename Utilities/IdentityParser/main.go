@@ -0,0 +1,148 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/identity"
+	"github.com/FactomProject/factomd/database"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/securedb"
+	"github.com/FactomProject/factomd/state"
+)
+
+func main() {
+	var (
+		dbtype      = flag.String("dbtype", "LDB", "Type of the database: LDB, Bolt, Badger, SecureLDB, SecureBolt, or SecureBadger")
+		passphrase  = flag.String("passphrase", "", "File holding the passphrase of a Secure database, empty to read it from "+securedb.PassphraseEnv)
+		api         = flag.String("api", "", "URL of the API of a factomd node to read the blocks from, as http://localhost:8088, instead of a database")
+		network     = flag.String("network", "MAIN", "Network of the blocks: MAIN, TEST, LOCAL, or CUSTOM")
+		customID    = flag.String("customidentity", "", "Bootstrap identity of a CUSTOM network")
+		customKey   = flag.String("customkey", "", "Bootstrap key of a CUSTOM network")
+		heightsList = flag.String("heights", "", "Comma separated heights to print the authority set at, empty for the highest block")
+		export      = flag.String("export", "", "File to export the authority sets to as JSON, instead of printing them")
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Println("IdentityParser -dbtype=LDB DBFileLocation")
+		fmt.Println("IdentityParser -api=http://localhost:8088")
+		fmt.Println("Replays the admin block entries and the identity chain entries through the identity manager, and")
+		fmt.Println("prints the authority set at the heights requested.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var source identity.BlockSource
+	if *api != "" {
+		if flag.NArg() != 0 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		source = identity.NewAPISource(*api)
+	} else {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		db, err := database.OpenExisting(*dbtype, flag.Arg(0), *passphrase)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		dbo := databaseOverlay.NewOverlay(db)
+		defer dbo.Close()
+		source = dbo
+	}
+
+	s := new(state.State)
+	switch strings.ToUpper(*network) {
+	case "MAIN":
+		s.NetworkNumber = constants.NETWORK_MAIN
+	case "TEST":
+		s.NetworkNumber = constants.NETWORK_TEST
+	case "LOCAL":
+		s.NetworkNumber = constants.NETWORK_LOCAL
+	case "CUSTOM":
+		s.NetworkNumber = constants.NETWORK_CUSTOM
+		s.CustomBootstrapIdentity = *customID
+		s.CustomBootstrapKey = *customKey
+	default:
+		fmt.Println(*network, "is not a valid network")
+		os.Exit(1)
+	}
+	p := identity.NewParser(source, s.GetIdentityParserNetwork())
+
+	var heights []uint32
+	if *heightsList == "" {
+		top, err := p.Top()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		heights = append(heights, top)
+	}
+	for _, h := range strings.Split(*heightsList, ",") {
+		if h == "" {
+			continue
+		}
+		height, err := strconv.ParseUint(strings.TrimSpace(h), 10, 32)
+		if err != nil {
+			fmt.Println("Bad height", h)
+			os.Exit(1)
+		}
+		heights = append(heights, uint32(height))
+	}
+	sort.Sort(heightSort(heights))
+
+	var sets []*identity.AuthoritySet
+	for _, height := range heights {
+		err := p.ParseTo(height)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		set := p.AuthoritySet()
+		if *export == "" {
+			printAuthoritySet(set)
+		}
+		sets = append(sets, set)
+	}
+
+	if *export != "" {
+		data, err := json.MarshalIndent(sets, "", "\t")
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		err = ioutil.WriteFile(*export, data, 0644)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d authority sets to %s\n", len(sets), *export)
+	}
+}
+
+func printAuthoritySet(set *identity.AuthoritySet) {
+	fmt.Printf("Height %d: %d federated, %d audit\n", set.Height, set.Federated, set.Audit)
+	for _, a := range set.Authorities {
+		fmt.Printf("  %-10s %s  key %s  efficiency %5d  coinbase %s\n", a.Status, a.ChainID, a.SigningKey, a.Efficiency, a.CoinbaseAddress)
+	}
+}
+
+type heightSort []uint32
+
+func (h heightSort) Len() int           { return len(h) }
+func (h heightSort) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h heightSort) Less(i, j int) bool { return h[i] < h[j] }
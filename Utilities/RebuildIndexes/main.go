@@ -0,0 +1,74 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/database"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/securedb"
+)
+
+func main() {
+	var (
+		dbtype     = flag.String("dbtype", "LDB", "Type of the database: LDB, Bolt, Badger, SecureLDB, SecureBolt, or SecureBadger")
+		passphrase = flag.String("passphrase", "", "File holding the passphrase of a Secure database, empty to read it from "+securedb.PassphraseEnv)
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Println("RebuildIndexes -dbtype=LDB DBFileLocation")
+		fmt.Println("Drops the indexes derived from the blocks and entries (block numbers and secondary indexes, Entry,")
+		fmt.Println("IncludedIn, PaidFor and DirBlockInfo) and builds them again from the blocks.")
+		fmt.Println("factomd must not be running on the database. If the rebuild is interrupted, run it again to resume it.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	path := flag.Arg(0)
+
+	db, err := database.OpenExisting(*dbtype, path, *passphrase)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	dbo := databaseOverlay.NewOverlay(db)
+	defer dbo.Close()
+
+	r, err := dbo.FetchIndexRebuild()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if r != nil {
+		fmt.Println("Resuming the interrupted rebuild")
+	}
+
+	start := time.Now()
+	var mutex sync.Mutex
+	err = dbo.RebuildIndexes(func(p databaseOverlay.IndexRebuildProgress) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if p.Done {
+			fmt.Printf("%-12s done in %.0fs\n", p.Index, time.Since(start).Seconds())
+		} else {
+			fmt.Printf("%-12s %d / %d\n", p.Index, p.Height, p.Top)
+		}
+	})
+	if err != nil {
+		fmt.Println("Rebuilding the indexes failed:", err)
+		fmt.Println("Run the rebuild again to resume it")
+		os.Exit(1)
+	}
+	fmt.Println("Index rebuild complete")
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/database/securedb"
+)
+
+func main() {
+	var (
+		dbtype  = flag.String("dbtype", "LDB", "Type of the encrypted database: LDB, Bolt, or Badger")
+		oldFile = flag.String("old", "", "File holding the current passphrase")
+		newFile = flag.String("new", "", "File holding the new passphrase")
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Println("SecureDBRotate -dbtype=LDB -old=oldpassphrase.txt -new=newpassphrase.txt DBFileLocation")
+		fmt.Println("Re-encrypts every record of an encrypted database with a key derived from the new passphrase.")
+		fmt.Println("factomd must not be running on the database. If the rotation is interrupted, run it again with the same passphrases.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *oldFile == "" || *newFile == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	path := flag.Arg(0)
+	if _, err := os.Stat(path); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	oldPassphrase, err := securedb.LoadPassphrase(*oldFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	newPassphrase, err := securedb.LoadPassphrase(*newFile)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = securedb.RotateKey(path, *dbtype, oldPassphrase, newPassphrase)
+	if err != nil {
+		fmt.Println("Key rotation failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Key rotation complete")
+}
@@ -0,0 +1,229 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	var (
+		factomd   = flag.String("factomd", "factomd", "Path of the factomd binary")
+		dir       = flag.String("dir", "testnet", "Directory to keep the configuration, database and log of every node in")
+		nodes     = flag.String("nodes", "LLLAAF", "Role of every node: L for a leader, A for an audit server, F for a follower.  The first is the bootstrap leader")
+		blkTime   = flag.Int("blktime", 30, "Seconds per block")
+		basePort  = flag.Int("baseport", 9000, "Node i uses the ports baseport+10*i (API) to baseport+10*i+3 (control panel, p2p, pprof)")
+		proxyPort = flag.Int("proxyport", 9500, "First port of the proxies carrying the links between the nodes")
+		promote   = flag.Bool("promote", true, "Promote the leaders and the audit servers once the nodes are started")
+		script    = flag.String("script", "", "File of commands to run before reading commands from stdin")
+		args      = flag.String("args", "", "More factomd flags for every node, separated by spaces")
+	)
+	flag.Usage = func() {
+		fmt.Println("Usage:")
+		fmt.Println("Testnet [-factomd=factomd -nodes=LLLAAF -blktime=30 ...]")
+		fmt.Println("Starts a LOCAL network of factomd processes on localhost, each with its own identity, configuration")
+		fmt.Println("and database, wired through real p2p.  Every link goes through a proxy of the launcher, so the")
+		fmt.Println("network can be partitioned.  Type help for the commands.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	config := Config{
+		Factomd:   *factomd,
+		Dir:       *dir,
+		Roles:     *nodes,
+		BlkTime:   *blkTime,
+		BasePort:  *basePort,
+		ProxyPort: *proxyPort,
+		Args:      strings.Fields(*args),
+	}
+	t, err := NewTestnet(config)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Println("Stopping the nodes")
+		t.Close()
+		os.Exit(0)
+	}()
+
+	commands := []string{"start all"}
+	if *promote {
+		commands = append(commands, "wait 0 2", "promote")
+	}
+	for _, command := range commands {
+		if err := runCommand(t, command); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if *script != "" {
+		file, err := os.Open(*script)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			runCommands(t, file, false)
+			file.Close()
+		}
+	}
+	runCommands(t, os.Stdin, true)
+	t.Close()
+}
+
+// runCommands runs the commands read, one per line, until quit or the end of the input
+func runCommands(t *Testnet, r io.Reader, prompt bool) {
+	scanner := bufio.NewScanner(r)
+	for {
+		if prompt {
+			fmt.Print("testnet> ")
+		}
+		if !scanner.Scan() {
+			return
+		}
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "quit" {
+			return
+		}
+		if err := runCommand(t, line); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// forNodes runs f on the node given, or on every node for all
+func forNodes(t *Testnet, args []string, f func(i int) error) error {
+	if len(args) != 1 {
+		return fmt.Errorf("give a node, or all")
+	}
+	if args[0] == "all" {
+		for i := range t.Nodes {
+			if err := f(i); err != nil {
+				fmt.Println(err)
+			}
+		}
+		return nil
+	}
+	i, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("%s is not a node", args[0])
+	}
+	return f(i)
+}
+
+func runCommand(t *Testnet, line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "help":
+		fmt.Println("status                  Show the nodes, their heights and the links cut")
+		fmt.Println("start <n|all>           Start a node")
+		fmt.Println("stop <n|all>            Interrupt a node so it shuts down cleanly")
+		fmt.Println("kill <n|all>            Kill a node, as a crash would")
+		fmt.Println("restart <n|all>         Stop and start a node")
+		fmt.Println("partition 0,1,2 3,4     Cut the links between the groups of nodes")
+		fmt.Println("heal                    Restore every link")
+		fmt.Println("promote                 Put the identities on the blockchain and promote the leaders and audit servers")
+		fmt.Println("sim <n> <command>...    Run simControl commands on a node, through the sim-ctrl debug method")
+		fmt.Println("wait <n> <height>       Wait for a node to reach a height")
+		fmt.Println("sleep <seconds>         Wait")
+		fmt.Println("quit                    Stop every node and exit")
+	case "status":
+		fmt.Print(t.Status())
+	case "start":
+		return forNodes(t, args, t.Start)
+	case "stop":
+		return forNodes(t, args, func(i int) error { return t.Stop(i, 30*time.Second) })
+	case "kill":
+		return forNodes(t, args, t.Kill)
+	case "restart":
+		return forNodes(t, args, func(i int) error {
+			if t.Running(i) {
+				if err := t.Stop(i, 30*time.Second); err != nil {
+					return err
+				}
+			}
+			return t.Start(i)
+		})
+	case "partition":
+		groups, err := parseGroups(args)
+		if err != nil {
+			return err
+		}
+		return t.Partition(groups)
+	case "heal":
+		t.Heal()
+	case "promote":
+		return t.Promote()
+	case "sim":
+		if len(args) < 2 {
+			return fmt.Errorf("give a node and the commands")
+		}
+		i, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("%s is not a node", args[0])
+		}
+		return t.SimControl(i, args[1:]...)
+	case "wait":
+		if len(args) != 2 {
+			return fmt.Errorf("give a node and a height")
+		}
+		i, err1 := strconv.Atoi(args[0])
+		height, err2 := strconv.ParseInt(args[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("bad node or height")
+		}
+		return t.WaitForHeight(i, height, time.Duration(height+10)*time.Duration(t.Config.BlkTime)*time.Second)
+	case "sleep":
+		if len(args) != 1 {
+			return fmt.Errorf("give a number of seconds")
+		}
+		s, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("bad number of seconds %s", args[0])
+		}
+		time.Sleep(time.Duration(s) * time.Second)
+	default:
+		return fmt.Errorf("unknown command %s, type help for the commands", fields[0])
+	}
+	return nil
+}
+
+// parseGroups parses groups of nodes, as 0,1,2 3,4
+func parseGroups(args []string) ([][]int, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("give at least two groups of nodes, as 0,1 2,3")
+	}
+	var groups [][]int
+	for _, arg := range args {
+		var group []int
+		for _, s := range strings.Split(arg, ",") {
+			i, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not a node", s)
+			}
+			group = append(group, i)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
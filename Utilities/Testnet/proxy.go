@@ -0,0 +1,131 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// linkProxy carries the p2p connections a node dials to another node.  Every node only dials its
+// proxies, so cutting the proxies between two groups of nodes partitions the network.
+type linkProxy struct {
+	From, To int
+	Target   string // address of the p2p port of node To
+
+	listener net.Listener
+	mutex    sync.Mutex
+	cut      bool
+	conns    map[net.Conn]bool
+}
+
+// newLinkProxy listens on address for the connections from node from to node to
+func newLinkProxy(from, to int, address, target string) (*linkProxy, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	p := &linkProxy{From: from, To: to, Target: target, listener: listener, conns: map[net.Conn]bool{}}
+	go p.run()
+	return p, nil
+}
+
+// Address returns the address the proxy listens on
+func (p *linkProxy) Address() string {
+	return p.listener.Addr().String()
+}
+
+func (p *linkProxy) run() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		go p.carry(conn)
+	}
+}
+
+// carry copies a connection to the target both ways, until either end closes or the link is cut
+func (p *linkProxy) carry(conn net.Conn) {
+	if p.IsCut() {
+		conn.Close()
+		return
+	}
+	target, err := net.Dial("tcp", p.Target)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if !p.track(conn, target) {
+		conn.Close()
+		target.Close()
+		return
+	}
+
+	done := make(chan bool, 2)
+	go func() {
+		io.Copy(target, conn)
+		done <- true
+	}()
+	go func() {
+		io.Copy(conn, target)
+		done <- true
+	}()
+	<-done
+	conn.Close()
+	target.Close()
+	<-done
+
+	p.mutex.Lock()
+	delete(p.conns, conn)
+	delete(p.conns, target)
+	p.mutex.Unlock()
+}
+
+// track remembers the two ends of a connection, unless the link was cut meanwhile
+func (p *linkProxy) track(conn, target net.Conn) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.cut {
+		return false
+	}
+	p.conns[conn] = true
+	p.conns[target] = true
+	return true
+}
+
+// SetCut cuts the link, closing its connections and refusing new ones, or restores it
+func (p *linkProxy) SetCut(cut bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.cut = cut
+	if cut {
+		for conn := range p.conns {
+			conn.Close()
+		}
+		p.conns = map[net.Conn]bool{}
+	}
+}
+
+// IsCut returns true if the link is cut
+func (p *linkProxy) IsCut() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.cut
+}
+
+// Connections returns the number of connections carried
+func (p *linkProxy) Connections() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.conns) / 2
+}
+
+// Close stops the proxy and closes its connections
+func (p *linkProxy) Close() {
+	p.listener.Close()
+	p.SetCut(true)
+}
@@ -0,0 +1,469 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/engine"
+)
+
+// localBootstrapIdentity is the identity of the first leader of a LOCAL network
+const localBootstrapIdentity = "38bab1455b7bd7e5efd15c53c777c79d0c988e9210f1da49a99d95b3a6417be9"
+
+// Config describes a testnet of factomd processes on localhost
+type Config struct {
+	Factomd   string   // path of the factomd binary
+	Dir       string   // directory the nodes keep their configuration, database and log in
+	Roles     string   // role of every node as in the simulator, L for a leader, A for an audit server, F for a follower
+	BlkTime   int      // seconds per block
+	BasePort  int      // node i uses the ports BasePort+10*i to BasePort+10*i+3
+	ProxyPort int      // the link from node i to node j listens on ProxyPort+i*len(Roles)+j
+	Args      []string // more factomd flags for every node
+}
+
+// Validate checks a testnet can be made of the configuration
+func (c *Config) Validate() error {
+	if len(c.Roles) < 1 || c.Roles[0] != 'L' {
+		return fmt.Errorf("the first node is the bootstrap leader, so the roles start with L")
+	}
+	for _, r := range c.Roles {
+		if r != 'L' && r != 'A' && r != 'F' {
+			return fmt.Errorf("unknown role %c, use L, A or F", r)
+		}
+	}
+	if c.BlkTime < 1 {
+		return fmt.Errorf("the block time must be at least a second")
+	}
+	if c.BasePort < 1024 || c.ProxyPort < 1024 {
+		return fmt.Errorf("use ports above 1023")
+	}
+	n := len(c.Roles)
+	if c.BasePort < c.ProxyPort+n*n && c.ProxyPort < c.BasePort+10*n {
+		return fmt.Errorf("the node ports and the proxy ports overlap")
+	}
+	return nil
+}
+
+// Node is a factomd process of the testnet
+type Node struct {
+	Index            int
+	Role             byte
+	Dir              string // the FACTOM_HOME of the node
+	Identity         engine.TestnetIdentity
+	APIPort          int
+	ControlPanelPort int
+	NetworkPort      int
+	LogPort          int
+
+	cmd  *exec.Cmd
+	done chan struct{} // closed when the process exits
+}
+
+// Testnet runs the nodes and the proxies of the links between them
+type Testnet struct {
+	Config  Config
+	Nodes   []*Node
+	proxies []*linkProxy
+	mutex   sync.Mutex
+}
+
+// NewTestnet writes the configuration of every node and starts the link proxies.  The nodes are
+// started with Start.
+func NewTestnet(c Config) (*Testnet, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	ids, err := engine.TestnetIdentities(len(c.Roles) - 1)
+	if err != nil {
+		return nil, err
+	}
+	bootstrap, err := primitives.NewPrivateKeyFromHex(engine.LOCAL_NET_PRIV_KEY)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Testnet{Config: c}
+	for i := range c.Roles {
+		n := &Node{
+			Index:            i,
+			Role:             c.Roles[i],
+			Dir:              filepath.Join(c.Dir, fmt.Sprintf("node%d", i)),
+			APIPort:          c.BasePort + 10*i,
+			ControlPanelPort: c.BasePort + 10*i + 1,
+			NetworkPort:      c.BasePort + 10*i + 2,
+			LogPort:          c.BasePort + 10*i + 3,
+		}
+		if i == 0 {
+			n.Identity = engine.TestnetIdentity{ChainID: localBootstrapIdentity, PrivateKey: bootstrap.PrivateKeyString(), PublicKey: bootstrap.PublicKeyString()}
+		} else {
+			n.Identity = ids[i-1]
+		}
+		if err := n.writeConfig(c.BlkTime); err != nil {
+			return nil, err
+		}
+		t.Nodes = append(t.Nodes, n)
+	}
+
+	for i := range t.Nodes {
+		for j := range t.Nodes {
+			if i == j {
+				continue
+			}
+			address := fmt.Sprintf("localhost:%d", c.ProxyPort+i*len(t.Nodes)+j)
+			p, err := newLinkProxy(i, j, address, fmt.Sprintf("localhost:%d", t.Nodes[j].NetworkPort))
+			if err != nil {
+				t.Close()
+				return nil, err
+			}
+			t.proxies = append(t.proxies, p)
+		}
+	}
+	return t, nil
+}
+
+// writeConfig writes the factomd.conf of the node, in its FACTOM_HOME
+func (n *Node) writeConfig(blkTime int) error {
+	dir := filepath.Join(n.Dir, ".factom", "m2")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("; Written by the Testnet launcher\n[app]\n")
+	fmt.Fprintf(&buf, "PortNumber                            = %d\n", n.APIPort)
+	fmt.Fprintf(&buf, "ControlPanelPort                      = %d\n", n.ControlPanelPort)
+	fmt.Fprintf(&buf, "ControlPanelSetting                   = readonly\n")
+	fmt.Fprintf(&buf, "DBType                                = \"LDB\"\n")
+	fmt.Fprintf(&buf, "DirectoryBlockInSeconds               = %d\n", blkTime)
+	fmt.Fprintf(&buf, "Network                               = LOCAL\n")
+	fmt.Fprintf(&buf, "LocalNetworkPort                      = %d\n", n.NetworkPort)
+	fmt.Fprintf(&buf, "LocalSeedURL                          = \"\"\n")
+	fmt.Fprintf(&buf, "LocalSpecialPeers                     = \"\"\n")
+	fmt.Fprintf(&buf, "NodeMode                              = SERVER\n")
+	fmt.Fprintf(&buf, "IdentityChainID                       = %s\n", n.Identity.ChainID)
+	fmt.Fprintf(&buf, "LocalServerPrivKey                    = %s\n", n.Identity.PrivateKey)
+	fmt.Fprintf(&buf, "LocalServerPublicKey                  = %s\n", n.Identity.PublicKey)
+	return ioutil.WriteFile(filepath.Join(dir, "factomd.conf"), buf.Bytes(), 0644)
+}
+
+// peers returns the addresses node i dials, those of its proxies
+func (t *Testnet) peers(i int) string {
+	var peers []string
+	for _, p := range t.proxies {
+		if p.From == i {
+			peers = append(peers, p.Address())
+		}
+	}
+	return strings.Join(peers, " ")
+}
+
+// node returns node i, or an error if there is none
+func (t *Testnet) node(i int) (*Node, error) {
+	if i < 0 || i >= len(t.Nodes) {
+		return nil, fmt.Errorf("%d is not a node", i)
+	}
+	return t.Nodes[i], nil
+}
+
+// process returns the process of node i and the channel closed when it exits, or an error if
+// it is not running
+func (t *Testnet) process(i int) (*exec.Cmd, chan struct{}, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	n, err := t.node(i)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n.cmd == nil {
+		return nil, nil, fmt.Errorf("node %d is not running", i)
+	}
+	select {
+	case <-n.done:
+		return nil, nil, fmt.Errorf("node %d is not running", i)
+	default:
+		return n.cmd, n.done, nil
+	}
+}
+
+// Running returns true if the process of node i is running
+func (t *Testnet) Running(i int) bool {
+	_, _, err := t.process(i)
+	return err == nil
+}
+
+// Start starts the process of node i, logging to factomd.log in its directory
+func (t *Testnet) Start(i int) error {
+	n, err := t.node(i)
+	if err != nil {
+		return err
+	}
+	if t.Running(i) {
+		return fmt.Errorf("node %d is running", i)
+	}
+	log, err := os.OpenFile(filepath.Join(n.Dir, "factomd.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"-network=LOCAL",
+		"-factomhome=" + n.Dir,
+		"-nodename=" + fmt.Sprintf("node%d", i),
+		fmt.Sprintf("-port=%d", n.APIPort),
+		fmt.Sprintf("-controlpanelport=%d", n.ControlPanelPort),
+		fmt.Sprintf("-networkport=%d", n.NetworkPort),
+		fmt.Sprintf("-logPort=%d", n.LogPort),
+		fmt.Sprintf("-blktime=%d", t.Config.BlkTime),
+		"-peers=" + t.peers(i),
+		"-exclusive=true", // only dial the proxies, so the links can be cut
+		"-sim_stdin=false",
+	}
+	args = append(args, t.Config.Args...)
+	cmd := exec.Command(t.Config.Factomd, args...)
+	cmd.Stdout = log
+	cmd.Stderr = log
+	if err := cmd.Start(); err != nil {
+		log.Close()
+		return err
+	}
+
+	t.mutex.Lock()
+	n.cmd = cmd
+	n.done = make(chan struct{})
+	done := n.done
+	t.mutex.Unlock()
+	go func() {
+		cmd.Wait()
+		log.Close()
+		close(done)
+	}()
+	return nil
+}
+
+// Stop interrupts the process of node i so it shuts down cleanly, and kills it if it has not
+// exited after the timeout
+func (t *Testnet) Stop(i int, timeout time.Duration) error {
+	cmd, done, err := t.process(i)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return t.Kill(i)
+	}
+}
+
+// Kill kills the process of node i, as a crash would
+func (t *Testnet) Kill(i int) error {
+	cmd, done, err := t.process(i)
+	if err != nil {
+		return err
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// Partition cuts the links between the nodes of different groups.  A node in no group keeps its
+// links, and the links cut by an earlier partition stay cut.
+func (t *Testnet) Partition(groups [][]int) error {
+	group := map[int]int{}
+	for g, nodes := range groups {
+		for _, i := range nodes {
+			if _, err := t.node(i); err != nil {
+				return err
+			}
+			group[i] = g
+		}
+	}
+	for _, p := range t.proxies {
+		g1, ok1 := group[p.From]
+		g2, ok2 := group[p.To]
+		if ok1 && ok2 && g1 != g2 {
+			p.SetCut(true)
+		}
+	}
+	return nil
+}
+
+// Heal restores every link
+func (t *Testnet) Heal() {
+	for _, p := range t.proxies {
+		p.SetCut(false)
+	}
+}
+
+// Call calls a method of the v2 API of node i, or of its debug API, decoding the result into
+// result if it is not nil
+func (t *Testnet) Call(i int, api string, method string, params interface{}, result interface{}) error {
+	n, err := t.node(i)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(primitives.NewJSON2Request(method, 0, params))
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(fmt.Sprintf("http://localhost:%d/%s", n.APIPort, api), "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	r := primitives.NewJSON2Response()
+	if err := json.Unmarshal(body, r); err != nil {
+		return err
+	}
+	if r.Error != nil {
+		return fmt.Errorf("%s: %v", method, r.Error.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	data, err = json.Marshal(r.Result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// SimControl runs simControl commands on node i, through the sim-ctrl debug method
+func (t *Testnet) SimControl(i int, commands ...string) error {
+	return t.Call(i, "debug", "sim-ctrl", map[string]interface{}{"commands": commands}, nil)
+}
+
+// Height returns the directory block height of node i
+func (t *Testnet) Height(i int) (int64, error) {
+	heights := new(struct {
+		DirectoryBlockHeight int64 `json:"directoryblockheight"`
+	})
+	if err := t.Call(i, "v2", "heights", nil, heights); err != nil {
+		return 0, err
+	}
+	return heights.DirectoryBlockHeight, nil
+}
+
+// WaitForHeight waits for node i to reach a height
+func (t *Testnet) WaitForHeight(i int, height int64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		h, err := t.Height(i)
+		if err == nil && h >= height {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %d did not reach height %d, it is at %d (%v)", i, height, h, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Promote puts the identities of the leaders and the audit servers on the blockchain through
+// node 0, as the g command of the simulator does, and has every node ask to be promoted to its
+// role.  The nodes must be running.
+func (t *Testnet) Promote() error {
+	last := 0
+	for i, n := range t.Nodes {
+		if i > 0 && n.Role != 'F' {
+			last = i
+		}
+	}
+	if last == 0 {
+		return nil
+	}
+	start, err := t.Height(0)
+	if err != nil {
+		return err
+	}
+	if err := t.SimControl(0, fmt.Sprintf("g%d", last)); err != nil {
+		return err
+	}
+	// The identities are scanned at the block after the one they are in
+	blocks := time.Duration(t.Config.BlkTime) * time.Second
+	if err := t.WaitForHeight(0, start+3, 10*blocks); err != nil {
+		return err
+	}
+	for i, n := range t.Nodes {
+		command := ""
+		switch {
+		case i == 0:
+		case n.Role == 'L':
+			command = "l"
+		case n.Role == 'A':
+			command = "o"
+		}
+		if command == "" {
+			continue
+		}
+		if err := t.SimControl(i, command); err != nil {
+			return fmt.Errorf("node %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Status describes the nodes and the links cut
+func (t *Testnet) Status() string {
+	var buf bytes.Buffer
+	for i, n := range t.Nodes {
+		state := "stopped"
+		if cmd, _, err := t.process(i); err == nil {
+			state = fmt.Sprintf("pid %d", cmd.Process.Pid)
+			if h, err := t.Height(i); err == nil {
+				state += fmt.Sprintf(", height %d", h)
+			}
+		}
+		fmt.Fprintf(&buf, "node%-3d %c  api %d  p2p %d  identity %s  %s\n", i, n.Role, n.APIPort, n.NetworkPort, n.Identity.ChainID[:10], state)
+	}
+	var cut []string
+	for _, p := range t.proxies {
+		if p.IsCut() {
+			cut = append(cut, fmt.Sprintf("%d->%d", p.From, p.To))
+		}
+	}
+	if len(cut) > 0 {
+		fmt.Fprintf(&buf, "Links cut: %s\n", strings.Join(cut, " "))
+	}
+	return buf.String()
+}
+
+// Close stops every node and the proxies
+func (t *Testnet) Close() {
+	var wg sync.WaitGroup
+	for i := range t.Nodes {
+		if t.Running(i) {
+			wg.Add(1)
+			go func(i int) {
+				t.Stop(i, 30*time.Second)
+				wg.Done()
+			}(i)
+		}
+	}
+	wg.Wait()
+	for _, p := range t.proxies {
+		p.Close()
+	}
+}
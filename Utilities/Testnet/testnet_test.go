@@ -0,0 +1,109 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	good := Config{Roles: "LLAF", BlkTime: 10, BasePort: 9000, ProxyPort: 9500}
+	if err := good.Validate(); err != nil {
+		t.Errorf("%v", err)
+	}
+	for _, c := range []Config{
+		{Roles: "ALL", BlkTime: 10, BasePort: 9000, ProxyPort: 9500},
+		{Roles: "LXF", BlkTime: 10, BasePort: 9000, ProxyPort: 9500},
+		{Roles: "LLL", BlkTime: 0, BasePort: 9000, ProxyPort: 9500},
+		{Roles: "LLL", BlkTime: 10, BasePort: 9000, ProxyPort: 9010},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
+
+func TestLinkProxy(t *testing.T) {
+	// An echo server stands for the p2p port of the node
+	target, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					conn.Write([]byte(scanner.Text() + "\n"))
+				}
+				conn.Close()
+			}()
+		}
+	}()
+
+	p, err := newLinkProxy(0, 1, "localhost:0", target.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	echo := func() bool {
+		conn, err := net.Dial("tcp", p.Address())
+		if err != nil {
+			return false
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(time.Second))
+		conn.Write([]byte("ping\n"))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		return err == nil && line == "ping\n"
+	}
+	if !echo() {
+		t.Fatalf("The link does not carry the connection")
+	}
+	p.SetCut(true)
+	if echo() {
+		t.Errorf("A cut link carried a connection")
+	}
+	p.SetCut(false)
+	if !echo() {
+		t.Errorf("A healed link does not carry the connection")
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := &Node{Dir: dir, APIPort: 9000, ControlPanelPort: 9001, NetworkPort: 9002}
+	n.Identity.ChainID = localBootstrapIdentity
+	if err := n.writeConfig(10); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".factom", "m2", "factomd.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"PortNumber                            = 9000", "Network                               = LOCAL", "IdentityChainID                       = " + localBootstrapIdentity} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("The configuration lacks %s", s)
+		}
+	}
+}
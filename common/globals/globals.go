@@ -73,4 +73,17 @@ type FactomParams struct {
 	WriteProcessedDBStates   bool // Write processed DBStates to debug file
 	NodeName                 string
 	FactomHome               string
+	TraceMessages            bool   // Trace the propagation of messages through the p2p network
+	Snapshot                 string // Directory of a snapshot to import into an empty database on boot
+	SnapshotKeyMR            string // KeyMR the last directory block of the snapshot must have
+	SchemaDryRun             bool   // List the pending database schema migrations and exit
+	SchemaBackup             string // Directory to back the database up to before migrating its schema
+	IntegrityCheck           bool   // Run the background database integrity verifier
+	IntegrityDelay           int    // Milliseconds the integrity verifier waits between heights
+	IntegrityRefetch         bool   // Request corrupted blocks and entries from peers
+	GrantScheduleFile        string // JSON file of a signed grant schedule
+	GrantChain               string // Chain ID of the governance chain holding signed grant schedules
+	GrantAuthority           string // Hex public key that signs the grant schedules
+	Deterministic            bool   // Run the simulated nodes on a virtual clock with seeded randomness
+	Seed                     int64  // Seed of a deterministic simulation
 }
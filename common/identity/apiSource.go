@@ -0,0 +1,140 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FactomProject/factomd/common/adminBlock"
+	"github.com/FactomProject/factomd/common/directoryBlock"
+	"github.com/FactomProject/factomd/common/entryBlock"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// APISource is a BlockSource reading the blocks from the V2 API of a factomd node
+type APISource struct {
+	URL    string // of the V2 API, as http://localhost:8088/v2
+	Client *http.Client
+}
+
+var _ BlockSource = (*APISource)(nil)
+
+// NewAPISource returns an APISource for the factomd node at url, with or without the /v2 path
+func NewAPISource(url string) *APISource {
+	a := new(APISource)
+	a.URL = strings.TrimSuffix(url, "/")
+	if !strings.HasSuffix(a.URL, "/v2") {
+		a.URL += "/v2"
+	}
+	a.Client = &http.Client{Timeout: 30 * time.Second}
+	return a
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// call calls an API method and decodes its result into result.  A block not found is not an error,
+// and returns false.
+func (a *APISource) call(method string, params interface{}, result interface{}) (bool, error) {
+	request, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 0, "method": method, "params": params})
+	if err != nil {
+		return false, err
+	}
+	resp, err := a.Client.Post(a.URL, "application/json", bytes.NewReader(request))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	response := struct {
+		Result json.RawMessage `json:"result"`
+		Error  *apiError       `json:"error"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", method, err)
+	}
+	if response.Error != nil {
+		if response.Error.Code == -32008 { // Block, Entry or Object not found
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %s", method, response.Error.Message)
+	}
+	return true, json.Unmarshal(response.Result, result)
+}
+
+// rawByHeight returns the raw data of a block by height, from the dblock-by-height and ablock-by-height methods
+func (a *APISource) rawByHeight(method string, height uint32) ([]byte, error) {
+	result := struct {
+		RawData string `json:"rawdata"`
+	}{}
+	found, err := a.call(method, map[string]interface{}{"height": height}, &result)
+	if err != nil || !found {
+		return nil, err
+	}
+	return hex.DecodeString(result.RawData)
+}
+
+func (a *APISource) rawByHash(hash interfaces.IHash) ([]byte, error) {
+	result := struct {
+		Data string `json:"data"`
+	}{}
+	found, err := a.call("raw-data", map[string]interface{}{"hash": hash.String()}, &result)
+	if err != nil || !found {
+		return nil, err
+	}
+	return hex.DecodeString(result.Data)
+}
+
+func (a *APISource) FetchDBlockHead() (interfaces.IDirectoryBlock, error) {
+	heights := struct {
+		DirectoryBlockHeight int64 `json:"directoryblockheight"`
+	}{}
+	_, err := a.call("heights", nil, &heights)
+	if err != nil {
+		return nil, err
+	}
+	return a.FetchDBlockByHeight(uint32(heights.DirectoryBlockHeight))
+}
+
+func (a *APISource) FetchDBlockByHeight(height uint32) (interfaces.IDirectoryBlock, error) {
+	raw, err := a.rawByHeight("dblock-by-height", height)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return directoryBlock.UnmarshalDBlock(raw)
+}
+
+func (a *APISource) FetchABlockByHeight(height uint32) (interfaces.IAdminBlock, error) {
+	raw, err := a.rawByHeight("ablock-by-height", height)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return adminBlock.UnmarshalABlock(raw)
+}
+
+func (a *APISource) FetchEBlock(keyMR interfaces.IHash) (interfaces.IEntryBlock, error) {
+	raw, err := a.rawByHash(keyMR)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return entryBlock.UnmarshalEBlock(raw)
+}
+
+func (a *APISource) FetchEntry(hash interfaces.IHash) (interfaces.IEBEntry, error) {
+	raw, err := a.rawByHash(hash)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	return entryBlock.UnmarshalEntry(raw)
+}
@@ -0,0 +1,224 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package identity
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/adminBlock"
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// BlockSource is where the Parser reads the blocks from.  A database overlay is a BlockSource,
+// and NewAPISource reads the blocks from the API of a factomd node.
+type BlockSource interface {
+	FetchDBlockHead() (interfaces.IDirectoryBlock, error)
+	FetchDBlockByHeight(uint32) (interfaces.IDirectoryBlock, error)
+	FetchABlockByHeight(uint32) (interfaces.IAdminBlock, error)
+	FetchEBlock(interfaces.IHash) (interfaces.IEntryBlock, error)
+	FetchEntry(interfaces.IHash) (interfaces.IEBEntry, error)
+}
+
+// ParserNetwork holds the identities a network starts with, as given by the State for its network
+type ParserNetwork struct {
+	BootstrapIdentity interfaces.IHash // signs the first block
+	BootstrapKey      interfaces.IHash
+	SkeletonIdentity  interfaces.IHash
+	RegistrationChain interfaces.IHash // where the identities register
+}
+
+// Parser rebuilds the identity manager outside factomd, by replaying the admin block entries
+// through ProcessABlockEntry and the entries of the identity chains through ProcessIdentityEntry,
+// block by block.
+//
+// Unlike factomd, which only syncs the chains of the identities it tracks, the parser replays
+// every entry of every identity chain.  That gives the same authority set, which only the admin
+// blocks change, but can track more identities.
+type Parser struct {
+	IM     *IdentityManager
+	Source BlockSource
+	Next   uint32 // next height to replay
+
+	pending       []interfaces.IEntryBlock // identity eblocks of the last block, synced with the next block
+	pendingTime   interfaces.Timestamp
+	pendingHeight uint32
+}
+
+// NewParser returns a Parser at height 0, with the starting identities of the network
+func NewParser(source BlockSource, network ParserNetwork) *Parser {
+	p := new(Parser)
+	p.Source = source
+	p.IM = NewIdentityManager()
+	p.IM.SetBootstrapIdentity(network.BootstrapIdentity, network.BootstrapKey)
+	p.IM.SetSkeletonIdentity(network.SkeletonIdentity)
+	p.IM.SetIdentityRegistration(network.RegistrationChain)
+	return p
+}
+
+// Top returns the height of the highest block of the source
+func (p *Parser) Top() (uint32, error) {
+	head, err := p.Source.FetchDBlockHead()
+	if err != nil {
+		return 0, err
+	}
+	if head == nil {
+		return 0, fmt.Errorf("there are no blocks")
+	}
+	return head.GetDatabaseHeight(), nil
+}
+
+// ParseTo replays the blocks up to and including height.  Parsing can continue to a greater height
+// later, but not go back.
+func (p *Parser) ParseTo(height uint32) error {
+	if p.Next > height+1 {
+		return fmt.Errorf("the parser is at height %d, past %d", p.Next-1, height)
+	}
+	for ; p.Next <= height; p.Next++ {
+		err := p.parseBlock(p.Next)
+		if err != nil {
+			return fmt.Errorf("height %d: %v", p.Next, err)
+		}
+	}
+	return nil
+}
+
+// parseBlock replays a block in the order factomd saves it: the admin block entries, then the
+// identity entries of the previous block, then the garbage collection of the cancels
+func (p *Parser) parseBlock(height uint32) error {
+	dblock, err := p.Source.FetchDBlockByHeight(height)
+	if err != nil {
+		return err
+	}
+	if dblock == nil {
+		return fmt.Errorf("directory block missing")
+	}
+	ablock, err := p.Source.FetchABlockByHeight(height)
+	if err != nil {
+		return err
+	}
+	if ablock == nil {
+		return fmt.Errorf("admin block missing")
+	}
+
+	for _, entry := range ablock.GetABEntries() {
+		// factomd adds a missing identity through the State, the parser does it here, so it
+		// never needs one
+		chainID := adminEntryChainID(entry)
+		if chainID != nil && p.IM.GetIdentity(chainID) == nil {
+			id := NewIdentity()
+			id.IdentityChainID = chainID
+			p.IM.SetIdentity(chainID, id)
+		}
+		err := p.IM.ProcessABlockEntry(entry, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, eblock := range p.pending {
+		err := p.parseEBlock(eblock)
+		if err != nil {
+			return err
+		}
+	}
+	p.pending = p.pending[:0]
+	p.IM.CancelManager.GC(height)
+
+	for _, dbentry := range dblock.GetDBEntries() {
+		if bytes.Compare(dbentry.GetChainID().Bytes()[:3], []byte{0x88, 0x88, 0x88}) != 0 {
+			continue
+		}
+		eblock, err := p.Source.FetchEBlock(dbentry.GetKeyMR())
+		if err != nil {
+			return err
+		}
+		if eblock == nil {
+			return fmt.Errorf("entry block %s missing", dbentry.GetKeyMR().String())
+		}
+		p.pending = append(p.pending, eblock)
+	}
+	p.pendingHeight = height
+	p.pendingTime = dblock.GetHeader().GetTimestamp()
+	return nil
+}
+
+func (p *Parser) parseEBlock(eblock interfaces.IEntryBlock) error {
+	for _, hash := range eblock.GetEntryHashes() {
+		if hash.IsMinuteMarker() {
+			continue
+		}
+		entry, err := p.Source.FetchEntry(hash)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf("entry %s missing", hash.String())
+		}
+		// Entries that are not valid identity entries are ignored, as in factomd
+		p.IM.ProcessIdentityEntry(entry, p.pendingHeight, p.pendingTime, true)
+	}
+	p.IM.ProcessOldEntries()
+	return nil
+}
+
+func adminEntryChainID(entry interfaces.IABEntry) interfaces.IHash {
+	switch e := entry.(type) {
+	case *adminBlock.AddFederatedServer:
+		return e.IdentityChainID
+	case *adminBlock.AddAuditServer:
+		return e.IdentityChainID
+	}
+	return nil
+}
+
+// AuthoritySet is the authority set at a height, in a form to print or export
+type AuthoritySet struct {
+	Height      uint32
+	Federated   int
+	Audit       int
+	Authorities []AuthoritySetEntry
+}
+
+// AuthoritySetEntry is an authority of an AuthoritySet
+type AuthoritySetEntry struct {
+	ChainID           string
+	Status            string
+	ManagementChainID string
+	MatryoshkaHash    string
+	SigningKey        string
+	AnchorKeys        []AnchorSigningKey
+	Efficiency        uint16
+	CoinbaseAddress   string
+}
+
+// AuthoritySet returns the authority set at the last height parsed
+func (p *Parser) AuthoritySet() *AuthoritySet {
+	set := new(AuthoritySet)
+	if p.Next > 0 {
+		set.Height = p.Next - 1
+	}
+	for _, a := range p.IM.GetSortedAuthorities() {
+		auth := a.(*Authority)
+		switch auth.Status {
+		case constants.IDENTITY_FEDERATED_SERVER:
+			set.Federated++
+		case constants.IDENTITY_AUDIT_SERVER:
+			set.Audit++
+		}
+		set.Authorities = append(set.Authorities, AuthoritySetEntry{
+			ChainID:           auth.AuthorityChainID.String(),
+			Status:            constants.IdentityStatusString(auth.Status),
+			ManagementChainID: auth.ManagementChainID.String(),
+			MatryoshkaHash:    auth.MatryoshkaHash.String(),
+			SigningKey:        auth.SigningKey.String(),
+			AnchorKeys:        auth.AnchorKeys,
+			Efficiency:        auth.Efficiency,
+			CoinbaseAddress:   auth.GetCoinbaseHumanReadable(),
+		})
+	}
+	return set
+}
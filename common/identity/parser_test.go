@@ -0,0 +1,64 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package identity_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/constants"
+	. "github.com/FactomProject/factomd/common/identity"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestParser(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+
+	// An empty network, so the authority set comes from the admin blocks only
+	network := ParserNetwork{
+		BootstrapIdentity: primitives.NewZeroHash(),
+		BootstrapKey:      primitives.NewZeroHash(),
+		SkeletonIdentity:  primitives.NewZeroHash(),
+		RegistrationChain: primitives.NewZeroHash(),
+	}
+	p := NewParser(dbo, network)
+	p.IM.RemoveAuthority(primitives.NewZeroHash())
+
+	top, err := p.Top()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = p.ParseTo(top)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// The first test admin block adds a federated server and its signing key
+	set := p.AuthoritySet()
+	if set.Height != top {
+		t.Errorf("Authority set at height %d, expected %d", set.Height, top)
+	}
+	if set.Federated != 1 || set.Audit != 0 || len(set.Authorities) != 1 {
+		t.Fatalf("Wrong authority set %v", set)
+	}
+	a := set.Authorities[0]
+	if a.ChainID != "38bab1455b7bd7e5efd15c53c777c79d0c988e9210f1da49a99d95b3a6417be9" {
+		t.Errorf("Wrong authority %s", a.ChainID)
+	}
+	if a.SigningKey != "cc1985cdfae4e32b5a454dfda8ce5e1361558482684f3367649c3ad852c8e31a" {
+		t.Errorf("Wrong signing key %s", a.SigningKey)
+	}
+	if a.Status != constants.IdentityStatusString(constants.IDENTITY_FEDERATED_SERVER) {
+		t.Errorf("Wrong status %s", a.Status)
+	}
+
+	// The parser does not go back
+	if err := p.ParseTo(top - 1); err == nil {
+		t.Errorf("Parsed back to a lower height")
+	}
+	if err := p.ParseTo(top); err != nil {
+		t.Errorf("%v", err)
+	}
+}
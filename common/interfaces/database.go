@@ -4,6 +4,8 @@
 
 package interfaces
 
+import "bytes"
+
 type IDatabase interface {
 	Close() error
 	Put(bucket, key []byte, data BinaryMarshallable) error
@@ -13,11 +15,81 @@ type IDatabase interface {
 	GetAll(bucket []byte, sample BinaryMarshallableAndCopyable) ([]BinaryMarshallableAndCopyable, [][]byte, error)
 	Clear(bucket []byte) error
 	PutInBatch(records []Record) error
+	// PutAndDeleteInBatch atomically deletes the keys of deletes and then writes records, across
+	// any number of buckets.  Either all the changes are made or, on error or crash, none are.
+	PutAndDeleteInBatch(records []Record, deletes []Record) error
 	ListAllBuckets() ([][]byte, error)
 	Trim()
 	DoesKeyExist(bucket, key []byte) (bool, error)
+	// Iterate streams the records of a bucket in key order, without loading the bucket into memory
+	Iterate(bucket []byte, options IteratorOptions) (IIterator, error)
+}
+
+// IIterator walks over the records of a bucket.  The slices returned by Key and Value are only
+// valid until the next call to Next, copy them to keep them.  Release must always be called once
+// the caller is done with the iterator.
+type IIterator interface {
+	Next() bool    // Move to the next record, false when there are no more records or on error
+	Key() []byte   // Key of the current record, without the bucket
+	Value() []byte // Marshalled data of the current record
+	Error() error  // The error that stopped the iteration, if any
+	Release()
+}
+
+// IteratorOptions selects the records of a bucket to iterate over.  The zero value iterates over
+// the whole bucket in ascending key order.
+type IteratorOptions struct {
+	Prefix  []byte // Only keys starting with Prefix
+	Start   []byte // First key of the range (inclusive), nil for the start of the bucket
+	Limit   []byte // End of the range (exclusive), nil for the end of the bucket
+	Reverse bool   // Iterate from the last key down to the first
+}
+
+// Range combines the prefix and the start/limit bounds into a single key range.  A nil limit
+// means there is no upper bound.
+func (o IteratorOptions) Range() (start []byte, limit []byte) {
+	start, limit = o.Start, o.Limit
+	if len(o.Prefix) > 0 {
+		if bytes.Compare(o.Prefix, start) > 0 {
+			start = o.Prefix
+		}
+		prefixLimit := PrefixLimit(o.Prefix)
+		if prefixLimit != nil && (limit == nil || bytes.Compare(prefixLimit, limit) < 0) {
+			limit = prefixLimit
+		}
+	}
+	return start, limit
+}
+
+// Contains returns true if the key is within the prefix and range of the options
+func (o IteratorOptions) Contains(key []byte) bool {
+	if !bytes.HasPrefix(key, o.Prefix) {
+		return false
+	}
+	if o.Start != nil && bytes.Compare(key, o.Start) < 0 {
+		return false
+	}
+	if o.Limit != nil && bytes.Compare(key, o.Limit) >= 0 {
+		return false
+	}
+	return true
+}
+
+// PrefixLimit returns the smallest key greater than all the keys starting with prefix, or nil if
+// there is none (the prefix is all 0xff)
+func PrefixLimit(prefix []byte) []byte {
+	limit := make([]byte, len(prefix))
+	copy(limit, prefix)
+	for i := len(limit) - 1; i >= 0; i-- {
+		if limit[i] < 0xff {
+			limit[i]++
+			return limit[:i+1]
+		}
+	}
+	return nil
 }
 
+// Record is a key and its data in a bucket.  Data is ignored for records that are deleted.
 type Record struct {
 	Bucket []byte
 	Key    []byte
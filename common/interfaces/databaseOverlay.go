@@ -10,6 +10,7 @@ package interfaces
 
 //A simplified DBOverlay to make sure we are not calling functions that could cause problems
 type DBOverlaySimple interface {
+	AbortMultiBatch()
 	Close() error
 	DoesKeyExist(bucket, key []byte) (bool, error)
 	ExecuteMultiBatch() error
@@ -61,9 +62,15 @@ type DBOverlay interface {
 
 	StartMultiBatch()
 	PutInMultiBatch(records []Record)
+	DeleteInMultiBatch(bucket, key []byte)
 	ExecuteMultiBatch() error
+	AbortMultiBatch()
 	GetEntryType(hash IHash) (IHash, error)
 
+	// ForEach and ForEachKey stream the records of a bucket rather than loading it all in memory
+	ForEach(bucket []byte, options IteratorOptions, sample BinaryMarshallableAndCopyable, f func(key []byte, data BinaryMarshallableAndCopyable) error) error
+	ForEachKey(bucket []byte, options IteratorOptions, f func(key []byte) error) error
+
 	//**********************************Entry**********************************//
 
 	// InsertEntry inserts an entry
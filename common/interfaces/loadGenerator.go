@@ -0,0 +1,18 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package interfaces
+
+// ILoadGenerator is the load generator of the simulation, which lives in the engine, as the
+// debug API reaches it
+type ILoadGenerator interface {
+	// Sets the workload from a profile in JSON
+	SetLoadProfile(data []byte) error
+	// Returns the *engine.LoadProfile of the workload
+	GetLoadProfile() interface{}
+	// Sets the transactions per second, 0 to stop the load
+	SetLoadRate(perSecond float64)
+	// Returns the *engine.LoadStats of the throughput and ack latencies achieved
+	GetLoadStats() interface{}
+}
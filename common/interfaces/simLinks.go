@@ -0,0 +1,12 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package interfaces
+
+// ISimLinks are the link models and partitions of the simulation, which live in the engine, as
+// the debug API reaches them
+type ISimLinks interface {
+	// Runs an X command of simControl, returning the status it prints
+	SimLinkCommand(cmd []string) (string, error)
+}
@@ -150,6 +150,36 @@ type IState interface {
 	GetNetworkNumber() int  // Encoded into Directory Blocks
 	GetNetworkName() string // Some networks have defined names
 	GetNetworkID() uint32
+	GetNetworkController() interface{} // *p2p.Controller, or nil if the node is not networked
+
+	// Starts writing a snapshot of the database in the directory name of the snapshot path,
+	// returns the *state.SnapshotExport
+	CreateSnapshot(name string, height uint32) (interface{}, error)
+	// Returns the *state.SnapshotExport of the last snapshot export, or nil if none was started
+	GetSnapshotExport() interface{}
+
+	// Returns the *state.IntegrityReport of the database integrity verifier, or nil if it is not running
+	GetIntegrityReport() interface{}
+
+	// Starts verifying, and unless dryRun repairing, the chain heads of chainIDs, or of every chain
+	// if chainIDs is empty.  Returns the *state.ChainHeadRepair.
+	RepairChainHeads(chainIDs []string, dryRun bool) (interface{}, error)
+	// Returns the *state.ChainHeadRepair of the last repair with its changes from index from on
+	GetChainHeadRepair(from int) interface{}
+
+	// Returns the *state.IdentityHistory of an identity chain, with the admin block entries
+	// between heights start and end (0 for the highest saved block) that changed it, searching
+	// at most the last 1000 blocks up to end if start is 0, else the first 1000 from start
+	GetIdentityHistory(chainID string, start, end uint32) (interface{}, error)
+	// Returns the *state.FaultHistoryReport of the server faults and elections recorded between
+	// heights start and end (0 for no limit), of a server and of a kind if not empty
+	QueryFaultHistory(start, end uint32, leader string, kind string, limit int) (interface{}, error)
+	// The load generator of the simulation, or nil if there is none
+	GetLoadGenerator() ILoadGenerator
+	SetLoadGenerator(lg ILoadGenerator)
+	// The link models and partitions of the simulation, or nil if there are none
+	GetSimLinks() ISimLinks
+	SetSimLinks(links ISimLinks)
 
 	// Bootstrap Identity Information is dependent on Network
 	GetNetworkBootStrapKey() IHash
@@ -0,0 +1,525 @@
+package electionMsgTesting
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/messages/electionMsgs"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/elections"
+	"github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+// The kinds of Step a Checker takes
+const (
+	StepDeliver   = "deliver"   // deliver a message and remove it from the messages in flight
+	StepDrop      = "drop"      // lose a message
+	StepDuplicate = "duplicate" // deliver a message and keep it in flight, to be delivered again
+	StepTimeout   = "timeout"   // fire the fault timer of the node with the ID, while it is electing
+)
+
+// maxRouted is the most messages a node may send to itself in a step
+const maxRouted = 1000
+
+// neverFires is the timeout of the fault timers the elections start, so that only the timeout
+// steps of a run fire them
+const neverFires = time.Duration(math.MaxInt64)
+
+// CheckConfig is the network a Checker runs the elections on, and the faults it may inject
+type CheckConfig struct {
+	Feds       int // federated servers, each one a node of the network
+	Auds       int // audit servers
+	Volunteers int // audit servers that volunteer, at most Auds
+	Electing   int // index of the federated server being replaced
+	Faulty     int // the last Faulty nodes may lose messages, less than half the nodes
+	Drops      int // maximum messages lost in a run
+	Dups       int // maximum messages duplicated in a run
+	MaxSteps   int // steps without a majority committed before a run fails liveness
+	Timeouts   int // maximum fault timeouts in a run
+}
+
+// DefaultCheckConfig is 3 federated servers replacing the first one, with 2 volunteers, one
+// node losing up to 2 messages, and up to 2 fault timeouts
+var DefaultCheckConfig = CheckConfig{
+	Feds:       3,
+	Auds:       2,
+	Volunteers: 2,
+	Electing:   0,
+	Faulty:     1,
+	Drops:      2,
+	Dups:       2,
+	MaxSteps:   1000,
+	Timeouts:   2,
+}
+
+func (c CheckConfig) Validate() error {
+	if c.Feds < 1 || c.Auds < 1 {
+		return fmt.Errorf("there must be a federated and an audit server")
+	}
+	if c.Volunteers < 1 || c.Volunteers > c.Auds {
+		return fmt.Errorf("there must be between 1 and %d volunteers", c.Auds)
+	}
+	if c.Electing < 0 || c.Electing >= c.Feds {
+		return fmt.Errorf("electing %d is not a federated server", c.Electing)
+	}
+	if c.Faulty < 0 || c.Faulty*2 >= c.Feds {
+		return fmt.Errorf("%d faulty nodes is not a minority of %d", c.Faulty, c.Feds)
+	}
+	if c.Drops < 0 || c.Dups < 0 || c.Timeouts < 0 || c.MaxSteps < 1 {
+		return fmt.Errorf("bad fault or step limits")
+	}
+	return nil
+}
+
+// Delivery is a message in flight to a node
+type Delivery struct {
+	ID   int // in the order the messages were sent, the same on every replay
+	From int // node that sent the message, -1 for a volunteer
+	To   int
+	Msg  interfaces.IMsg
+}
+
+// Step is a step of a run, on the Delivery with the ID, or on the node with the ID for a timeout
+type Step struct {
+	Kind string
+	ID   int
+}
+
+// Violation is an invariant a run broke, with the steps that led to it
+type Violation struct {
+	Reason string
+	Trace  *Trace
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s after %d steps", v.Reason, len(v.Trace.Steps))
+}
+
+// Checker runs the production elections code, elections.Elections and the FedVote messages
+// through ElectionProcess, on a network of nodes, and checks the invariants of the elections
+// after every step:
+//
+// Safety: the nodes that committed agree on the volunteer, and the nodes that swapped agree on
+// the server replacing the leader, without any server leading two VMs.
+//
+// Liveness: as only a minority of the nodes lose messages, a majority of the nodes commit before
+// MaxSteps steps.
+//
+// The messages a node sends to itself, on the InMsgQueue for its state and on the ElectionsQueue
+// for its elections, are run within the step that sent them.  The fault timers the elections
+// start never fire on their own, the timeout steps of the run fire them instead, so that a
+// trace replays the same way.
+type Checker struct {
+	Config   CheckConfig
+	States   []*state.State
+	Nodes    []*elections.Elections
+	Adapters []*electionMsgs.ElectionAdapter
+	Pending  []*Delivery // messages in flight
+	Steps    []Step      // steps of the run so far
+
+	feds     []interfaces.IServer
+	auds     []interfaces.IServer
+	waiting  [][]interfaces.IElectionMsg // internal messages each node's elections can't run yet
+	nextID   int
+	drops    int
+	dups     int
+	timeouts int
+}
+
+// NewChecker creates the states of the nodes, which are reused by every run
+func NewChecker(config CheckConfig) (*Checker, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	c := new(Checker)
+	c.Config = config
+	for i := 0; i < config.Feds; i++ {
+		s := testHelper.CreateAndPopulateTestState()
+		s.FactomNodeName = fmt.Sprintf("Node%d", i)
+		c.States = append(c.States, s)
+	}
+	c.Reset()
+	return c, nil
+}
+
+// Reset starts a new run, with the volunteer messages in flight to every node
+func (c *Checker) Reset() {
+	c.feds = make([]interfaces.IServer, c.Config.Feds)
+	for i := range c.feds {
+		s := new(state.Server)
+		s.ChainID, _ = primitives.HexToHash("888888" + fmt.Sprintf("%058d", i))
+		s.Name = fmt.Sprintf("Node%d", i)
+		s.Online = true
+		c.feds[i] = s
+	}
+	c.auds = make([]interfaces.IServer, c.Config.Auds)
+	for i := range c.auds {
+		s := new(state.Server)
+		s.ChainID, _ = primitives.HexToHash("888888" + fmt.Sprintf("%058d", i+c.Config.Feds))
+		s.Name = fmt.Sprintf("Audit%d", i)
+		s.Online = true
+		c.auds[i] = s
+	}
+
+	c.Nodes = make([]*elections.Elections, len(c.States))
+	c.Adapters = make([]*electionMsgs.ElectionAdapter, len(c.States))
+	for i, s := range c.States {
+		s.SetIdentityChainID(c.feds[i].GetChainID())
+
+		e := new(elections.Elections)
+		e.State = s
+		e.FedID = c.feds[i].GetChainID()
+		e.Name = s.FactomNodeName
+		e.Federated = append([]interfaces.IServer{}, c.feds...)
+		e.Audit = append([]interfaces.IServer{}, c.auds...)
+		e.DBHeight = int(s.ProcessLists.DBHeightBase)
+		e.SigType = true
+		e.Electing = c.Config.Electing
+		e.Round = make([]int, len(c.feds))
+		e.Input = s.ElectionsQueue()
+		e.Output = s.InMsgQueue()
+		e.Timeout = neverFires
+		e.RoundTimeout = neverFires
+		if pl := s.ProcessLists.Get(uint32(e.DBHeight)); pl != nil {
+			// The state swaps its own servers when the election commits
+			pl.FedServers = append([]interfaces.IServer{}, e.Federated...)
+			pl.AuditServers = append([]interfaces.IServer{}, e.Audit...)
+		}
+		c.drain(i)
+
+		c.Adapters[i] = electionMsgs.NewElectionAdapter(e, primitives.NewZeroHash())
+		e.Adapter = c.Adapters[i]
+		s.Elections = e
+		c.Nodes[i] = e
+	}
+
+	c.Pending = nil
+	c.Steps = nil
+	c.waiting = make([][]interfaces.IElectionMsg, len(c.Nodes))
+	c.nextID = 0
+	c.drops = 0
+	c.dups = 0
+	c.timeouts = 0
+	for v := 0; v < c.Config.Volunteers; v++ {
+		msg := NewTestVolunteerMessage(c.Nodes[0], c.Config.Electing, v)
+		// The state adds the acknowledged missing message to its process list on the commit
+		msg.Ack.(*messages.Ack).MessageHash = msg.Missing.GetMsgHash()
+		for to := range c.Nodes {
+			c.send(msg, -1, to)
+		}
+	}
+}
+
+func (c *Checker) send(msg interfaces.IMsg, from, to int) {
+	c.Pending = append(c.Pending, &Delivery{ID: c.nextID, From: from, To: to, Msg: msg})
+	c.nextID++
+}
+
+// drain empties the queues of the state of a node, which has no other loop running
+func (c *Checker) drain(node int) {
+	s := c.States[node]
+	for s.InMsgQueue().Length() > 0 && s.InMsgQueue().Dequeue() != nil {
+	}
+	for s.ElectionsQueue().Length() > 0 && s.ElectionsQueue().Dequeue() != nil {
+	}
+	for s.NetworkOutMsgQueue().Length() > 0 && s.NetworkOutMsgQueue().Dequeue() != nil {
+	}
+}
+
+// deliver runs a message through the elections of a node
+func (c *Checker) deliver(node int, msg interfaces.IElectionMsg) error {
+	return c.process(node, func() {
+		msg.ElectionProcess(c.States[node], c.Nodes[node])
+	})
+}
+
+// timeout fires the fault timer of a node, queuing the timeout as Fault does when it runs out
+func (c *Checker) timeout(node int) error {
+	e := c.Nodes[node]
+	timeout := new(electionMsgs.TimeoutInternal)
+	timeout.DBHeight = e.DBHeight
+	timeout.Minute = byte(e.Minute)
+	timeout.SigType = e.SigType
+	return c.process(node, func() {
+		e.Input.Enqueue(timeout)
+	})
+}
+
+// process calls f on a node, runs what the node sends to itself, and sends what it sends out to
+// every other node.  A panic of the node is returned as an error.
+func (c *Checker) process(node int, f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("node %d panicked: %v", node, r)
+		}
+	}()
+	f()
+	c.route(node)
+
+	out := c.States[node].NetworkOutMsgQueue()
+	for out.Length() > 0 {
+		msg := out.Dequeue()
+		if msg == nil {
+			break
+		}
+		for to := range c.Nodes {
+			if to != node {
+				c.send(msg, node, to)
+			}
+		}
+	}
+	return nil
+}
+
+// route runs the messages a node sent to itself, as the loops of its state and elections would:
+// the state executes the messages of the InMsgQueue, such as the commit of an election, and the
+// elections process the internal messages of the ElectionsQueue, such as the leaders the state
+// added or removed.  Internal messages the elections can't run yet wait until they can.
+func (c *Checker) route(node int) {
+	s, e := c.States[node], c.Nodes[node]
+	for i := 0; i < maxRouted; i++ {
+		if s.InMsgQueue().Length() > 0 {
+			if msg := s.InMsgQueue().Dequeue(); msg != nil && msg.Validate(s) == 1 {
+				msg.FollowerExecute(s)
+			}
+			continue
+		}
+		for s.ElectionsQueue().Length() > 0 {
+			if msg, ok := s.ElectionsQueue().Dequeue().(interfaces.IElectionMsg); ok {
+				c.waiting[node] = append(c.waiting[node], msg)
+			}
+		}
+		if !c.runWaiting(node, s, e) {
+			return
+		}
+	}
+	panic(fmt.Sprintf("sent itself more than %d messages in a step", maxRouted))
+}
+
+// runWaiting processes the first waiting internal message the elections of a node can run, and
+// drops those they never will.  It returns false if none was processed.
+func (c *Checker) runWaiting(node int, s *state.State, e *elections.Elections) bool {
+	waiting := c.waiting[node]
+	for i, msg := range waiting {
+		switch msg.ElectionValidate(e) {
+		case -1:
+			c.waiting[node] = append(waiting[:i:i], waiting[i+1:]...)
+			return true
+		case 0:
+			continue
+		}
+		c.waiting[node] = append(waiting[:i:i], waiting[i+1:]...)
+		msg.ElectionProcess(s, e)
+		return true
+	}
+	return false
+}
+
+// canTimeout is true if the fault timer of a node may fire
+func (c *Checker) canTimeout(node int) bool {
+	return node >= 0 && node < len(c.Nodes) && c.Nodes[node].Electing >= 0 && c.timeouts < c.Config.Timeouts
+}
+
+func (c *Checker) find(id int) int {
+	for i, d := range c.Pending {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// faulty is true for the nodes allowed to lose messages
+func (c *Checker) faulty(node int) bool {
+	return node >= len(c.Nodes)-c.Config.Faulty
+}
+
+// Apply takes a step of the run
+func (c *Checker) Apply(step Step) error {
+	if step.Kind == StepTimeout {
+		if !c.canTimeout(step.ID) {
+			return fmt.Errorf("node %d cannot time out", step.ID)
+		}
+		c.timeouts++
+		c.Steps = append(c.Steps, step)
+		return c.timeout(step.ID)
+	}
+
+	i := c.find(step.ID)
+	if i < 0 {
+		return fmt.Errorf("message %d is not in flight", step.ID)
+	}
+	d := c.Pending[i]
+	switch step.Kind {
+	case StepDeliver:
+		c.Pending = append(c.Pending[:i], c.Pending[i+1:]...)
+	case StepDrop:
+		if !c.faulty(d.To) || c.drops >= c.Config.Drops {
+			return fmt.Errorf("message %d to node %d cannot be dropped", d.ID, d.To)
+		}
+		c.drops++
+		c.Pending = append(c.Pending[:i], c.Pending[i+1:]...)
+		c.Steps = append(c.Steps, step)
+		return nil
+	case StepDuplicate:
+		if c.dups >= c.Config.Dups {
+			return fmt.Errorf("message %d cannot be duplicated", d.ID)
+		}
+		c.dups++
+	default:
+		return fmt.Errorf("unknown step %s", step.Kind)
+	}
+	c.Steps = append(c.Steps, step)
+	return c.deliver(d.To, d.Msg.(interfaces.IElectionMsg))
+}
+
+// Committed returns the number of nodes that committed to a volunteer
+func (c *Checker) Committed() int {
+	n := 0
+	for _, a := range c.Adapters {
+		if a.SimulatedElection.Committed {
+			n++
+		}
+	}
+	return n
+}
+
+// CheckSafety returns why the nodes disagree, or an empty string
+func (c *Checker) CheckSafety() string {
+	committed := -1
+	for i, a := range c.Adapters {
+		if !a.SimulatedElection.Committed {
+			continue
+		}
+		p := a.SimulatedElection.CurrentVote.VolunteerPriority
+		if committed != -1 && p != committed {
+			return fmt.Sprintf("node %d committed to volunteer priority %d, another node to %d", i, p, committed)
+		}
+		committed = p
+	}
+
+	var elected interfaces.IHash
+	for i, e := range c.Nodes {
+		seen := map[[32]byte]bool{}
+		for _, f := range e.Federated {
+			if seen[f.GetChainID().Fixed()] {
+				return fmt.Sprintf("node %d has %x leading two VMs", i, f.GetChainID().Bytes()[3:6])
+			}
+			seen[f.GetChainID().Fixed()] = true
+		}
+		for _, a := range e.Audit {
+			if seen[a.GetChainID().Fixed()] {
+				return fmt.Sprintf("node %d has %x as a leader and an audit server", i, a.GetChainID().Bytes()[3:6])
+			}
+		}
+		if !c.Adapters[i].IsElectionProcessed() {
+			continue
+		}
+		leader := e.Federated[c.Config.Electing].GetChainID()
+		if leader.IsSameAs(c.feds[c.Config.Electing].GetChainID()) {
+			return fmt.Sprintf("node %d ended the election without replacing the leader", i)
+		}
+		if elected != nil && !leader.IsSameAs(elected) {
+			return fmt.Sprintf("node %d elected %x, another node %x", i, leader.Bytes()[3:6], elected.Bytes()[3:6])
+		}
+		elected = leader
+	}
+	return ""
+}
+
+// Trace returns the configuration and the steps of the run so far
+func (c *Checker) Trace() *Trace {
+	t := new(Trace)
+	t.Config = c.Config
+	t.Steps = append([]Step{}, c.Steps...)
+	return t
+}
+
+func (c *Checker) violation(reason string) *Violation {
+	return &Violation{Reason: reason, Trace: c.Trace()}
+}
+
+// Run takes steps picked by the random source until no message is in flight, checking the
+// invariants after every step
+func (c *Checker) Run(r *rand.Rand) *Violation {
+	c.Reset()
+	for len(c.Pending) > 0 {
+		if len(c.Steps) >= c.Config.MaxSteps {
+			break
+		}
+		d := c.Pending[r.Intn(len(c.Pending))]
+		step := Step{StepDeliver, d.ID}
+		switch r.Intn(8) {
+		case 0:
+			if c.faulty(d.To) && c.drops < c.Config.Drops {
+				step.Kind = StepDrop
+			}
+		case 1:
+			if c.dups < c.Config.Dups {
+				step.Kind = StepDuplicate
+			}
+		case 2:
+			if node := r.Intn(len(c.Nodes)); c.canTimeout(node) {
+				step = Step{StepTimeout, node}
+			}
+		}
+		if err := c.Apply(step); err != nil {
+			return c.violation(err.Error())
+		}
+		if reason := c.CheckSafety(); reason != "" {
+			return c.violation(reason)
+		}
+	}
+	return c.checkLiveness()
+}
+
+func (c *Checker) checkLiveness() *Violation {
+	if c.Committed() < len(c.Nodes)/2+1 {
+		if len(c.Pending) > 0 {
+			return c.violation(fmt.Sprintf("no majority committed in %d steps", len(c.Steps)))
+		}
+		return c.violation(fmt.Sprintf("no majority committed, %d of %d nodes did", c.Committed(), len(c.Nodes)))
+	}
+	return nil
+}
+
+// Check runs the seeds from seed to seed+runs-1, and returns the first violation found
+func (c *Checker) Check(seed int64, runs int) *Violation {
+	for i := int64(0); i < int64(runs); i++ {
+		if v := c.Run(rand.New(rand.NewSource(seed + i))); v != nil {
+			v.Trace.Seed = seed + i
+			return v
+		}
+	}
+	return nil
+}
+
+// Replay takes the steps of a trace on a new run, and returns the violation it leads to, if any
+func (c *Checker) Replay(t *Trace) *Violation {
+	c.Reset()
+	v := c.replay(t)
+	if v != nil {
+		v.Trace.Seed = t.Seed
+	}
+	return v
+}
+
+func (c *Checker) replay(t *Trace) *Violation {
+	for _, step := range t.Steps {
+		if err := c.Apply(step); err != nil {
+			return c.violation(err.Error())
+		}
+		if reason := c.CheckSafety(); reason != "" {
+			return c.violation(reason)
+		}
+	}
+	if len(c.Pending) > 0 && len(c.Steps) < c.Config.MaxSteps {
+		return nil // a prefix of a run, liveness cannot be judged
+	}
+	return c.checkLiveness()
+}
@@ -0,0 +1,120 @@
+package electionMsgTesting_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	. "github.com/FactomProject/factomd/common/messages/electionMsgs/electionMsgTesting"
+)
+
+func TestCheckerNoFaults(t *testing.T) {
+	config := DefaultCheckConfig
+	config.Faulty, config.Drops, config.Dups, config.Timeouts = 0, 0, 0, 0
+	c, err := NewChecker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Check(1, 5); v != nil {
+		var buf bytes.Buffer
+		v.Trace.Write(&buf)
+		t.Fatalf("%v\n%s", v, buf.String())
+	}
+	if c.Committed() < config.Feds/2+1 {
+		t.Errorf("Only %d nodes committed", c.Committed())
+	}
+}
+
+func TestCheckerReplay(t *testing.T) {
+	c, err := NewChecker(DefaultCheckConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Run(rand.New(rand.NewSource(7)))
+	trace := c.Trace()
+	if v != nil {
+		trace = v.Trace
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Config != trace.Config || len(read.Steps) != len(trace.Steps) {
+		t.Fatalf("Trace changed when written and read")
+	}
+	for i := range read.Steps {
+		if read.Steps[i] != trace.Steps[i] {
+			t.Fatalf("Step %d changed from %v to %v", i, trace.Steps[i], read.Steps[i])
+		}
+	}
+
+	// The replay ends the same way as the run
+	replayed := c.Replay(read)
+	if (v == nil) != (replayed == nil) {
+		t.Errorf("Run found %v, replay %v", v, replayed)
+	}
+	if len(c.Steps) != len(trace.Steps) {
+		t.Errorf("Replayed %d steps of %d", len(c.Steps), len(trace.Steps))
+	}
+}
+
+func TestCheckerTimeoutSteps(t *testing.T) {
+	c, err := NewChecker(DefaultCheckConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Apply(Step{StepTimeout, len(c.Nodes)}); err == nil {
+		t.Errorf("Timed out a node that does not exist")
+	}
+	if err := c.Apply(Step{StepTimeout, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Nodes[1].Round[c.Config.Electing] != 1 {
+		t.Errorf("The timeout did not start a new round, round %d", c.Nodes[1].Round[c.Config.Electing])
+	}
+	pending := len(c.Pending)
+
+	// The timeout is part of the trace, and replays the same way
+	var buf bytes.Buffer
+	if err := c.Trace().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Steps) != 1 || read.Steps[0] != (Step{StepTimeout, 1}) {
+		t.Fatalf("Wrong steps read %v", read.Steps)
+	}
+	if v := c.Replay(read); v != nil {
+		t.Fatalf("%v", v)
+	}
+	if c.Nodes[1].Round[c.Config.Electing] != 1 || len(c.Pending) != pending {
+		t.Errorf("The replay of the timeout differs from the run")
+	}
+
+	// Older traces without timeouts in the config allow none
+	read, err = ReadTrace(bytes.NewBufferString("config 3 2 2 0 0 0 0 10\ntimeout 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Replay(read); v == nil {
+		t.Errorf("Replayed a timeout the config does not allow")
+	}
+}
+
+func TestCheckerRejects(t *testing.T) {
+	config := DefaultCheckConfig
+	config.Faulty = 2
+	if _, err := NewChecker(config); err == nil {
+		t.Errorf("2 faulty nodes of 3 accepted")
+	}
+	if _, err := ReadTrace(bytes.NewBufferString("config 3 2 2 0 0 0 0 10\nvote 1\n")); err == nil {
+		t.Errorf("Unknown statement accepted")
+	}
+}
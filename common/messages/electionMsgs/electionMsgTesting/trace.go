@@ -0,0 +1,132 @@
+package electionMsgTesting
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Trace is a run of a Checker that can be saved to a file and replayed.  The file has one
+// statement per line, and # starts a comment:
+//
+//	config <feds> <auds> <volunteers> <electing> <faulty> <drops> <dups> <maxsteps> [<timeouts>]
+//	seed <seed>
+//	deliver <id>
+//	drop <id>
+//	duplicate <id>
+//	timeout <node>
+//
+// The ids are those of the messages in flight, numbered in the order they were sent.  A config
+// without timeouts allows none.
+type Trace struct {
+	Config CheckConfig
+	Seed   int64 // of the run, for information
+	Steps  []Step
+}
+
+// Write writes the trace in the format of a trace file
+func (t *Trace) Write(w io.Writer) error {
+	c := t.Config
+	_, err := fmt.Fprintf(w, "# feds auds volunteers electing faulty drops dups maxsteps timeouts\nconfig %d %d %d %d %d %d %d %d %d\nseed %d\n",
+		c.Feds, c.Auds, c.Volunteers, c.Electing, c.Faulty, c.Drops, c.Dups, c.MaxSteps, c.Timeouts, t.Seed)
+	if err != nil {
+		return err
+	}
+	for i, step := range t.Steps {
+		_, err = fmt.Fprintf(w, "%s %d # %d\n", step.Kind, step.ID, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save writes the trace to a file
+func (t *Trace) Save(name string) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return t.Write(file)
+}
+
+// ReadTrace reads a trace in the format of a trace file
+func ReadTrace(r io.Reader) (*Trace, error) {
+	t := new(Trace)
+	t.Config = DefaultCheckConfig
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		var args []int64
+		for _, f := range fields[1:] {
+			n, err := strconv.ParseInt(f, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad number %s", line, f)
+			}
+			args = append(args, n)
+		}
+
+		switch fields[0] {
+		case "config":
+			if len(args) != 8 && len(args) != 9 {
+				return nil, fmt.Errorf("line %d: config takes 8 or 9 numbers", line)
+			}
+			args = append(args, 0)
+			t.Config = CheckConfig{int(args[0]), int(args[1]), int(args[2]), int(args[3]), int(args[4]), int(args[5]), int(args[6]), int(args[7]), int(args[8])}
+		case "seed":
+			if len(args) != 1 {
+				return nil, fmt.Errorf("line %d: seed takes a number", line)
+			}
+			t.Seed = args[0]
+		case StepDeliver, StepDrop, StepDuplicate, StepTimeout:
+			if len(args) != 1 {
+				return nil, fmt.Errorf("line %d: %s takes an id", line, fields[0])
+			}
+			t.Steps = append(t.Steps, Step{fields[0], int(args[0])})
+		default:
+			return nil, fmt.Errorf("line %d: unknown statement %s", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := t.Config.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadTrace reads a trace file
+func LoadTrace(name string) (*Trace, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadTrace(file)
+}
+
+// ReplayFromFile replays a trace file on a new Checker, as divefromfile does for the electionsCore
+// model, and returns the violation it leads to, if any
+func ReplayFromFile(name string) (*Violation, error) {
+	t, err := LoadTrace(name)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewChecker(t.Config)
+	if err != nil {
+		return nil, err
+	}
+	return c.Replay(t), nil
+}
@@ -90,9 +90,9 @@ func (m *EomSigInternal) GetMsgHash() (rval interfaces.IHash) {
 func Fault(e *elections.Elections, dbheight int, minute int, timeOutId int, currentTimeoutId *atomic.AtomicInt, sigtype bool, timeoutDuration time.Duration) {
 	//	e.LogPrintf("election", "Start Timeout %d", timeOutId)
 	for !e.State.(*state.State).DBFinished || e.State.(*state.State).IgnoreMissing {
-		time.Sleep(timeoutDuration)
+		primitives.Sleep(timeoutDuration)
 	}
-	time.Sleep(timeoutDuration)
+	primitives.Sleep(timeoutDuration)
 
 	if currentTimeoutId.Load() == timeOutId {
 		//		e.LogPrintf("election", "Timeout %d", timeOutId)
@@ -154,6 +154,7 @@ func (m *EomSigInternal) ElectionProcess(is interfaces.IState, elect interfaces.
 		// Reset elections as we moved forward
 		if int(m.DBHeight) > e.DBHeight && e.Electing != -1 {
 			e.Electing = -1
+			s.GetFaultHistory().ElectionEnded(nil)
 		}
 
 		// We stop sorting on 6/28/18 at 12pm ...
@@ -78,6 +78,7 @@ func (m *FedVoteVolunteerMsg) ElectionProcess(is interfaces.IState, elect interf
 	}
 	e.Msg = m.Missing
 	e.Ack = m.Ack
+	is.(*state.State).GetFaultHistory().ElectionVolunteer(m.Round, m.ServerID)
 	e.VName = m.ServerName
 
 	/******  Election Adapter Control   ******/
@@ -158,6 +158,7 @@ func (m *TimeoutInternal) ElectionProcess(is interfaces.IState, elect interfaces
 			e.Round = append(e.Round, 0)
 		}
 		e.Round[e.Electing] = 0
+		s.GetFaultHistory().ElectionStarted(uint32(m.DBHeight), int(m.Minute), e.VMIndex, m.SigType, e.FedID)
 
 		sync := "dbsig"
 		if m.SigType {
@@ -183,6 +184,7 @@ func (m *TimeoutInternal) ElectionProcess(is interfaces.IState, elect interfaces
 
 	// New timeout, new round of elections.
 	e.Round[e.Electing]++
+	s.GetFaultHistory().ElectionRound(e.Round[e.Electing])
 
 	// If we don't have all our sync messages, we will have to come back around and see if all is well.
 	// Start our timer to timeout this sync
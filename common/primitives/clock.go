@@ -0,0 +1,137 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package primitives
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/util/atomic"
+)
+
+// The clock of the nodes is the real time, unless a deterministic simulation switches every node
+// of the process to a virtual clock that only moves when it is advanced.  Code that times the
+// protocol uses Now and Sleep rather than the time package, so it follows the virtual clock.
+// virtualClock is only changed under clockMutex, but read without it so the real clock costs
+// no lock.
+var clockMutex sync.Mutex
+var virtualClock atomic.AtomicBool
+var virtualNow time.Time
+var sleepers []*sleeper
+
+// sleeper is a goroutine sleeping on the virtual clock, woken when the clock passes wake
+type sleeper struct {
+	wake time.Time
+	done chan struct{}
+}
+
+type sleeperSort []*sleeper
+
+func (s sleeperSort) Len() int           { return len(s) }
+func (s sleeperSort) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sleeperSort) Less(i, j int) bool { return s[i].wake.Before(s[j].wake) }
+
+// UseVirtualClock switches to a virtual clock set to start
+func UseVirtualClock(start time.Time) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	virtualNow = start
+	virtualClock.Store(true)
+}
+
+// UseRealClock switches back to the real time, waking every goroutine sleeping on the virtual clock
+func UseRealClock() {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	virtualClock.Store(false)
+	for _, s := range sleepers {
+		close(s.done)
+	}
+	sleepers = nil
+}
+
+// IsVirtualClock returns true if the nodes run on the virtual clock
+func IsVirtualClock() bool {
+	return virtualClock.Load()
+}
+
+// Now returns the time of the clock
+func Now() time.Time {
+	if !virtualClock.Load() {
+		return time.Now()
+	}
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	if virtualClock.Load() {
+		return virtualNow
+	}
+	return time.Now()
+}
+
+// Sleep sleeps for a duration of the clock.  On the virtual clock, it returns once the clock
+// is advanced past the duration.
+func Sleep(d time.Duration) {
+	if !virtualClock.Load() {
+		time.Sleep(d)
+		return
+	}
+	clockMutex.Lock()
+	if !virtualClock.Load() {
+		clockMutex.Unlock()
+		time.Sleep(d)
+		return
+	}
+	if d <= 0 {
+		clockMutex.Unlock()
+		return
+	}
+	s := &sleeper{wake: virtualNow.Add(d), done: make(chan struct{})}
+	sleepers = append(sleepers, s)
+	sort.Stable(sleeperSort(sleepers))
+	clockMutex.Unlock()
+	<-s.done
+}
+
+// AdvanceClock moves the virtual clock forward, waking the goroutines due in the order of
+// their wake times
+func AdvanceClock(d time.Duration) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	if !virtualClock.Load() || d <= 0 {
+		return
+	}
+	virtualNow = virtualNow.Add(d)
+	for len(sleepers) > 0 && !sleepers[0].wake.After(virtualNow) {
+		close(sleepers[0].done)
+		sleepers = sleepers[1:]
+	}
+}
+
+// WakeNext wakes the earliest goroutine sleeping on the virtual clock, if it is due by until,
+// moving the clock to its wake time.  It returns false if no goroutine is due by until.
+func WakeNext(until time.Time) bool {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	if !virtualClock.Load() || len(sleepers) == 0 || sleepers[0].wake.After(until) {
+		return false
+	}
+	if sleepers[0].wake.After(virtualNow) {
+		virtualNow = sleepers[0].wake
+	}
+	close(sleepers[0].done)
+	sleepers = sleepers[1:]
+	return true
+}
+
+// NextWake returns the earliest time a goroutine sleeping on the virtual clock wakes, if any
+func NextWake() (time.Time, bool) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	if len(sleepers) == 0 {
+		return time.Time{}, false
+	}
+	return sleepers[0].wake, true
+}
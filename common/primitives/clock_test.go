@@ -0,0 +1,104 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package primitives_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/factomd/common/primitives"
+)
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	UseVirtualClock(start)
+	defer UseRealClock()
+
+	if !Now().Equal(start) {
+		t.Fatalf("Clock at %v, not %v", Now(), start)
+	}
+	if GetTimeMilli() != uint64(start.UnixNano()/1000000) || NewTimestampNow().GetTimeMilli() != start.UnixNano()/1000000 {
+		t.Errorf("Timestamps do not follow the virtual clock")
+	}
+
+	woken := make(chan int, 2)
+	go func() {
+		Sleep(100 * time.Millisecond)
+		woken <- 100
+	}()
+	go func() {
+		Sleep(30 * time.Millisecond)
+		woken <- 30
+	}()
+	for i := 0; i < 100; i++ {
+		if _, ok := NextWake(); ok {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	AdvanceClock(50 * time.Millisecond)
+	if w := <-woken; w != 30 {
+		t.Errorf("Woke the sleeper of %d ms first", w)
+	}
+	select {
+	case w := <-woken:
+		t.Errorf("Woke the sleeper of %d ms at 50 ms", w)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	AdvanceClock(50 * time.Millisecond)
+	select {
+	case <-woken:
+	case <-time.After(time.Second):
+		t.Errorf("The sleeper of 100 ms did not wake at 100 ms")
+	}
+	if !Now().Equal(start.Add(100 * time.Millisecond)) {
+		t.Errorf("Clock at %v after advancing 100 ms", Now())
+	}
+}
+
+func TestWakeNext(t *testing.T) {
+	start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	UseVirtualClock(start)
+	defer UseRealClock()
+
+	woken := make(chan int, 2)
+	for _, ms := range []int{20, 10} {
+		go func(ms int) {
+			Sleep(time.Duration(ms) * time.Millisecond)
+			woken <- ms
+		}(ms)
+	}
+	for i := 0; i < 100; i++ {
+		if w, ok := NextWake(); ok && w.Equal(start.Add(10*time.Millisecond)) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if WakeNext(start.Add(5 * time.Millisecond)) {
+		t.Errorf("Woke a sleeper not due")
+	}
+	if !WakeNext(start.Add(50*time.Millisecond)) || <-woken != 10 {
+		t.Errorf("Did not wake the sleeper of 10 ms first")
+	}
+	if !Now().Equal(start.Add(10 * time.Millisecond)) {
+		t.Errorf("Clock at %v, not at the wake time", Now())
+	}
+	select {
+	case w := <-woken:
+		t.Errorf("Woke the sleeper of %d ms with the first", w)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if !WakeNext(start.Add(50*time.Millisecond)) || <-woken != 20 {
+		t.Errorf("Did not wake the sleeper of 20 ms")
+	}
+	if WakeNext(start.Add(50 * time.Millisecond)) {
+		t.Errorf("Woke a sleeper that is not there")
+	}
+}
@@ -6,10 +6,73 @@ package random
 
 import (
 	"crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
 	"math"
 	"math/big"
+	mrand "math/rand"
+	"sync"
 )
 
+// The random numbers are from crypto/rand, unless SetSeed makes them a seeded sequence so a
+// deterministic simulation can be run again
+var seedMutex sync.Mutex
+var seeded bool
+var seed int64
+var seededRand *mrand.Rand
+
+// seededReader reads the seeded sequence, safe to share between goroutines
+type seededReader struct{}
+
+func (seededReader) Read(p []byte) (int, error) {
+	seedMutex.Lock()
+	defer seedMutex.Unlock()
+	return seededRand.Read(p)
+}
+
+// reader returns the source of the random numbers
+func reader() io.Reader {
+	seedMutex.Lock()
+	defer seedMutex.Unlock()
+	if seeded {
+		return seededReader{}
+	}
+	return rand.Reader
+}
+
+// SetSeed makes the random numbers a sequence that only depends on the seed
+func SetSeed(s int64) {
+	seedMutex.Lock()
+	defer seedMutex.Unlock()
+	seeded = true
+	seed = s
+	seededRand = mrand.New(mrand.NewSource(s))
+}
+
+// GetSeed returns the seed, and false if the random numbers are not seeded
+func GetSeed() (int64, bool) {
+	seedMutex.Lock()
+	defer seedMutex.Unlock()
+	return seed, seeded
+}
+
+// NewRand returns a source of random numbers of its own for the stream name.  When seeded, the
+// numbers only depend on the seed and the name, so streams used by different goroutines do not
+// change each other's numbers.
+func NewRand(name string) *mrand.Rand {
+	seedMutex.Lock()
+	defer seedMutex.Unlock()
+	if !seeded {
+		var b [8]byte
+		rand.Read(b[:])
+		return mrand.New(mrand.NewSource(int64(binary.BigEndian.Uint64(b[:]))))
+	}
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return mrand.New(mrand.NewSource(seed ^ int64(h.Sum64())))
+}
+
 func RandUInt64() uint64 {
 	return RandUInt64Between(0, math.MaxUint64)
 }
@@ -32,7 +95,7 @@ func RandUInt64Between(min, max uint64) uint64 {
 	}
 	uint64max := big.NewInt(0)
 	uint64max.SetUint64(max - min)
-	randnum, _ := rand.Int(rand.Reader, uint64max)
+	randnum, _ := rand.Int(reader(), uint64max)
 	m := big.NewInt(0)
 	m.SetUint64(min)
 	randnum = randnum.Add(randnum, m)
@@ -41,7 +104,7 @@ func RandUInt64Between(min, max uint64) uint64 {
 
 func RandInt64() int64 {
 	int64max := big.NewInt(math.MaxInt64)
-	randnum, _ := rand.Int(rand.Reader, int64max)
+	randnum, _ := rand.Int(reader(), int64max)
 	return randnum.Int64()
 }
 
@@ -50,7 +113,7 @@ func RandInt64Between(min, max int64) int64 {
 		return 0
 	}
 	int64max := big.NewInt(max - min)
-	randnum, _ := rand.Int(rand.Reader, int64max)
+	randnum, _ := rand.Int(reader(), int64max)
 	m := big.NewInt(min)
 	randnum = randnum.Add(randnum, m)
 	return randnum.Int64()
@@ -67,7 +130,7 @@ func RandIntBetween(min, max int) int {
 func RandByteSlice() []byte {
 	l := RandInt() % 64
 	answer := make([]byte, l)
-	_, err := rand.Read(answer)
+	_, err := io.ReadFull(reader(), answer)
 	if err != nil {
 		return nil
 	}
@@ -77,7 +140,7 @@ func RandByteSlice() []byte {
 func RandNonEmptyByteSlice() []byte {
 	l := RandInt()%63 + 1
 	answer := make([]byte, l)
-	_, err := rand.Read(answer)
+	_, err := io.ReadFull(reader(), answer)
 	if err != nil {
 		return nil
 	}
@@ -89,7 +152,7 @@ func RandByteSliceOfLen(l int) []byte {
 		return nil
 	}
 	answer := make([]byte, l)
-	_, err := rand.Read(answer)
+	_, err := io.ReadFull(reader(), answer)
 	if err != nil {
 		return nil
 	}
@@ -137,3 +137,32 @@ func TestUintNumbers(t *testing.T) {
 		}
 	}
 }
+
+func TestSetSeed(t *testing.T) {
+	SetSeed(42)
+	a := []uint64{RandUInt64(), RandUInt64(), RandUInt64()}
+	s := RandByteSliceOfLen(16)
+	SetSeed(42)
+	b := []uint64{RandUInt64(), RandUInt64(), RandUInt64()}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Errorf("Seeded number %d changed from %d to %d", i, a[i], b[i])
+		}
+	}
+	if string(RandByteSliceOfLen(16)) != string(s) {
+		t.Errorf("Seeded bytes changed")
+	}
+	if seed, ok := GetSeed(); !ok || seed != 42 {
+		t.Errorf("Seed %d %v", seed, ok)
+	}
+
+	// The streams only depend on the seed and their names
+	r1, r2 := NewRand("a->b"), NewRand("b->a")
+	x1, x2 := r1.Int63(), r2.Int63()
+	if x1 == x2 {
+		t.Errorf("Streams of different names are the same")
+	}
+	if NewRand("a->b").Int63() != x1 {
+		t.Errorf("Stream a->b changed")
+	}
+}
@@ -15,11 +15,11 @@ import (
 )
 
 func GetTimeMilli() uint64 {
-	return uint64(time.Now().UnixNano()) / 1000000 // 10^-9 >> 10^-3
+	return uint64(Now().UnixNano()) / 1000000 // 10^-9 >> 10^-3
 }
 
 func GetTime() uint64 {
-	return uint64(time.Now().Unix())
+	return uint64(Now().Unix())
 }
 
 //A structure for handling timestamps for messages
@@ -433,10 +433,45 @@ func factomdQuery(item string, value string, batchQueried bool) []byte {
 		} else {
 			return []byte(`{"Access":"denied", "Id":"` + hash + `"}`)
 		}
+	case "bans":
+		return getBans()
+	case "ban", "unban":
+		DisplayStateMutex.RLock()
+		CPS := DisplayState.ControlPanelSetting
+		DisplayStateMutex.RUnlock()
+		resp := struct {
+			Access  string
+			Address string
+		}{"denied", value}
+		if CPS == 2 && Controller != nil && len(value) > 0 {
+			if item == "ban" {
+				Controller.BanAddress(value, "banned from the control panel", 0)
+			} else {
+				Controller.Unban(value)
+			}
+			resp.Access = "granted"
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			return []byte(`error`)
+		}
+		return data
 	}
 	return []byte("")
 }
 
+// Returns the current peer bans
+func getBans() []byte {
+	if Controller == nil {
+		return []byte(`[]`)
+	}
+	data, err := json.Marshal(Controller.GetBans())
+	if err != nil {
+		return []byte(`error`)
+	}
+	return data
+}
+
 func disconnectPeer(hash string) {
 	if Controller != nil {
 		fmt.Println("ControlPanel: Sent a disconnect signal.")
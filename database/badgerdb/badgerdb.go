@@ -0,0 +1,443 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package badgerdb
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerDB stores the buckets in a Badger key-value store.  Badger has no buckets, so every
+// key is prefixed by the length of its bucket and the bucket itself:
+//
+//	[len(bucket)][bucket][key]
+//
+// The length byte keeps buckets from overlapping each other (bucket "ab" can't collide with
+// bucket "a" holding keys starting with "b"), and lets us find the buckets by seeking from
+// one bucket to the next.  Buckets are limited to 255 bytes.
+type BadgerDB struct {
+	// lock preventing multiple entry
+	dbLock sync.RWMutex
+	db     *badger.DB
+	closed bool
+}
+
+var _ interfaces.IDatabase = (*BadgerDB)(nil)
+
+// maxTableSize is raised from the Badger default of 64MB.  A transaction can only hold about
+// 15% of a table, and a directory block with its entries is written as a single transaction.
+const maxTableSize = 256 << 20
+
+func NewBadgerDB(dirname string, create bool) (interfaces.IDatabase, error) {
+	if create == true {
+		err := os.MkdirAll(dirname, 0750)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		_, err := os.Stat(dirname)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := badger.DefaultOptions
+	opts.Dir = dirname
+	opts.ValueDir = dirname
+	opts.MaxTableSize = maxTableSize
+
+	tdb, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	db := new(BadgerDB)
+	db.db = tdb
+	return db, nil
+}
+
+// bucketPrefix returns the prefix shared by all the keys of a bucket
+func bucketPrefix(bucket []byte) ([]byte, error) {
+	if len(bucket) > 255 {
+		return nil, fmt.Errorf("bucket %x is longer than 255 bytes", bucket)
+	}
+	prefix := make([]byte, 0, len(bucket)+1)
+	prefix = append(prefix, byte(len(bucket)))
+	return append(prefix, bucket...), nil
+}
+
+func CombineBucketAndKey(bucket []byte, key []byte) ([]byte, error) {
+	prefix, err := bucketPrefix(bucket)
+	if err != nil {
+		return nil, err
+	}
+	return append(prefix, key...), nil
+}
+
+/***************************************
+ *       Methods
+ ***************************************/
+
+func (db *BadgerDB) ListAllBuckets() ([][]byte, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	answer := [][]byte{}
+	err := db.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		iter := txn.NewIterator(opts)
+		defer iter.Close()
+
+		iter.Rewind()
+		for iter.Valid() {
+			key := iter.Item().Key()
+			l := int(key[0])
+			if len(key) < l+1 {
+				return fmt.Errorf("key %x is shorter than its bucket", key)
+			}
+			bucket := make([]byte, l)
+			copy(bucket, key[1:l+1])
+			answer = append(answer, bucket)
+
+			// Skip the rest of the bucket
+			next := interfaces.PrefixLimit(key[:l+1])
+			if next == nil {
+				break
+			}
+			iter.Seek(next)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// We don't care if delete works or not.  If the key isn't there, that's ok
+func (db *BadgerDB) Delete(bucket []byte, key []byte) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	bKey, err := CombineBucketAndKey(bucket, key)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(bKey)
+	})
+}
+
+// Badger collects its own garbage
+func (db *BadgerDB) Trim() {
+}
+
+// Close can be called more than once, Badger hangs if it is closed twice
+func (db *BadgerDB) Close() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	return db.db.Close()
+}
+
+func (db *BadgerDB) Get(bucket []byte, key []byte, destination interfaces.BinaryMarshallable) (interfaces.BinaryMarshallable, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	bKey, err := CombineBucketAndKey(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	err = db.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(bKey)
+		if err != nil {
+			return err
+		}
+		// The value is only valid during the transaction
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = destination.UnmarshalBinaryData(data)
+	if err != nil {
+		return nil, err
+	}
+	return destination, nil
+}
+
+func (db *BadgerDB) Put(bucket []byte, key []byte, data interfaces.BinaryMarshallable) error {
+	return db.PutAndDeleteInBatch([]interfaces.Record{{Bucket: bucket, Key: key, Data: data}}, nil)
+}
+
+func (db *BadgerDB) PutInBatch(records []interfaces.Record) error {
+	return db.PutAndDeleteInBatch(records, nil)
+}
+
+// PutAndDeleteInBatch makes all the changes in a single transaction, which Badger commits
+// atomically.  A batch too large for one transaction fails with badger.ErrTxnTooBig and
+// changes nothing.
+func (db *BadgerDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.db.Update(func(txn *badger.Txn) error {
+		for _, v := range deletes {
+			bKey, err := CombineBucketAndKey(v.Bucket, v.Key)
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(bKey)
+			if err != nil {
+				return err
+			}
+		}
+		for _, v := range records {
+			bKey, err := CombineBucketAndKey(v.Bucket, v.Key)
+			if err != nil {
+				return err
+			}
+			hex, err := v.Data.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			err = txn.Set(bKey, hex)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *BadgerDB) Clear(bucket []byte) error {
+	keys, err := db.ListAllKeys(bucket)
+	if err != nil {
+		return err
+	}
+
+	deletes := make([]interfaces.Record, len(keys))
+	for i, key := range keys {
+		deletes[i].Bucket = bucket
+		deletes[i].Key = key
+	}
+	return db.PutAndDeleteInBatch(nil, deletes)
+}
+
+func (db *BadgerDB) ListAllKeys(bucket []byte) (keys [][]byte, err error) {
+	it, err := db.iterate(bucket, interfaces.IteratorOptions{}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+
+	var answer [][]byte
+	for it.Next() {
+		key := make([]byte, len(it.Key()))
+		copy(key, it.Key())
+		answer = append(answer, key)
+	}
+	err = it.Error()
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+func (db *BadgerDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAndCopyable) ([]interfaces.BinaryMarshallableAndCopyable, [][]byte, error) {
+	it, err := db.iterate(bucket, interfaces.IteratorOptions{}, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer it.Release()
+
+	answer := []interfaces.BinaryMarshallableAndCopyable{}
+	keys := [][]byte{}
+	for it.Next() {
+		v := it.Value()
+		vCopy := make([]byte, len(v))
+		copy(vCopy, v)
+		tmp := sample.New()
+		err := tmp.UnmarshalBinary(vCopy)
+		if err != nil {
+			return nil, nil, err
+		}
+		k := make([]byte, len(it.Key()))
+		copy(k, it.Key())
+		keys = append(keys, k)
+		answer = append(answer, tmp)
+	}
+	err = it.Error()
+	if err != nil {
+		return nil, nil, err
+	}
+	return answer, keys, nil
+}
+
+// Iterate returns an iterator over a key range of the bucket.  The iterator reads from a
+// read-only transaction, so writes made while iterating are not seen.
+func (db *BadgerDB) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	return db.iterate(bucket, options, true)
+}
+
+func (db *BadgerDB) iterate(bucket []byte, options interfaces.IteratorOptions, values bool) (*BadgerIterator, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	prefix, err := bucketPrefix(bucket)
+	if err != nil {
+		return nil, err
+	}
+	start, limit := options.Range()
+
+	it := new(BadgerIterator)
+	it.prefix = prefix
+	it.lower = append(append([]byte{}, prefix...), start...)
+	if limit != nil {
+		it.upper = append(append([]byte{}, prefix...), limit...)
+	}
+	it.reverse = options.Reverse
+	it.values = values
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = values
+	opts.Reverse = options.Reverse
+	it.txn = db.db.NewTransaction(false)
+	it.iter = it.txn.NewIterator(opts)
+	return it, nil
+}
+
+// BadgerIterator iterates over the keys of one bucket, hiding the bucket prefix from the caller
+type BadgerIterator struct {
+	txn     *badger.Txn
+	iter    *badger.Iterator
+	prefix  []byte // the bucket prefix
+	lower   []byte // first key of the range, with the bucket prefix
+	upper   []byte // end of the range (exclusive) with the bucket prefix, nil for the end of the bucket
+	reverse bool
+	values  bool // false if only the keys are needed
+	started bool
+	done    bool
+	value   []byte
+	err     error
+}
+
+var _ interfaces.IIterator = (*BadgerIterator)(nil)
+
+func (it *BadgerIterator) Next() bool {
+	it.value = nil
+	if it.done {
+		return false
+	}
+
+	switch {
+	case !it.started && it.reverse:
+		// In reverse, Seek finds the last key <= the bound, which is excluded from the range
+		bound := it.upper
+		if bound == nil {
+			bound = interfaces.PrefixLimit(it.prefix)
+		}
+		if bound == nil {
+			it.iter.Rewind()
+		} else {
+			it.iter.Seek(bound)
+		}
+		for bound != nil && it.iter.Valid() && bytes.Compare(it.iter.Item().Key(), bound) >= 0 {
+			it.iter.Next()
+		}
+	case !it.started:
+		it.iter.Seek(it.lower)
+	default:
+		it.iter.Next()
+	}
+	it.started = true
+
+	if !it.iter.Valid() || !it.inRange(it.iter.Item().Key()) {
+		it.done = true
+		return false
+	}
+	if it.values {
+		var err error
+		it.value, err = it.iter.Item().Value()
+		if err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+	}
+	return true
+}
+
+func (it *BadgerIterator) inRange(key []byte) bool {
+	if !bytes.HasPrefix(key, it.prefix) || bytes.Compare(key, it.lower) < 0 {
+		return false
+	}
+	return it.upper == nil || bytes.Compare(key, it.upper) < 0
+}
+
+func (it *BadgerIterator) Key() []byte {
+	if !it.started || it.done {
+		return nil
+	}
+	return it.iter.Item().Key()[len(it.prefix):]
+}
+
+func (it *BadgerIterator) Value() []byte {
+	return it.value
+}
+
+func (it *BadgerIterator) Error() error {
+	return it.err
+}
+
+func (it *BadgerIterator) Release() {
+	if it.iter != nil {
+		it.iter.Close()
+		it.iter = nil
+	}
+	if it.txn != nil {
+		it.txn.Discard()
+		it.txn = nil
+	}
+	it.done = true
+}
+
+func (db *BadgerDB) DoesKeyExist(bucket, key []byte) (bool, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	bKey, err := CombineBucketAndKey(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	err = db.db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(bKey)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package badgerdb_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/badgerdb"
+)
+
+var dbFilename string = "badgerTest.db"
+
+func newTestDB(t *testing.T) interfaces.IDatabase {
+	m, err := NewBadgerDB(dbFilename, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return m
+}
+
+func CleanupTest(t *testing.T, m interfaces.IDatabase) {
+	err := m.Close()
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	os.RemoveAll(dbFilename)
+}
+
+func TestBucketsDontOverlap(t *testing.T) {
+	m := newTestDB(t)
+	defer CleanupTest(t, m)
+
+	// Without the bucket length, key "bc" of bucket "a" would be key "c" of bucket "ab"
+	err := m.Put([]byte("a"), []byte("bc"), primitives.StringToByteSlice("a"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = m.Put([]byte("ab"), []byte("c"), primitives.StringToByteSlice("ab"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, bucket := range []string{"a", "ab"} {
+		keys, err := m.ListAllKeys([]byte(bucket))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if len(keys) != 1 {
+			t.Errorf("Bucket %v has %d keys, expected 1", bucket, len(keys))
+		}
+	}
+
+	err = m.Clear([]byte("a"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	exists, err := m.DoesKeyExist([]byte("ab"), []byte("c"))
+	if err != nil || !exists {
+		t.Errorf("Clearing a bucket removed a key of another bucket")
+	}
+}
+
+func TestListAllBuckets(t *testing.T) {
+	m := newTestDB(t)
+	defer CleanupTest(t, m)
+
+	buckets := [][]byte{[]byte("a"), []byte("ab"), []byte("b"), {0xff, 0xff}}
+	for _, bucket := range buckets {
+		for _, key := range []string{"1", "2", "3"} {
+			err := m.Put(bucket, []byte(key), primitives.StringToByteSlice(key))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+		}
+	}
+
+	list, err := m.ListAllBuckets()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(list) != len(buckets) {
+		t.Fatalf("Found %d buckets, expected %d", len(list), len(buckets))
+	}
+	for _, bucket := range buckets {
+		found := false
+		for _, b := range list {
+			if bytes.Equal(b, bucket) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Bucket %x not listed", bucket)
+		}
+	}
+}
+
+func TestLongBucket(t *testing.T) {
+	m := newTestDB(t)
+	defer CleanupTest(t, m)
+
+	err := m.Put(make([]byte, 256), []byte("key"), primitives.StringToByteSlice("data"))
+	if err == nil {
+		t.Errorf("Buckets longer than 255 bytes should be refused")
+	}
+}
@@ -5,6 +5,7 @@
 package boltdb
 
 import (
+	"bytes"
 	"fmt"
 	"sync"
 
@@ -183,6 +184,41 @@ func (db *BoltDB) PutInBatch(records []interfaces.Record) error {
 	return nil
 }
 
+// PutAndDeleteInBatch makes all the changes in a single read-write transaction, which bolt
+// commits atomically.  The transaction is rolled back on error or panic.
+func (db *BoltDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	db.Sem.Lock()
+	defer db.Sem.Unlock()
+
+	return db.db.Update(func(tx *bolt.Tx) error {
+		for _, v := range deletes {
+			b := tx.Bucket(v.Bucket)
+			if b == nil {
+				continue
+			}
+			err := b.Delete(v.Key)
+			if err != nil {
+				return err
+			}
+		}
+		for _, v := range records {
+			b, err := tx.CreateBucketIfNotExists(v.Bucket)
+			if err != nil {
+				return err
+			}
+			hex, err := v.Data.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			err = b.Put(v.Key, hex)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (db *BoltDB) Clear(bucket []byte) error {
 	db.Sem.Lock()
 	defer db.Sem.Unlock()
@@ -248,6 +284,105 @@ func (db *BoltDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAndC
 	return answer, keys, nil
 }
 
+// Iterate returns an iterator over a key range of the bucket.  The iterator holds a read
+// transaction open until it is released, so don't write to the database from the goroutine
+// that is iterating.
+func (db *BoltDB) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	tx, err := db.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	it := new(BoltIterator)
+	it.tx = tx
+	it.start, it.limit = options.Range()
+	it.reverse = options.Reverse
+	b := tx.Bucket(bucket)
+	if b != nil {
+		it.cursor = b.Cursor()
+	}
+	return it, nil
+}
+
+// BoltIterator walks a bolt cursor over a key range
+type BoltIterator struct {
+	tx      *bolt.Tx
+	cursor  *bolt.Cursor // nil if the bucket doesn't exist or we are done
+	start   []byte
+	limit   []byte
+	reverse bool
+	started bool
+	key     []byte
+	value   []byte
+}
+
+var _ interfaces.IIterator = (*BoltIterator)(nil)
+
+func (it *BoltIterator) Next() bool {
+	if it.cursor == nil {
+		return false
+	}
+
+	var k, v []byte
+	switch {
+	case !it.started && it.reverse:
+		if it.limit == nil {
+			k, v = it.cursor.Last()
+		} else {
+			// Seek finds the first key >= limit, the one before it is the last key in range
+			k, v = it.cursor.Seek(it.limit)
+			if k == nil {
+				k, v = it.cursor.Last()
+			} else {
+				k, v = it.cursor.Prev()
+			}
+		}
+	case !it.started:
+		if it.start == nil {
+			k, v = it.cursor.First()
+		} else {
+			k, v = it.cursor.Seek(it.start)
+		}
+	case it.reverse:
+		k, v = it.cursor.Prev()
+	default:
+		k, v = it.cursor.Next()
+	}
+	it.started = true
+
+	if k == nil || (it.reverse && it.start != nil && bytes.Compare(k, it.start) < 0) ||
+		(!it.reverse && it.limit != nil && bytes.Compare(k, it.limit) >= 0) {
+		it.cursor = nil
+		it.key, it.value = nil, nil
+		return false
+	}
+	it.key, it.value = k, v
+	return true
+}
+
+func (it *BoltIterator) Key() []byte {
+	return it.key
+}
+
+func (it *BoltIterator) Value() []byte {
+	return it.value
+}
+
+func (it *BoltIterator) Error() error {
+	return nil
+}
+
+func (it *BoltIterator) Release() {
+	it.cursor = nil
+	if it.tx != nil {
+		it.tx.Rollback()
+		it.tx = nil
+	}
+}
+
 // We have to make accommodation for many Init functions.  But what we really
 // want here is:
 //
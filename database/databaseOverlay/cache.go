@@ -0,0 +1,165 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"reflect"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// RecordCache is a size-bounded LRU cache of the records read through the overlay.  It holds
+// the decoded objects, so a hit costs no unmarshalling.  The cached objects are shared by every
+// reader and must be treated as read-only.  The size of a record is the size of its marshalled
+// data.
+//
+// A write only invalidates the records it touches.  To keep a read racing with a write from
+// caching the old data, every invalidation bumps a version, and data read from the database is
+// only cached if no invalidation happened since the read started.
+type RecordCache struct {
+	sync.Mutex
+	maxSize int // in bytes
+	size    int
+	lru     *list.List // most recently used at the front
+	items   map[string]*list.Element
+	version uint64
+}
+
+type cachedRecord struct {
+	id     string
+	bucket []byte
+	object interfaces.BinaryMarshallable
+	size   int
+}
+
+// NewRecordCache creates a cache holding records of up to maxSize bytes of marshalled data
+func NewRecordCache(maxSize int) *RecordCache {
+	c := new(RecordCache)
+	c.maxSize = maxSize
+	c.lru = list.New()
+	c.items = make(map[string]*list.Element)
+	return c
+}
+
+// recordID combines the bucket and the key, with the length of the bucket so that buckets
+// can't overlap
+func recordID(bucket, key []byte) string {
+	id := make([]byte, 2, 2+len(bucket)+len(key))
+	binary.BigEndian.PutUint16(id, uint16(len(bucket)))
+	id = append(id, bucket...)
+	return string(append(id, key...))
+}
+
+// Get returns the decoded object of a cached record and the version of the cache.  On a miss
+// the version is to be passed to Add once the record has been read from the database.
+func (c *RecordCache) Get(bucket, key []byte) (interfaces.BinaryMarshallable, uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.items[recordID(bucket, key)]
+	if !ok {
+		CacheMiss(bucket)
+		return nil, c.version, false
+	}
+	c.lru.MoveToFront(e)
+	CacheHit(bucket)
+	return e.Value.(*cachedRecord).object, c.version, true
+}
+
+// Add caches the object decoded from size bytes of a record, unless the cache was invalidated
+// since version
+func (c *RecordCache) Add(bucket, key []byte, object interfaces.BinaryMarshallable, size int, version uint64) {
+	if size > c.maxSize {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if version != c.version {
+		return
+	}
+	id := recordID(bucket, key)
+	if e, ok := c.items[id]; ok {
+		c.remove(e)
+	}
+	r := &cachedRecord{id: id, bucket: append([]byte{}, bucket...), object: object, size: size}
+	c.items[id] = c.lru.PushFront(r)
+	c.size += size
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+	OverlayCacheSize.Set(float64(c.size))
+}
+
+// remove drops a record from the cache.  Must hold the lock.
+func (c *RecordCache) remove(e *list.Element) {
+	r := c.lru.Remove(e).(*cachedRecord)
+	delete(c.items, r.id)
+	c.size -= r.size
+}
+
+// Invalidate drops the records from the cache
+func (c *RecordCache) Invalidate(records ...interfaces.Record) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.version++
+	for _, r := range records {
+		if e, ok := c.items[recordID(r.Bucket, r.Key)]; ok {
+			c.remove(e)
+		}
+	}
+	OverlayCacheSize.Set(float64(c.size))
+}
+
+// InvalidateBucket drops all the records of a bucket from the cache
+func (c *RecordCache) InvalidateBucket(bucket []byte) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.version++
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if bytes.Equal(e.Value.(*cachedRecord).bucket, bucket) {
+			c.remove(e)
+		}
+		e = next
+	}
+	OverlayCacheSize.Set(float64(c.size))
+}
+
+// Len returns the number of cached records
+func (c *RecordCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.lru.Len()
+}
+
+// sameType tells if a cached object can stand in for the destination of a read
+func sameType(cached, destination interfaces.BinaryMarshallable) bool {
+	return reflect.TypeOf(cached) == reflect.TypeOf(destination)
+}
+
+// sizeMarshaller passes the data read from the database on to the destination, recording how
+// many bytes it decoded
+type sizeMarshaller struct {
+	interfaces.BinaryMarshallable
+	size int
+}
+
+func (c *sizeMarshaller) UnmarshalBinaryData(data []byte) ([]byte, error) {
+	rest, err := c.BinaryMarshallable.UnmarshalBinaryData(data)
+	c.size = len(data) - len(rest)
+	return rest, err
+}
+
+func (c *sizeMarshaller) UnmarshalBinary(data []byte) error {
+	_, err := c.UnmarshalBinaryData(data)
+	return err
+}
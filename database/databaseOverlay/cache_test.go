@@ -0,0 +1,167 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/mapdb"
+)
+
+func newCachedOverlay(cacheSize int) (*Overlay, *mapdb.MapDB) {
+	m := new(mapdb.MapDB)
+	m.Init(nil)
+	return NewOverlayWithCache(m, cacheSize), m
+}
+
+func getString(t *testing.T, dbo *Overlay, bucket, key string) string {
+	resp, err := dbo.Get([]byte(bucket), []byte(key), new(primitives.ByteSlice))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if resp == nil {
+		return ""
+	}
+	return string(resp.(*primitives.ByteSlice).Bytes)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	dbo, _ := newCachedOverlay(1024)
+
+	put := func(bucket, key, value string) {
+		err := dbo.Put([]byte(bucket), []byte(key), primitives.StringToByteSlice(value))
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	put("b", "k", "one")
+	if getString(t, dbo, "b", "k") != "one" || getString(t, dbo, "b", "k") != "one" {
+		t.Fatalf("Wrong value read")
+	}
+	if dbo.Cache.Len() != 1 {
+		t.Errorf("Expected 1 cached record, got %d", dbo.Cache.Len())
+	}
+
+	put("b", "k", "two")
+	if v := getString(t, dbo, "b", "k"); v != "two" {
+		t.Errorf("Put did not invalidate the cache, read %v", v)
+	}
+
+	err := dbo.PutInBatch([]interfaces.Record{{[]byte("b"), []byte("k"), primitives.StringToByteSlice("three")}})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if v := getString(t, dbo, "b", "k"); v != "three" {
+		t.Errorf("PutInBatch did not invalidate the cache, read %v", v)
+	}
+
+	err = dbo.Delete([]byte("b"), []byte("k"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if v := getString(t, dbo, "b", "k"); v != "" {
+		t.Errorf("Delete did not invalidate the cache, read %v", v)
+	}
+
+	put("b", "k", "four")
+	put("c", "k", "other")
+	getString(t, dbo, "b", "k")
+	getString(t, dbo, "c", "k")
+	err = dbo.Clear([]byte("b"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if v := getString(t, dbo, "b", "k"); v != "" {
+		t.Errorf("Clear did not invalidate the cache, read %v", v)
+	}
+	if dbo.Cache.Len() != 1 {
+		t.Errorf("Clear should only drop the records of its bucket, %d records cached", dbo.Cache.Len())
+	}
+}
+
+func TestCacheMultiBatchInvalidation(t *testing.T) {
+	dbo, _ := newCachedOverlay(1024)
+
+	dbo.Put([]byte("b"), []byte("put"), primitives.StringToByteSlice("old"))
+	dbo.Put([]byte("b"), []byte("deleted"), primitives.StringToByteSlice("old"))
+	getString(t, dbo, "b", "put")
+	getString(t, dbo, "b", "deleted")
+
+	dbo.StartMultiBatch()
+	dbo.PutInMultiBatch([]interfaces.Record{{[]byte("b"), []byte("put"), primitives.StringToByteSlice("new")}})
+	dbo.DeleteInMultiBatch([]byte("b"), []byte("deleted"))
+	err := dbo.ExecuteMultiBatch()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if v := getString(t, dbo, "b", "put"); v != "new" {
+		t.Errorf("Multi batch put did not invalidate the cache, read %v", v)
+	}
+	if v := getString(t, dbo, "b", "deleted"); v != "" {
+		t.Errorf("Multi batch delete did not invalidate the cache, read %v", v)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	dbo, _ := newCachedOverlay(10)
+
+	for _, key := range []string{"1", "2", "3"} {
+		dbo.Put([]byte("b"), []byte(key), primitives.StringToByteSlice("1234"))
+		getString(t, dbo, "b", key)
+	}
+	// Only two records of 4 bytes fit in 10 bytes
+	if dbo.Cache.Len() != 2 {
+		t.Errorf("Expected 2 cached records, got %d", dbo.Cache.Len())
+	}
+	if _, _, ok := dbo.Cache.Get([]byte("b"), []byte("1")); ok {
+		t.Errorf("The least recently used record should have been evicted")
+	}
+}
+
+func TestCacheReturnsDecodedRecord(t *testing.T) {
+	dbo, _ := newCachedOverlay(1024)
+
+	// 32 bytes, so that the record also decodes as a hash
+	dbo.Put([]byte("b"), []byte("k"), primitives.StringToByteSlice("0123456789abcdef0123456789abcdef"))
+	first, _ := dbo.Get([]byte("b"), []byte("k"), new(primitives.ByteSlice))
+
+	// A hit returns the cached object without decoding into the destination
+	dst := new(primitives.ByteSlice)
+	second, err := dbo.Get([]byte("b"), []byte("k"), dst)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if second != first {
+		t.Errorf("A hit should return the cached object")
+	}
+	if dst.Bytes != nil {
+		t.Errorf("A hit should not decode into the destination")
+	}
+
+	// A destination of another type reads the record from the database
+	hash, err := dbo.Get([]byte("b"), []byte("k"), new(primitives.Hash))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok := hash.(*primitives.Hash); !ok {
+		t.Errorf("Expected a hash, got %T", hash)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	dbo, _ := newCachedOverlay(0)
+	if dbo.Cache != nil {
+		t.Errorf("A cache size of 0 should disable the cache")
+	}
+	dbo.Put([]byte("b"), []byte("k"), primitives.StringToByteSlice("data"))
+	if v := getString(t, dbo, "b", "k"); v != "data" {
+		t.Errorf("Wrong value read %v", v)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay
+
+import (
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// copyBatchSize is the number of records copied per batch
+const copyBatchSize = 10000
+
+// AllBuckets returns all the buckets of a database.  LevelDB can't list its buckets, so for it
+// we list the buckets factomd uses: the fixed buckets, plus the entry and entry block number
+// buckets of every chain with a chain head.
+func AllBuckets(db interfaces.IDatabase) ([][]byte, error) {
+	buckets, err := db.ListAllBuckets()
+	if err == nil {
+		return buckets, nil
+	}
+
+	buckets = [][]byte{}
+	for bucket := range ConstantNamesMap {
+		buckets = append(buckets, []byte(bucket))
+	}
+	chainIDs, err := db.ListAllKeys(CHAIN_HEAD)
+	if err != nil {
+		return nil, err
+	}
+	for _, chainID := range chainIDs {
+		buckets = append(buckets, chainID)
+		numberBucket := append([]byte{}, ENTRYBLOCK_CHAIN_NUMBER...)
+		buckets = append(buckets, append(numberBucket, chainID...))
+	}
+	return buckets, nil
+}
+
+// CopyDatabase copies every record of the source database into the destination database, as
+// is.  copied, if not nil, is called after each bucket.  Returns the number of records copied.
+func CopyDatabase(from interfaces.IDatabase, to interfaces.IDatabase, copied func(bucket []byte)) (int, error) {
+	buckets, err := AllBuckets(from)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, bucket := range buckets {
+		it, err := from.Iterate(bucket, interfaces.IteratorOptions{})
+		if err != nil {
+			return total, err
+		}
+
+		batch := []interfaces.Record{}
+		for it.Next() {
+			key := make([]byte, len(it.Key()))
+			copy(key, it.Key())
+			data := new(primitives.ByteSlice)
+			err = data.UnmarshalBinary(it.Value())
+			if err != nil {
+				break
+			}
+			batch = append(batch, interfaces.Record{Bucket: bucket, Key: key, Data: data})
+
+			if len(batch) == copyBatchSize {
+				err = to.PutInBatch(batch)
+				if err != nil {
+					break
+				}
+				total += len(batch)
+				batch = []interfaces.Record{}
+			}
+		}
+		if err == nil {
+			err = it.Error()
+		}
+		it.Release()
+		if err == nil && len(batch) > 0 {
+			err = to.PutInBatch(batch)
+			total += len(batch)
+		}
+		if err != nil {
+			return total, err
+		}
+		if copied != nil {
+			copied(bucket)
+		}
+	}
+	return total, nil
+}
@@ -84,17 +84,17 @@ func (db *Overlay) FetchAllEntryIDsByChainID(chainID interfaces.IHash) ([]interf
 }
 
 func (db *Overlay) FetchAllEntryIDs() ([]interfaces.IHash, error) {
-	ids, err := db.ListAllKeys(ENTRY)
-	if err != nil {
-		return nil, err
-	}
 	entries := []interfaces.IHash{}
-	for _, id := range ids {
+	err := db.ForEachKey(ENTRY, interfaces.IteratorOptions{}, func(id []byte) error {
 		h, err := primitives.NewShaHash(id)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		entries = append(entries, h)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return entries, nil
 }
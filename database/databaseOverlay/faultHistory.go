@@ -0,0 +1,87 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// faultRecordKey is the key of a record of the fault history, its big endian sequence number
+func faultRecordKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// SaveFaultRecord saves, or replaces, the record of the fault history with sequence number id
+func (db *Overlay) SaveFaultRecord(id uint64, record []byte) error {
+	bs := new(primitives.ByteSlice)
+	bs.Bytes = record
+	return db.Put(FAULT_HISTORY, faultRecordKey(id), bs)
+}
+
+// FetchFaultRecords returns up to count records of the fault history, all of them if count is 0,
+// starting at sequence number from and going up, or going down if reverse
+func (db *Overlay) FetchFaultRecords(from uint64, count int, reverse bool) ([][]byte, error) {
+	options := interfaces.IteratorOptions{Start: faultRecordKey(from)}
+	if reverse {
+		options = interfaces.IteratorOptions{Reverse: true}
+		if from < math.MaxUint64 {
+			options.Limit = faultRecordKey(from + 1)
+		}
+	}
+	it, err := db.Iterate(FAULT_HISTORY, options)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+
+	records := [][]byte{}
+	for (count == 0 || len(records) < count) && it.Next() {
+		if len(it.Key()) != 8 {
+			continue
+		}
+		bs := new(primitives.ByteSlice)
+		err = bs.UnmarshalBinary(append([]byte{}, it.Value()...))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, bs.Bytes)
+	}
+	return records, it.Error()
+}
+
+// FetchNextFaultRecordID returns the sequence number following the last record of the fault history
+func (db *Overlay) FetchNextFaultRecordID() (uint64, error) {
+	it, err := db.Iterate(FAULT_HISTORY, interfaces.IteratorOptions{Reverse: true})
+	if err != nil {
+		return 0, err
+	}
+	defer it.Release()
+
+	for it.Next() {
+		if len(it.Key()) == 8 {
+			return binary.BigEndian.Uint64(it.Key()) + 1, nil
+		}
+	}
+	return 0, it.Error()
+}
+
+// PruneFaultRecords deletes the records of the fault history with a sequence number below before
+func (db *Overlay) PruneFaultRecords(before uint64) error {
+	var deletes []interfaces.Record
+	err := db.ForEachKey(FAULT_HISTORY, interfaces.IteratorOptions{Limit: faultRecordKey(before)}, func(key []byte) error {
+		deletes = append(deletes, interfaces.Record{FAULT_HISTORY, append([]byte{}, key...), nil})
+		return nil
+	})
+	if err != nil || len(deletes) == 0 {
+		return err
+	}
+	return db.PutAndDeleteInBatch(nil, deletes)
+}
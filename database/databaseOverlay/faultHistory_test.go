@@ -0,0 +1,52 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay_test
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/FactomProject/factomd/testHelper"
+)
+
+func TestFaultRecords(t *testing.T) {
+	dbo := CreateEmptyTestDatabaseOverlay()
+
+	next, err := dbo.FetchNextFaultRecordID()
+	if err != nil || next != 0 {
+		t.Fatalf("Next record %d of an empty history, %v", next, err)
+	}
+	for i := uint64(0); i < 300; i++ {
+		if err := dbo.SaveFaultRecord(i, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if next, _ = dbo.FetchNextFaultRecordID(); next != 300 {
+		t.Errorf("Next record %d, expected 300", next)
+	}
+
+	records, err := dbo.FetchFaultRecords(256, 3, false)
+	if err != nil || len(records) != 3 || string(records[0]) != "256" || string(records[2]) != "258" {
+		t.Errorf("Records from 256 up %q, %v", records, err)
+	}
+	records, err = dbo.FetchFaultRecords(256, 3, true)
+	if err != nil || len(records) != 3 || string(records[0]) != "256" || string(records[2]) != "254" {
+		t.Errorf("Records from 256 down %q, %v", records, err)
+	}
+	if records, _ = dbo.FetchFaultRecords(0, 0, false); len(records) != 300 {
+		t.Errorf("Found %d records, expected 300", len(records))
+	}
+
+	if err := dbo.PruneFaultRecords(100); err != nil {
+		t.Fatalf("%v", err)
+	}
+	records, _ = dbo.FetchFaultRecords(0, 0, false)
+	if len(records) != 200 || string(records[0]) != "100" {
+		t.Errorf("Found %d records from %q after pruning, expected 200 from 100", len(records), records[0])
+	}
+	if next, _ = dbo.FetchNextFaultRecordID(); next != 300 {
+		t.Errorf("Next record %d after pruning, expected 300", next)
+	}
+}
@@ -1,6 +1,8 @@
 package databaseOverlay
 
 import (
+	"bytes"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -119,6 +121,22 @@ var (
 		Name: "factomd_database_overlay_gets_paidfor",
 		Help: "Counts gets from the database",
 	})
+
+	// Record cache
+	OverlayCacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_database_overlay_cache_hits",
+		Help: "Counts gets answered by the overlay cache, by bucket",
+	}, []string{"bucket"})
+
+	OverlayCacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_database_overlay_cache_misses",
+		Help: "Counts gets the overlay cache sent to the database, by bucket",
+	}, []string{"bucket"})
+
+	OverlayCacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factomd_database_overlay_cache_size_bytes",
+		Help: "Size of the data held by the overlay cache",
+	})
 )
 
 var registered = false
@@ -154,6 +172,31 @@ func RegisterPrometheus() {
 	prometheus.MustRegister(OverlayDBGetsDirBlockInfoSecondary)
 	prometheus.MustRegister(OverlayDBGetsInvludeIn)
 	prometheus.MustRegister(OverlayDBGetsPaidFor)
+
+	prometheus.MustRegister(OverlayCacheHits)
+	prometheus.MustRegister(OverlayCacheMisses)
+	prometheus.MustRegister(OverlayCacheSize)
+}
+
+// BucketLabel names a bucket for the metrics.  Entries are stored in one bucket per chain, and
+// entry block heights in one bucket per chain, so those are grouped to keep the number of
+// labels bounded.
+func BucketLabel(bucket []byte) string {
+	if name, ok := ConstantNamesMap[string(bucket)]; ok {
+		return name
+	}
+	if bytes.HasPrefix(bucket, ENTRYBLOCK_CHAIN_NUMBER) {
+		return ConstantNamesMap[string(ENTRYBLOCK_CHAIN_NUMBER)]
+	}
+	return "ChainEntries"
+}
+
+func CacheHit(bucket []byte) {
+	OverlayCacheHits.WithLabelValues(BucketLabel(bucket)).Inc()
+}
+
+func CacheMiss(bucket []byte) {
+	OverlayCacheMisses.WithLabelValues(BucketLabel(bucket)).Inc()
 }
 
 func GetBucket(bucket []byte) {
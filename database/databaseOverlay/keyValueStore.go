@@ -13,7 +13,7 @@ func (db *Overlay) SaveKeyValueStore(kvs interfaces.BinaryMarshallable, key []by
 
 	batch = append(batch, interfaces.Record{KEY_VALUE_STORE, key, kvs})
 
-	err := db.DB.PutInBatch(batch)
+	err := db.PutInBatch(batch)
 	if err != nil {
 		return err
 	}
@@ -69,6 +69,9 @@ var (
 	PAID_FOR = []byte("PaidFor")
 
 	KEY_VALUE_STORE = []byte("KeyValueStore")
+
+	// Server faults and elections seen by the node
+	FAULT_HISTORY = []byte("FaultHistory")
 )
 
 var ConstantNamesMap map[string]string
@@ -108,6 +111,7 @@ func init() {
 
 	ConstantNamesMap[string(PAID_FOR)] = "PaidFor"
 	ConstantNamesMap[string(KEY_VALUE_STORE)] = "KeyValueStore"
+	ConstantNamesMap[string(FAULT_HISTORY)] = "FaultHistory"
 
 	RegisterPrometheus()
 }
@@ -118,9 +122,13 @@ type Overlay struct {
 	ExportData     bool
 	ExportDataPath string
 
-	BatchSemaphore sync.Mutex
-	MultiBatch     []interfaces.Record
-	BlockExtractor blockExtractor.BlockExtractor
+	BatchSemaphore    sync.Mutex
+	MultiBatch        []interfaces.Record
+	MultiBatchDeletes []interfaces.Record
+	BlockExtractor    blockExtractor.BlockExtractor
+
+	// Cache of the records read from DB, nil if caching is disabled
+	Cache *RecordCache
 }
 
 var _ interfaces.IDatabase = (*Overlay)(nil)
@@ -136,28 +144,58 @@ func (db *Overlay) SetExportData(path string) {
 	db.BlockExtractor.DataStorePath = path
 }
 
+// StartMultiBatch starts collecting changes that ExecuteMultiBatch then writes atomically.  Every
+// StartMultiBatch must be followed by either ExecuteMultiBatch or AbortMultiBatch.
 func (db *Overlay) StartMultiBatch() {
 	db.BatchSemaphore.Lock()
 	db.MultiBatch = make([]interfaces.Record, 0, 128)
+	db.MultiBatchDeletes = nil
 }
 
 func (db *Overlay) PutInMultiBatch(records []interfaces.Record) {
 	db.MultiBatch = append(db.MultiBatch, records...)
 }
 
+// DeleteInMultiBatch deletes a key when the multi batch is executed, before any of the puts
+func (db *Overlay) DeleteInMultiBatch(bucket, key []byte) {
+	db.MultiBatchDeletes = append(db.MultiBatchDeletes, interfaces.Record{bucket, key, nil})
+}
+
+// ExecuteMultiBatch writes all the changes of the multi batch, all or nothing
 func (db *Overlay) ExecuteMultiBatch() error {
 	defer func() {
 		db.MultiBatch = nil
+		db.MultiBatchDeletes = nil
 		db.BatchSemaphore.Unlock()
 	}()
-	return db.PutInBatch(db.MultiBatch)
+	return db.PutAndDeleteInBatch(db.MultiBatch, db.MultiBatchDeletes)
+}
+
+// AbortMultiBatch discards the changes of the multi batch without writing any of them
+func (db *Overlay) AbortMultiBatch() {
+	db.MultiBatch = nil
+	db.MultiBatchDeletes = nil
+	db.BatchSemaphore.Unlock()
 }
 
 func (db *Overlay) PutInBatch(records []interfaces.Record) error {
+	if db.Cache != nil {
+		defer db.Cache.Invalidate(records...)
+	}
 	return db.DB.PutInBatch(records)
 }
 
+func (db *Overlay) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	if db.Cache != nil {
+		defer db.Cache.Invalidate(append(deletes, records...)...)
+	}
+	return db.DB.PutAndDeleteInBatch(records, deletes)
+}
+
 func (db *Overlay) Put(bucket, key []byte, data interfaces.BinaryMarshallable) error {
+	if db.Cache != nil {
+		defer db.Cache.Invalidate(interfaces.Record{bucket, key, nil})
+	}
 	return db.DB.Put(bucket, key, data)
 }
 
@@ -169,12 +207,83 @@ func (db *Overlay) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAnd
 	return db.DB.GetAll(bucket, sample)
 }
 
+func (db *Overlay) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	return db.DB.Iterate(bucket, options)
+}
+
+// ForEach unmarshals the records of a key range of the bucket one at a time and calls f on each,
+// stopping at the first error.  The key is only valid for the duration of the call.
+func (db *Overlay) ForEach(bucket []byte, options interfaces.IteratorOptions, sample interfaces.BinaryMarshallableAndCopyable, f func(key []byte, data interfaces.BinaryMarshallableAndCopyable) error) error {
+	it, err := db.Iterate(bucket, options)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	for it.Next() {
+		// Copy the value, the iterator may reuse its buffer and the data may keep references to it
+		v := make([]byte, len(it.Value()))
+		copy(v, it.Value())
+		data := sample.New()
+		err = data.UnmarshalBinary(v)
+		if err != nil {
+			return err
+		}
+		err = f(it.Key(), data)
+		if err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// ForEachKey calls f on the keys of a key range of the bucket, stopping at the first error.
+// The key is only valid for the duration of the call.
+func (db *Overlay) ForEachKey(bucket []byte, options interfaces.IteratorOptions, f func(key []byte) error) error {
+	it, err := db.Iterate(bucket, options)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	for it.Next() {
+		err = f(it.Key())
+		if err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// Get reads a record into destination.  With the cache enabled a hit returns the cached object
+// instead, leaving destination untouched, so callers must use the returned object and treat it
+// as read-only.
 func (db *Overlay) Get(bucket, key []byte, destination interfaces.BinaryMarshallable) (interfaces.BinaryMarshallable, error) {
 	GetBucket(bucket)
-	return db.DB.Get(bucket, key, destination)
+	if db.Cache == nil {
+		return db.DB.Get(bucket, key, destination)
+	}
+
+	cached, version, ok := db.Cache.Get(bucket, key)
+	if ok && sameType(cached, destination) {
+		return cached, nil
+	}
+
+	sized := &sizeMarshaller{BinaryMarshallable: destination}
+	resp, err := db.DB.Get(bucket, key, sized)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	if sized.size > 0 {
+		db.Cache.Add(bucket, key, destination, sized.size, version)
+	}
+	return destination, nil
 }
 
 func (db *Overlay) Clear(bucket []byte) error {
+	if db.Cache != nil {
+		defer db.Cache.InvalidateBucket(bucket)
+	}
 	return db.DB.Clear(bucket)
 }
 
@@ -190,6 +299,9 @@ func (db *Overlay) Trim() {
 }
 
 func (db *Overlay) Delete(bucket, key []byte) error {
+	if db.Cache != nil {
+		defer db.Cache.Invalidate(interfaces.Record{bucket, key, nil})
+	}
 	return db.DB.Delete(bucket, key)
 }
 
@@ -199,6 +311,16 @@ func NewOverlay(db interfaces.IDatabase) *Overlay {
 	return answer
 }
 
+// NewOverlayWithCache creates an overlay caching up to cacheSize bytes of the records it reads.
+// A cacheSize of 0 or less disables the cache.
+func NewOverlayWithCache(db interfaces.IDatabase, cacheSize int) *Overlay {
+	answer := NewOverlay(db)
+	if cacheSize > 0 {
+		answer.Cache = NewRecordCache(cacheSize)
+	}
+	return answer
+}
+
 func (db *Overlay) FetchBlockByHeight(heightBucket []byte, blockBucket []byte, blockHeight uint32, dst interfaces.DatabaseBatchable) (interfaces.DatabaseBatchable, error) {
 	index, err := db.FetchBlockIndexByHeight(heightBucket, blockHeight)
 	if err != nil {
@@ -258,7 +380,11 @@ func (db *Overlay) FetchBlock(bucket []byte, key interfaces.IHash, dst interface
 }
 
 func (db *Overlay) FetchAllBlocksFromBucket(bucket []byte, sample interfaces.BinaryMarshallableAndCopyable) ([]interfaces.BinaryMarshallableAndCopyable, error) {
-	answer, _, err := db.GetAll(bucket, sample)
+	answer := []interfaces.BinaryMarshallableAndCopyable{}
+	err := db.ForEach(bucket, interfaces.IteratorOptions{}, sample, func(key []byte, data interfaces.BinaryMarshallableAndCopyable) error {
+		answer = append(answer, data)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -266,22 +392,22 @@ func (db *Overlay) FetchAllBlocksFromBucket(bucket []byte, sample interfaces.Bin
 }
 
 func (db *Overlay) FetchAllBlockKeysFromBucket(bucket []byte) ([]interfaces.IHash, error) {
-	entries, err := db.ListAllKeys(bucket)
-	if err != nil {
-		return nil, err
-	}
-	answer := make([]interfaces.IHash, len(entries))
-	for i := range entries {
-		h, err := primitives.NewShaHash(entries[i])
+	answer := []interfaces.IHash{}
+	err := db.ForEachKey(bucket, interfaces.IteratorOptions{}, func(key []byte) error {
+		h, err := primitives.NewShaHash(key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		// be careful to not assign a nil hash to an IHash
 		if h != nil { // should always happen
-			answer[i] = h
+			answer = append(answer, h)
 		} else {
 			fmt.Fprintf(os.Stderr, "Overlay.FetchAllBlockKeysFromBucket() unexpected nil")
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 	return answer, nil
 }
@@ -12,6 +12,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"testing"
@@ -21,6 +22,7 @@ import (
 	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/common/primitives/random"
 	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/leveldb"
 	"github.com/FactomProject/factomd/database/mapdb"
 	"github.com/FactomProject/factomd/testHelper"
 )
@@ -632,3 +634,208 @@ func TestFetchAllBlockKeysFromBucket(t *testing.T) {
 		}
 	}
 }
+
+// crashingDB simulates the node dying while the backend writes a batch: every record the backend
+// is given counts as a put, and the put number crashAt panics, once the backend has already taken
+// the records before it.  An overlay writing the multi batch in several writes would leave the
+// earlier writes in the database.
+type crashingDB struct {
+	interfaces.IDatabase
+	crashAt int // -1 to never crash
+	puts    int
+}
+
+func (db *crashingDB) count() {
+	if db.puts == db.crashAt {
+		panic("injected crash")
+	}
+	db.puts++
+}
+
+// crashingRecord counts the record as a put when the backend marshals it into its batch
+type crashingRecord struct {
+	interfaces.BinaryMarshallable
+	db *crashingDB
+}
+
+func (r *crashingRecord) MarshalBinary() ([]byte, error) {
+	r.db.count()
+	return r.BinaryMarshallable.MarshalBinary()
+}
+
+func (db *crashingDB) wrap(records []interfaces.Record) []interfaces.Record {
+	wrapped := make([]interfaces.Record, len(records))
+	for i, r := range records {
+		wrapped[i] = interfaces.Record{r.Bucket, r.Key, &crashingRecord{r.Data, db}}
+	}
+	return wrapped
+}
+
+func (db *crashingDB) Put(bucket, key []byte, data interfaces.BinaryMarshallable) error {
+	db.count()
+	return db.IDatabase.Put(bucket, key, data)
+}
+
+func (db *crashingDB) PutInBatch(records []interfaces.Record) error {
+	return db.IDatabase.PutInBatch(db.wrap(records))
+}
+
+func (db *crashingDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	return db.IDatabase.PutAndDeleteInBatch(db.wrap(records), deletes)
+}
+
+// queueBlockSet adds all the blocks and entries of a block set to the multi batch, the same way a
+// DBState is saved
+func queueBlockSet(t *testing.T, dbo *Overlay, set *testHelper.BlockSet) {
+	if err := dbo.ProcessABlockMultiBatch(set.ABlock); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbo.ProcessEBlockMultiBatch(set.EBlock, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbo.ProcessEBlockMultiBatch(set.AnchorEBlock, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbo.ProcessECBlockMultiBatch(set.ECBlock, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := dbo.ProcessFBlockMultiBatch(set.FBlock); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range set.Entries {
+		if err := dbo.InsertEntryMultiBatch(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dbo.ProcessDBlockMultiBatch(set.DBlock); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// blockSetSaved returns how many of the blocks and entries of the set are in the database
+func blockSetSaved(t *testing.T, dbo *Overlay, set *testHelper.BlockSet) (found int, total int) {
+	height := set.DBlock.GetDatabaseHeight()
+	check := func(present bool, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		total++
+		if present {
+			found++
+		}
+	}
+
+	dblock, err := dbo.FetchDBlockByHeight(height)
+	check(dblock != nil, err)
+	ablock, err := dbo.FetchABlockByHeight(height)
+	check(ablock != nil, err)
+	fblock, err := dbo.FetchFBlockByHeight(height)
+	check(fblock != nil, err)
+	ecblock, err := dbo.FetchECBlockByHeight(height)
+	check(ecblock != nil, err)
+	eblock, err := dbo.FetchEBlock(set.EBlock.DatabasePrimaryIndex())
+	check(eblock != nil, err)
+	for _, entry := range set.Entries {
+		e, err := dbo.FetchEntry(entry.GetHash())
+		check(e != nil, err)
+	}
+	return
+}
+
+func TestMultiBatchCrashIsAtomic(t *testing.T) {
+	testHelper.MakeSureAnchorValidationKeyIsPresent()
+
+	dir, err := ioutil.TempDir("", "multibatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var crashing *crashingDB
+	open := func() *Overlay {
+		db, err := leveldb.NewLevelDB(dir, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crashing = &crashingDB{IDatabase: db, crashAt: -1}
+		return NewOverlay(crashing)
+	}
+
+	first := testHelper.CreateTestBlockSet(nil)
+	second := testHelper.CreateTestBlockSet(first)
+
+	dbo := open()
+	dbo.StartMultiBatch()
+	queueBlockSet(t, dbo, first)
+	if err := dbo.ExecuteMultiBatch(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Find out how many records saving the second block set writes
+	dbo.StartMultiBatch()
+	queueBlockSet(t, dbo, second)
+	records := len(dbo.MultiBatch)
+	dbo.AbortMultiBatch()
+
+	for _, crashAt := range []int{1, records / 2, records - 1} {
+		dbo.StartMultiBatch()
+		queueBlockSet(t, dbo, second)
+		crashing.puts, crashing.crashAt = 0, crashAt
+
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Injected crash at record %d did not happen", crashAt)
+				}
+			}()
+			dbo.ExecuteMultiBatch()
+		}()
+		if crashing.puts != crashAt {
+			t.Errorf("The backend took %d records before the crash, expected %d", crashing.puts, crashAt)
+		}
+
+		// Restart the database, as a node would after a crash
+		dbo.Close()
+		dbo = open()
+
+		if found, total := blockSetSaved(t, dbo, first); found != total {
+			t.Errorf("Crash at record %d: only %d of %d records of the previous height remain", crashAt, found, total)
+		}
+		if found, _ := blockSetSaved(t, dbo, second); found != 0 {
+			t.Errorf("Crash at record %d: %d records of the crashed height were saved", crashAt, found)
+		}
+	}
+
+	dbo.StartMultiBatch()
+	queueBlockSet(t, dbo, second)
+	if err := dbo.ExecuteMultiBatch(); err != nil {
+		t.Fatal(err)
+	}
+	if found, total := blockSetSaved(t, dbo, second); found != total {
+		t.Errorf("Only %d of %d records were saved", found, total)
+	}
+	dbo.Close()
+}
+
+func TestAbortMultiBatch(t *testing.T) {
+	dbo := NewOverlay(new(mapdb.MapDB))
+	set := testHelper.CreateTestBlockSet(nil)
+
+	dbo.StartMultiBatch()
+	queueBlockSet(t, dbo, set)
+	dbo.AbortMultiBatch()
+
+	if found, _ := blockSetSaved(t, dbo, set); found != 0 {
+		t.Errorf("%d records of an aborted multi batch were saved", found)
+	}
+
+	// The multi batch must be usable again after an abort
+	dbo.StartMultiBatch()
+	queueBlockSet(t, dbo, set)
+	if err := dbo.ExecuteMultiBatch(); err != nil {
+		t.Fatal(err)
+	}
+	if found, total := blockSetSaved(t, dbo, set); found != total {
+		t.Errorf("Only %d of %d records were saved", found, total)
+	}
+}
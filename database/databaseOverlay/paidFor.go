@@ -15,7 +15,7 @@ func (db *Overlay) SavePaidFor(entry, ecEntry interfaces.IHash) error {
 
 	batch = append(batch, interfaces.Record{PAID_FOR, entry.Bytes(), ecEntry})
 
-	err := db.DB.PutInBatch(batch)
+	err := db.PutInBatch(batch)
 	if err != nil {
 		return err
 	}
@@ -96,7 +96,7 @@ func (db *Overlay) SavePaidForMultiFromBlock(block interfaces.IEntryCreditBlock,
 		return nil
 	}
 
-	err := db.DB.PutInBatch(batch)
+	err := db.PutInBatch(batch)
 	if err != nil {
 		return err
 	}
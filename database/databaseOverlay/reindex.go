@@ -0,0 +1,422 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/entryCreditBlock"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// IndexRebuildKey is the key of the progress record of an index rebuild in the KEY_VALUE_STORE
+var IndexRebuildKey = []byte("IndexRebuild")
+
+// reindexCheckpoint is the number of heights between two saves of the rebuild progress
+const reindexCheckpoint = 1000
+
+// The indexes rebuilt in parallel once the directory block indexes are rebuilt
+const (
+	IndexABlock       = "ABlock"
+	IndexFBlock       = "FBlock"
+	IndexECBlock      = "ECBlock"
+	IndexEBlock       = "EBlock"
+	IndexDirBlockInfo = "DirBlockInfo"
+)
+
+var reindexedBlocks = []string{IndexABlock, IndexFBlock, IndexECBlock, IndexEBlock}
+
+// IndexBuckets are the buckets derived from the primary block and entry buckets, dropped by
+// RebuildIndexes.  The per chain ENTRYBLOCK_CHAIN_NUMBER buckets are dropped too.
+var IndexBuckets = [][]byte{
+	DIRECTORYBLOCK_NUMBER, DIRECTORYBLOCK_SECONDARYINDEX,
+	ADMINBLOCK_NUMBER, ADMINBLOCK_SECONDARYINDEX,
+	FACTOIDBLOCK_NUMBER, FACTOIDBLOCK_SECONDARYINDEX,
+	ENTRYCREDITBLOCK_NUMBER, ENTRYCREDITBLOCK_SECONDARYINDEX,
+	ENTRYBLOCK_SECONDARYINDEX,
+	ENTRY,
+	INCLUDED_IN,
+	PAID_FOR,
+	DIRBLOCKINFO, DIRBLOCKINFO_NUMBER, DIRBLOCKINFO_SECONDARYINDEX,
+}
+
+// IndexRebuild is the progress of a rebuild, saved so an interrupted rebuild resumes where it
+// stopped instead of starting over
+type IndexRebuild struct {
+	Dropped        bool              // the index buckets were dropped
+	DBlocksIndexed bool              // the directory block indexes were rebuilt
+	Top            uint32            // height of the highest directory block
+	Next           map[string]uint32 // next height to index, by index
+	Done           map[string]bool
+}
+
+// IndexRebuildProgress is reported as the rebuild goes
+type IndexRebuildProgress struct {
+	Index  string // DBlock, or one of the Index constants
+	Height uint32 // last height indexed, for DBlock the number of directory blocks indexed
+	Top    uint32 // highest height, for DBlock the number of directory blocks
+	Done   bool
+}
+
+// FetchIndexRebuild returns the progress of an interrupted rebuild, or nil if there is none
+func (db *Overlay) FetchIndexRebuild() (*IndexRebuild, error) {
+	bs := new(primitives.ByteSlice)
+	resp, err := db.FetchKeyValueStore(IndexRebuildKey, bs)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, nil
+	}
+	r := new(IndexRebuild)
+	err = json.Unmarshal(bs.Bytes, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (db *Overlay) saveIndexRebuild(r *IndexRebuild) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	bs := new(primitives.ByteSlice)
+	bs.Bytes = data
+	return db.SaveKeyValueStore(bs, IndexRebuildKey)
+}
+
+// RebuildIndexes drops every index derived from the primary block and entry buckets and builds
+// them again: the number and secondary indexes of the blocks, ENTRY, INCLUDED_IN, PAID_FOR and
+// DIRBLOCKINFO.  The directory block indexes are rebuilt first, then every block type is
+// indexed in parallel, walking the directory blocks up.  The progress is saved as it goes, and
+// calling RebuildIndexes again after an interruption resumes the rebuild.
+//
+// progress, which can be nil, is called from several goroutines.  Nothing else may write to
+// the database while the indexes are rebuilt.
+func (db *Overlay) RebuildIndexes(progress func(IndexRebuildProgress)) error {
+	if progress == nil {
+		progress = func(IndexRebuildProgress) {}
+	}
+	r, err := db.FetchIndexRebuild()
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		r = new(IndexRebuild)
+		r.Next = map[string]uint32{}
+		r.Done = map[string]bool{}
+	}
+
+	if !r.Dropped {
+		err = db.dropIndexes()
+		if err != nil {
+			return err
+		}
+		r.Dropped = true
+		err = db.saveIndexRebuild(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !r.DBlocksIndexed {
+		r.Top, err = db.rebuildDBlockIndexes(progress)
+		if err != nil {
+			return err
+		}
+		r.DBlocksIndexed = true
+		err = db.saveIndexRebuild(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	var mutex sync.Mutex
+	checkpoint := func(index string, next uint32, done bool) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		r.Next[index] = next
+		r.Done[index] = done
+		return db.saveIndexRebuild(r)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(reindexedBlocks)+1)
+	for _, index := range reindexedBlocks {
+		if r.Done[index] {
+			continue
+		}
+		wg.Add(1)
+		go func(index string, next uint32) {
+			defer wg.Done()
+			errs <- db.rebuildBlockIndexes(index, next, r.Top, checkpoint, progress)
+		}(index, r.Next[index])
+	}
+	if !r.Done[IndexDirBlockInfo] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := db.rebuildDirBlockInfoIndexes()
+			if err == nil {
+				err = checkpoint(IndexDirBlockInfo, 0, true)
+			}
+			if err == nil {
+				progress(IndexRebuildProgress{Index: IndexDirBlockInfo, Height: r.Top, Top: r.Top, Done: true})
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Delete(KEY_VALUE_STORE, IndexRebuildKey)
+}
+
+// dropIndexes clears the index buckets
+func (db *Overlay) dropIndexes() error {
+	buckets, err := AllBuckets(db.DB)
+	if err != nil {
+		return err
+	}
+	drop := append([][]byte{}, IndexBuckets...)
+	for _, bucket := range buckets {
+		if len(bucket) > len(ENTRYBLOCK_CHAIN_NUMBER) && bytes.HasPrefix(bucket, ENTRYBLOCK_CHAIN_NUMBER) {
+			drop = append(drop, bucket)
+		}
+	}
+	for _, bucket := range drop {
+		err = db.Clear(bucket)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveBlockIndexes saves the number and secondary index records of a block
+func (db *Overlay) saveBlockIndexes(numberBucket, secondaryIndexBucket []byte, block interfaces.DatabaseBatchable) error {
+	height := make([]byte, 4)
+	binary.BigEndian.PutUint32(height, block.GetDatabaseHeight())
+	return db.PutInBatch([]interfaces.Record{
+		{numberBucket, height, block.DatabasePrimaryIndex()},
+		{secondaryIndexBucket, block.DatabaseSecondaryIndex().Bytes(), block.DatabasePrimaryIndex()},
+	})
+}
+
+// rebuildDBlockIndexes indexes every directory block of the DIRECTORYBLOCK bucket, returning
+// the highest height
+func (db *Overlay) rebuildDBlockIndexes(progress func(IndexRebuildProgress)) (uint32, error) {
+	keys, err := db.DB.ListAllKeys(DIRECTORYBLOCK)
+	if err != nil {
+		return 0, err
+	}
+	top := uint32(0)
+	for i, key := range keys {
+		dblock, err := db.FetchDBlockByPrimary(primitives.NewHash(key))
+		if err != nil {
+			return 0, err
+		}
+		if dblock == nil {
+			continue
+		}
+		err = db.saveBlockIndexes(DIRECTORYBLOCK_NUMBER, DIRECTORYBLOCK_SECONDARYINDEX, dblock)
+		if err != nil {
+			return 0, err
+		}
+		err = db.rebuildIncludedIn(dblock)
+		if err != nil {
+			return 0, err
+		}
+		if h := dblock.GetDatabaseHeight(); h > top {
+			top = h
+		}
+		if (i+1)%reindexCheckpoint == 0 {
+			progress(IndexRebuildProgress{Index: "DBlock", Height: uint32(i + 1), Top: uint32(len(keys))})
+		}
+	}
+	progress(IndexRebuildProgress{Index: "DBlock", Height: uint32(len(keys)), Top: uint32(len(keys)), Done: true})
+	return top, nil
+}
+
+// rebuildBlockIndexes indexes the blocks of one type referenced by the directory blocks from
+// height next up to top
+func (db *Overlay) rebuildBlockIndexes(index string, next, top uint32, checkpoint func(string, uint32, bool) error, progress func(IndexRebuildProgress)) error {
+	for h := next; h <= top; h++ {
+		dblock, err := db.FetchDBlockByHeight(h)
+		if err != nil {
+			return err
+		}
+		if dblock == nil {
+			return fmt.Errorf("directory block %d is missing", h)
+		}
+		for _, e := range dblock.GetDBEntries() {
+			err = db.indexDBEntry(index, e)
+			if err != nil {
+				return fmt.Errorf("%s at height %d: %v", index, h, err)
+			}
+		}
+
+		if (h+1)%reindexCheckpoint == 0 {
+			err = checkpoint(index, h+1, false)
+			if err != nil {
+				return err
+			}
+			progress(IndexRebuildProgress{Index: index, Height: h, Top: top})
+		}
+	}
+	err := checkpoint(index, top+1, true)
+	if err != nil {
+		return err
+	}
+	progress(IndexRebuildProgress{Index: index, Height: top, Top: top, Done: true})
+	return nil
+}
+
+// indexDBEntry indexes the block a directory block entry refers to, if it is of the type of index
+func (db *Overlay) indexDBEntry(index string, e interfaces.IDBEntry) error {
+	chainID := e.GetChainID().Bytes()
+	switch {
+	case bytes.Equal(chainID, constants.ADMIN_CHAINID):
+		if index != IndexABlock {
+			return nil
+		}
+		ablock, err := db.FetchABlockByPrimary(e.GetKeyMR())
+		if err != nil || ablock == nil {
+			return missing("admin block", e, err)
+		}
+		return db.saveBlockIndexes(ADMINBLOCK_NUMBER, ADMINBLOCK_SECONDARYINDEX, ablock)
+
+	case bytes.Equal(chainID, constants.FACTOID_CHAINID):
+		if index != IndexFBlock {
+			return nil
+		}
+		fblock, err := db.FetchFBlockByPrimary(e.GetKeyMR())
+		if err != nil || fblock == nil {
+			return missing("factoid block", e, err)
+		}
+		err = db.saveBlockIndexes(FACTOIDBLOCK_NUMBER, FACTOIDBLOCK_SECONDARYINDEX, fblock)
+		if err != nil {
+			return err
+		}
+		return db.rebuildIncludedIn(fblock)
+
+	case bytes.Equal(chainID, constants.EC_CHAINID):
+		if index != IndexECBlock {
+			return nil
+		}
+		ecblock, err := db.FetchECBlockByPrimary(e.GetKeyMR())
+		if err != nil || ecblock == nil {
+			return missing("entry credit block", e, err)
+		}
+		err = db.saveBlockIndexes(ENTRYCREDITBLOCK_NUMBER, ENTRYCREDITBLOCK_SECONDARYINDEX, ecblock)
+		if err != nil {
+			return err
+		}
+		err = db.rebuildIncludedIn(ecblock)
+		if err != nil {
+			return err
+		}
+		return db.rebuildPaidFor(ecblock)
+	}
+
+	if index != IndexEBlock {
+		return nil
+	}
+	eblock, err := db.FetchEBlockByPrimary(e.GetKeyMR())
+	if err != nil || eblock == nil {
+		return missing("entry block", e, err)
+	}
+	numberBucket := append(append([]byte{}, ENTRYBLOCK_CHAIN_NUMBER...), eblock.GetChainID().Bytes()...)
+	err = db.saveBlockIndexes(numberBucket, ENTRYBLOCK_SECONDARYINDEX, eblock)
+	if err != nil {
+		return err
+	}
+	err = db.rebuildIncludedIn(eblock)
+	if err != nil {
+		return err
+	}
+
+	// Only entries that are in the database are indexed, so missing entries stay missing
+	batch := []interfaces.Record{}
+	for _, hash := range eblock.GetEntryHashes() {
+		if hash.IsMinuteMarker() {
+			continue
+		}
+		exists, err := db.DoesKeyExist(eblock.GetChainID().Bytes(), hash.Bytes())
+		if err != nil {
+			return err
+		}
+		if exists {
+			batch = append(batch, interfaces.Record{ENTRY, hash.Bytes(), eblock.GetChainID()})
+		}
+	}
+	return db.PutInBatch(batch)
+}
+
+// rebuildIncludedIn indexes the entries of a block the way the node saves them, without the
+// minute markers, an entry staying in the first block it was included in
+func (db *Overlay) rebuildIncludedIn(block interfaces.DatabaseBlockWithEntries) error {
+	entries := []interfaces.IHash{}
+	for _, hash := range append(block.GetEntryHashes(), block.GetEntrySigHashes()...) {
+		if !hash.IsMinuteMarker() {
+			entries = append(entries, hash)
+		}
+	}
+	return db.SaveIncludedInMulti(entries, block.DatabasePrimaryIndex(), true)
+}
+
+// rebuildPaidFor indexes the commits of an entry credit block the way the node saves them, by
+// the signature hash of the commit, a later commit of an entry replacing an earlier one
+func (db *Overlay) rebuildPaidFor(block interfaces.IEntryCreditBlock) error {
+	batch := []interfaces.Record{}
+	for _, entry := range block.GetBody().GetEntries() {
+		switch entry.ECID() {
+		case constants.ECIDChainCommit:
+			batch = append(batch, interfaces.Record{PAID_FOR, entry.(*entryCreditBlock.CommitChain).EntryHash.Bytes(), entry.GetSigHash()})
+		case constants.ECIDEntryCommit:
+			batch = append(batch, interfaces.Record{PAID_FOR, entry.(*entryCreditBlock.CommitEntry).EntryHash.Bytes(), entry.GetSigHash()})
+		}
+	}
+	return db.PutInBatch(batch)
+}
+
+// rebuildDirBlockInfoIndexes rebuilds the confirmed DirBlockInfos from the anchor chain, and
+// indexes the unconfirmed ones again
+func (db *Overlay) rebuildDirBlockInfoIndexes() error {
+	err := db.RebuildDirBlockInfo()
+	if err != nil {
+		return err
+	}
+	unconfirmed, err := db.FetchAllUnconfirmedDirBlockInfos()
+	if err != nil {
+		return err
+	}
+	for _, dbi := range unconfirmed {
+		err = db.ProcessDirBlockInfoBatch(dbi)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func missing(block string, e interfaces.IDBEntry, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s %s is missing", block, e.GetKeyMR().String())
+}
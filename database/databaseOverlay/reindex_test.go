@@ -0,0 +1,121 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+// indexRecords returns every record of the index buckets, by bucket and key
+func indexRecords(t *testing.T, dbo *Overlay) map[string]map[string]string {
+	buckets, err := AllBuckets(dbo.DB)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	indexes := append([][]byte{}, IndexBuckets...)
+	for _, bucket := range buckets {
+		if len(bucket) > len(ENTRYBLOCK_CHAIN_NUMBER) && bytes.HasPrefix(bucket, ENTRYBLOCK_CHAIN_NUMBER) {
+			indexes = append(indexes, bucket)
+		}
+	}
+
+	records := map[string]map[string]string{}
+	for _, bucket := range indexes {
+		keys, err := dbo.DB.ListAllKeys(bucket)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, key := range keys {
+			value, err := dbo.DB.Get(bucket, key, new(primitives.ByteSlice))
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if records[string(bucket)] == nil {
+				records[string(bucket)] = map[string]string{}
+			}
+			records[string(bucket)][string(key)] = string(value.(*primitives.ByteSlice).Bytes)
+		}
+	}
+	return records
+}
+
+func compareIndexes(t *testing.T, expected, found map[string]map[string]string) {
+	for bucket, records := range expected {
+		if len(found[bucket]) != len(records) {
+			t.Errorf("Bucket %s has %d records after the rebuild, expected %d", bucket, len(found[bucket]), len(records))
+		}
+		for key, value := range records {
+			if found[bucket][key] != value {
+				t.Errorf("Record %x of bucket %s differs after the rebuild", key, bucket)
+			}
+		}
+	}
+	for bucket := range found {
+		if expected[bucket] == nil {
+			t.Errorf("Bucket %s was not there before the rebuild", bucket)
+		}
+	}
+}
+
+func TestRebuildIndexes(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	expected := indexRecords(t, dbo)
+
+	done := map[string]bool{}
+	err := dbo.RebuildIndexes(func(p IndexRebuildProgress) {
+		if p.Done {
+			done[p.Index] = true
+		}
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	compareIndexes(t, expected, indexRecords(t, dbo))
+
+	for _, index := range []string{"DBlock", IndexABlock, IndexFBlock, IndexECBlock, IndexEBlock, IndexDirBlockInfo} {
+		if !done[index] {
+			t.Errorf("The rebuild of %s was not reported done", index)
+		}
+	}
+	if r, err := dbo.FetchIndexRebuild(); err != nil || r != nil {
+		t.Errorf("The progress record is still there after the rebuild: %v %v", r, err)
+	}
+}
+
+func TestRebuildIndexesResume(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	expected := indexRecords(t, dbo)
+
+	// Interrupt a rebuild once the indexes are dropped and the directory blocks indexed
+	func() {
+		defer func() {
+			recover()
+		}()
+		dbo.RebuildIndexes(func(p IndexRebuildProgress) {
+			if p.Index == "DBlock" && p.Done {
+				panic("interrupted")
+			}
+		})
+	}()
+
+	r, err := dbo.FetchIndexRebuild()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if r == nil || !r.Dropped {
+		t.Fatalf("The progress of the interrupted rebuild was not saved")
+	}
+
+	err = dbo.RebuildIndexes(nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	compareIndexes(t, expected, indexRecords(t, dbo))
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+var SchemaVersionKey = []byte("SchemaVersion")
+
+// Migration upgrades the database from the previous schema version to Version
+type Migration struct {
+	Version     uint32
+	Description string
+	Migrate     func(db *Overlay) error
+}
+
+// Migrations is the ordered list of schema migrations.  A database without a schema version
+// record that already holds blocks predates schema versioning and is at version 0.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Start tracking the schema version",
+		Migrate:     func(db *Overlay) error { return nil },
+	},
+}
+
+// RegisterMigration adds a migration at the end of the list.  Versions must be registered in
+// increasing order.
+func RegisterMigration(m Migration) {
+	if m.Version <= SchemaVersion() {
+		panic(fmt.Sprintf("migration to schema version %d registered after version %d", m.Version, SchemaVersion()))
+	}
+	Migrations = append(Migrations, m)
+}
+
+// SchemaVersion returns the schema version this binary writes, the version of the last migration
+func SchemaVersion() uint32 {
+	if len(Migrations) == 0 {
+		return 0
+	}
+	return Migrations[len(Migrations)-1].Version
+}
+
+func (db *Overlay) SaveSchemaVersion(version uint32) error {
+	buf := primitives.NewBuffer(nil)
+	buf.PushUInt32(version)
+	bs := new(primitives.ByteSlice)
+	bs.Bytes = buf.DeepCopyBytes()
+
+	return db.SaveKeyValueStore(bs, SchemaVersionKey)
+}
+
+// FetchSchemaVersion returns the schema version of the database.  A database without a schema
+// version record is at the current version if it is empty, and at version 0 otherwise.
+func (db *Overlay) FetchSchemaVersion() (uint32, error) {
+	bs := new(primitives.ByteSlice)
+	resp, err := db.FetchKeyValueStore(SchemaVersionKey, bs)
+	if err != nil {
+		return 0, err
+	}
+	if resp != nil {
+		buf := primitives.NewBuffer(bs.Bytes)
+		return buf.PopUInt32()
+	}
+
+	head, err := db.FetchDBlockHead()
+	if err != nil {
+		return 0, err
+	}
+	if head == nil {
+		return SchemaVersion(), nil
+	}
+	return 0, nil
+}
+
+// MigrationOptions control how MigrateSchema runs the pending migrations
+type MigrationOptions struct {
+	DryRun bool                 // Only return the pending migrations, without running them
+	Backup interfaces.IDatabase // If not nil, the database is copied here before migrating
+}
+
+// PendingMigrations returns the migrations to run to bring a database at version up to date
+func PendingMigrations(version uint32) []Migration {
+	pending := []Migration{}
+	for _, m := range Migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// MigrateSchema brings the database up to the schema version of this binary, returning the
+// migrations run, or with DryRun the migrations that would run.  The schema version is saved after
+// every migration, so an interrupted upgrade resumes at the failed migration.  A database with a
+// newer schema than this binary is refused.
+func (db *Overlay) MigrateSchema(options MigrationOptions) ([]Migration, error) {
+	version, err := db.FetchSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > SchemaVersion() {
+		return nil, fmt.Errorf("the database schema version %d is newer than version %d supported by this binary", version, SchemaVersion())
+	}
+
+	pending := PendingMigrations(version)
+	if options.DryRun {
+		return pending, nil
+	}
+
+	if len(pending) > 0 && options.Backup != nil {
+		count, err := CopyDatabase(db.DB, options.Backup, nil)
+		if err != nil {
+			return nil, fmt.Errorf("backing up the database failed: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Backed up %d records of the database before migrating\n", count)
+	}
+
+	for i, m := range pending {
+		fmt.Fprintf(os.Stderr, "Migrating the database to schema version %d: %s\n", m.Version, m.Description)
+		err = m.Migrate(db)
+		if err != nil {
+			return pending[:i], fmt.Errorf("migration to schema version %d failed: %v", m.Version, err)
+		}
+		err = db.SaveSchemaVersion(m.Version)
+		if err != nil {
+			return pending[:i], err
+		}
+	}
+
+	// A new database has no migrations to run, but still needs its version recorded
+	if len(pending) == 0 {
+		resp, err := db.FetchKeyValueStore(SchemaVersionKey, new(primitives.ByteSlice))
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return nil, db.SaveSchemaVersion(version)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,140 @@
+package databaseOverlay_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/mapdb"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+// withMigrations replaces the registered migrations for the duration of a test
+func withMigrations(migrations []Migration) func() {
+	old := Migrations
+	Migrations = migrations
+	return func() { Migrations = old }
+}
+
+func TestSchemaVersionNewDatabase(t *testing.T) {
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	defer dbo.Close()
+
+	version, err := dbo.FetchSchemaVersion()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if version != SchemaVersion() {
+		t.Errorf("A new database should be at version %d, got %d", SchemaVersion(), version)
+	}
+
+	migrated, err := dbo.MigrateSchema(MigrationOptions{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(migrated) != 0 {
+		t.Errorf("A new database should need no migrations, ran %d", len(migrated))
+	}
+	resp, err := dbo.FetchKeyValueStore(SchemaVersionKey, new(primitives.ByteSlice))
+	if err != nil || resp == nil {
+		t.Errorf("The schema version of a new database was not recorded: %v", err)
+	}
+}
+
+func TestSchemaMigrations(t *testing.T) {
+	ran := []uint32{}
+	migration := func(version uint32) Migration {
+		return Migration{Version: version, Description: fmt.Sprintf("migration %d", version), Migrate: func(db *Overlay) error {
+			ran = append(ran, version)
+			return nil
+		}}
+	}
+	defer withMigrations([]Migration{migration(1), migration(2)})()
+
+	// Blocks without a schema version are a database from before versioning
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+	version, err := dbo.FetchSchemaVersion()
+	if err != nil || version != 0 {
+		t.Fatalf("Expected version 0, got %d %v", version, err)
+	}
+
+	pending, err := dbo.MigrateSchema(MigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(pending) != 2 || len(ran) != 0 {
+		t.Errorf("Dry run should list 2 migrations without running them, listed %d, ran %d", len(pending), len(ran))
+	}
+	if version, _ := dbo.FetchSchemaVersion(); version != 0 {
+		t.Errorf("Dry run changed the schema version to %d", version)
+	}
+
+	backup := new(mapdb.MapDB)
+	backup.Init(nil)
+	migrated, err := dbo.MigrateSchema(MigrationOptions{Backup: backup})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(migrated) != 2 || len(ran) != 2 || ran[0] != 1 || ran[1] != 2 {
+		t.Errorf("Migrations did not run in order: %v", ran)
+	}
+	if version, _ := dbo.FetchSchemaVersion(); version != 2 {
+		t.Errorf("Expected version 2 after migrating, got %d", version)
+	}
+	head, err := NewOverlay(backup).FetchDBlockHead()
+	if err != nil || head == nil {
+		t.Errorf("The database was not backed up: %v", err)
+	}
+
+	RegisterMigration(migration(3))
+	migrated, err = dbo.MigrateSchema(MigrationOptions{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(migrated) != 1 || migrated[0].Version != 3 {
+		t.Errorf("Only the new migration should run, ran %v", migrated)
+	}
+}
+
+func TestSchemaMigrationFailure(t *testing.T) {
+	defer withMigrations([]Migration{
+		{Version: 1, Description: "works", Migrate: func(db *Overlay) error { return nil }},
+		{Version: 2, Description: "fails", Migrate: func(db *Overlay) error { return fmt.Errorf("failed") }},
+	})()
+
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+	_, err := dbo.MigrateSchema(MigrationOptions{})
+	if err == nil {
+		t.Fatalf("A failed migration should fail MigrateSchema")
+	}
+	if version, _ := dbo.FetchSchemaVersion(); version != 1 {
+		t.Errorf("The database should stay at the last successful migration, got version %d", version)
+	}
+}
+
+func TestSchemaNewerDatabase(t *testing.T) {
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	defer dbo.Close()
+
+	err := dbo.SaveSchemaVersion(SchemaVersion() + 1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, err = dbo.MigrateSchema(MigrationOptions{})
+	if err == nil {
+		t.Errorf("A database newer than the binary should be refused")
+	}
+}
+
+func TestRegisterMigrationOrder(t *testing.T) {
+	defer withMigrations([]Migration{{Version: 2, Description: "two"}})()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a migration out of order should panic")
+		}
+	}()
+	RegisterMigration(Migration{Version: 1, Description: "one"})
+}
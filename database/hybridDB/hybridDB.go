@@ -107,6 +107,18 @@ func (db *HybridDB) PutInBatch(records []interfaces.Record) error {
 	return nil
 }
 
+func (db *HybridDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	db.Sem.Lock()
+	defer db.Sem.Unlock()
+
+	// The temporary storage is only a cache, it only needs updating once the changes are persisted
+	err := db.persistentStorage.PutAndDeleteInBatch(records, deletes)
+	if err != nil {
+		return err
+	}
+	return db.temporaryStorage.PutAndDeleteInBatch(records, deletes)
+}
+
 func (db *HybridDB) Get(bucket, key []byte, destination interfaces.BinaryMarshallable) (interfaces.BinaryMarshallable, error) {
 	db.Sem.RLock()
 	defer db.Sem.RUnlock()
@@ -158,6 +170,13 @@ func (db *HybridDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAn
 	return db.persistentStorage.GetAll(bucket, sample)
 }
 
+func (db *HybridDB) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	return db.persistentStorage.Iterate(bucket, options)
+}
+
 func (db *HybridDB) Clear(bucket []byte) error {
 	db.Sem.Lock()
 	defer db.Sem.Unlock()
@@ -14,6 +14,7 @@ import (
 
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/goleveldb/leveldb"
+	"github.com/FactomProject/goleveldb/leveldb/iterator"
 	"github.com/FactomProject/goleveldb/leveldb/opt"
 	"github.com/FactomProject/goleveldb/leveldb/util"
 )
@@ -139,6 +140,12 @@ func (db *LevelDB) Put(bucket []byte, key []byte, data interfaces.BinaryMarshall
 }
 
 func (db *LevelDB) PutInBatch(records []interfaces.Record) error {
+	return db.PutAndDeleteInBatch(records, nil)
+}
+
+// PutAndDeleteInBatch writes all the changes as one leveldb batch.  The batch is a single record
+// in the journal, so after a crash it is either replayed completely or not at all.
+func (db *LevelDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
 	db.dbLock.Lock()
 	defer db.dbLock.Unlock()
 
@@ -148,6 +155,9 @@ func (db *LevelDB) PutInBatch(records []interfaces.Record) error {
 
 	defer db.lbatch.Reset()
 
+	for _, v := range deletes {
+		db.lbatch.Delete(CombineBucketAndKey(v.Bucket, v.Key))
+	}
 	for _, v := range records {
 		ldbKey := CombineBucketAndKey(v.Bucket, v.Key)
 		hex, err := v.Data.MarshalBinary()
@@ -258,6 +268,74 @@ func (db *LevelDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAnd
 	return answer, keys, nil
 }
 
+// Iterate returns an iterator over a key range of the bucket.  The iterator reads from a snapshot
+// of the database, so writes made while iterating are not seen.
+func (db *LevelDB) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	ldbKey := ExtendBucket(bucket)
+	start, limit := options.Range()
+
+	// Build the bounds in fresh slices, appending to the bucket could make them share memory
+	withBucket := func(key []byte) []byte {
+		answer := make([]byte, 0, len(ldbKey)+len(key))
+		answer = append(answer, ldbKey...)
+		return append(answer, key...)
+	}
+
+	keyRange := new(util.Range)
+	keyRange.Start = withBucket(start)
+	if limit != nil {
+		keyRange.Limit = withBucket(limit)
+	} else {
+		keyRange.Limit = addOneToByteArray(ldbKey)
+	}
+
+	it := new(LevelDBIterator)
+	it.iter = db.lDB.NewIterator(keyRange, db.ro)
+	it.bucketLen = len(ldbKey)
+	it.reverse = options.Reverse
+	return it, nil
+}
+
+// LevelDBIterator iterates over the keys of one bucket, hiding the bucket prefix from the caller
+type LevelDBIterator struct {
+	iter      iterator.Iterator
+	bucketLen int
+	reverse   bool
+	started   bool
+}
+
+var _ interfaces.IIterator = (*LevelDBIterator)(nil)
+
+func (it *LevelDBIterator) Next() bool {
+	if !it.reverse {
+		return it.iter.Next()
+	}
+	if !it.started {
+		it.started = true
+		return it.iter.Last()
+	}
+	return it.iter.Prev()
+}
+
+func (it *LevelDBIterator) Key() []byte {
+	return it.iter.Key()[it.bucketLen:]
+}
+
+func (it *LevelDBIterator) Value() []byte {
+	return it.iter.Value()
+}
+
+func (it *LevelDBIterator) Error() error {
+	return it.iter.Error()
+}
+
+func (it *LevelDBIterator) Release() {
+	it.iter.Release()
+}
+
 func NewLevelDB(filename string, create bool) (interfaces.IDatabase, error) {
 	db := new(LevelDB)
 	var err error
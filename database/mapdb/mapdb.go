@@ -5,6 +5,7 @@
 package mapdb
 
 import (
+	"bytes"
 	"sort"
 	"sync"
 
@@ -111,14 +112,39 @@ func (db *MapDB) rawPut(bucket, key []byte, data interfaces.BinaryMarshallable)
 }
 
 func (db *MapDB) PutInBatch(records []interfaces.Record) error {
-	db.Sem.Lock()
-	defer db.Sem.Unlock()
+	return db.PutAndDeleteInBatch(records, nil)
+}
 
-	for _, v := range records {
-		err := db.rawPut(v.Bucket, v.Key, v.Data)
+// PutAndDeleteInBatch marshals all the records before touching the map, so an error part way
+// through leaves the map unchanged.
+func (db *MapDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	data := make([][]byte, len(records))
+	for i, v := range records {
+		if v.Data == nil {
+			continue
+		}
+		hex, err := v.Data.MarshalBinary()
 		if err != nil {
 			return err
 		}
+		data[i] = hex
+	}
+
+	db.Sem.Lock()
+	defer db.Sem.Unlock()
+
+	if db.Cache == nil {
+		db.Cache = map[string]map[string][]byte{}
+	}
+	for _, v := range deletes {
+		delete(db.Cache[string(v.Bucket)], string(v.Key))
+	}
+	for i, v := range records {
+		_, ok := db.Cache[string(v.Bucket)]
+		if ok == false {
+			db.Cache[string(v.Bucket)] = map[string][]byte{}
+		}
+		db.Cache[string(v.Bucket)][string(v.Key)] = data[i]
 	}
 	return nil
 }
@@ -228,6 +254,81 @@ func (db *MapDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAndCo
 	return answer, keys, nil
 }
 
+// Iterate returns an iterator over a key range of the bucket.  The matching records are
+// snapshotted when the iterator is created, so later writes are not seen.
+func (db *MapDB) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	it := new(MapDBIterator)
+	for k, v := range db.Cache[string(bucket)] {
+		key := []byte(k)
+		if options.Contains(key) {
+			it.keys = append(it.keys, key)
+			it.values = append(it.values, v)
+		}
+	}
+	sort.Sort(it)
+	if options.Reverse {
+		for i, j := 0, len(it.keys)-1; i < j; i, j = i+1, j-1 {
+			it.Swap(i, j)
+		}
+	}
+	it.index = -1
+	return it, nil
+}
+
+// MapDBIterator iterates over a sorted snapshot of records
+type MapDBIterator struct {
+	keys   [][]byte
+	values [][]byte
+	index  int
+}
+
+var _ interfaces.IIterator = (*MapDBIterator)(nil)
+
+func (it *MapDBIterator) Len() int {
+	return len(it.keys)
+}
+
+func (it *MapDBIterator) Less(i, j int) bool {
+	return bytes.Compare(it.keys[i], it.keys[j]) < 0
+}
+
+func (it *MapDBIterator) Swap(i, j int) {
+	it.keys[i], it.keys[j] = it.keys[j], it.keys[i]
+	it.values[i], it.values[j] = it.values[j], it.values[i]
+}
+
+func (it *MapDBIterator) Next() bool {
+	if it.index < len(it.keys) {
+		it.index++
+	}
+	return it.index < len(it.keys)
+}
+
+func (it *MapDBIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+func (it *MapDBIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.values) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *MapDBIterator) Error() error {
+	return nil
+}
+
+func (it *MapDBIterator) Release() {
+	it.keys, it.values = nil, nil
+}
+
 func (db *MapDB) Clear(bucket []byte) error {
 	db.Sem.Lock()
 	defer db.Sem.Unlock()
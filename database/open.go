@@ -0,0 +1,41 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/badgerdb"
+	"github.com/FactomProject/factomd/database/boltdb"
+	"github.com/FactomProject/factomd/database/leveldb"
+	"github.com/FactomProject/factomd/database/securedb"
+)
+
+// OpenExisting opens the database of a type at path for the utilities working on the database
+// of a node: LDB, Bolt, Badger, or one of the Secure types, which read their passphrase from
+// passphraseFile.  The database must already exist.
+func OpenExisting(dbtype, path, passphraseFile string) (interfaces.IDatabase, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if plain, ok := securedb.SecureDBTypes[dbtype]; ok {
+		passphrase, err := securedb.LoadPassphrase(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return securedb.NewEncryptedDB(path, plain, passphrase)
+	}
+	switch dbtype {
+	case "LDB":
+		return leveldb.NewLevelDB(path, false)
+	case "Bolt":
+		return boltdb.NewBoltDB(nil, path), nil
+	case "Badger":
+		return badgerdb.NewBadgerDB(path, false)
+	}
+	return nil, fmt.Errorf("%s is not a valid database type", dbtype)
+}
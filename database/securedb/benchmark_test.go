@@ -0,0 +1,144 @@
+package securedb_test
+
+// Compares the encrypted database against plain LevelDB, the default node database:
+//
+//	go test -run XXX -bench . ./database/securedb/
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/database/leveldb"
+	. "github.com/FactomProject/factomd/database/securedb"
+)
+
+var benchBucket = []byte("Bench")
+
+// recordSize is about the size of an entry
+const recordSize = 1024
+
+func benchLevelDB(b *testing.B, secure bool) (interfaces.IDatabase, func()) {
+	dir, err := ioutil.TempDir("", "securedb-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	path := filepath.Join(dir, "bench.db")
+
+	var db interfaces.IDatabase
+	if secure {
+		db, err = NewEncryptedDB(path, "LDB", "benchPassword")
+	} else {
+		db, err = leveldb.NewLevelDB(path, true)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		b.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func benchPut(b *testing.B, secure bool) {
+	db, cleanup := benchLevelDB(b, secure)
+	defer cleanup()
+	data := new(primitives.ByteSlice)
+	data.Bytes = random.RandByteSliceOfLen(recordSize)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := db.Put(benchBucket, intToKey(i), data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchPutInBatch(b *testing.B, secure bool) {
+	db, cleanup := benchLevelDB(b, secure)
+	defer cleanup()
+	data := new(primitives.ByteSlice)
+	data.Bytes = random.RandByteSliceOfLen(recordSize)
+
+	b.ResetTimer()
+	batch := []interfaces.Record{}
+	for i := 0; i < b.N; i++ {
+		batch = append(batch, interfaces.Record{Bucket: benchBucket, Key: intToKey(i), Data: data})
+		if len(batch) == 100 || i == b.N-1 {
+			err := db.PutInBatch(batch)
+			if err != nil {
+				b.Fatal(err)
+			}
+			batch = []interfaces.Record{}
+		}
+	}
+}
+
+func benchGet(b *testing.B, secure bool) {
+	db, cleanup := benchLevelDB(b, secure)
+	defer cleanup()
+	data := new(primitives.ByteSlice)
+	data.Bytes = random.RandByteSliceOfLen(recordSize)
+	const records = 1000
+	for i := 0; i < records; i++ {
+		err := db.Put(benchBucket, intToKey(i), data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := db.Get(benchBucket, intToKey(i%records), new(primitives.ByteSlice))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchIterate(b *testing.B, secure bool) {
+	db, cleanup := benchLevelDB(b, secure)
+	defer cleanup()
+	data := new(primitives.ByteSlice)
+	data.Bytes = random.RandByteSliceOfLen(recordSize)
+	const records = 1000
+	for i := 0; i < records; i++ {
+		err := db.Put(benchBucket, intToKey(i), data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it, err := db.Iterate(benchBucket, interfaces.IteratorOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for it.Next() {
+		}
+		if it.Error() != nil {
+			b.Fatal(it.Error())
+		}
+		it.Release()
+	}
+}
+
+func intToKey(i int) []byte {
+	return []byte{byte(i >> 24), byte(i >> 16), byte(i >> 8), byte(i)}
+}
+
+func BenchmarkLevelDBPut(b *testing.B)              { benchPut(b, false) }
+func BenchmarkSecureLevelDBPut(b *testing.B)        { benchPut(b, true) }
+func BenchmarkLevelDBPutInBatch(b *testing.B)       { benchPutInBatch(b, false) }
+func BenchmarkSecureLevelDBPutInBatch(b *testing.B) { benchPutInBatch(b, true) }
+func BenchmarkLevelDBGet(b *testing.B)              { benchGet(b, false) }
+func BenchmarkSecureLevelDBGet(b *testing.B)        { benchGet(b, true) }
+func BenchmarkLevelDBIterate(b *testing.B)          { benchIterate(b, false) }
+func BenchmarkSecureLevelDBIterate(b *testing.B)    { benchIterate(b, true) }
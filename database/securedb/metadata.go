@@ -14,6 +14,15 @@ var _ = fmt.Sprint
 type SecureDBMetaData struct {
 	Salt      primitives.ByteSlice
 	Challenge primitives.ByteSlice
+
+	// Salt and challenge of the new key while a key rotation is in progress, empty otherwise
+	NextSalt      primitives.ByteSlice
+	NextChallenge primitives.ByteSlice
+}
+
+// IsRotating returns true if a key rotation was started and not finished
+func (m *SecureDBMetaData) IsRotating() bool {
+	return len(m.NextSalt.Bytes) > 0
 }
 
 func NewSecureDBMetaData() *SecureDBMetaData {
@@ -30,6 +39,14 @@ func (m *SecureDBMetaData) IsSameAs(b *SecureDBMetaData) bool {
 		return false
 	}
 
+	if !m.NextSalt.IsSameAs(&b.NextSalt) {
+		return false
+	}
+
+	if !m.NextChallenge.IsSameAs(&b.NextChallenge) {
+		return false
+	}
+
 	return true
 }
 
@@ -63,6 +80,29 @@ func (m *SecureDBMetaData) UnmarshalBinaryData(data []byte) (newData []byte, err
 	copy(m.Challenge.Bytes, newData[4:clen+4])
 	newData = newData[clen+4:]
 
+	// The next salt and challenge are only there during a key rotation
+	if len(newData) == 0 {
+		m.NextSalt.Bytes = nil
+		m.NextChallenge.Bytes = nil
+		return
+	}
+
+	nslen, err := bytesToUint32(newData[:4])
+	if err != nil {
+		return nil, err
+	}
+	m.NextSalt.Bytes = make([]byte, nslen)
+	copy(m.NextSalt.Bytes, newData[4:nslen+4])
+	newData = newData[nslen+4:]
+
+	nclen, err := bytesToUint32(newData[:4])
+	if err != nil {
+		return nil, err
+	}
+	m.NextChallenge.Bytes = make([]byte, nclen)
+	copy(m.NextChallenge.Bytes, newData[4:nclen+4])
+	newData = newData[nclen+4:]
+
 	return
 }
 
@@ -88,6 +128,22 @@ func (m *SecureDBMetaData) MarshalBinary() (rval []byte, err error) {
 	}
 	buf.Write(data)
 
+	if m.IsRotating() {
+		buf.Write(intToBytes(len(m.NextSalt.Bytes)))
+		data, err = m.NextSalt.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+
+		buf.Write(intToBytes(len(m.NextChallenge.Bytes)))
+		data, err = m.NextChallenge.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+
 	return buf.DeepCopyBytes(), nil
 }
 
@@ -38,3 +38,25 @@ func TestSecureDBMetaData(t *testing.T) {
 		}
 	}
 }
+
+func TestSecureDBMetaDataRotating(t *testing.T) {
+	m := new(SecureDBMetaData)
+	m.Salt.Bytes = random.RandNonEmptyByteSlice()
+	m.Challenge.Bytes = random.RandNonEmptyByteSlice()
+	m.NextSalt.Bytes = random.RandNonEmptyByteSlice()
+	m.NextChallenge.Bytes = random.RandNonEmptyByteSlice()
+
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Error(err)
+	}
+
+	m2 := new(SecureDBMetaData)
+	err = m2.UnmarshalBinary(data)
+	if err != nil {
+		t.Error(err)
+	}
+	if !m2.IsRotating() || !m.IsSameAs(m2) {
+		t.Errorf("The rotation salt and challenge were not kept")
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package securedb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// PassphraseEnv is the environment variable the passphrase of the node database is read from
+// when no passphrase file is given
+const PassphraseEnv = "FACTOMD_DB_PASSPHRASE"
+
+// SecureDBTypes maps the DBType of an encrypted database to the type of the database holding
+// the encrypted records
+var SecureDBTypes = map[string]string{
+	"SecureLDB":    "LDB",
+	"SecureBolt":   "Bolt",
+	"SecureBadger": "Badger",
+}
+
+// LoadPassphrase reads the passphrase from filename, or from the PassphraseEnv environment
+// variable if filename is empty.  A trailing newline in the file is not part of the passphrase.
+func LoadPassphrase(filename string) (string, error) {
+	var passphrase string
+	if filename != "" {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	} else {
+		passphrase = os.Getenv(PassphraseEnv)
+	}
+
+	if passphrase == "" {
+		if filename != "" {
+			return "", fmt.Errorf("the passphrase file %s is empty", filename)
+		}
+		return "", fmt.Errorf("no passphrase given, set a passphrase file or the %s environment variable", PassphraseEnv)
+	}
+	return passphrase, nil
+}
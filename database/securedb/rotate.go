@@ -0,0 +1,175 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package securedb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+)
+
+// rotateBatchSize is the number of records re-encrypted per batch
+const rotateBatchSize = 1000
+
+// RotateKey re-encrypts every record of the database in place, with a key derived from
+// newPassword.  The database must not be open elsewhere.
+//
+// The new salt and challenge are saved before any record is touched, and only replace the old
+// ones once every record is re-encrypted.  If the rotation is interrupted the database can't be
+// opened until RotateKey is run again with the same passwords, which picks up where it stopped.
+//
+// LevelDB can't list its buckets, so on LevelDB only the buckets of the node database are
+// re-encrypted.
+func RotateKey(filename, dbtype, oldPassword, newPassword string) error {
+	db := new(EncryptedDB)
+	db.Init(filename, dbtype)
+	defer db.Close()
+
+	m := new(SecureDBMetaData)
+	v, err := db.db.Get(EncyptedMetaData, EncyptedMetaData, m)
+	if err != nil {
+		return err
+	}
+	if v == nil || len(m.Challenge.Bytes) == 0 {
+		return fmt.Errorf("%s is not an encrypted database", filename)
+	}
+
+	oldKey, err := checkPassword(oldPassword, m.Salt.Bytes, m.Challenge.Bytes)
+	if err != nil {
+		return err
+	}
+
+	var newKey []byte
+	if m.IsRotating() {
+		newKey, err = checkPassword(newPassword, m.NextSalt.Bytes, m.NextChallenge.Bytes)
+		if err != nil {
+			return fmt.Errorf("the new password does not match the one of the interrupted rotation")
+		}
+	} else {
+		salt := make([]byte, 30)
+		_, err = rand.Read(salt)
+		if err != nil {
+			return err
+		}
+		newKey, err = GetKey(newPassword, salt)
+		if err != nil {
+			return err
+		}
+		c, err := Encrypt(challenge, newKey)
+		if err != nil {
+			return err
+		}
+		m.NextSalt.Bytes = salt
+		m.NextChallenge.Bytes = c
+		err = db.db.Put(EncyptedMetaData, EncyptedMetaData, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	buckets, err := databaseOverlay.AllBuckets(db.db)
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets {
+		if bytes.Equal(bucket, EncyptedMetaData) {
+			continue
+		}
+		err = reencryptBucket(db.db, bucket, oldKey, newKey)
+		if err != nil {
+			return fmt.Errorf("re-encrypting bucket %x failed: %v", bucket, err)
+		}
+	}
+
+	m.Salt, m.Challenge = m.NextSalt, m.NextChallenge
+	m.NextSalt.Bytes, m.NextChallenge.Bytes = nil, nil
+	return db.db.Put(EncyptedMetaData, EncyptedMetaData, m)
+}
+
+// checkPassword derives the key of a password and checks it against the challenge
+func checkPassword(password string, salt []byte, cipherChallenge []byte) ([]byte, error) {
+	key, err := GetKey(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := Decrypt(cipherChallenge, key)
+	if err != nil || subtle.ConstantTimeCompare(plainText, challenge) == 0 {
+		return nil, fmt.Errorf("password supplied is incorrect, and cannot decrypt the existing database")
+	}
+	return key, nil
+}
+
+// reencryptBucket re-encrypts the records of a bucket still encrypted with the old key.  The
+// bucket is read in chunks, so no iterator is open while writing.
+func reencryptBucket(db interfaces.IDatabase, bucket []byte, oldKey, newKey []byte) error {
+	var start []byte
+	for {
+		it, err := db.Iterate(bucket, interfaces.IteratorOptions{Start: start})
+		if err != nil {
+			return err
+		}
+		keys, values := [][]byte{}, [][]byte{}
+		for len(keys) < rotateBatchSize && it.Next() {
+			keys = append(keys, append([]byte{}, it.Key()...))
+			values = append(values, append([]byte{}, it.Value()...))
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+
+		batch := []interfaces.Record{}
+		for i, key := range keys {
+			plainData, err := openRecord(values[i], oldKey)
+			if err != nil {
+				// Already re-encrypted by an interrupted rotation
+				if _, err2 := openRecord(values[i], newKey); err2 == nil {
+					continue
+				}
+				return fmt.Errorf("record %x: %v", key, err)
+			}
+			cipherData, err := Encrypt(plainData, newKey)
+			if err != nil {
+				return err
+			}
+			data := new(primitives.ByteSlice)
+			data.Bytes = append(intToBytes(len(cipherData)), cipherData...)
+			batch = append(batch, interfaces.Record{Bucket: bucket, Key: key, Data: data})
+		}
+		if len(batch) > 0 {
+			err = db.PutInBatch(batch)
+			if err != nil {
+				return err
+			}
+		}
+
+		start = append(keys[len(keys)-1], 0)
+	}
+}
+
+// openRecord decrypts a record as written by the EncryptedMarshaler: 4 bytes of length then
+// the cipher text
+func openRecord(cipherData []byte, key []byte) ([]byte, error) {
+	if len(cipherData) < 4 {
+		return nil, fmt.Errorf("encrypted record is too short")
+	}
+	l, err := bytesToUint32(cipherData[:4])
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(cipherData)) < uint64(l)+4 {
+		return nil, fmt.Errorf("encrypted record is too short")
+	}
+	return Decrypt(cipherData[4:l+4], key)
+}
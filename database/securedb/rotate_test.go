@@ -0,0 +1,99 @@
+package securedb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/securedb"
+)
+
+func TestRotateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "securedb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rotate.db")
+
+	s, err := NewEncryptedDB(path, "Bolt", "oldPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{}
+	for _, bucket := range []string{"one", "two"} {
+		for i := 0; i < 20; i++ {
+			key := string([]byte{byte(i)})
+			values[bucket+key] = primitives.RandomHash().String()
+			err = s.Put([]byte(bucket), []byte(key), primitives.StringToByteSlice(values[bucket+key]))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	s.Close()
+
+	err = RotateKey(path, "Bolt", "wrongPassword", "newPassword")
+	if err == nil {
+		t.Errorf("Rotating with the wrong password should fail")
+	}
+
+	err = RotateKey(path, "Bolt", "oldPassword", "newPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewEncryptedDB(path, "Bolt", "oldPassword")
+	if err == nil {
+		t.Errorf("The old password should no longer open the database")
+	}
+	s, err = NewEncryptedDB(path, "Bolt", "newPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, bucket := range []string{"one", "two"} {
+		for i := 0; i < 20; i++ {
+			key := string([]byte{byte(i)})
+			resp, err := s.Get([]byte(bucket), []byte(key), new(primitives.ByteSlice))
+			if err != nil || resp == nil {
+				t.Fatalf("Reading %s %x after the rotation failed: %v", bucket, key, err)
+			}
+			if string(resp.(*primitives.ByteSlice).Bytes) != values[bucket+key] {
+				t.Errorf("Wrong value for %s %x after the rotation", bucket, key)
+			}
+		}
+	}
+}
+
+func TestLoadPassphrase(t *testing.T) {
+	f, err := ioutil.TempFile("", "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("secret\n")
+	f.Close()
+
+	p, err := LoadPassphrase(f.Name())
+	if err != nil || p != "secret" {
+		t.Errorf("Expected secret, got %q %v", p, err)
+	}
+
+	old := os.Getenv(PassphraseEnv)
+	defer os.Setenv(PassphraseEnv, old)
+
+	os.Setenv(PassphraseEnv, "fromenv")
+	p, err = LoadPassphrase("")
+	if err != nil || p != "fromenv" {
+		t.Errorf("Expected fromenv, got %q %v", p, err)
+	}
+
+	os.Setenv(PassphraseEnv, "")
+	_, err = LoadPassphrase("")
+	if err == nil {
+		t.Errorf("A missing passphrase should be an error")
+	}
+}
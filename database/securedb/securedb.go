@@ -13,6 +13,7 @@ import (
 
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/leveldb"
 	"github.com/FactomProject/factomd/database/mapdb"
@@ -49,6 +50,7 @@ type EncryptedDB struct {
 //			Map
 //			Bolt
 //			LevelDB
+//			Badger
 func NewEncryptedDB(filename, dbtype, password string) (*EncryptedDB, error) {
 	e := new(EncryptedDB)
 	e.Init(filename, dbtype)
@@ -77,6 +79,10 @@ func (db *EncryptedDB) initSecureDB(password string) error {
 		db.metadata = m
 	}
 
+	if db.metadata.IsRotating() {
+		return fmt.Errorf("a key rotation of the database was interrupted, run it again with the same passphrases to finish it")
+	}
+
 	key, err := GetKey(password, db.metadata.Salt.Bytes)
 	if err != nil {
 		return err
@@ -216,6 +222,18 @@ func (db *EncryptedDB) PutInBatch(records []interfaces.Record) error {
 		return lockedError
 	}
 
+	return db.db.PutInBatch(db.encryptRecords(records))
+}
+
+func (db *EncryptedDB) PutAndDeleteInBatch(records []interfaces.Record, deletes []interfaces.Record) error {
+	if db.isLocked() {
+		return lockedError
+	}
+
+	return db.db.PutAndDeleteInBatch(db.encryptRecords(records), deletes)
+}
+
+func (db *EncryptedDB) encryptRecords(records []interfaces.Record) []interfaces.Record {
 	cipherRecords := make([]interfaces.Record, len(records))
 	for i, r := range records {
 		cipherRecords[i].Bucket = r.Bucket
@@ -224,8 +242,7 @@ func (db *EncryptedDB) PutInBatch(records []interfaces.Record) error {
 		e := NewEncryptedMarshaler(db.encryptionkey, r.Data)
 		cipherRecords[i].Data = e
 	}
-
-	return db.db.PutInBatch(cipherRecords)
+	return cipherRecords
 }
 
 func (db *EncryptedDB) Clear(bucket []byte) error {
@@ -269,6 +286,67 @@ func (db *EncryptedDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallabl
 	return originalSamples, keys, err
 }
 
+// Iterate returns an iterator that decrypts the records of the bucket as it goes
+func (db *EncryptedDB) Iterate(bucket []byte, options interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	if db.isLocked() {
+		return nil, lockedError
+	}
+
+	cipherIterator, err := db.db.Iterate(bucket, options)
+	if err != nil {
+		return nil, err
+	}
+
+	it := new(EncryptedIterator)
+	it.iter = cipherIterator
+	it.encryptionkey = db.encryptionkey
+	return it, nil
+}
+
+// EncryptedIterator decrypts the values of an iterator over the encrypted records
+type EncryptedIterator struct {
+	iter          interfaces.IIterator
+	encryptionkey []byte
+	value         []byte
+	err           error
+}
+
+var _ interfaces.IIterator = (*EncryptedIterator)(nil)
+
+func (it *EncryptedIterator) Next() bool {
+	it.value = nil
+	if it.err != nil || !it.iter.Next() {
+		return false
+	}
+
+	var err error
+	it.value, err = openRecord(it.iter.Value(), it.encryptionkey)
+	if err != nil {
+		it.err = fmt.Errorf("record %x: %v", it.iter.Key(), err)
+		return false
+	}
+	return true
+}
+
+func (it *EncryptedIterator) Key() []byte {
+	return it.iter.Key()
+}
+
+func (it *EncryptedIterator) Value() []byte {
+	return it.value
+}
+
+func (it *EncryptedIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.iter.Error()
+}
+
+func (it *EncryptedIterator) Release() {
+	it.iter.Release()
+}
+
 func (db *EncryptedDB) Init(filename string, dbtype string) {
 	var err error
 	switch dbtype {
@@ -281,8 +359,13 @@ func (db *EncryptedDB) Init(filename string, dbtype string) {
 		}
 	case "Bolt":
 		db.db = boltdb.NewBoltDB(nil, filename)
+	case "Badger":
+		db.db, err = badgerdb.NewBadgerDB(filename, true)
+		if err != nil {
+			panic(err)
+		}
 	default:
-		panic(fmt.Sprintf("%s is not a valid option. Expect 'Map', 'LDB', or 'Bolt'", dbtype))
+		panic(fmt.Sprintf("%s is not a valid option. Expect 'Map', 'LDB', 'Bolt', or 'Badger'", dbtype))
 	}
 }
 
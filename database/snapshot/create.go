@@ -0,0 +1,184 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package snapshot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// FastBoot is the fastboot SaveState to include in a snapshot
+type FastBoot struct {
+	Filename string // base name of the fastboot file
+	Height   uint32 // height of the SaveState, must not be above the snapshot height
+	Data     []byte
+}
+
+// Create writes a snapshot of the blocks from height 0 up to height into dir, which must not
+// already hold a snapshot.  Blocks that are saved are never modified, so this can run against
+// the database of a running node as long as height is not above its highest saved block.
+// fastBoot can be nil.
+func Create(db interfaces.DBOverlaySimple, dir string, network string, height uint32, fastBoot *FastBoot) (*Manifest, error) {
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+		return nil, fmt.Errorf("%s already holds a snapshot", dir)
+	}
+	if fastBoot != nil && fastBoot.Height > height {
+		return nil, fmt.Errorf("the fastboot state at height %d is above the snapshot height %d", fastBoot.Height, height)
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(Manifest)
+	m.Version = Version
+	m.Network = network
+	m.Height = height
+	m.Created = time.Now()
+
+	f, err := os.Create(filepath.Join(dir, BlocksFile))
+	if err != nil {
+		return nil, err
+	}
+	rw := newRecordWriter(f)
+	head, err := writeBlocks(db, rw, height)
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	m.HeadKeyMR = head.GetKeyMR().String()
+	m.NetworkID = head.GetHeader().GetNetworkID()
+	m.Files = append(m.Files, FileChecksum{Name: BlocksFile, Size: rw.size, SHA256: hex.EncodeToString(rw.hash.Sum(nil))})
+
+	if fastBoot != nil {
+		err = ioutil.WriteFile(filepath.Join(dir, fastBoot.Filename), fastBoot.Data, 0644)
+		if err != nil {
+			return nil, err
+		}
+		c, err := fileChecksum(dir, fastBoot.Filename)
+		if err != nil {
+			return nil, err
+		}
+		m.FastBootFile = fastBoot.Filename
+		m.FastBootHeight = fastBoot.Height
+		m.Files = append(m.Files, c)
+	}
+
+	err = WriteManifest(dir, m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeBlocks writes the blocks of every height up to height, and returns the last directory block
+func writeBlocks(db interfaces.DBOverlaySimple, rw *recordWriter, height uint32) (interfaces.IDirectoryBlock, error) {
+	var dblock interfaces.IDirectoryBlock
+	for h := uint32(0); h <= height; h++ {
+		var err error
+		dblock, err = db.FetchDBlockByHeight(h)
+		if err != nil {
+			return nil, err
+		}
+		if dblock == nil {
+			return nil, fmt.Errorf("directory block %d is missing", h)
+		}
+		err = rw.Write(RecordDBlock, dblock)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range dblock.GetDBEntries() {
+			err = writeDBEntry(db, rw, e)
+			if err != nil {
+				return nil, fmt.Errorf("directory block %d: %v", h, err)
+			}
+		}
+	}
+	return dblock, nil
+}
+
+// writeDBEntry writes the block a directory block entry refers to, with its entries
+func writeDBEntry(db interfaces.DBOverlaySimple, rw *recordWriter, e interfaces.IDBEntry) error {
+	chainID := e.GetChainID().Bytes()
+	switch {
+	case bytes.Equal(chainID, constants.ADMIN_CHAINID):
+		ablock, err := db.FetchABlock(e.GetKeyMR())
+		if err != nil {
+			return err
+		}
+		if ablock == nil {
+			return fmt.Errorf("admin block %s is missing", e.GetKeyMR().String())
+		}
+		return rw.Write(RecordABlock, ablock)
+
+	case bytes.Equal(chainID, constants.FACTOID_CHAINID):
+		fblock, err := db.FetchFBlock(e.GetKeyMR())
+		if err != nil {
+			return err
+		}
+		if fblock == nil {
+			return fmt.Errorf("factoid block %s is missing", e.GetKeyMR().String())
+		}
+		return rw.Write(RecordFBlock, fblock)
+
+	case bytes.Equal(chainID, constants.EC_CHAINID):
+		ecblock, err := db.FetchECBlock(e.GetKeyMR())
+		if err != nil {
+			return err
+		}
+		if ecblock == nil {
+			return fmt.Errorf("entry credit block %s is missing", e.GetKeyMR().String())
+		}
+		return rw.Write(RecordECBlock, ecblock)
+	}
+
+	eblock, err := db.FetchEBlock(e.GetKeyMR())
+	if err != nil {
+		return err
+	}
+	if eblock == nil {
+		return fmt.Errorf("entry block %s is missing", e.GetKeyMR().String())
+	}
+	err = rw.Write(RecordEBlock, eblock)
+	if err != nil {
+		return err
+	}
+
+	// An entry can be in an entry block more than once, but is only stored once
+	written := map[[32]byte]bool{}
+	for _, hash := range eblock.GetEntryHashes() {
+		if hash.IsMinuteMarker() || written[hash.Fixed()] {
+			continue
+		}
+		entry, err := db.FetchEntry(hash)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf("entry %s of entry block %s is missing", hash.String(), e.GetKeyMR().String())
+		}
+		err = rw.Write(RecordEntry, entry)
+		if err != nil {
+			return err
+		}
+		written[hash.Fixed()] = true
+	}
+	return nil
+}
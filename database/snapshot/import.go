@@ -0,0 +1,320 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package snapshot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/FactomProject/factomd/common/adminBlock"
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/directoryBlock"
+	"github.com/FactomProject/factomd/common/entryBlock"
+	"github.com/FactomProject/factomd/common/entryCreditBlock"
+	"github.com/FactomProject/factomd/common/factoid"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// BlockSet is a directory block with the blocks and entries it references
+type BlockSet struct {
+	DBlock  interfaces.IDirectoryBlock
+	ABlock  interfaces.IAdminBlock
+	FBlock  interfaces.IFBlock
+	ECBlock interfaces.IEntryCreditBlock
+	EBlocks []interfaces.IEntryBlock
+	Entries []interfaces.IEBEntry
+}
+
+// ReadBlockSets reads the blocks file of the snapshot in dir, calling f on the block set of
+// every height in order and stopping at the first error
+func ReadBlockSets(dir string, f func(set *BlockSet) error) error {
+	file, err := os.Open(filepath.Join(dir, BlocksFile))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rr := newRecordReader(file)
+	var set *BlockSet
+	for {
+		recordType, data, err := rr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if recordType == RecordDBlock {
+			if set != nil {
+				err = f(set)
+				if err != nil {
+					return err
+				}
+			}
+			set = new(BlockSet)
+		} else if set == nil {
+			return fmt.Errorf("the blocks file does not start with a directory block")
+		}
+
+		err = set.add(recordType, data)
+		if err != nil {
+			return err
+		}
+	}
+	if set != nil {
+		return f(set)
+	}
+	return nil
+}
+
+// add decodes a record into the block set
+func (set *BlockSet) add(recordType byte, data []byte) error {
+	var err error
+	switch recordType {
+	case RecordDBlock:
+		set.DBlock = directoryBlock.NewDirectoryBlock(nil)
+		err = set.DBlock.UnmarshalBinary(data)
+	case RecordABlock:
+		set.ABlock = adminBlock.NewAdminBlock(nil)
+		err = set.ABlock.UnmarshalBinary(data)
+	case RecordFBlock:
+		set.FBlock = factoid.NewFBlock(nil)
+		err = set.FBlock.UnmarshalBinary(data)
+	case RecordECBlock:
+		set.ECBlock = entryCreditBlock.NewECBlock()
+		err = set.ECBlock.UnmarshalBinary(data)
+	case RecordEBlock:
+		eblock := entryBlock.NewEBlock()
+		err = eblock.UnmarshalBinary(data)
+		set.EBlocks = append(set.EBlocks, eblock)
+	case RecordEntry:
+		entry := entryBlock.NewEntry()
+		err = entry.UnmarshalBinary(data)
+		set.Entries = append(set.Entries, entry)
+	default:
+		return fmt.Errorf("unknown record type %d", recordType)
+	}
+	return err
+}
+
+// Verifier checks that the block sets of a snapshot form the directory block chain described
+// by its manifest, and that every block set holds exactly the blocks and entries its directory
+// block references
+type Verifier struct {
+	manifest  *Manifest
+	next      uint32 // height of the next directory block
+	prevKeyMR interfaces.IHash
+}
+
+func NewVerifier(m *Manifest) *Verifier {
+	v := new(Verifier)
+	v.manifest = m
+	return v
+}
+
+// indexed is a block that can be found by its primary or secondary index
+type indexed interface {
+	DatabasePrimaryIndex() interfaces.IHash
+	DatabaseSecondaryIndex() interfaces.IHash
+}
+
+func matches(block indexed, keyMR interfaces.IHash) bool {
+	return block.DatabasePrimaryIndex().IsSameAs(keyMR) || block.DatabaseSecondaryIndex().IsSameAs(keyMR)
+}
+
+// Verify checks the block set of the next height
+func (v *Verifier) Verify(set *BlockSet) error {
+	dblock := set.DBlock
+	h := dblock.GetHeader().GetDBHeight()
+	if h != v.next {
+		return fmt.Errorf("expected directory block %d, found %d", v.next, h)
+	}
+	if h > v.manifest.Height {
+		return fmt.Errorf("directory block %d is above the snapshot height %d", h, v.manifest.Height)
+	}
+	if dblock.GetHeader().GetNetworkID() != v.manifest.NetworkID {
+		return fmt.Errorf("directory block %d is for network %x, expected %x", h, dblock.GetHeader().GetNetworkID(), v.manifest.NetworkID)
+	}
+
+	keyMR := dblock.GetKeyMR()
+	if h > 0 && !dblock.GetHeader().GetPrevKeyMR().IsSameAs(v.prevKeyMR) {
+		return fmt.Errorf("directory block %d does not follow directory block %d", h, h-1)
+	}
+	if v.manifest.NetworkID == constants.MAIN_NETWORK_ID {
+		if checkpoint := constants.CheckPoints[h]; checkpoint != "" && checkpoint != keyMR.String() {
+			return fmt.Errorf("directory block %d has KeyMR %s, the checkpoint is %s", h, keyMR.String(), checkpoint)
+		}
+	}
+
+	err := verifyReferences(set)
+	if err != nil {
+		return fmt.Errorf("directory block %d: %v", h, err)
+	}
+
+	v.prevKeyMR = keyMR
+	v.next++
+	return nil
+}
+
+// Finish checks that the snapshot ended at the head given by the manifest
+func (v *Verifier) Finish() error {
+	if v.next != v.manifest.Height+1 {
+		return fmt.Errorf("the snapshot ends at height %d, expected %d", int64(v.next)-1, v.manifest.Height)
+	}
+	if v.prevKeyMR.String() != v.manifest.HeadKeyMR {
+		return fmt.Errorf("the snapshot ends at KeyMR %s, expected %s", v.prevKeyMR.String(), v.manifest.HeadKeyMR)
+	}
+	return nil
+}
+
+// verifyReferences checks that the blocks and entries of a set are the ones its directory
+// block references
+func verifyReferences(set *BlockSet) error {
+	eblocks := 0
+	entries := map[[32]byte]bool{}
+	for _, e := range set.DBlock.GetDBEntries() {
+		chainID := e.GetChainID().Bytes()
+		switch {
+		case bytes.Equal(chainID, constants.ADMIN_CHAINID):
+			if set.ABlock == nil || !matches(set.ABlock, e.GetKeyMR()) {
+				return fmt.Errorf("admin block %s is missing", e.GetKeyMR().String())
+			}
+		case bytes.Equal(chainID, constants.FACTOID_CHAINID):
+			if set.FBlock == nil || !matches(set.FBlock, e.GetKeyMR()) {
+				return fmt.Errorf("factoid block %s is missing", e.GetKeyMR().String())
+			}
+		case bytes.Equal(chainID, constants.EC_CHAINID):
+			if set.ECBlock == nil || !matches(set.ECBlock, e.GetKeyMR()) {
+				return fmt.Errorf("entry credit block %s is missing", e.GetKeyMR().String())
+			}
+		default:
+			var eblock interfaces.IEntryBlock
+			for _, eb := range set.EBlocks {
+				if matches(eb, e.GetKeyMR()) && eb.GetChainID().IsSameAs(e.GetChainID()) {
+					eblock = eb
+					break
+				}
+			}
+			if eblock == nil {
+				return fmt.Errorf("entry block %s is missing", e.GetKeyMR().String())
+			}
+			eblocks++
+			for _, hash := range eblock.GetEntryHashes() {
+				if !hash.IsMinuteMarker() {
+					entries[hash.Fixed()] = true
+				}
+			}
+		}
+	}
+	if eblocks != len(set.EBlocks) {
+		return fmt.Errorf("holds %d entry blocks, but references %d", len(set.EBlocks), eblocks)
+	}
+
+	found := map[[32]byte]bool{}
+	for _, entry := range set.Entries {
+		hash := entry.GetHash().Fixed()
+		if !entries[hash] {
+			return fmt.Errorf("entry %x is not in any of the entry blocks", hash)
+		}
+		found[hash] = true
+	}
+	if len(found) != len(entries) {
+		return fmt.Errorf("holds %d of the %d entries of its entry blocks", len(found), len(entries))
+	}
+	return nil
+}
+
+// SaveBlockSet saves the blocks and entries of a set in a single batch
+func SaveBlockSet(db interfaces.DBOverlaySimple, set *BlockSet) (err error) {
+	db.StartMultiBatch()
+	executed := false
+	defer func() {
+		if !executed {
+			db.AbortMultiBatch()
+		}
+	}()
+
+	if err = db.ProcessABlockMultiBatch(set.ABlock); err != nil {
+		return err
+	}
+	if err = db.ProcessFBlockMultiBatch(set.FBlock); err != nil {
+		return err
+	}
+	if err = db.ProcessECBlockMultiBatch(set.ECBlock, false); err != nil {
+		return err
+	}
+	for _, eblock := range set.EBlocks {
+		if err = db.ProcessEBlockMultiBatch(eblock, true); err != nil {
+			return err
+		}
+	}
+	for _, entry := range set.Entries {
+		if err = db.InsertEntryMultiBatch(entry); err != nil {
+			return err
+		}
+	}
+	if err = db.ProcessDBlockMultiBatch(set.DBlock); err != nil {
+		return err
+	}
+
+	// ExecuteMultiBatch ends the batch whether it fails or not
+	executed = true
+	return db.ExecuteMultiBatch()
+}
+
+// Import verifies the snapshot in dir and saves its blocks into db, which must be empty.  The
+// whole snapshot is verified before anything is written.  If trustedHeadKeyMR is not empty, the
+// snapshot must end at that directory block.
+func Import(db interfaces.DBOverlaySimple, dir string, trustedHeadKeyMR string) (*Manifest, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	if trustedHeadKeyMR != "" && trustedHeadKeyMR != m.HeadKeyMR {
+		return nil, fmt.Errorf("the snapshot ends at KeyMR %s, expected %s", m.HeadKeyMR, trustedHeadKeyMR)
+	}
+	err = VerifyFiles(dir, m)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := db.FetchDBlockHead()
+	if err != nil {
+		return nil, err
+	}
+	if head != nil {
+		return nil, fmt.Errorf("can't import a snapshot into a database that already holds blocks")
+	}
+
+	v := NewVerifier(m)
+	err = ReadBlockSets(dir, v.Verify)
+	if err == nil {
+		err = v.Finish()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot verification failed: %v", err)
+	}
+
+	// Verify again while saving, in case the file changed since it was checked
+	v = NewVerifier(m)
+	err = ReadBlockSets(dir, func(set *BlockSet) error {
+		err := v.Verify(set)
+		if err != nil {
+			return err
+		}
+		return SaveBlockSet(db, set)
+	})
+	if err == nil {
+		err = v.Finish()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
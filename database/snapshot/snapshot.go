@@ -0,0 +1,224 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+// Package snapshot creates and imports snapshots of the block database.
+//
+// A snapshot is a directory holding:
+//
+//	manifest.json  what the snapshot holds, with the size and sha256 of every other file
+//	blocks.dat     every block and entry from height 0 up to the snapshot height
+//	FastBoot_*.db  optionally, the fastboot SaveState of the node the snapshot was taken from
+//
+// blocks.dat is a sequence of records, each a one byte type, a 4 byte big endian length and the
+// marshalled block or entry.  The records are grouped by height: the directory block comes
+// first, followed by the admin, factoid, entry credit and entry blocks it references, and the
+// entries of those entry blocks.
+//
+// The manifest is written last, so a directory without a manifest is an incomplete snapshot.
+package snapshot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// Version of the snapshot format
+const Version = 1
+
+const (
+	ManifestFile = "manifest.json"
+	BlocksFile   = "blocks.dat"
+)
+
+// Record types in the blocks file
+const (
+	RecordDBlock byte = iota + 1
+	RecordABlock
+	RecordFBlock
+	RecordECBlock
+	RecordEBlock
+	RecordEntry
+)
+
+// maxRecordSize guards against allocating huge buffers when reading a corrupted blocks file
+const maxRecordSize = 100 * 1024 * 1024
+
+// Manifest describes a snapshot
+type Manifest struct {
+	Version        int
+	Network        string
+	NetworkID      uint32
+	Height         uint32 // height of the last directory block in the snapshot
+	HeadKeyMR      string // KeyMR of the last directory block in the snapshot
+	Created        time.Time
+	FastBootFile   string `json:",omitempty"` // name of the fastboot file, empty if there is none
+	FastBootHeight uint32 `json:",omitempty"` // height of the fastboot SaveState
+	Files          []FileChecksum
+}
+
+// FileChecksum is the size and sha256 of a file of the snapshot
+type FileChecksum struct {
+	Name   string
+	Size   int64
+	SHA256 string
+}
+
+// ReadManifest reads the manifest of the snapshot in dir
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("snapshot version %d is not supported, expected %d", m.Version, Version)
+	}
+	// The files must be in the snapshot directory, so a crafted manifest can't read elsewhere
+	for _, f := range m.Files {
+		if !isFileName(f.Name) {
+			return nil, fmt.Errorf("snapshot file %q is not a file name", f.Name)
+		}
+	}
+	if m.FastBootFile != "" && !isFileName(m.FastBootFile) {
+		return nil, fmt.Errorf("snapshot fastboot file %q is not a file name", m.FastBootFile)
+	}
+	return m, nil
+}
+
+// isFileName returns true if name is the name of a file in a directory, not a path
+func isFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name && !filepath.IsAbs(name)
+}
+
+// WriteManifest writes the manifest of a snapshot.  The manifest is written to a temporary file
+// first, so it only appears once it is complete.
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+// VerifyFiles checks the size and checksum of every file listed in the manifest
+func VerifyFiles(dir string, m *Manifest) error {
+	for _, f := range m.Files {
+		c, err := fileChecksum(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if c.Size != f.Size || c.SHA256 != f.SHA256 {
+			return fmt.Errorf("snapshot file %s is corrupted: expected %d bytes with sha256 %s, found %d bytes with sha256 %s",
+				f.Name, f.Size, f.SHA256, c.Size, c.SHA256)
+		}
+	}
+	return nil
+}
+
+func fileChecksum(dir string, name string) (FileChecksum, error) {
+	c := FileChecksum{Name: name}
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	c.Size, err = io.Copy(h, f)
+	if err != nil {
+		return c, err
+	}
+	c.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return c, nil
+}
+
+// recordWriter writes the records of the blocks file, computing its checksum on the way
+type recordWriter struct {
+	w    *bufio.Writer
+	hash hash.Hash
+	size int64
+}
+
+func newRecordWriter(w io.Writer) *recordWriter {
+	rw := new(recordWriter)
+	rw.hash = sha256.New()
+	rw.w = bufio.NewWriter(io.MultiWriter(w, rw.hash))
+	return rw
+}
+
+func (rw *recordWriter) Write(recordType byte, data interfaces.BinaryMarshallable) error {
+	b, err := data.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 5)
+	header[0] = recordType
+	binary.BigEndian.PutUint32(header[1:], uint32(len(b)))
+	_, err = rw.w.Write(header)
+	if err != nil {
+		return err
+	}
+	_, err = rw.w.Write(b)
+	if err != nil {
+		return err
+	}
+	rw.size += int64(len(header) + len(b))
+	return nil
+}
+
+func (rw *recordWriter) Flush() error {
+	return rw.w.Flush()
+}
+
+// recordReader reads the records of the blocks file
+type recordReader struct {
+	r *bufio.Reader
+}
+
+func newRecordReader(r io.Reader) *recordReader {
+	return &recordReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record, or io.EOF at the end of the file
+func (rr *recordReader) Next() (byte, []byte, error) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(rr.r, header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated record header")
+		}
+		return 0, nil, err
+	}
+	l := binary.BigEndian.Uint32(header[1:])
+	if l > maxRecordSize {
+		return 0, nil, fmt.Errorf("record of %d bytes is too large", l)
+	}
+	data := make([]byte, l)
+	_, err = io.ReadFull(rr.r, data)
+	if err != nil {
+		return 0, nil, fmt.Errorf("truncated record: %v", err)
+	}
+	return header[0], data, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package snapshot_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/FactomProject/factomd/database/snapshot"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func createSnapshot(t *testing.T, height uint32) (string, *Manifest) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	fb := &FastBoot{Filename: "FastBoot_LOCAL_v9.db", Height: height, Data: []byte("fastboot")}
+	m, err := Create(dbo, dir, "LOCAL", height, fb)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("%v", err)
+	}
+	return dir, m
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	height := uint32(testHelper.BlockCount - 1)
+	dir, m := createSnapshot(t, height)
+	defer os.RemoveAll(dir)
+
+	if m.Height != height || len(m.Files) != 2 {
+		t.Fatalf("Wrong manifest %v", m)
+	}
+	m2, err := ReadManifest(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if m2.HeadKeyMR != m.HeadKeyMR {
+		t.Errorf("Manifest read back does not match")
+	}
+
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	_, err = Import(dbo, dir, m.HeadKeyMR)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	source := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	for h := uint32(0); h <= height; h++ {
+		expected, _ := source.FetchDBlockByHeight(h)
+		imported, err := dbo.FetchDBlockByHeight(h)
+		if err != nil || imported == nil {
+			t.Fatalf("Directory block %d was not imported: %v", h, err)
+		}
+		if !imported.GetKeyMR().IsSameAs(expected.GetKeyMR()) {
+			t.Errorf("Directory block %d does not match", h)
+		}
+		for _, e := range expected.GetEBlockDBEntries() {
+			eblock, _ := source.FetchEBlock(e.GetKeyMR())
+			for _, hash := range eblock.GetEntryHashes() {
+				if hash.IsMinuteMarker() {
+					continue
+				}
+				entry, err := dbo.FetchEntry(hash)
+				if err != nil || entry == nil {
+					t.Errorf("Entry %s was not imported: %v", hash.String(), err)
+				}
+			}
+		}
+	}
+
+	// Importing twice must fail, the database is no longer empty
+	_, err = Import(dbo, dir, "")
+	if err == nil {
+		t.Errorf("Import into a non empty database should fail")
+	}
+}
+
+func TestSnapshotPartialHeight(t *testing.T) {
+	dir, m := createSnapshot(t, 3)
+	defer os.RemoveAll(dir)
+
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	_, err := Import(dbo, dir, "")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	head, _ := dbo.FetchDBlockHead()
+	if head == nil || head.GetKeyMR().String() != m.HeadKeyMR {
+		t.Errorf("Wrong head after import")
+	}
+	if dblock, _ := dbo.FetchDBlockByHeight(4); dblock != nil {
+		t.Errorf("Blocks above the snapshot height were imported")
+	}
+}
+
+func TestSnapshotWrongKeyMR(t *testing.T) {
+	dir, _ := createSnapshot(t, 3)
+	defer os.RemoveAll(dir)
+
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	_, err := Import(dbo, dir, "0000000000000000000000000000000000000000000000000000000000000000")
+	if err == nil {
+		t.Errorf("Import of a snapshot not ending at the trusted KeyMR should fail")
+	}
+	if head, _ := dbo.FetchDBlockHead(); head != nil {
+		t.Errorf("A failed import wrote to the database")
+	}
+}
+
+func TestSnapshotCorrupted(t *testing.T) {
+	dir, _ := createSnapshot(t, 3)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, BlocksFile)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	data[len(data)/2] ^= 0xFF
+	err = ioutil.WriteFile(filename, data, 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	_, err = Import(dbo, dir, "")
+	if err == nil {
+		t.Errorf("Import of a corrupted snapshot should fail")
+	}
+	if head, _ := dbo.FetchDBlockHead(); head != nil {
+		t.Errorf("A failed import wrote to the database")
+	}
+}
+
+func TestSnapshotFilesOutsideDirectory(t *testing.T) {
+	dir, m := createSnapshot(t, 3)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"../" + BlocksFile, "/etc/passwd", "..", ""} {
+		bad := *m
+		bad.Files = append([]FileChecksum{{Name: name}}, m.Files...)
+		if err := WriteManifest(dir, &bad); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if _, err := ReadManifest(dir); err == nil {
+			t.Errorf("A manifest listing the file %q was accepted", name)
+		}
+	}
+
+	bad := *m
+	bad.FastBootFile = "../FastBoot_LOCAL_v9.db"
+	if err := WriteManifest(dir, &bad); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := ReadManifest(dir); err == nil {
+		t.Errorf("A manifest with the fastboot file %q was accepted", bad.FastBootFile)
+	}
+}
+
+func TestSnapshotFastBootAboveHeight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fb := &FastBoot{Filename: "FastBoot_LOCAL_v9.db", Height: 5, Data: []byte("fastboot")}
+	_, err = Create(testHelper.CreateAndPopulateTestDatabaseOverlay(), dir, "LOCAL", 3, fb)
+	if err == nil {
+		t.Errorf("A fastboot state above the snapshot height should be refused")
+	}
+}
@@ -7,11 +7,13 @@ package database_test
 import (
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/databaseOverlay"
 	"github.com/FactomProject/factomd/database/leveldb"
@@ -49,11 +51,29 @@ func (t *TestData) UnmarshalBinary(data []byte) (err error) {
 
 var _ interfaces.BinaryMarshallable = (*TestData)(nil)
 
+// CrashingData simulates the node dying part way through a write: it panics when marshalled
+type CrashingData struct {
+	TestData
+}
+
+func (t *CrashingData) MarshalBinary() ([]byte, error) {
+	panic("injected crash")
+}
+
+// FailingData fails to marshal, making the write it is part of fail
+type FailingData struct {
+	TestData
+}
+
+func (t *FailingData) MarshalBinary() ([]byte, error) {
+	return nil, fmt.Errorf("injected failure")
+}
+
 var dbFilename = "testdb"
 
 func TestAllDatabases(t *testing.T) {
 	// Secure Bolt
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		m, err := securedb.NewEncryptedDB(dbFilename, "Bolt", random.RandomString())
 		if err != nil {
 			t.Error(err)
@@ -63,7 +83,7 @@ func TestAllDatabases(t *testing.T) {
 	}
 
 	// Secure LDB
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		m, err := securedb.NewEncryptedDB(dbFilename, "LDB", random.RandomString())
 		if err != nil {
 			t.Error(err)
@@ -73,7 +93,7 @@ func TestAllDatabases(t *testing.T) {
 	}
 
 	// Secure Map
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		m, err := securedb.NewEncryptedDB(dbFilename, "Map", random.RandomString())
 		if err != nil {
 			t.Error(err)
@@ -83,14 +103,14 @@ func TestAllDatabases(t *testing.T) {
 	}
 
 	// Bolt
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		m := boltdb.NewBoltDB(nil, dbFilename)
 		testDB(t, m, i)
 		CleanupTest(t, m)
 	}
 
 	// Level
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		m, err := leveldb.NewLevelDB(dbFilename, true)
 		if err != nil {
 			t.Error(err)
@@ -99,8 +119,28 @@ func TestAllDatabases(t *testing.T) {
 		CleanupTest(t, m)
 	}
 
+	// Badger
+	for i := 0; i < 6; i++ {
+		m, err := badgerdb.NewBadgerDB(dbFilename, true)
+		if err != nil {
+			t.Error(err)
+		}
+		testDB(t, m, i)
+		CleanupTest(t, m)
+	}
+
+	// Secure Badger
+	for i := 0; i < 6; i++ {
+		m, err := securedb.NewEncryptedDB(dbFilename, "Badger", random.RandomString())
+		if err != nil {
+			t.Error(err)
+		}
+		testDB(t, m, i)
+		CleanupTest(t, m)
+	}
+
 	// Map
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 6; i++ {
 		m := new(mapdb.MapDB)
 		testDB(t, m, i)
 		CleanupTest(t, m)
@@ -117,6 +157,10 @@ func testDB(t *testing.T, m interfaces.IDatabase, i int) {
 		testDoesKeyExist(t, m)
 	case 3:
 		testGetAll(t, m)
+	case 4:
+		testIterate(t, m)
+	case 5:
+		testPutAndDeleteInBatch(t, m)
 	}
 }
 
@@ -291,3 +335,144 @@ func testGetAll(t *testing.T, m interfaces.IDatabase) {
 		}
 	}
 }
+
+func iterateKeys(t *testing.T, m interfaces.IDatabase, bucket []byte, options interfaces.IteratorOptions) []string {
+	it, err := m.Iterate(bucket, options)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer it.Release()
+
+	keys := []string{}
+	for it.Next() {
+		test := new(TestData)
+		err = test.UnmarshalBinary(it.Value())
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if test.Str != "value "+string(it.Key()) {
+			t.Errorf("Wrong value %q for key %q", test.Str, it.Key())
+		}
+		keys = append(keys, string(it.Key()))
+	}
+	if it.Error() != nil {
+		t.Errorf("%v", it.Error())
+	}
+	return keys
+}
+
+func testIterate(t *testing.T, m interfaces.IDatabase) {
+	defer CleanupTest(t, m)
+
+	bucket := []byte("iterate")
+	for _, key := range []string{"b2", "a1", "b1", "c1", "a2", "b3"} {
+		test := new(TestData)
+		test.Str = "value " + key
+		err := m.Put(bucket, []byte(key), test)
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+	// A record in another bucket must never show up
+	err := m.Put([]byte("iterate2"), []byte("a0"), &TestData{Str: "value a0"})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	tests := []struct {
+		options  interfaces.IteratorOptions
+		expected string
+	}{
+		{interfaces.IteratorOptions{}, "a1 a2 b1 b2 b3 c1"},
+		{interfaces.IteratorOptions{Reverse: true}, "c1 b3 b2 b1 a2 a1"},
+		{interfaces.IteratorOptions{Prefix: []byte("b")}, "b1 b2 b3"},
+		{interfaces.IteratorOptions{Prefix: []byte("b"), Reverse: true}, "b3 b2 b1"},
+		{interfaces.IteratorOptions{Start: []byte("a2"), Limit: []byte("b3")}, "a2 b1 b2"},
+		{interfaces.IteratorOptions{Start: []byte("a2"), Limit: []byte("b3"), Reverse: true}, "b2 b1 a2"},
+		{interfaces.IteratorOptions{Prefix: []byte("b"), Start: []byte("b2")}, "b2 b3"},
+		{interfaces.IteratorOptions{Prefix: []byte("d")}, ""},
+	}
+	for _, test := range tests {
+		keys := strings.Join(iterateKeys(t, m, bucket, test.options), " ")
+		if keys != test.expected {
+			t.Errorf("Iterate(%+v) returned %q, expected %q", test.options, keys, test.expected)
+		}
+	}
+
+	if keys := iterateKeys(t, m, []byte("missing"), interfaces.IteratorOptions{}); len(keys) != 0 {
+		t.Errorf("Iterating over a missing bucket returned %v", keys)
+	}
+}
+
+func testPutAndDeleteInBatch(t *testing.T, m interfaces.IDatabase) {
+	defer CleanupTest(t, m)
+
+	buckets := [][]byte{[]byte("atomicA"), []byte("atomicB")}
+	err := m.Put(buckets[0], []byte("old"), &TestData{Str: "old"})
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	deletes := []interfaces.Record{{buckets[0], []byte("old"), nil}}
+
+	newRecords := func(broken int, brokenData interfaces.BinaryMarshallable) []interfaces.Record {
+		records := []interfaces.Record{}
+		for i := 0; i < 4; i++ {
+			var data interfaces.BinaryMarshallable = &TestData{Str: fmt.Sprintf("new%d", i)}
+			if i == broken {
+				data = brokenData
+			}
+			records = append(records, interfaces.Record{buckets[i%2], []byte(fmt.Sprintf("new%d", i)), data})
+		}
+		return records
+	}
+	checkUnchanged := func(when string) {
+		exists, err := m.DoesKeyExist(buckets[0], []byte("old"))
+		if err != nil || !exists {
+			t.Errorf("%s: the deleted key is gone, the batch was partially applied", when)
+		}
+		for i := 0; i < 4; i++ {
+			exists, err := m.DoesKeyExist(buckets[i%2], []byte(fmt.Sprintf("new%d", i)))
+			if err != nil || exists {
+				t.Errorf("%s: record new%d was written, the batch was partially applied", when, i)
+			}
+		}
+	}
+
+	// Crash at every position of the batch
+	for crashAt := 0; crashAt < 4; crashAt++ {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Injected crash at record %d did not happen", crashAt)
+				}
+			}()
+			m.PutAndDeleteInBatch(newRecords(crashAt, new(CrashingData)), deletes)
+		}()
+		checkUnchanged(fmt.Sprintf("crash at record %d", crashAt))
+	}
+
+	// Fail at every position of the batch
+	for failAt := 0; failAt < 4; failAt++ {
+		err = m.PutAndDeleteInBatch(newRecords(failAt, new(FailingData)), deletes)
+		if err == nil {
+			t.Errorf("Injected failure at record %d did not return an error", failAt)
+		}
+		checkUnchanged(fmt.Sprintf("failure at record %d", failAt))
+	}
+
+	err = m.PutAndDeleteInBatch(newRecords(-1, nil), deletes)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	exists, err := m.DoesKeyExist(buckets[0], []byte("old"))
+	if err != nil || exists {
+		t.Errorf("Deleted key still exists")
+	}
+	for i := 0; i < 4; i++ {
+		test := new(TestData)
+		data, err := m.Get(buckets[i%2], []byte(fmt.Sprintf("new%d", i)), test)
+		if err != nil || data == nil || test.Str != fmt.Sprintf("new%d", i) {
+			t.Errorf("Record new%d was not written", i)
+		}
+	}
+}
@@ -65,6 +65,10 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 	s.ControlPanelPort = 8090
 	logPort = p.LogPort
 
+	if p.Deterministic {
+		StartDeterministic(p.Seed)
+	}
+
 	messages.AckBalanceHash = p.AckbalanceHash
 	// Must add the prefix before loading the configuration.
 	s.AddPrefix(p.Prefix)
@@ -277,6 +281,8 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 	os.Stderr.WriteString(fmt.Sprintf("%20s %d\n", "FastSaveRate", p.FastSaveRate))
 	os.Stderr.WriteString(fmt.Sprintf("%20s \"%s\"\n", "net spec", pnet))
 	os.Stderr.WriteString(fmt.Sprintf("%20s %d\n", "Msgs droped", p.DropRate))
+	os.Stderr.WriteString(fmt.Sprintf("%20s %v\n", "deterministic", p.Deterministic))
+	os.Stderr.WriteString(fmt.Sprintf("%20s %d\n", "seed", p.Seed))
 	os.Stderr.WriteString(fmt.Sprintf("%20s \"%s\"\n", "journal", p.Journal))
 	os.Stderr.WriteString(fmt.Sprintf("%20s \"%s\"\n", "database", p.Db))
 	os.Stderr.WriteString(fmt.Sprintf("%20s \"%s\"\n", "database for clones", p.CloneDB))
@@ -331,22 +337,25 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 
 	// Start the P2P network
 	var networkID p2p.NetworkID
-	var seedURL, networkPort, configPeers string
+	var seedURL, seedPublicKey, networkPort, configPeers string
 	switch s.Network {
 	case "MAIN", "main":
 		networkID = p2p.MainNet
 		seedURL = s.MainSeedURL
+		seedPublicKey = s.MainSeedPublicKey
 		networkPort = s.MainNetworkPort
 		configPeers = s.MainSpecialPeers
 		s.DirectoryBlockInSeconds = 600
 	case "TEST", "test":
 		networkID = p2p.TestNet
 		seedURL = s.TestSeedURL
+		seedPublicKey = s.TestSeedPublicKey
 		networkPort = s.TestNetworkPort
 		configPeers = s.TestSpecialPeers
 	case "LOCAL", "local":
 		networkID = p2p.LocalNet
 		seedURL = s.LocalSeedURL
+		seedPublicKey = s.LocalSeedPublicKey
 		networkPort = s.LocalNetworkPort
 		configPeers = s.LocalSpecialPeers
 
@@ -363,6 +372,7 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 			fnodes[i].State.CustomNetworkID = p.CustomNet
 		}
 		seedURL = s.CustomSeedURL
+		seedPublicKey = s.CustomSeedPublicKey
 		networkPort = s.CustomNetworkPort
 		configPeers = s.CustomSpecialPeers
 
@@ -385,14 +395,18 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 		ci := p2p.ControllerInit{
 			NodeName:                 nodeName,
 			Port:                     networkPort,
+			Listeners:                s.P2PListeners,
 			PeersFile:                s.PeersFile,
 			Network:                  networkID,
 			Exclusive:                p.Exclusive,
 			ExclusiveIn:              p.ExclusiveIn,
 			SeedURL:                  seedURL,
+			SeedPublicKey:            seedPublicKey,
 			ConfigPeers:              configPeers,
 			CmdLinePeers:             p.Peers,
 			ConnectionMetricsChannel: connectionMetricsChannel,
+			BanDuration:              s.BanDuration,
+			TraceMessages:            p.TraceMessages,
 		}
 		p2pNetwork = new(p2p.Controller).Init(ci)
 		fnodes[0].State.NetworkController = p2pNetwork
@@ -544,6 +558,9 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 	} else {
 		startServers(true)
 	}
+	if p.Deterministic {
+		go runSimClock()
+	}
 
 	// Start the webserver
 	wsapi.Start(fnodes[0].State)
@@ -614,6 +631,9 @@ func startServers(load bool) {
 		go fnode.State.GoSyncEntries()
 		go Timer(fnode.State)
 		go elections.Run(fnode.State)
+		if Params.IntegrityCheck {
+			fnode.State.StartIntegrityVerifier(time.Duration(Params.IntegrityDelay)*time.Millisecond, Params.IntegrityRefetch)
+		}
 		go fnode.State.ValidatorLoop()
 	}
 }
@@ -8,19 +8,24 @@ import (
 	"bytes"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/FactomProject/factomd/common/constants"
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/messages/msgsupport"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/util/atomic"
 )
 
 var _ = fmt.Print
 var _ = bytes.Compare
 
 type SimPacket struct {
-	data []byte
-	sent int64 // Time in milliseconds
+	data    []byte
+	sent    int64 // Time in milliseconds
+	deliver int64 // Time in milliseconds the packet can be received, set by the sender
 }
 
 type SimPeer struct {
@@ -32,10 +37,18 @@ type SimPeer struct {
 	BroadcastIn  chan *SimPacket
 
 	// Delay in Milliseconds
-	Delay    int64 // The maximum delay
-	DelayUse int64 // We actually select a random delay for each data element.
-	// Were we hold delayed packets
-	Delayed *SimPacket
+	Delay int64 // The maximum delay, a random delay is selected for each packet
+	// Were we hold packets received until they are due
+	pending      []*SimPacket
+	pendingMutex sync.Mutex
+	released     int // Packets the clock of a deterministic simulation lets be received
+
+	// The fault model of the link, set by SetSimLinkModel
+	model      *LinkModel
+	modelMutex sync.Mutex
+	rng        *rand.Rand
+	nextFree   int64 // Time in milliseconds the bandwidth cap lets the next packet go
+	lastDue    int64 // Time in milliseconds the last packet kept in order is delivered
 
 	bytesOut int // Bytes sent out
 	bytesIn  int // Bytes received
@@ -44,6 +57,8 @@ type SimPeer struct {
 
 	RateOut int // Rate of Bytes output per ms
 	RateIn  int // Rate of Bytes input per ms
+
+	Cut atomic.AtomicBool // Everything sent is lost, set by ApplySimPartition
 }
 
 var _ interfaces.IPeer = (*SimPeer)(nil)
@@ -90,6 +105,7 @@ func (f *SimPeer) Init(fromName, toName string) interfaces.IPeer {
 	f.FromName = fromName
 	f.BroadcastOut = make(chan *SimPacket, 10000)
 	f.Last = time.Now().UnixNano()
+	f.rng = random.NewRand(fromName + "->" + toName) // follows the seed of a deterministic simulation
 	return f
 }
 
@@ -114,6 +130,72 @@ func (f *SimPeer) computeBandwidth() {
 	f.Last = now
 }
 
+// SetModel sets the fault model of the link, nil for a perfect link
+func (f *SimPeer) SetModel(model *LinkModel) {
+	f.modelMutex.Lock()
+	defer f.modelMutex.Unlock()
+	f.model = model
+}
+
+// GetModel returns the fault model of the link, nil for a perfect link
+func (f *SimPeer) GetModel() *LinkModel {
+	f.modelMutex.Lock()
+	defer f.modelMutex.Unlock()
+	return f.model
+}
+
+// schedule returns the times a packet of size bytes sent now is delivered, none if it is lost
+// and two if it is duplicated.  Packets are delivered in the order they are sent, a random delay
+// only holds up the packets behind, except for the packets a model with Reorder lets overtake.
+func (f *SimPeer) schedule(now int64, size int) []int64 {
+	f.modelMutex.Lock()
+	defer f.modelMutex.Unlock()
+	inOrder := func(due int64) int64 {
+		if due < f.lastDue {
+			due = f.lastDue
+		}
+		f.lastDue = due
+		return due
+	}
+	m := f.model
+	if m == nil {
+		if f.Delay > 0 {
+			return []int64{inOrder(now + f.rng.Int63n(f.Delay))}
+		}
+		return []int64{inOrder(now)}
+	}
+	if m.Drop > 0 && f.rng.Intn(1000) < m.Drop {
+		return nil
+	}
+
+	// The bandwidth cap queues the packets on the link, one after the other
+	start := now
+	if m.Bandwidth > 0 {
+		if f.nextFree > start {
+			start = f.nextFree
+		}
+		f.nextFree = start + int64(size)*1000/int64(m.Bandwidth)
+	}
+
+	due := func() int64 {
+		if m.Reorder > 0 && f.rng.Intn(1000) < m.Reorder {
+			return start
+		}
+		if m.Latency.Kind != "" {
+			return inOrder(start + m.Latency.Sample(f.rng))
+		}
+		if f.Delay > 0 {
+			return inOrder(start + f.rng.Int63n(f.Delay))
+		}
+		return inOrder(start)
+	}
+	times := []int64{due()}
+	if m.Duplicate > 0 && f.rng.Intn(1000) < m.Duplicate {
+		times = append(times, due())
+	}
+	return times
+}
+
 func (f *SimPeer) Send(msg interfaces.IMsg) error {
 	data, err := msg.MarshalBinary()
 	f.bytesOut += len(data)
@@ -122,49 +204,102 @@ func (f *SimPeer) Send(msg interfaces.IMsg) error {
 		fmt.Println("ERROR on Send: ", err)
 		return err
 	}
-	if len(f.BroadcastOut) < 9000 {
-		packet := SimPacket{data: data, sent: time.Now().UnixNano() / 1000000}
-		f.BroadcastOut <- &packet
+	if f.Cut.Load() {
+		return nil
+	}
+	now := primitives.Now().UnixNano() / 1000000
+	for _, deliver := range f.schedule(now, len(data)) {
+		if len(f.BroadcastOut) < 9000 {
+			packet := SimPacket{data: data, sent: now, deliver: deliver}
+			f.BroadcastOut <- &packet
+		}
 	}
 	return nil
 }
 
-// Non-blocking return value from channel.
-func (f *SimPeer) Receive() (interfaces.IMsg, error) {
-	if f.Delayed == nil {
+// collect moves the packets sent on the link to the pending packets, the pendingMutex held
+func (f *SimPeer) collect() {
+	for len(f.pending) < 1000 {
 		select {
 		case packet, ok := <-f.BroadcastIn:
-			if ok {
-				f.Delayed = packet
+			if !ok {
+				return
 			}
+			f.pending = append(f.pending, packet)
 		default:
-			return nil, nil // Nothing to do
+			return
 		}
-		if f.Delay > 0 {
-			f.DelayUse = rand.Int63n(f.Delay)
-		} else {
-			f.DelayUse = 0
+	}
+}
+
+// Non-blocking return value from channel.  Packets are received once they are due, in the order
+// they were sent unless the link model reorders them.  On the virtual clock, a packet due is only
+// received once the clock releases it, so the nodes handle the packets one at a time.
+func (f *SimPeer) Receive() (interfaces.IMsg, error) {
+	f.pendingMutex.Lock()
+	defer f.pendingMutex.Unlock()
+	f.collect()
+	if len(f.pending) == 0 {
+		return nil, nil // Nothing to do
+	}
+	if primitives.IsVirtualClock() && f.released == 0 {
+		return nil, nil
+	}
+
+	// Deliver the earliest packet due, the first sent of those due at the same time
+	now := primitives.Now().UnixNano() / 1000000
+	next := 0
+	for i, packet := range f.pending {
+		if packet.deliver < f.pending[next].deliver {
+			next = i
 		}
+	}
+	if f.pending[next].deliver > now {
+		return nil, nil
+	}
+	if f.released > 0 {
+		f.released--
+	}
+	data := f.pending[next].data
+	f.pending = append(f.pending[:next], f.pending[next+1:]...)
 
+	msg, err := msgsupport.UnmarshalMessage(data)
+	if err != nil {
+		fmt.Printf("SimPeer ERROR: %s %x %s\n", err.Error(), data[:8], constants.MessageName(data[0]))
 	}
 
-	now := time.Now().UnixNano() / 1000000
+	f.bytesIn += len(data)
+	f.computeBandwidth()
+	return msg, err
+}
 
-	if f.Delayed != nil && now-f.Delayed.sent > f.DelayUse {
-		data := f.Delayed.data
-		f.Delayed = nil
-		msg, err := msgsupport.UnmarshalMessage(data)
-		if err != nil {
-			fmt.Printf("SimPeer ERROR: %s %x %s\n", err.Error(), data[:8], constants.MessageName(data[0]))
+// Due returns the number of packets received that are due at the time now, in milliseconds,
+// and not yet released
+func (f *SimPeer) Due(now int64) int {
+	f.pendingMutex.Lock()
+	defer f.pendingMutex.Unlock()
+	f.collect()
+	due := 0
+	for _, packet := range f.pending {
+		if packet.deliver <= now {
+			due++
 		}
-
-		f.bytesIn += len(data)
-		f.computeBandwidth()
-		return msg, err
-	} else {
-		// fmt.Println("dddd Delay: ", now-f.Delayed.sent)
 	}
-	return nil, nil
+	return due - f.released
+}
+
+// Release lets the next packet due be received on the virtual clock
+func (f *SimPeer) Release() {
+	f.pendingMutex.Lock()
+	defer f.pendingMutex.Unlock()
+	f.released++
+}
+
+// Releasing returns true if a packet released is not yet received
+func (f *SimPeer) Releasing() bool {
+	f.pendingMutex.Lock()
+	defer f.pendingMutex.Unlock()
+	return f.released > 0
 }
 
 func AddSimPeer(fnodes []*FactomNode, i1 int, i2 int) {
@@ -203,6 +338,11 @@ func AddSimPeer(fnodes []*FactomNode, i1 int, i2 int) {
 	f1.Peers = append(f1.Peers, peer12)
 	f2.Peers = append(f2.Peers, peer21)
 
+	// The new link respects the partitions already applied
+	simPartitionsMutex.Lock()
+	cutSimPeers(fnodes)
+	simPartitionsMutex.Unlock()
+
 	// 	for _, p := range f1.Peers {
 	// 		fmt.Printf("%s's peer: %s\n", p.GetNameFrom(), p.GetNameTo())
 	// 	}
@@ -30,7 +30,18 @@ func init() {
 	flag.IntVar(&p.FaultTimeout, "faulttimeout", 120, "Seconds before considering Federated servers at-fault. Default is 120.")
 	flag.IntVar(&p.RoundTimeout, "roundtimeout", 30, "Seconds before audit servers will increment rounds and volunteer.")
 	flag.IntVar(&p2p.NumberPeersToBroadcast, "broadcastnum", 16, "Number of peers to broadcast to in the peer to peer networking")
+	flag.BoolVar(&p.TraceMessages, "tracemessages", false, "If true, attach trace context to messages sent over the network and record how messages propagate. See the message-traces debug API.")
 	flag.StringVar(&p.ConfigPath, "config", "", "Override the config file location (factomd.conf)")
+	flag.StringVar(&p.Snapshot, "snapshot", "", "Boot from the snapshot in this directory. The snapshot is verified and imported into the database, which must be empty.")
+	flag.StringVar(&p.SnapshotKeyMR, "snapshotkeymr", "", "If set, only import a snapshot ending at the directory block with this KeyMR")
+	flag.BoolVar(&p.SchemaDryRun, "schemadryrun", false, "List the database schema migrations that would run on boot, then exit without running them")
+	flag.StringVar(&p.SchemaBackup, "schemabackup", "", "Before running database schema migrations, back the database up to a new database of the same type in this directory")
+	flag.BoolVar(&p.IntegrityCheck, "integritycheck", false, "Continuously verify the saved blocks and entries in the background, reporting corruption through metrics and the debug API")
+	flag.IntVar(&p.IntegrityDelay, "integritydelay", 100, "Milliseconds the integrity verifier waits between heights, to keep its load on the database low")
+	flag.BoolVar(&p.IntegrityRefetch, "integrityrefetch", false, "Request blocks and entries the integrity verifier finds corrupted or missing from peers, and save them")
+	flag.StringVar(&p.GrantScheduleFile, "grantschedule", "", "JSON file of a grant schedule signed by the grant authority, paid in addition to the hard-coded grants, not on MAIN")
+	flag.StringVar(&p.GrantChain, "grantchain", "", "Chain ID of the governance chain whose entries hold grant schedules signed by the grant authority")
+	flag.StringVar(&p.GrantAuthority, "grantauthority", "", "Hex ed25519 public key of the grant authority that signs the grant schedules")
 	flag.BoolVar(&p.CheckChainHeads, "checkheads", true, "Enables checking chain heads on boot")
 	flag.BoolVar(&p.FixChainHeads, "fixheads", true, "If --checkheads is enabled, then this will also correct any errors reported")
 	flag.BoolVar(&p.AckbalanceHash, "balancehash", true, "If false, then don't pass around balance hashes")
@@ -41,11 +52,13 @@ func init() {
 	flag.StringVar(&p.Net, "net", "tree", "The default algorithm to build the network connections")
 	flag.StringVar(&p.Fnet, "fnet", "", "Read the given file to build the network connections")
 	flag.IntVar(&p.DropRate, "drop", 0, "Number of messages to drop out of every thousand")
+	flag.BoolVar(&p.Deterministic, "deterministic", false, "If true, the simulated nodes share a virtual clock and seeded randomness, so a run can be replayed from its --seed")
+	flag.Int64Var(&p.Seed, "seed", 1, "Seed of the randomness of a --deterministic simulation")
 	flag.StringVar(&p.Journal, "journal", "", "Rerun a Journal of messages")
 	flag.BoolVar(&p.Journaling, "journaling", false, "Write a journal of all messages received. Default is off.")
 	flag.BoolVar(&p.Follower, "follower", false, "If true, force node to be a follower.  Only used when replaying a journal.")
 	flag.BoolVar(&p.Leader, "leader", true, "If true, force node to be a leader.  Only used when replaying a journal.")
-	flag.StringVar(&p.Db, "db", "", "Override the Database in the Config file and use this Database implementation. Options Map, LDB, or Bolt")
+	flag.StringVar(&p.Db, "db", "", "Override the Database in the Config file and use this Database implementation. Options Map, LDB, Bolt, Badger, SecureLDB, SecureBolt, or SecureBadger")
 	flag.StringVar(&p.CloneDB, "clonedb", "", "Override the main node and use this database for the clones in a Network.")
 	flag.StringVar(&p.NetworkName, "network", "", "Network to join: MAIN, TEST or LOCAL")
 	flag.StringVar(&p.Peers, "peers", "", "Array of peer addresses. ")
@@ -1534,6 +1534,15 @@ func TestDBState(t *testing.T) {
 	ShutDownEverything(t)
 }
 
+func TestScenarioFollowerPartition(t *testing.T) {
+	if RanSimTest {
+		return
+	}
+	RanSimTest = true
+
+	RunScenarioFile("scenarios/followerPartition.scenario", t)
+}
+
 func SystemCall(cmd string) {
 	fmt.Println("SystemCall(\"", cmd, "\")")
 	out, err := exec.Command("sh", "-c", cmd).Output()
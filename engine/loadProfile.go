@@ -0,0 +1,237 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// maxLoadEntrySize is the largest entry the load generator makes, the limit of an entry
+const maxLoadEntrySize = 10240
+
+// LoadProfile is the workload of the load generator.  The ratios are in tenths of a percent, as the
+// rates of the link models.  Profiles are read from JSON files, the missing settings keeping the
+// values of the DefaultLoadProfile.
+type LoadProfile struct {
+	Name          string  `json:"name"`
+	Rate          float64 `json:"rate"`          // transactions per second, 0 to keep the rate set by Rnnn
+	ChainRatio    int     `json:"chainratio"`    // entries creating a new chain rather than going to the last one
+	EntrySize     string  `json:"entrysize"`     // distribution of the content size of entries in bytes
+	ExtIDs        int     `json:"extids"`        // entries have 1 to extids external ids, of up to 300 bytes
+	HotChains     int     `json:"hotchains"`     // number of chains that get most of the entries
+	HotRatio      int     `json:"hotratio"`      // entries going to a hot chain
+	TransferRatio int     `json:"transferratio"` // transactions that are factoid transfers
+	FctAddresses  int     `json:"fctaddresses"`  // number of addresses the transfers move factoids among
+	PurchaseRatio int     `json:"purchaseratio"` // transactions that are entry credit purchases
+	Arrivals      string  `json:"arrivals"`      // steady, or poisson for random arrivals
+	BurstEvery    int     `json:"burstevery"`    // seconds between the starts of bursts, 0 for no bursts
+	BurstLength   int     `json:"burstlength"`   // seconds a burst lasts
+	BurstFactor   float64 `json:"burstfactor"`   // rate during a burst, as a multiple of the rate
+
+	entrySize Distribution
+}
+
+// DefaultLoadProfile returns the profile of the load generator before any profile is set: entries
+// of up to 4000 bytes only, a new chain now and then, at a steady rate.
+func DefaultLoadProfile() *LoadProfile {
+	p := &LoadProfile{
+		Name:         "default",
+		ChainRatio:   100,
+		EntrySize:    "uniform:0:4000",
+		ExtIDs:       4,
+		FctAddresses: 10,
+		Arrivals:     "steady",
+		BurstFactor:  1,
+	}
+	p.Validate()
+	return p
+}
+
+// Validate checks the settings of the profile, and parses its entry size distribution
+func (p *LoadProfile) Validate() error {
+	d, err := ParseDistribution(p.EntrySize)
+	if err != nil {
+		return fmt.Errorf("entrysize: %v", err)
+	}
+	p.entrySize = d
+	for _, r := range []struct {
+		name  string
+		ratio int
+	}{{"chainratio", p.ChainRatio}, {"hotratio", p.HotRatio}, {"transferratio", p.TransferRatio}, {"purchaseratio", p.PurchaseRatio}} {
+		if r.ratio < 0 || r.ratio > 1000 {
+			return fmt.Errorf("%s is in tenths of a percent, from 0 to 1000", r.name)
+		}
+	}
+	if p.TransferRatio+p.PurchaseRatio > 1000 {
+		return fmt.Errorf("transferratio and purchaseratio add up to more than 1000")
+	}
+	if p.Rate < 0 {
+		return fmt.Errorf("rate must not be negative")
+	}
+	if p.ExtIDs < 1 || p.ExtIDs > 20 {
+		return fmt.Errorf("extids is from 1 to 20")
+	}
+	if p.HotChains < 0 || (p.HotRatio > 0 && p.HotChains == 0) {
+		return fmt.Errorf("a hotratio needs hotchains")
+	}
+	if p.TransferRatio > 0 && p.FctAddresses < 2 {
+		return fmt.Errorf("transfers need at least 2 fctaddresses")
+	}
+	if p.Arrivals != "steady" && p.Arrivals != "poisson" {
+		return fmt.Errorf("unknown arrivals %s, use steady or poisson", p.Arrivals)
+	}
+	if p.BurstEvery < 0 || p.BurstLength < 0 || p.BurstFactor < 0 {
+		return fmt.Errorf("the burst settings must not be negative")
+	}
+	if p.BurstEvery > 0 && p.BurstLength >= p.BurstEvery {
+		return fmt.Errorf("burstlength must be shorter than burstevery")
+	}
+	return nil
+}
+
+// RateAt returns the transactions per second at a number of seconds into the load
+func (p *LoadProfile) RateAt(rate float64, second int) float64 {
+	if p.BurstEvery > 0 && second%p.BurstEvery < p.BurstLength {
+		return rate * p.BurstFactor
+	}
+	return rate
+}
+
+// Arrive returns the number of transactions arriving in a second at a rate.  Steady arrivals carry
+// the fraction of a transaction over to the next second in carry.
+func (p *LoadProfile) Arrive(r *rand.Rand, rate float64, carry *float64) int {
+	if p.Arrivals == "poisson" {
+		return poisson(r, rate)
+	}
+	*carry += rate
+	n := math.Floor(*carry)
+	*carry -= n
+	return int(n)
+}
+
+// EntrySizeSample returns the content size of an entry, keeping the entry under the limit
+func (p *LoadProfile) EntrySizeSample(r *rand.Rand, extIDBytes int) int {
+	size := int(p.entrySize.Sample(r))
+	if max := maxLoadEntrySize - 35 - extIDBytes; size > max {
+		size = max
+	}
+	return size
+}
+
+// ParseLoadProfile parses a profile in JSON, starting from the default profile
+func ParseLoadProfile(data []byte) (*LoadProfile, error) {
+	p := DefaultLoadProfile()
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReadLoadProfile reads a profile file
+func ReadLoadProfile(file string) (*LoadProfile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseLoadProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return p, nil
+}
+
+// poisson samples the number of arrivals in a second at a mean rate, by Knuth for low rates and by
+// the normal approximation for high ones
+func poisson(r *rand.Rand, mean float64) int {
+	if mean <= 0 {
+		return 0
+	}
+	if mean > 30 {
+		n := int(math.Floor(mean + r.NormFloat64()*math.Sqrt(mean) + .5))
+		if n < 0 {
+			return 0
+		}
+		return n
+	}
+	limit := math.Exp(-mean)
+	n := 0
+	for prod := r.Float64(); prod > limit; prod *= r.Float64() {
+		n++
+	}
+	return n
+}
+
+// LoadKinds are the kinds of transactions of the load generator
+var LoadKinds = []string{"chain", "entry", "transfer", "purchase"}
+
+// LatencyPercentiles are the percentiles of the ack latencies, in milliseconds
+type LatencyPercentiles struct {
+	P50     int64
+	P90     int64
+	P99     int64
+	Max     int64
+	Samples int
+}
+
+// LoadStats reports what the load generator submitted and how fast it was acknowledged
+type LoadStats struct {
+	Profile      string
+	Running      bool
+	Rate         float64            // transactions per second asked for
+	Elapsed      string             // since the load started
+	Submitted    map[string]int     // by kind of transaction
+	Acked        map[string]int     // scaled up by Sample, as are Pending, Expired, Untracked and TPS
+	Sample       int                // 1 in Sample transactions is tracked to its ack
+	Pending      int                // submitted, not acknowledged yet
+	Expired      int                // not acknowledged within a minute
+	Untracked    int                // submitted while too many were pending, not in Acked nor the latencies
+	SubmittedTPS float64            // over the last minute
+	TPS          float64            // acknowledged over the last minute
+	LatencyMs    LatencyPercentiles // the acks are polled every 100 ms, so the latencies are up to 100 ms high
+}
+
+func (s *LoadStats) String() string {
+	str := fmt.Sprintf("Load %s running %v rate %.1f/s for %s, submitted %.1f/s, acked %.1f/s, %d pending, %d expired, %d untracked, tracking 1 in %d\n",
+		s.Profile, s.Running, s.Rate, s.Elapsed, s.SubmittedTPS, s.TPS, s.Pending, s.Expired, s.Untracked, s.Sample)
+	for _, k := range LoadKinds {
+		str += fmt.Sprintf("  %-8s submitted %8d acked %8d\n", k, s.Submitted[k], s.Acked[k])
+	}
+	l := s.LatencyMs
+	str += fmt.Sprintf("  ack latency p50 %d ms p90 %d ms p99 %d ms max %d ms over %d acks\n", l.P50, l.P90, l.P99, l.Max, l.Samples)
+	return str
+}
+
+type latencySort []int64
+
+func (l latencySort) Len() int           { return len(l) }
+func (l latencySort) Less(i, j int) bool { return l[i] < l[j] }
+func (l latencySort) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// NewLatencyPercentiles returns the percentiles of the latencies, in milliseconds
+func NewLatencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	ms := make([]int64, len(latencies))
+	for i, l := range latencies {
+		ms[i] = int64(l / time.Millisecond)
+	}
+	sort.Sort(latencySort(ms))
+	r := LatencyPercentiles{Samples: len(ms)}
+	if len(ms) == 0 {
+		return r
+	}
+	at := func(p int) int64 {
+		return ms[(len(ms)-1)*p/100]
+	}
+	r.P50, r.P90, r.P99, r.Max = at(50), at(90), at(99), ms[len(ms)-1]
+	return r
+}
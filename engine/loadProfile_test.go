@@ -0,0 +1,94 @@
+package engine_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	. "github.com/FactomProject/factomd/engine"
+)
+
+func TestParseLoadProfile(t *testing.T) {
+	p, err := ParseLoadProfile([]byte(`{"name": "hot", "entrysize": "normal:1000:300", "hotchains": 3, "hotratio": 800,
+		"transferratio": 200, "fctaddresses": 50, "purchaseratio": 50, "arrivals": "poisson"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "hot" || p.HotChains != 3 || p.FctAddresses != 50 || p.Arrivals != "poisson" {
+		t.Errorf("Parsed %+v", p)
+	}
+	if p.ExtIDs != DefaultLoadProfile().ExtIDs || p.ChainRatio != DefaultLoadProfile().ChainRatio {
+		t.Errorf("The settings missing from the profile do not keep their defaults")
+	}
+
+	for _, bad := range []string{
+		`{"entrysize": "gamma:1"}`,
+		`{"chainratio": 1001}`,
+		`{"transferratio": 600, "purchaseratio": 600}`,
+		`{"hotratio": 500}`,
+		`{"transferratio": 100, "fctaddresses": 1}`,
+		`{"arrivals": "bursty"}`,
+		`{"burstevery": 10, "burstlength": 10}`,
+		`{"extids": 0}`,
+		`{"rate": "fast"}`,
+	} {
+		if _, err := ParseLoadProfile([]byte(bad)); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestLoadProfileArrivals(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	p := DefaultLoadProfile()
+	p.BurstEvery, p.BurstLength, p.BurstFactor = 10, 2, 5
+
+	if p.RateAt(4, 0) != 20 || p.RateAt(4, 11) != 20 || p.RateAt(4, 2) != 4 {
+		t.Errorf("Bursts at the wrong times")
+	}
+
+	var carry float64
+	total := 0
+	for i := 0; i < 10; i++ {
+		total += p.Arrive(r, .5, &carry)
+	}
+	if total != 5 {
+		t.Errorf("%d steady arrivals at .5 per second over 10 seconds", total)
+	}
+
+	p.Arrivals = "poisson"
+	for _, rate := range []float64{2, 100} {
+		total = 0
+		for i := 0; i < 1000; i++ {
+			total += p.Arrive(r, rate, &carry)
+		}
+		if mean := float64(total) / 1000; mean < rate*.9 || mean > rate*1.1 {
+			t.Errorf("Poisson arrivals average %f per second at a rate of %f", mean, rate)
+		}
+	}
+}
+
+func TestLoadEntrySize(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	p, err := ParseLoadProfile([]byte(`{"entrysize": "const:20000"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := p.EntrySizeSample(r, 300); size+300+35 > 10240 {
+		t.Errorf("Entry of %d bytes of content over the limit", size)
+	}
+}
+
+func TestLatencyPercentiles(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	l := NewLatencyPercentiles(latencies)
+	if l.P50 != 50 || l.P90 != 90 || l.P99 != 99 || l.Max != 100 || l.Samples != 100 {
+		t.Errorf("Percentiles %+v", l)
+	}
+	if l = NewLatencyPercentiles(nil); l.Samples != 0 || l.Max != 0 {
+		t.Errorf("Percentiles of no latencies %+v", l)
+	}
+}
@@ -10,7 +10,9 @@ import (
 
 	"fmt"
 	"os"
+	"sync"
 
+	"github.com/FactomProject/factomd/common/constants"
 	"github.com/FactomProject/factomd/common/entryBlock"
 	"github.com/FactomProject/factomd/common/entryCreditBlock"
 	"github.com/FactomProject/factomd/common/factoid"
@@ -25,69 +27,420 @@ import (
 
 type LoadGenerator struct {
 	ECKey     *primitives.PrivateKey // Entry Credit private key
-	ToSend    int                    // How much to send
-	PerSecond atomic.AtomicInt       // How much per second
+	PerSecond atomic.AtomicInt       // How much per second, in tenths
 	stop      chan bool              // Stop the go routine
 	running   atomic.AtomicBool      // We are running
+	runMutex  sync.Mutex             // held while the load starts or stops, so only one Run loops
 	tight     atomic.AtomicBool      // Only allocate ECs as needed (more EC purchases)
 	txoffset  int64                  // Offset to be added to the timestamp of created tx to test time limits.
+
+	profileMutex sync.Mutex
+	profile      *LoadProfile       // the workload
+	rng          *rand.Rand         // only used by Run
+	hot          []interfaces.IHash // the hot chains
+	chain        interfaces.IHash   // the chain the other entries go to, until a new one is created
+	addresses    [][2]string        // secret and public address the transfers are among
+
+	statsMutex sync.Mutex
+	started    time.Time
+	submitted  map[string]int
+	acked      map[string]int
+	expired    int
+	untracked  int
+	pending    map[[32]byte]*loadTx // at most loadMaxPending
+	submits    []time.Time          // of the last minute
+	acks       []loadAck            // of the last minute
+	latencies  []time.Duration      // of the last loadLatencySamples acks
+	tracking   sync.Once
+}
+
+// loadTx is a transaction submitted by the load generator, waiting for its ack
+type loadTx struct {
+	hash      interfaces.IHash // entry hash, or transaction id
+	kind      string
+	submitted time.Time
+	weight    int // transactions it stands for, as 1 in weight of its kind is tracked
 }
 
+// loadAck is the ack of a tracked transaction
+type loadAck struct {
+	at     time.Time
+	weight int
+}
+
+const (
+	loadLatencySamples = 10000
+	loadAckTimeout     = time.Minute
+	loadAckInterval    = 100 * time.Millisecond // resolution of the ack latencies
+	loadMaxPending     = 2000                   // transactions tracked at a time, as each is polled every interval
+	loadFunding        = 100e8                  // factoshis given to each address the transfers are among
+)
+
 // NewLoadGenerator makes a new load generator. The state is used for funding the transaction
 func NewLoadGenerator(s *state.State) *LoadGenerator {
 	lg := new(LoadGenerator)
 	lg.ECKey, _ = primitives.NewPrivateKeyFromHex(ecSec)
 	lg.stop = make(chan bool, 5)
+	lg.profile = DefaultLoadProfile()
+	lg.rng = random.NewRand("load")
+	lg.resetStats()
 
+	// Let the debug API of every node reach the load generator
+	for _, f := range fnodes {
+		f.State.SetLoadGenerator(lg)
+	}
 	return lg
 }
 
 func (lg *LoadGenerator) Run() {
+	lg.runMutex.Lock()
 	if lg.running.Load() {
+		lg.runMutex.Unlock()
 		return
 	}
 	lg.running.Store(true)
-	//FundWallet(fnodes[wsapiNode].State, 15000e8)
-
-	// Every second add the per second amount
-	ticker := time.NewTicker(time.Second)
-	for range ticker.C {
-		select {
-		case <-lg.stop:
-			lg.running.Store(false)
+	lg.runMutex.Unlock()
+	lg.tracking.Do(func() { go lg.trackAcks() })
+	lg.statsMutex.Lock()
+	lg.started = primitives.Now()
+	lg.statsMutex.Unlock()
+
+	// Every second add the transactions arriving at the rate of the profile.  Sleep on the
+	// simulation clock, so the load follows it in deterministic mode.
+	var carry float64
+	for second := 0; ; second++ {
+		primitives.Sleep(time.Second)
+		if lg.done() {
 			return
-		default:
-
 		}
-		addSend := lg.PerSecond.Load()
-		lg.ToSend += addSend
-		top := lg.ToSend / 10
-		lg.ToSend = lg.ToSend % 10
-		if addSend == 0 {
-			lg.running.Store(false)
+		perSecond := lg.PerSecond.Load()
+		p := lg.GetProfile()
+		n := p.Arrive(lg.rng, p.RateAt(float64(perSecond)/10, second), &carry)
+		for i := 0; i < n; i++ {
+			lg.send(p)
+		}
+	}
+}
+
+// done returns true, marking the load as not running, once the load is stopped or its rate set to
+// 0.  It holds runMutex, so a Run started meanwhile either sees the load stopped or keeps it going.
+func (lg *LoadGenerator) done() bool {
+	lg.runMutex.Lock()
+	defer lg.runMutex.Unlock()
+	select {
+	case <-lg.stop:
+		lg.running.Store(false)
+		return true
+	default:
+	}
+	if lg.PerSecond.Load() == 0 {
+		lg.running.Store(false)
+		return true
+	}
+	return false
+}
+
+func (lg *LoadGenerator) Stop() {
+	lg.stop <- true
+}
+
+// GetProfile returns a copy of the profile of the load generator
+func (lg *LoadGenerator) GetProfile() *LoadProfile {
+	lg.profileMutex.Lock()
+	defer lg.profileMutex.Unlock()
+	p := *lg.profile
+	return &p
+}
+
+// SetProfile sets the workload, restarting the statistics.  A profile with a rate also sets the
+// rate, starting the load.
+func (lg *LoadGenerator) SetProfile(p *LoadProfile) {
+	lg.profileMutex.Lock()
+	lg.profile = p
+	lg.profileMutex.Unlock()
+	lg.resetStats()
+	if p.Rate > 0 {
+		lg.SetLoadRate(p.Rate)
+	}
+}
+
+// SetLoadProfile sets the workload from a profile in JSON
+func (lg *LoadGenerator) SetLoadProfile(data []byte) error {
+	p, err := ParseLoadProfile(data)
+	if err != nil {
+		return err
+	}
+	lg.SetProfile(p)
+	return nil
+}
+
+// GetLoadProfile returns the *LoadProfile of the workload
+func (lg *LoadGenerator) GetLoadProfile() interface{} {
+	return lg.GetProfile()
+}
+
+// SetLoadRate sets the transactions per second, starting the load, or stopping it for 0
+func (lg *LoadGenerator) SetLoadRate(perSecond float64) {
+	lg.PerSecond.Store(int(perSecond*10 + .5))
+	if lg.PerSecond.Load() > 0 {
+		go lg.Run()
+	}
+}
+
+// GetLoadStats returns the *LoadStats of the load
+func (lg *LoadGenerator) GetLoadStats() interface{} {
+	return lg.Stats()
+}
+
+// send submits a transaction of a kind picked by the ratios of the profile
+func (lg *LoadGenerator) send(p *LoadProfile) {
+	s := fnodes[wsapiNode].State
+	r := lg.rng.Intn(1000)
+	switch {
+	case r < p.TransferRatio:
+		if lg.sendTransfer(s, p) {
 			return
 		}
-		var chain interfaces.IHash = nil
-		for i := 0; i < top; i++ {
-			var c interfaces.IMsg
-			e := RandomEntry()
-			if chain == nil {
-				c = lg.NewCommitChain(e)
-				chain = e.ChainID
+	case r < p.TransferRatio+p.PurchaseRatio:
+		lg.sendPurchase(s)
+		return
+	}
+	lg.sendEntry(s, p)
+}
+
+// sendEntry submits an entry, to a hot chain, to the last chain created, or creating a new chain
+func (lg *LoadGenerator) sendEntry(s *state.State, p *LoadProfile) {
+	hot := lg.hot
+	if len(hot) > p.HotChains {
+		hot = hot[:p.HotChains]
+	}
+
+	e := lg.NewEntry(p)
+	kind := "entry"
+	var c interfaces.IMsg
+	switch {
+	case p.HotRatio > 0 && lg.rng.Intn(1000) < p.HotRatio && len(hot) == p.HotChains:
+		e.ChainID = hot[lg.rng.Intn(len(hot))]
+		c = lg.NewCommitEntry(e)
+	case p.HotRatio > 0 && len(hot) < p.HotChains:
+		c = lg.NewCommitChain(e)
+		lg.hot = append(hot, e.ChainID)
+		kind = "chain"
+	case lg.chain == nil || lg.rng.Intn(1000) < p.ChainRatio:
+		c = lg.NewCommitChain(e)
+		lg.chain = e.ChainID
+		kind = "chain"
+	default:
+		e.ChainID = lg.chain
+		c = lg.NewCommitEntry(e)
+	}
+	r := lg.NewRevealEntry(e)
+
+	s.APIQueue().Enqueue(c)
+	s.APIQueue().Enqueue(r)
+	lg.submit(kind, e.GetHash())
+}
+
+// sendTransfer submits a factoid transfer between two of the addresses of the profile, funding
+// the addresses from the bootstrap address as they are added.  Returns false if no address holds
+// the factoids yet.
+func (lg *LoadGenerator) sendTransfer(s *state.State, p *LoadProfile) bool {
+	ecPrice := s.GetFactoshisPerEC()
+	for len(lg.addresses) < p.FctAddresses {
+		secret, public := RandomFctAddressPair()
+		lg.addresses = append(lg.addresses, [2]string{secret, public})
+		SendTxn(s, loadFunding, bankSecret, public, ecPrice)
+	}
+
+	amt := uint64(1e6 + lg.rng.Intn(1e7))
+	n := p.FctAddresses
+	for try := 0; try < 3; try++ {
+		from := lg.addresses[lg.rng.Intn(n)]
+		to := lg.addresses[lg.rng.Intn(n)]
+		if GetBalance(s, from[1]) < int64(amt+20*ecPrice) {
+			continue
+		}
+		txn, err := SendTxn(s, amt, from[0], to[1], ecPrice)
+		if err != nil {
+			return false
+		}
+		lg.submit("transfer", txn.GetSigHash())
+		return true
+	}
+	return false
+}
+
+// sendPurchase submits a purchase of entry credits for the key of the load generator
+func (lg *LoadGenerator) sendPurchase(s *state.State) {
+	err, txid := FundWalletTOFF(s, lg.txoffset, uint64(100+lg.rng.Intn(900))*s.GetFactoshisPerEC())
+	if err != nil {
+		return
+	}
+	if hash, err := primitives.HexToHash(txid); err == nil {
+		lg.submit("purchase", hash)
+	}
+}
+
+// NewEntry makes an entry of the size distribution of the profile, its chain id set as for a new chain
+func (lg *LoadGenerator) NewEntry(p *LoadProfile) *entryBlock.Entry {
+	entry := entryBlock.NewEntry()
+	entry.ExtIDs = make([]primitives.ByteSlice, lg.rng.Intn(p.ExtIDs)+1)
+	extIDBytes := 0
+	for i := range entry.ExtIDs {
+		entry.ExtIDs[i] = primitives.ByteSlice{random.RandByteSliceOfLen(lg.rng.Intn(300))}
+		extIDBytes += 2 + len(entry.ExtIDs[i].Bytes)
+	}
+	entry.Content = primitives.ByteSlice{random.RandByteSliceOfLen(p.EntrySizeSample(lg.rng, extIDBytes))}
+	entry.ChainID = newChainID(entry)
+	return entry
+}
+
+// loadSample returns k, tracking the ack of 1 in k transactions submitted at a rate, so the
+// transactions never acknowledged fill at most half of the loadMaxPending before they expire
+func loadSample(perSecond float64) int {
+	return 1 + int(2*perSecond*loadAckTimeout.Seconds()/loadMaxPending)
+}
+
+// submit counts a transaction, tracking its ack if it is 1 in loadSample of its kind, unless
+// loadMaxPending are tracked already
+func (lg *LoadGenerator) submit(kind string, hash interfaces.IHash) {
+	now := primitives.Now()
+	k := loadSample(float64(lg.PerSecond.Load()) / 10)
+	lg.statsMutex.Lock()
+	defer lg.statsMutex.Unlock()
+	lg.submitted[kind]++
+	lg.submits = append(trimLoadTimes(lg.submits, now), now)
+	if lg.submitted[kind]%k != 0 {
+		return
+	}
+	if len(lg.pending) < loadMaxPending {
+		lg.pending[hash.Fixed()] = &loadTx{hash: hash, kind: kind, submitted: now, weight: k}
+	} else {
+		lg.untracked += k
+	}
+}
+
+// trackAcks checks the acks of the transactions submitted, every loadAckInterval
+func (lg *LoadGenerator) trackAcks() {
+	for {
+		primitives.Sleep(loadAckInterval)
+		lg.checkAcks(fnodes[wsapiNode].State, primitives.Now())
+	}
+}
+
+// checkAcks records the latency of the transactions acknowledged since the last check, and
+// gives up on the ones waiting longer than loadAckTimeout.  The counts are scaled up by the
+// weight of the transactions, for the ones not tracked.
+func (lg *LoadGenerator) checkAcks(s interfaces.IState, now time.Time) {
+	lg.statsMutex.Lock()
+	waiting := make([]*loadTx, 0, len(lg.pending))
+	for _, tx := range lg.pending {
+		waiting = append(waiting, tx)
+	}
+	lg.statsMutex.Unlock()
+
+	for _, tx := range waiting {
+		status, _, _, _, _ := s.GetACKStatus(tx.hash)
+		acked := status == constants.AckStatusACK || status == constants.AckStatus1Minute || status == constants.AckStatusDBlockConfirmed
+		expired := !acked && now.Sub(tx.submitted) > loadAckTimeout
+		if !acked && !expired {
+			continue
+		}
+
+		lg.statsMutex.Lock()
+		if _, ok := lg.pending[tx.hash.Fixed()]; ok {
+			delete(lg.pending, tx.hash.Fixed())
+			if acked {
+				lg.acked[tx.kind] += tx.weight
+				lg.acks = append(trimLoadAcks(lg.acks, now), loadAck{at: now, weight: tx.weight})
+				lg.latencies = append(lg.latencies, now.Sub(tx.submitted))
+				if len(lg.latencies) > loadLatencySamples {
+					lg.latencies = lg.latencies[len(lg.latencies)-loadLatencySamples:]
+				}
 			} else {
-				e.ChainID = chain
-				c = lg.NewCommitEntry(e)
+				lg.expired += tx.weight
 			}
-			r := lg.NewRevealEntry(e)
-
-			fnodes[wsapiNode].State.APIQueue().Enqueue(c)
-			fnodes[wsapiNode].State.APIQueue().Enqueue(r)
 		}
+		lg.statsMutex.Unlock()
 	}
 }
 
-func (lg *LoadGenerator) Stop() {
-	lg.stop <- true
+// trimLoadTimes drops the times before the last minute
+func trimLoadTimes(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > time.Minute {
+		i++
+	}
+	return times[i:]
+}
+
+// trimLoadAcks drops the acks before the last minute
+func trimLoadAcks(acks []loadAck, now time.Time) []loadAck {
+	i := 0
+	for i < len(acks) && now.Sub(acks[i].at) > time.Minute {
+		i++
+	}
+	return acks[i:]
+}
+
+func (lg *LoadGenerator) resetStats() {
+	lg.statsMutex.Lock()
+	defer lg.statsMutex.Unlock()
+	lg.started = primitives.Now()
+	lg.submitted = map[string]int{}
+	lg.acked = map[string]int{}
+	lg.expired = 0
+	lg.untracked = 0
+	lg.pending = map[[32]byte]*loadTx{}
+	lg.submits = nil
+	lg.acks = nil
+	lg.latencies = nil
+}
+
+// Stats returns what the load generator submitted, and the throughput and ack latencies achieved
+func (lg *LoadGenerator) Stats() *LoadStats {
+	p := lg.GetProfile()
+	now := primitives.Now()
+
+	lg.statsMutex.Lock()
+	defer lg.statsMutex.Unlock()
+	r := &LoadStats{
+		Profile:   p.Name,
+		Running:   lg.running.Load(),
+		Rate:      float64(lg.PerSecond.Load()) / 10,
+		Elapsed:   now.Sub(lg.started).Truncate(time.Second).String(),
+		Submitted: map[string]int{},
+		Acked:     map[string]int{},
+		Sample:    loadSample(float64(lg.PerSecond.Load()) / 10),
+		Expired:   lg.expired,
+		Untracked: lg.untracked,
+		LatencyMs: NewLatencyPercentiles(lg.latencies),
+	}
+	for k, n := range lg.submitted {
+		r.Submitted[k] = n
+	}
+	for k, n := range lg.acked {
+		r.Acked[k] = n
+	}
+	for _, tx := range lg.pending {
+		r.Pending += tx.weight
+	}
+
+	window := now.Sub(lg.started)
+	if window > time.Minute {
+		window = time.Minute
+	}
+	if window >= time.Second {
+		lg.submits = trimLoadTimes(lg.submits, now)
+		lg.acks = trimLoadAcks(lg.acks, now)
+		acks := 0
+		for _, a := range lg.acks {
+			acks += a.weight
+		}
+		r.SubmittedTPS = float64(len(lg.submits)) / window.Seconds()
+		r.TPS = float64(acks) / window.Seconds()
+	}
+	return r
 }
 
 func RandomEntry() *entryBlock.Entry {
@@ -100,16 +453,19 @@ func RandomEntry() *entryBlock.Entry {
 		raw[i] = entry.ExtIDs[i].Bytes
 	}
 
+	entry.ChainID = newChainID(entry)
+	return entry
+}
+
+// newChainID returns the chain id of a new chain made by the entry, from its external ids
+func newChainID(entry *entryBlock.Entry) interfaces.IHash {
 	sum := sha256.New()
 	for _, v := range entry.ExtIDs {
 		x := sha256.Sum256(v.Bytes)
 		sum.Write(x[:])
 	}
 	originalHash := sum.Sum(nil)
-	checkHash := primitives.Shad(originalHash)
-
-	entry.ChainID = checkHash
-	return entry
+	return primitives.Shad(originalHash)
 }
 
 func (lg *LoadGenerator) NewRevealEntry(entry *entryBlock.Entry) *messages.RevealEntryMsg {
@@ -0,0 +1,70 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// ackState acknowledges every transaction, or none
+type ackState struct {
+	interfaces.IState
+	ack bool
+}
+
+func (s *ackState) GetACKStatus(hash interfaces.IHash) (int, interfaces.IHash, interfaces.Timestamp, interfaces.Timestamp, error) {
+	if s.ack {
+		return constants.AckStatusACK, hash, nil, nil, nil
+	}
+	return constants.AckStatusUnknown, hash, nil, nil, nil
+}
+
+func TestLoadAcksSampled(t *testing.T) {
+	lg := new(LoadGenerator)
+	lg.profile = DefaultLoadProfile()
+	lg.resetStats()
+	lg.PerSecond.Store(1000) // 100 per second
+	k := loadSample(100)
+	if k < 2 {
+		t.Fatalf("Tracking 1 in %d at 100 per second", k)
+	}
+
+	sent := 0
+	submit := func(n int) {
+		for i := 0; i < n; i++ {
+			sent++
+			lg.submit("entry", primitives.Sha([]byte(fmt.Sprintf("tx %d", sent))))
+		}
+	}
+	submit(100 * k)
+	if len(lg.pending) != 100 {
+		t.Errorf("Tracked %d of %d", len(lg.pending), 100*k)
+	}
+	lg.checkAcks(&ackState{ack: true}, primitives.Now())
+	if r := lg.Stats(); r.Acked["entry"] != 100*k || r.Pending != 0 || r.Sample != k {
+		t.Errorf("Wrong stats %+v", r)
+	}
+
+	submit(10 * k)
+	lg.checkAcks(&ackState{}, primitives.Now())
+	if r := lg.Stats(); r.Pending != 10*k || r.Expired != 0 {
+		t.Errorf("Wrong stats %+v", r)
+	}
+	lg.checkAcks(&ackState{}, primitives.Now().Add(loadAckTimeout+time.Second))
+	if r := lg.Stats(); r.Pending != 0 || r.Expired != 10*k || r.Acked["entry"] != 100*k {
+		t.Errorf("Wrong stats %+v", r)
+	}
+
+	// Transactions never acked fill at most half of the slots before they expire
+	if n := 2000 * int(loadAckTimeout.Seconds()) / loadSample(2000); n > loadMaxPending/2 {
+		t.Errorf("%d tracked at 2000 per second", n)
+	}
+}
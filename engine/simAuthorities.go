@@ -788,6 +788,41 @@ func buildMessages() []hardCodedAuthority {
 	return list
 }
 
+// TestnetIdentity is one of the hard coded identities the g command puts on the blockchain, with
+// the block signing key it registers for it
+type TestnetIdentity struct {
+	ChainID    string
+	PrivateKey string // LocalServerPrivKey of the node running the identity
+	PublicKey  string
+}
+
+// TestnetIdentities returns the first n identities the g command of a new network makes, in
+// order, so nodes running in other processes can be configured with them.  The stack of the
+// simulator is left as it was.
+func TestnetIdentities(n int) ([]TestnetIdentity, error) {
+	saved, savedNext := authStack, nextAuthority
+	defer func() { authStack, nextAuthority = saved, savedNext }()
+
+	authStack = nil
+	buildMessages()
+	if n > len(authStack) {
+		return nil, fmt.Errorf("only %d hard coded identities are available", len(authStack))
+	}
+	ids := make([]TestnetIdentity, 0, n)
+	for _, ele := range authStack[:n] {
+		_, key, err := identity.MakeBlockSigningKeyFixed(ele.ChainID.String(), ele.ManageChain.String(), &(ele.Sk1), false)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := primitives.NewPrivateKeyFromHex(hex.EncodeToString(key))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, TestnetIdentity{ChainID: ele.ChainID.String(), PrivateKey: priv.PrivateKeyString(), PublicKey: priv.PublicKeyString()})
+	}
+	return ids, nil
+}
+
 func getFactomPackageEntryFromString(message string) (*factom.Entry, error) {
 	entry := entryBlock.NewEntry()
 	if p, err := hex.DecodeString(message); err != nil {
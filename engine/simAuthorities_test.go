@@ -0,0 +1,54 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/FactomProject/factom"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+func TestTestnetIdentities(t *testing.T) {
+	saved, savedNext := authStack, nextAuthority
+	defer func() { authStack, nextAuthority = saved, savedNext }()
+
+	ids, err := TestnetIdentities(3)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(ids) != 3 {
+		t.Fatalf("Found %d identities, expected 3", len(ids))
+	}
+	if len(authStack) != len(saved) || nextAuthority != savedNext {
+		t.Errorf("The stack of the simulator was changed")
+	}
+
+	// The g command of a new network takes the identities from the top of a new stack, and
+	// registers the block signing key of makeBlockKey
+	authStack = nil
+	buildMessages()
+	sec, _ := hex.DecodeString(ecSec)
+	ec, _ := factom.MakeECAddress(sec[:32])
+	for i, id := range ids {
+		ele := authStack[i]
+		_, _, key, _ := makeBlockKey(ele, ec, false)
+		priv, err := primitives.NewPrivateKeyFromHex(key)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if id.ChainID != ele.ChainID.String() {
+			t.Errorf("Identity %d is %s, the g command registers %s", i, id.ChainID, ele.ChainID.String())
+		}
+		if id.PrivateKey != priv.PrivateKeyString() || id.PublicKey != priv.PublicKeyString() {
+			t.Errorf("Identity %d has key %s, the g command registers %s", i, id.PublicKey, priv.PublicKeyString())
+		}
+	}
+
+	if _, err := TestnetIdentities(STACK_HEIGHT + 1); err == nil {
+		t.Errorf("More identities than the hard coded ones were returned")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/util/atomic"
+)
+
+// A deterministic simulation runs every node on a shared virtual clock, with the random numbers
+// and the delivery of the SimPeer packets following a seed.  The clock is advanced a step at a
+// time.  Within a step, the packets due and the goroutines sleeping on the clock are let go one
+// at a time, in an order drawn from the seed, and each is handled by the nodes before the next,
+// so the nodes see the same times and the same order of messages whatever the speed of the
+// machine.  A node is taken to be done once its queues stay empty for a few milliseconds of real
+// time; a node still busy past that without a message queued can break the order.
+
+// DeterministicEpoch is the time the virtual clock starts at.  A deterministic simulation is run
+// on new databases, as the blocks of a database saved in real time would be in its future.
+var DeterministicEpoch = time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var simClockPaused atomic.AtomicBool
+var simClockStep = atomic.AtomicInt(10) // milliseconds the clock is advanced at a time
+var simClockPatience = 2 * time.Second  // real time to wait for the nodes before warning
+var simClockSteps atomic.AtomicInt      // steps the clock was advanced
+var simClockStalls atomic.AtomicInt     // times the nodes were not quiet after simClockPatience
+var simClockStarted atomic.AtomicBool   // set once the clock driver runs
+var simClockMutex sync.Mutex            // one driver advances the clock at a time
+var simClockRand = random.NewRand("simClock")
+
+// StartDeterministic seeds the random numbers and switches to the virtual clock.  It is called
+// before the nodes are made, so their keys and salts follow the seed too.
+func StartDeterministic(seed int64) {
+	random.SetSeed(seed)
+	rand.Seed(seed)
+	simClockRand = random.NewRand("simClock")
+	primitives.UseVirtualClock(DeterministicEpoch)
+}
+
+// runSimClock advances the virtual clock a step at a time, until it is switched back to the
+// real time
+func runSimClock() {
+	if simClockStarted.Load() {
+		return
+	}
+	simClockStarted.Store(true)
+	for primitives.IsVirtualClock() {
+		if simClockPaused.Load() {
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		stepSimClock(time.Duration(simClockStep.Load()) * time.Millisecond)
+	}
+}
+
+// stepSimClock advances the virtual clock by d.  The packets due and the goroutines sleeping
+// until the end of the step are let go one at a time, each once the nodes are quiet.
+func stepSimClock(d time.Duration) {
+	simClockMutex.Lock()
+	defer simClockMutex.Unlock()
+	until := primitives.Now().Add(d)
+	for {
+		waitForSimQuiet()
+		if deliverSimPacket() {
+			continue
+		}
+		if !primitives.WakeNext(until) {
+			break
+		}
+	}
+	waitForSimQuiet()
+	primitives.AdvanceClock(until.Sub(primitives.Now()))
+	simClockSteps.Add(1)
+}
+
+// deliverSimPacket releases a packet due on one of the links, drawn from the seed, and returns
+// false if no packet is due
+func deliverSimPacket() bool {
+	now := primitives.Now().UnixNano() / 1000000
+	var due []*SimPeer
+	for _, f := range fnodes {
+		for _, p := range f.Peers {
+			if sim, ok := p.(*SimPeer); ok && sim.Due(now) > 0 {
+				due = append(due, sim)
+			}
+		}
+	}
+	if len(due) == 0 {
+		return false
+	}
+	due[simClockRand.Intn(len(due))].Release()
+	return true
+}
+
+// waitForSimQuiet waits for the nodes to handle the messages and the packets released.  It
+// never gives up, as stepping on would break the order; it warns every simClockPatience of
+// real time the nodes are not quiet.
+func waitForSimQuiet() {
+	warn := time.Now().Add(simClockPatience)
+	for quiet := 0; quiet < 3; {
+		if time.Now().After(warn) {
+			simClockStalls.Add(1)
+			os.Stderr.WriteString(fmt.Sprintf("The virtual clock waits for the nodes at %s\n",
+				primitives.Now().UTC().Format("2006-01-02 15:04:05.000")))
+			warn = time.Now().Add(simClockPatience)
+		}
+		time.Sleep(time.Millisecond)
+		if simQuiet() {
+			quiet++
+		} else {
+			quiet = 0
+		}
+	}
+}
+
+// simQuiet returns true if no node has a message queued, and no link a packet released but
+// not yet received
+func simQuiet() bool {
+	for _, f := range fnodes {
+		s := f.State
+		if s.InMsgQueue().Length() > 0 || len(s.MsgQueue()) > 0 || len(s.AckQueue()) > 0 ||
+			s.NetworkOutMsgQueue().Length() > 0 || s.ElectionsQueue().Length() > 0 || len(s.TickerQueue()) > 0 {
+			return false
+		}
+		for _, p := range f.Peers {
+			if sim, ok := p.(*SimPeer); ok && (len(sim.BroadcastOut) > 0 || sim.Releasing()) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SimClockStatus describes the clock of the simulation
+func SimClockStatus() string {
+	if !primitives.IsVirtualClock() {
+		return "The nodes run on the real clock\n"
+	}
+	seed, _ := random.GetSeed()
+	state := "running"
+	if simClockPaused.Load() {
+		state = "paused"
+	}
+	return fmt.Sprintf("Virtual clock %s, %s, seed %d, step %d ms, %d steps, waited %d times on the nodes\n",
+		primitives.Now().UTC().Format("2006-01-02 15:04:05.000"), state, seed, simClockStep.Load(), simClockSteps.Load(), simClockStalls.Load())
+}
+
+// SimClockCommand runs the K commands of simControl, for the virtual clock:
+//
+//	K       show the clock
+//	Kp      pause the clock
+//	Kr      run the clock again
+//	Kannn   advance the paused clock nnn milliseconds
+//	Ksnnn   advance the clock nnn milliseconds a step
+func SimClockCommand(b string) (string, error) {
+	if !primitives.IsVirtualClock() {
+		return "", fmt.Errorf("The clock is only controlled in a --deterministic simulation")
+	}
+	if len(b) < 2 {
+		return SimClockStatus(), nil
+	}
+	switch b[1] {
+	case 'p':
+		simClockPaused.Store(true)
+	case 'r':
+		simClockPaused.Store(false)
+	case 'a', 's':
+		nn, err := strconv.Atoi(b[2:])
+		if err != nil || nn <= 0 {
+			return "", fmt.Errorf("Specify a number of milliseconds")
+		}
+		if b[1] == 's' {
+			simClockStep.Store(nn)
+			break
+		}
+		if !simClockPaused.Load() {
+			return "", fmt.Errorf("Pause the clock with Kp before advancing it")
+		}
+		for nn > 0 {
+			step := simClockStep.Load()
+			if step > nn {
+				step = nn
+			}
+			stepSimClock(time.Duration(step) * time.Millisecond)
+			nn -= step
+		}
+	default:
+		return "", fmt.Errorf("Unknown clock command %s", b)
+	}
+	return SimClockStatus(), nil
+}
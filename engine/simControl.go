@@ -106,6 +106,10 @@ func SimControl(listenTo int, listenStdin bool) {
 	if loadGenerator == nil {
 		loadGenerator = NewLoadGenerator(fnodes[0].State)
 	}
+	// Let the debug API of every node reach the link models
+	for _, f := range fnodes {
+		f.State.SetSimLinks(simLinks{})
+	}
 
 	for {
 		// This splits up the command at anycodepoint that is not a letter, number or punctuation, so usually by spaces.
@@ -1127,6 +1131,20 @@ func SimControl(listenTo int, listenStdin bool) {
 						}
 					}
 				}
+			case 'K' == b[0]:
+				status, err := SimClockCommand(b)
+				if err != nil {
+					os.Stderr.WriteString(err.Error() + "\n")
+					break
+				}
+				os.Stderr.WriteString(status)
+			case 'X' == b[0]:
+				status, err := SimLinkCommand(cmd)
+				if err != nil {
+					os.Stderr.WriteString(err.Error() + "\n")
+					break
+				}
+				os.Stderr.WriteString(status)
 			case 'J' == b[0]:
 				elect := fnodes[listenTo].State.Elections.(*elections2.Elections)
 				flist := elect.Federated
@@ -1198,7 +1216,28 @@ func SimControl(listenTo int, listenStdin bool) {
 				if len(b) < 2 {
 					os.Stderr.WriteString("Specify in seconds (R3) or in tenths of a second (R.5).\n" +
 						"Or Re to have entry credits allocated tightly.\n" +
-						"Or RtMMM where MMM is some milliseconds to be added to the timestamp of TX generated.")
+						"Or RtMMM where MMM is some milliseconds to be added to the timestamp of TX generated.\n" +
+						"Or Rp file to set the workload from a profile file, or Rs for the load statistics.\n")
+					continue
+				}
+
+				if b[1] == 'p' {
+					if len(cmd) < 2 {
+						os.Stderr.WriteString(fmt.Sprintf("Profile %s\n", loadGenerator.GetProfile().Name))
+						continue
+					}
+					p, err := ReadLoadProfile(cmd[1])
+					if err != nil {
+						os.Stderr.WriteString(err.Error() + "\n")
+						continue
+					}
+					loadGenerator.SetProfile(p)
+					os.Stderr.WriteString(fmt.Sprintf("Set the load profile %s\n", p.Name))
+					continue
+				}
+
+				if b[1] == 's' {
+					os.Stderr.WriteString(loadGenerator.Stats().String())
 					continue
 				}
 
@@ -1416,6 +1455,16 @@ func SimControl(listenTo int, listenStdin bool) {
 				os.Stderr.WriteString("Onnn          Set Drop Rate to nnn on this node\n")
 				os.Stderr.WriteString("Dnnn          Set the Delay on messages from the current node to nnn milliseconds\n")
 				os.Stderr.WriteString("Fnnn          Set the Delay on messages from all nodes to nnn milliseconds\n")
+				os.Stderr.WriteString("K             Show the virtual clock of a --deterministic simulation\n")
+				os.Stderr.WriteString("Kp / Kr       Pause / run the virtual clock\n")
+				os.Stderr.WriteString("Kannn         Advance the paused virtual clock nnn milliseconds\n")
+				os.Stderr.WriteString("Ksnnn         Advance the virtual clock nnn milliseconds a step\n")
+				os.Stderr.WriteString("X             Show the named partitions and the fault models of the links\n")
+				os.Stderr.WriteString("Xp name 0,1 2 Apply the partition name, cutting the links between the groups of nodes\n")
+				os.Stderr.WriteString("Xh [name]     Heal the partition name, or all of them\n")
+				os.Stderr.WriteString("Xl f t k v .. Set the links from node f to node t (* for any) with latency const:ms, uniform:min:max,\n")
+				os.Stderr.WriteString("              normal:mean:dev or exp:mean, bandwidth bytes/s, reorder, duplicate and drop in 1/1000s\n")
+				os.Stderr.WriteString("Xc            Clear the fault models of all the links\n")
 				os.Stderr.WriteString("/             Toggle the sort order between ChainID and Factom Node Name\n")
 				os.Stderr.WriteString("Pnnn          Set's the efficiency of the given node to nnn\n")
 				os.Stderr.WriteString("B             Set's the coinbase address to a random one. Tyoe BFA... for a specific\n")
@@ -1423,6 +1472,8 @@ func SimControl(listenTo int, listenStdin bool) {
 				os.Stderr.WriteString("Rnnn          Set load generator to write entries at nnn per second\n")
 				os.Stderr.WriteString("Re            Turn on 'tight' mode, that buys ECs in only small amounts when running Rnnn\n")
 				os.Stderr.WriteString("Rtnnn         Add a signed constant to the timestamp of load generator FCT TXs.\n")
+				os.Stderr.WriteString("Rp file       Set the workload of the load generator from a profile file\n")
+				os.Stderr.WriteString("Rs            Show the TPS and ack latency percentiles the load generator achieves\n")
 
 				//os.Stderr.WriteString("i[m/b/a][N]   Shows only the Mhash, block signing key, or anchor key up to the Nth identity\n")
 				//os.Stderr.WriteString("isN           Shows only Nth identity\n")
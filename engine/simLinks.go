@@ -0,0 +1,356 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// Distribution is a distribution of latencies in milliseconds, as const:ms, uniform:min:max,
+// normal:mean:deviation or exp:mean
+type Distribution struct {
+	Kind string
+	A, B float64
+}
+
+// ParseDistribution parses a distribution, a plain number being a constant latency
+func ParseDistribution(s string) (Distribution, error) {
+	parts := strings.Split(s, ":")
+	d := Distribution{Kind: parts[0]}
+	if _, err := strconv.ParseFloat(parts[0], 64); err == nil {
+		d.Kind = "const"
+		parts = append([]string{"const"}, parts...)
+	}
+	args := map[string]int{"const": 1, "uniform": 2, "normal": 2, "exp": 1}
+	n, ok := args[d.Kind]
+	if !ok {
+		return d, fmt.Errorf("unknown distribution %s, use const, uniform, normal or exp", d.Kind)
+	}
+	if len(parts) != n+1 {
+		return d, fmt.Errorf("the %s distribution takes %d numbers", d.Kind, n)
+	}
+	var values [2]float64
+	for i := 0; i < n; i++ {
+		v, err := strconv.ParseFloat(parts[i+1], 64)
+		if err != nil || v < 0 {
+			return d, fmt.Errorf("bad number %s", parts[i+1])
+		}
+		values[i] = v
+	}
+	d.A, d.B = values[0], values[1]
+	if d.Kind == "uniform" && d.B < d.A {
+		return d, fmt.Errorf("the uniform distribution needs min <= max")
+	}
+	return d, nil
+}
+
+// Sample returns a latency in milliseconds, never negative
+func (d Distribution) Sample(r *rand.Rand) int64 {
+	var v float64
+	switch d.Kind {
+	case "const":
+		v = d.A
+	case "uniform":
+		v = d.A + r.Float64()*(d.B-d.A)
+	case "normal":
+		v = d.A + r.NormFloat64()*d.B
+	case "exp":
+		v = r.ExpFloat64() * d.A
+	}
+	if v < 0 {
+		return 0
+	}
+	return int64(v)
+}
+
+func (d Distribution) String() string {
+	switch d.Kind {
+	case "const", "exp":
+		return fmt.Sprintf("%s:%g", d.Kind, d.A)
+	case "":
+		return "none"
+	}
+	return fmt.Sprintf("%s:%g:%g", d.Kind, d.A, d.B)
+}
+
+// LinkModel is the fault model of the SimPeer link from a node to another.  The rates are in
+// tenths of a percent, as the drop rate of the nodes.
+type LinkModel struct {
+	Latency   Distribution // of every packet, the Delay of the link if not set
+	Bandwidth int          // bytes per second, 0 for no cap
+	Reorder   int          // packets that skip the latency, overtaking the packets sent before them
+	Duplicate int          // packets delivered twice
+	Drop      int          // packets lost
+}
+
+// ParseLinkModel parses pairs of a setting and its value, as latency normal:100:20 bandwidth
+// 50000 reorder 10 duplicate 5 drop 20, changing the settings of a model
+func ParseLinkModel(m LinkModel, args []string) (LinkModel, error) {
+	if len(args)%2 != 0 {
+		return m, fmt.Errorf("every link setting takes a value")
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, value := args[i], args[i+1]
+		if key == "latency" {
+			d, err := ParseDistribution(value)
+			if err != nil {
+				return m, err
+			}
+			m.Latency = d
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return m, fmt.Errorf("bad %s %s", key, value)
+		}
+		switch key {
+		case "bandwidth":
+			m.Bandwidth = n
+		case "reorder", "duplicate", "drop":
+			if n > 1000 {
+				return m, fmt.Errorf("the %s rate is in tenths of a percent, up to 1000", key)
+			}
+			switch key {
+			case "reorder":
+				m.Reorder = n
+			case "duplicate":
+				m.Duplicate = n
+			case "drop":
+				m.Drop = n
+			}
+		default:
+			return m, fmt.Errorf("unknown link setting %s", key)
+		}
+	}
+	return m, nil
+}
+
+func (m *LinkModel) String() string {
+	return fmt.Sprintf("latency %s bandwidth %d reorder %d duplicate %d drop %d", m.Latency.String(), m.Bandwidth, m.Reorder, m.Duplicate, m.Drop)
+}
+
+// simPartitions are the named partitions applied, as groups of node indexes
+var simPartitions = map[string][][]int{}
+var simPartitionsMutex sync.Mutex
+
+// ApplySimPartition applies a named partition, cutting the links between the nodes of different
+// groups.  A node in no group keeps its links.  Applying a partition of the same name replaces it.
+func ApplySimPartition(name string, groups [][]int) {
+	simPartitionsMutex.Lock()
+	defer simPartitionsMutex.Unlock()
+	simPartitions[name] = groups
+	cutSimPeers(fnodes)
+}
+
+// HealSimPartition removes a named partition, or all of them for an empty name
+func HealSimPartition(name string) {
+	simPartitionsMutex.Lock()
+	defer simPartitionsMutex.Unlock()
+	if name == "" {
+		simPartitions = map[string][][]int{}
+	} else {
+		delete(simPartitions, name)
+	}
+	cutSimPeers(fnodes)
+}
+
+// cutSimPeers cuts the links of the nodes separated by any partition.  Must hold
+// simPartitionsMutex.
+func cutSimPeers(fnodes []*FactomNode) {
+	separated := func(groups [][]int, from, to string) bool {
+		g1, g2 := -1, -1
+		for g, nodes := range groups {
+			for _, n := range nodes {
+				if n < 0 || n >= len(fnodes) {
+					continue
+				}
+				if fnodes[n].State.FactomNodeName == from {
+					g1 = g
+				}
+				if fnodes[n].State.FactomNodeName == to {
+					g2 = g
+				}
+			}
+		}
+		return g1 >= 0 && g2 >= 0 && g1 != g2
+	}
+
+	for _, f := range fnodes {
+		for _, p := range f.Peers {
+			sim, ok := p.(*SimPeer)
+			if !ok {
+				continue
+			}
+			cut := false
+			for _, groups := range simPartitions {
+				cut = cut || separated(groups, sim.FromName, sim.ToName)
+			}
+			sim.Cut.Store(cut)
+		}
+	}
+}
+
+// SetSimLinkModel changes the settings of the links from node from to node to, -1 meaning every
+// node, keeping the other settings of each link.  Without settings the links are made perfect
+// again, but for their Delay.
+func SetSimLinkModel(from, to int, settings []string) error {
+	// Check the settings before changing any link
+	if _, err := ParseLinkModel(LinkModel{}, settings); err != nil {
+		return err
+	}
+	name := func(i int) string {
+		if i < 0 || i >= len(fnodes) {
+			return ""
+		}
+		return fnodes[i].State.FactomNodeName
+	}
+	fromName, toName := name(from), name(to)
+	for _, f := range fnodes {
+		for _, p := range f.Peers {
+			sim, ok := p.(*SimPeer)
+			if !ok {
+				continue
+			}
+			if (from >= 0 && sim.FromName != fromName) || (to >= 0 && sim.ToName != toName) {
+				continue
+			}
+			if len(settings) == 0 {
+				sim.SetModel(nil)
+				continue
+			}
+			model := LinkModel{}
+			if m := sim.GetModel(); m != nil {
+				model = *m
+			}
+			model, _ = ParseLinkModel(model, settings)
+			sim.SetModel(&model)
+		}
+	}
+	return nil
+}
+
+// SimLinkStatus describes the partitions and the link models
+func SimLinkStatus() string {
+	var lines []string
+	simPartitionsMutex.Lock()
+	var names []string
+	for name := range simPartitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("Partition %s: %v", name, simPartitions[name]))
+	}
+	simPartitionsMutex.Unlock()
+
+	for _, f := range fnodes {
+		for _, p := range f.Peers {
+			sim, ok := p.(*SimPeer)
+			if !ok {
+				continue
+			}
+			if m := sim.GetModel(); m != nil || sim.Cut.Load() {
+				status := "cut"
+				if m != nil {
+					status = m.String()
+					if sim.Cut.Load() {
+						status += " (cut)"
+					}
+				}
+				lines = append(lines, fmt.Sprintf("%s -> %s: %s", sim.FromName, sim.ToName, status))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "All links are perfect\n"
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// simLinks lets the debug API run the X commands
+type simLinks struct{}
+
+var _ interfaces.ISimLinks = simLinks{}
+
+func (simLinks) SimLinkCommand(cmd []string) (string, error) {
+	return SimLinkCommand(cmd)
+}
+
+// SimLinkCommand runs the X commands of simControl, for the partitions and the link models:
+//
+//	X                            show the partitions and the link models
+//	Xp name 0,1,2 3,4            apply the partition name, cutting the links between the groups
+//	Xh [name]                    heal the partition name, or all of them
+//	Xl from to setting value...  set the links from node from to node to (* for any node)
+//	Xc                           make every link perfect again
+func SimLinkCommand(cmd []string) (string, error) {
+	if len(cmd) == 0 || len(cmd[0]) == 0 || cmd[0][0] != 'X' {
+		return "", fmt.Errorf("not a link command")
+	}
+	node := func(s string) (int, error) {
+		if s == "*" {
+			return -1, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n >= len(fnodes) {
+			return 0, fmt.Errorf("%s is not a node", s)
+		}
+		return n, nil
+	}
+
+	switch cmd[0] {
+	case "X":
+	case "Xp":
+		if len(cmd) < 4 {
+			return "", fmt.Errorf("Xp takes a name and at least two groups of nodes, as Xp split 0,1 2,3")
+		}
+		var groups [][]int
+		for _, arg := range cmd[2:] {
+			var group []int
+			for _, s := range strings.Split(arg, ",") {
+				n, err := node(s)
+				if err != nil || n < 0 {
+					return "", fmt.Errorf("%s is not a node", s)
+				}
+				group = append(group, n)
+			}
+			groups = append(groups, group)
+		}
+		ApplySimPartition(cmd[1], groups)
+	case "Xh":
+		name := ""
+		if len(cmd) > 1 {
+			name = cmd[1]
+		}
+		HealSimPartition(name)
+	case "Xl":
+		if len(cmd) < 5 {
+			return "", fmt.Errorf("Xl takes two nodes and settings, as Xl 0 * latency normal:100:20")
+		}
+		from, err := node(cmd[1])
+		if err != nil {
+			return "", err
+		}
+		to, err := node(cmd[2])
+		if err != nil {
+			return "", err
+		}
+		if err := SetSimLinkModel(from, to, cmd[3:]); err != nil {
+			return "", err
+		}
+	case "Xc":
+		SetSimLinkModel(-1, -1, nil)
+	default:
+		return "", fmt.Errorf("unknown link command %s", cmd[0])
+	}
+	return SimLinkStatus(), nil
+}
@@ -0,0 +1,151 @@
+package engine_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/engine"
+)
+
+func TestParseDistribution(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, s := range []string{"100", "const:100", "uniform:10:50", "normal:100:20", "exp:50"} {
+		d, err := ParseDistribution(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			if v := d.Sample(r); v < 0 {
+				t.Errorf("%s sampled %d", s, v)
+			}
+		}
+	}
+	for _, s := range []string{"", "pareto:1", "uniform:50:10", "normal:100", "exp:-5", "const:a"} {
+		if _, err := ParseDistribution(s); err == nil {
+			t.Errorf("%s accepted", s)
+		}
+	}
+
+	d, _ := ParseDistribution("uniform:10:50")
+	for i := 0; i < 100; i++ {
+		if v := d.Sample(r); v < 10 || v > 50 {
+			t.Errorf("uniform:10:50 sampled %d", v)
+		}
+	}
+}
+
+func TestParseLinkModel(t *testing.T) {
+	m, err := ParseLinkModel(LinkModel{Drop: 5}, []string{"latency", "normal:100:20", "bandwidth", "50000", "reorder", "10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Latency.Kind != "normal" || m.Latency.A != 100 || m.Latency.B != 20 || m.Bandwidth != 50000 || m.Reorder != 10 || m.Drop != 5 {
+		t.Errorf("Parsed %s", m.String())
+	}
+	for _, args := range [][]string{{"drop"}, {"drop", "1001"}, {"jitter", "5"}, {"bandwidth", "-1"}} {
+		if _, err := ParseLinkModel(LinkModel{}, args); err == nil {
+			t.Errorf("%v accepted", args)
+		}
+	}
+}
+
+// linkedSimPeers returns the two ends of a link, as AddSimPeer connects them
+func linkedSimPeers() (*SimPeer, *SimPeer) {
+	p12 := new(SimPeer).Init("a", "b").(*SimPeer)
+	p21 := new(SimPeer).Init("b", "a").(*SimPeer)
+	p12.BroadcastIn = p21.BroadcastOut
+	p21.BroadcastIn = p12.BroadcastOut
+	return p12, p21
+}
+
+// countReceived sends n messages from one end of a link and counts those received at the other
+func countReceived(t *testing.T, from, to *SimPeer, n int) int {
+	for i := 0; i < n; i++ {
+		b := new(messages.Bounce)
+		b.Timestamp = primitives.NewTimestampNow()
+		if err := from.Send(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	received := 0
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		msg, err := to.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg != nil {
+			received++
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return received
+}
+
+func TestSimPeerLinkModel(t *testing.T) {
+	p12, p21 := linkedSimPeers()
+	if n := countReceived(t, p12, p21, 10); n != 10 {
+		t.Errorf("A perfect link delivered %d of 10", n)
+	}
+
+	p12.SetModel(&LinkModel{Drop: 1000})
+	if n := countReceived(t, p12, p21, 10); n != 0 {
+		t.Errorf("A link dropping everything delivered %d of 10", n)
+	}
+
+	p12.SetModel(&LinkModel{Duplicate: 1000})
+	if n := countReceived(t, p12, p21, 10); n != 20 {
+		t.Errorf("A link duplicating everything delivered %d of 10", n)
+	}
+
+	// The other direction is not affected
+	if n := countReceived(t, p21, p12, 10); n != 10 {
+		t.Errorf("The reverse link delivered %d of 10", n)
+	}
+
+	p12.SetModel(&LinkModel{Latency: Distribution{Kind: "const", A: 5000}})
+	if n := countReceived(t, p12, p21, 10); n != 0 {
+		t.Errorf("A link of 5 seconds latency delivered %d of 10 in a second", n)
+	}
+
+	p12.SetModel(nil)
+	p12.Cut.Store(true)
+	if n := countReceived(t, p12, p21, 10); n != 0 {
+		t.Errorf("A cut link delivered %d of 10", n)
+	}
+}
+
+func TestSimPeerDelayKeepsOrder(t *testing.T) {
+	p12, p21 := linkedSimPeers()
+	p12.Delay = 20
+	for i := 0; i < 50; i++ {
+		b := new(messages.Bounce)
+		b.Number = int32(i)
+		b.Timestamp = primitives.NewTimestampNow()
+		if err := p12.Send(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := int32(0)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && next < 50; {
+		msg, err := p21.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil {
+			time.Sleep(time.Millisecond)
+			continue
+		}
+		if n := msg.(*messages.Bounce).Number; n != next {
+			t.Fatalf("Received message %d, expected %d", n, next)
+		}
+		next++
+	}
+	if next != 50 {
+		t.Errorf("A delayed link delivered %d of 50", next)
+	}
+}
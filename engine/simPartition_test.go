@@ -0,0 +1,93 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FactomProject/factomd/state"
+)
+
+// simNodes makes n nodes without any link, standing in for the nodes of the simulation
+func simNodes(n int) []*FactomNode {
+	var nodes []*FactomNode
+	for i := 0; i < n; i++ {
+		s := new(state.State)
+		s.FactomNodeName = fmt.Sprintf("FNode%d", i)
+		nodes = append(nodes, &FactomNode{Index: i, State: s})
+	}
+	return nodes
+}
+
+// simLink returns the link from node from to node to
+func simLink(t *testing.T, nodes []*FactomNode, from, to int) *SimPeer {
+	for _, p := range nodes[from].Peers {
+		if sim, ok := p.(*SimPeer); ok && sim.ToName == nodes[to].State.FactomNodeName {
+			return sim
+		}
+	}
+	t.Fatalf("No link from %d to %d", from, to)
+	return nil
+}
+
+func TestAddSimPeerRespectsPartitions(t *testing.T) {
+	saved := fnodes
+	fnodes = simNodes(3)
+	defer func() {
+		HealSimPartition("")
+		fnodes = saved
+	}()
+
+	ApplySimPartition("split", [][]int{{0}, {1, 2}})
+	AddSimPeer(fnodes, 0, 1)
+	AddSimPeer(fnodes, 1, 2)
+
+	if !simLink(t, fnodes, 0, 1).Cut.Load() || !simLink(t, fnodes, 1, 0).Cut.Load() {
+		t.Errorf("A link added across the partition was not cut")
+	}
+	if simLink(t, fnodes, 1, 2).Cut.Load() {
+		t.Errorf("A link added within a group was cut")
+	}
+
+	HealSimPartition("split")
+	if simLink(t, fnodes, 0, 1).Cut.Load() {
+		t.Errorf("The link stayed cut once the partition healed")
+	}
+}
+
+func TestSetSimLinkModelKeepsSettings(t *testing.T) {
+	saved := fnodes
+	fnodes = simNodes(3)
+	defer func() { fnodes = saved }()
+
+	AddSimPeer(fnodes, 0, 1)
+	AddSimPeer(fnodes, 1, 2)
+
+	if err := SetSimLinkModel(0, 1, []string{"drop", "5"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetSimLinkModel(-1, -1, []string{"latency", "10"}); err != nil {
+		t.Fatal(err)
+	}
+	if m := simLink(t, fnodes, 0, 1).GetModel(); m == nil || m.Drop != 5 || m.Latency.A != 10 {
+		t.Errorf("Setting every link lost the drop rate of a link, %v", m)
+	}
+	if m := simLink(t, fnodes, 1, 2).GetModel(); m == nil || m.Drop != 0 || m.Latency.A != 10 {
+		t.Errorf("Wrong model %v", m)
+	}
+
+	if err := SetSimLinkModel(-1, -1, []string{"drop", "2000"}); err == nil {
+		t.Errorf("A bad setting was accepted")
+	}
+	if m := simLink(t, fnodes, 0, 1).GetModel(); m.Drop != 5 {
+		t.Errorf("A bad setting changed a link, %v", m)
+	}
+
+	SetSimLinkModel(-1, -1, nil)
+	if m := simLink(t, fnodes, 0, 1).GetModel(); m != nil {
+		t.Errorf("The link is not perfect again, %v", m)
+	}
+}
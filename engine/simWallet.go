@@ -12,6 +12,9 @@ import (
 	"github.com/FactomProject/factomd/wsapi"
 )
 
+// bankSecret is the secret of an address funded in the genesis block, FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q
+const bankSecret = "Fs3E9gV6DXsYzf7Fqx1fVBQPQXV695eP3k5XbmHEZVRLkMdD9qCK"
+
 // FundWallet()
 // Entry Point for no time offset on the transaction.
 func FundWallet(st *state.State, amt uint64) (error, string) {
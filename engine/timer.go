@@ -10,39 +10,40 @@ import (
 
 	"github.com/FactomProject/factomd/common/constants"
 	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
 	s "github.com/FactomProject/factomd/state"
 )
 
 var _ = (*s.State)(nil)
 
 func Timer(state interfaces.IState) {
-	time.Sleep(2 * time.Second)
+	primitives.Sleep(2 * time.Second)
 
 	billion := int64(1000000000)
 	period := int64(state.GetDirectoryBlockInSeconds()) * billion
 	tenthPeriod := period / 10
 
-	now := time.Now().UnixNano() // Time in billionths of a second
+	now := primitives.Now().UnixNano() // Time in billionths of a second
 
 	wait := tenthPeriod - (now % tenthPeriod)
 
 	next := now + wait + tenthPeriod
 
 	if state.GetOut() {
-		state.Print(fmt.Sprintf("Time: %v\r\n", time.Now()))
+		state.Print(fmt.Sprintf("Time: %v\r\n", primitives.Now()))
 	}
 
-	time.Sleep(time.Duration(wait))
+	primitives.Sleep(time.Duration(wait))
 
 	for {
 		for i := 0; i < 10; i++ {
 			// Don't stuff messages into the system if the
 			// Leader is behind.
 			for j := 0; j < 10 && len(state.AckQueue()) > 1000; j++ {
-				time.Sleep(time.Millisecond * 10)
+				primitives.Sleep(time.Millisecond * 10)
 			}
 
-			now = time.Now().UnixNano()
+			now = primitives.Now().UnixNano()
 			if now > next {
 				wait = 1
 				for next < now {
@@ -53,13 +54,13 @@ func Timer(state interfaces.IState) {
 				wait = next - now
 				next += tenthPeriod
 			}
-			time.Sleep(time.Duration(wait))
+			primitives.Sleep(time.Duration(wait))
 			for state.InMsgQueue().Length() > constants.INMSGQUEUE_HIGH {
-				time.Sleep(100 * time.Millisecond)
+				primitives.Sleep(100 * time.Millisecond)
 			}
 
 			// Delay some number of milliseconds.
-			time.Sleep(time.Duration(state.GetTimeOffset().GetTimeMilli()) * time.Millisecond)
+			primitives.Sleep(time.Duration(state.GetTimeOffset().GetTimeMilli()) * time.Millisecond)
 
 			state.TickerQueue() <- i
 
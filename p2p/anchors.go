@@ -0,0 +1,128 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// Anchors are a small set of outgoing connections that we keep across restarts.
+// On startup they are dialed before any peer learned through discovery, so an attacker
+// who poisoned our peers file cannot take over all of our outgoing slots at once.
+// The anchors saved by the previous run are dialed as persistent connections, in outgoing
+// slots reserved for them, and redialed by managePeers when they drop.  Only a ban drops
+// a peer from the anchors.
+
+// AnchorsFilePath derives the path of the anchors file from the path of the peers file,
+// eg: "main-peers.json" becomes "main-peers-anchors.json"
+func AnchorsFilePath(peersFile string) string {
+	return peersFileVariant(peersFile, "anchors")
+}
+
+// selectAnchors picks the best online outgoing regular connections to use as anchors.
+func (c *Controller) selectAnchors() []Peer {
+	candidates := c.connections.getMatching(func(conn *Connection) bool {
+		return conn.IsOutGoing() && conn.IsOnline() && !conn.peer.IsSpecial()
+	})
+	peers := make([]Peer, 0, len(candidates))
+	for _, conn := range candidates {
+		peers = append(peers, conn.peer)
+	}
+	sort.Sort(sort.Reverse(PeerQualitySort(peers)))
+	if len(peers) > NumberAnchorPeers {
+		peers = peers[:NumberAnchorPeers]
+	}
+	return peers
+}
+
+// saveAnchors saves the current anchors out to disk. Called from the runloop.
+func (c *Controller) saveAnchors() {
+	if c.anchorsFilePath == "" {
+		return
+	}
+	anchors := c.selectAnchors()
+	file, err := os.Create(c.anchorsFilePath)
+	if nil != err {
+		c.logger.Errorf("Controller.saveAnchors() File write error on file: %s, Error: %+v", c.anchorsFilePath, err)
+		return
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.Encode(anchors)
+	writer.Flush()
+	c.logger.Debugf("saveAnchors() saved %d anchors", len(anchors))
+}
+
+// loadAnchors loads the anchors saved by the previous run.
+func (c *Controller) loadAnchors() []Peer {
+	if c.anchorsFilePath == "" {
+		return nil
+	}
+	file, err := os.Open(c.anchorsFilePath)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			c.logger.Errorf("Controller.loadAnchors() File read error on file: %s, Error: %+v", c.anchorsFilePath, err)
+		}
+		return nil
+	}
+	defer file.Close()
+	var saved []Peer
+	dec := json.NewDecoder(bufio.NewReader(file))
+	if err := dec.Decode(&saved); err != nil {
+		c.logger.Errorf("Controller.loadAnchors() Decode error on file: %s, Error: %+v", c.anchorsFilePath, err)
+		return nil
+	}
+	anchors := make([]Peer, 0, len(saved))
+	for _, anchor := range saved {
+		if anchor.Network != CurrentNetwork || c.reputation.IsBanned(anchor.Address) {
+			continue
+		}
+		peer := new(Peer).Init(anchor.Address, anchor.Port, anchor.QualityScore, RegularPeer, 0)
+		peer.Source["Anchor"] = anchor.LastContact
+		anchors = append(anchors, *peer)
+	}
+	return anchors
+}
+
+// dialAnchors dials the anchors saved by the previous run, reserving their slots.
+func (c *Controller) dialAnchors() {
+	c.anchors = make(map[string]Peer)
+	for _, anchor := range c.loadAnchors() {
+		if len(c.anchors) >= NumberAnchorPeers {
+			break
+		}
+		c.anchors[anchor.Address] = anchor
+		c.logger.WithField("peer", anchor.AddressPort()).Info("Dialing anchor peer")
+		c.DialPeer(anchor, true)
+	}
+}
+
+// redialAnchors dials the anchors we lost the connection to. Called from the runloop.
+func (c *Controller) redialAnchors() {
+	for address, anchor := range c.anchors {
+		if c.reputation.IsBanned(address) {
+			delete(c.anchors, address)
+			continue
+		}
+		if !c.connections.ConnectedTo(address) {
+			c.logger.WithField("peer", anchor.AddressPort()).Info("Redialing anchor peer")
+			c.DialPeer(anchor, true)
+		}
+	}
+}
+
+// regularOutgoingSlots returns the number of outgoing connections we can open to peers from
+// discovery, the slots of the anchors being reserved for them whether they are connected or not.
+func (c *Controller) regularOutgoingSlots() int {
+	regular := len(c.connections.getMatching(func(conn *Connection) bool {
+		_, anchor := c.anchors[conn.peer.Address]
+		return conn.IsOutGoing() && !anchor
+	}))
+	return NumberPeersToConnect - len(c.anchors) - regular
+}
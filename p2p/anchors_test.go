@@ -0,0 +1,59 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func newAnchorTestController() *Controller {
+	c := new(Controller)
+	c.logger = controllerLogger
+	c.connections = new(ConnectionManager).Init()
+	c.commandChannel = make(chan interface{}, StandardChannelSize)
+	c.reputation = new(Reputation).Init("")
+	c.anchors = make(map[string]Peer)
+	return c
+}
+
+func TestAnchorsReserveOutgoingSlots(t *testing.T) {
+	c := newAnchorTestController()
+	anchor := newPeer("1.2.3.4", "8108", RegularPeer)
+	c.anchors[anchor.Address] = *anchor
+
+	if slots := c.regularOutgoingSlots(); slots != NumberPeersToConnect-1 {
+		t.Errorf("%d slots open with one anchor down, expected %d", slots, NumberPeersToConnect-1)
+	}
+	c.connections.Add(new(Connection).Init(*anchor, true))
+	c.connections.Add(newOutgoingConnection(newPeer("2.3.4.5", "8108", RegularPeer)))
+	if slots := c.regularOutgoingSlots(); slots != NumberPeersToConnect-2 {
+		t.Errorf("%d slots open with one anchor and one regular peer, expected %d", slots, NumberPeersToConnect-2)
+	}
+}
+
+func TestAnchorsRedial(t *testing.T) {
+	c := newAnchorTestController()
+	up := newPeer("1.2.3.4", "8108", RegularPeer)
+	down := newPeer("2.3.4.5", "8108", RegularPeer)
+	banned := newPeer("3.4.5.6", "8108", RegularPeer)
+	for _, p := range []*Peer{up, down, banned} {
+		c.anchors[p.Address] = *p
+	}
+	c.connections.Add(new(Connection).Init(*up, true))
+	c.reputation.Ban(banned.Address, "test", time.Hour)
+
+	c.redialAnchors()
+	if len(c.commandChannel) != 1 {
+		t.Fatalf("%d anchors dialed, expected 1", len(c.commandChannel))
+	}
+	dial := (<-c.commandChannel).(CommandDialPeer)
+	if dial.peer.Address != down.Address || !dial.persistent {
+		t.Errorf("Dialed %s persistent %v, expected a persistent dial of %s", dial.peer.Address, dial.persistent, down.Address)
+	}
+	if _, ok := c.anchors[banned.Address]; ok {
+		t.Errorf("A banned anchor was kept")
+	}
+}
@@ -86,6 +86,17 @@ func (cm *ConnectionManager) SendToAll(message interface{}) {
 	}
 }
 
+// Count the regular connections per subnet, either the incoming or the outgoing ones.
+func (cm *ConnectionManager) CountSubnets(outgoing bool) *SubnetCounter {
+	counter := new(SubnetCounter).Init()
+	for _, connection := range cm.connections {
+		if connection.IsOutGoing() == outgoing && !connection.peer.IsSpecial() {
+			counter.Add(connection.peer.Address)
+		}
+	}
+	return counter
+}
+
 // Update connection counts in Prometheus.
 func (cm *ConnectionManager) UpdatePrometheusMetrics() {
 	p2pControllerNumConnections.Set(float64(cm.Count()))
@@ -11,6 +11,7 @@ package p2p
 // Other than Init and NetworkStart, all administration is done via the channel.
 
 import (
+	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"net"
@@ -36,6 +37,7 @@ type Controller struct {
 	keepRunning bool // Indicates its time to shut down when false.
 
 	listenPort  string             // port we listen on for new connections
+	listeners   []string           // addresses (host:port) we listen on, empty to listen on all interfaces on listenPort
 	connections *ConnectionManager // current connections
 
 	// After launching the network, the management is done via these channels.
@@ -58,6 +60,10 @@ type Controller struct {
 	lastPeerRequest      time.Time        // Last time we asked peers about the peers they know about.
 	specialPeers         map[string]*Peer // special peers (from config file and from the command line params) by peer address
 	partsAssembler       *PartsAssembler  // a data structure that assembles full messages from received message parts
+	reputation           *Reputation      // persistent store of banned peers
+	anchorsFilePath      string           // the path to the anchors file
+	anchors              map[string]Peer  // anchors of this run by peer address, with reserved outgoing slots
+	tracer               *MessageTracer   // propagation records of application messages, nil if tracing is off
 
 	// logging
 	logger *log.Entry
@@ -66,16 +72,20 @@ type Controller struct {
 type ControllerInit struct {
 	NodeName                 string           // Name of the current node
 	Port                     string           // Port to listen on
+	Listeners                []string         // Additional interface/port addresses to listen on, eg "[::1]:8108". If empty listen on all interfaces on Port
 	PeersFile                string           // Path to file to find / save peers
 	Network                  NetworkID        // Network - eg MainNet, TestNet etc.
 	Exclusive                bool             // flag to indicate we should only connect to trusted peers
 	ExclusiveIn              bool             // flag to indicate we should only connect to trusted peers and disallow incoming connections
-	SeedURL                  string           // URL to a source of peer info
+	SeedURL                  string           // URLs of the sources of peer info, see seeds.go
+	SeedPublicKey            string           // Hex encoded key that seed lists must be signed with, empty to accept unsigned lists
 	ConfigPeers              string           // Peers to always connect to at startup, and stay persistent, passed from the config file
 	CmdLinePeers             string           // Additional special peers passed from the command line
 	ConnectionMetricsChannel chan interface{} // Channel on which we put the connection metrics map, periodically.
 	LogPath                  string           // Path for logs
 	LogLevel                 string           // Logging level
+	BanDuration              time.Duration    // How long to ban misbehaving peers, 0 uses the default
+	TraceMessages            bool             // Attach trace context to application messages and record their propagation
 }
 
 // CommandDialPeer is used to instruct the Controller to dial a peer address
@@ -132,6 +142,27 @@ func (e *CommandBan) String() string {
 	return str
 }
 
+// CommandBanAddress is used to instruct the Controller to ban a peer address, disconnecting
+// all the connections to it
+type CommandBanAddress struct {
+	Address  string
+	Reason   string
+	Duration time.Duration
+}
+
+func (e *CommandBanAddress) JSONByte() ([]byte, error) {
+	return primitives.EncodeJSON(e)
+}
+
+func (e *CommandBanAddress) JSONString() (string, error) {
+	return primitives.EncodeJSONString(e)
+}
+
+func (e *CommandBanAddress) String() string {
+	str, _ := e.JSONString()
+	return str
+}
+
 // CommandDisconnect is used to instruct the Controller to disconnect from a peer
 type CommandDisconnect struct {
 	PeerHash string
@@ -175,6 +206,7 @@ func (c *Controller) Init(ci ControllerInit) *Controller {
 	c.connectionMetrics = make(map[string]ConnectionMetrics)
 	c.connectionMetricsChannel = ci.ConnectionMetricsChannel
 	c.listenPort = ci.Port
+	c.listeners = ci.Listeners
 	NetworkListenPort = ci.Port
 	// Set this to the past so we will do peer management almost right away after starting up.
 	c.lastPeerManagement = time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
@@ -182,11 +214,25 @@ func (c *Controller) Init(ci ControllerInit) *Controller {
 	CurrentNetwork = ci.Network
 	OnlySpecialPeers = ci.Exclusive || ci.ExclusiveIn
 	AllowUnknownIncomingPeers = !ci.ExclusiveIn
+	if ci.BanDuration > 0 {
+		BanDuration = ci.BanDuration
+	}
+	c.reputation = new(Reputation).Init(ReputationFilePath(ci.PeersFile))
+	c.reputation.Load()
+	c.anchorsFilePath = AnchorsFilePath(ci.PeersFile)
 	c.initSpecialPeers(ci)
 	c.lastDiscoveryRequest = time.Now() // Discovery does its own on startup.
 	c.lastConnectionMetricsUpdate = time.Now()
 	c.partsAssembler = new(PartsAssembler).Init()
-	discovery := new(Discovery).Init(ci.PeersFile, ci.SeedURL)
+	if ci.TraceMessages {
+		c.tracer = new(MessageTracer).Init(ci.NodeName, MaxTracedMessages)
+	}
+	seedPublicKey, err := hex.DecodeString(ci.SeedPublicKey)
+	if err != nil || (len(seedPublicKey) != 0 && len(seedPublicKey) != 32) {
+		c.logger.Errorf("Invalid seed public key %q, seed lists will be rejected", ci.SeedPublicKey)
+		seedPublicKey = []byte{0} // a key that can't verify anything, rather than silently accepting unsigned lists
+	}
+	discovery := new(Discovery).Init(ci.PeersFile, ci.SeedURL, seedPublicKey)
 	c.discovery = *discovery
 	return c
 }
@@ -199,6 +245,8 @@ func (c *Controller) StartNetwork() {
 	c.listen()
 	// Dial all the gathered special peers
 	c.dialSpecialPeers()
+	// Dial the anchors we had before the last shutdown
+	c.dialAnchors()
 	// Start the runloop
 	go c.runloop()
 }
@@ -225,6 +273,48 @@ func (c *Controller) Ban(peerHash string) {
 	BlockFreeChannelSend(c.commandChannel, CommandBan{PeerHash: peerHash})
 }
 
+// BanAddress bans a peer address for the given duration (0 for the default BanDuration) and
+// disconnects all the connections to that address.
+func (c *Controller) BanAddress(address string, reason string, duration time.Duration) {
+	BlockFreeChannelSend(c.commandChannel, CommandBanAddress{Address: address, Reason: reason, Duration: duration})
+}
+
+// Unban lifts the ban on a peer address. Returns false if the address was not banned.
+func (c *Controller) Unban(address string) bool {
+	return c.reputation.Unban(address)
+}
+
+// GetBans returns the current bans. Safe to call from any goroutine.
+func (c *Controller) GetBans() []BanEntry {
+	return c.reputation.GetBans()
+}
+
+// GetSeedStatus returns the result of the last attempt to use each seed. Safe to call from any goroutine.
+func (c *Controller) GetSeedStatus() []SeedStatus {
+	return c.discovery.GetSeedStatus()
+}
+
+// IsTracing returns true if the controller records the propagation of application messages
+func (c *Controller) IsTracing() bool {
+	return c.tracer != nil
+}
+
+// GetMessageTraces returns the newest propagation records, see MessageTracer.GetRecords
+func (c *Controller) GetMessageTraces(appHash string, appType string, limit int) []PropagationRecord {
+	if c.tracer == nil {
+		return nil
+	}
+	return c.tracer.GetRecords(appHash, appType, limit)
+}
+
+// GetTraceLatencyStats returns the gossip latency distribution of the traced messages
+func (c *Controller) GetTraceLatencyStats(appType string) TraceLatencyStats {
+	if c.tracer == nil {
+		return TraceLatencyStats{AppType: appType}
+	}
+	return c.tracer.GetLatencyStats(appType)
+}
+
 func (c *Controller) Disconnect(peerHash string) {
 	BlockFreeChannelSend(c.commandChannel, CommandDisconnect{PeerHash: peerHash})
 }
@@ -297,16 +387,37 @@ func (c *Controller) dialSpecialPeers() {
 	}
 }
 
+// listen starts an accept loop for every listen address.  Without configured listeners
+// we listen on all interfaces (both IPv4 and IPv6) on the listen port.
 func (c *Controller) listen() {
-	address := fmt.Sprintf(":%s", c.listenPort)
-	c.logger.WithFields(log.Fields{"address": address, "port": c.listenPort}).Infof("Listening for new connections")
-	listener, err := net.Listen("tcp", address)
-	listener = LimitListenerSources(listener)
-	if nil != err {
-		c.logger.Errorf("Controller.listen() Error: %+v", err)
-	} else {
-		go c.acceptLoop(listener)
+	addresses := c.listenAddresses()
+	for _, address := range addresses {
+		c.logger.WithFields(log.Fields{"address": address, "port": c.listenPort}).Infof("Listening for new connections")
+		listener, err := net.Listen("tcp", address)
+		if nil != err {
+			c.logger.Errorf("Controller.listen() Error: %+v", err)
+			continue
+		}
+		go c.acceptLoop(LimitListenerSources(listener))
+	}
+}
+
+// listenAddresses returns the addresses to listen on.  Listeners given without a port
+// use the listen port.
+func (c *Controller) listenAddresses() []string {
+	if len(c.listeners) == 0 {
+		return []string{net.JoinHostPort("", c.listenPort)}
+	}
+	addresses := make([]string, 0, len(c.listeners))
+	for _, listener := range c.listeners {
+		host, port, err := net.SplitHostPort(listener)
+		if err != nil {
+			// no port given, eg "0.0.0.0" or "::1" or "[::1]"
+			host, port = strings.Trim(listener, "[]"), c.listenPort
+		}
+		addresses = append(addresses, net.JoinHostPort(host, port))
 	}
+	return addresses
 }
 
 // Since this runs in its own goroutine we need to send a command when
@@ -338,6 +449,10 @@ func (c *Controller) canConnectTo(conn net.Conn) (bool, string) {
 		return false, "too many incoming connections"
 	}
 
+	if address, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil && c.reputation.IsBanned(address) {
+		return false, "peer is banned"
+	}
+
 	if !AllowUnknownIncomingPeers && !c.isSpecialPeer(conn) {
 		return false, "not a special peer and unknown incoming connections are not allowed"
 	}
@@ -377,7 +492,7 @@ func (c *Controller) parseSpecialPeers(peersString string, peerType uint8) []*Pe
 	for _, peerAddress := range peerAddresses {
 		address, port, err := net.SplitHostPort(peerAddress)
 		if err != nil {
-			c.logger.Errorf("%s is not a valid peer (%v), use format: 127.0.0.1:8999", peersString, err)
+			c.logger.Errorf("%s is not a valid peer (%v), use format: 127.0.0.1:8999 or [::1]:8999", peersString, err)
 		} else {
 			peer := new(Peer).Init(address, port, 0, peerType, 0)
 			peer.Source["Local-Configuration"] = time.Now()
@@ -447,6 +562,9 @@ func (c *Controller) route() {
 		message := <-c.ToNetwork
 		parcel := message.(Parcel)
 		TotalMessagesSent++
+		if c.tracer != nil {
+			c.tracer.Outgoing(&parcel)
+		}
 		switch parcel.Header.TargetPeer {
 		case FullBroadcastFlag: // Send to all peers
 			c.broadcast(parcel, true)
@@ -476,6 +594,9 @@ func (c *Controller) handleParcelReceive(message interface{}, peerHash string, c
 	parameters := message.(ConnectionParcel)
 	parcel := parameters.Parcel
 	parcel.Header.TargetPeer = peerHash // Set the connection ID so the application knows which peer the message is from.
+	if c.tracer != nil {
+		c.tracer.Received(&parcel, connection.peer.AddressPort())
+	}
 	switch parcel.Header.Type {
 	case TypeMessage: // Application message, send it on.
 		ApplicationMessagesReceived++
@@ -526,10 +647,20 @@ func (c *Controller) handleCommand(command interface{}) {
 
 		parameters := command.(CommandAddPeer)
 		conn := parameters.conn // net.Conn
-		addPort := strings.Split(conn.RemoteAddr().String(), ":")
+		address, port, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			c.logger.Warnf("Unable to parse the remote address of an incoming connection: %v", err)
+			_ = conn.Close()
+			break
+		}
 		// Port initially stored will be the connection port (not the listen port), but peer will update it on first message.
-		peer := new(Peer).Init(addPort[0], addPort[1], 0, RegularPeer, 0)
+		peer := new(Peer).Init(address, port, 0, RegularPeer, 0)
 		peer.Source["Accept()"] = time.Now()
+		if !c.isSpecialPeer(conn) && !c.connections.CountSubnets(false).Allows(peer.Address, MaxIncomingPerWideSubnet, MaxIncomingPerNarrowSubnet) {
+			c.logger.WithField("remote_address", conn.RemoteAddr()).Info("Rejecting new connection request: too many incoming connections from the same subnet")
+			_ = conn.Close()
+			break
+		}
 		connection := new(Connection).InitWithConn(conn, *peer)
 		c.handleNewConnection(connection)
 	case CommandShutdown:
@@ -542,6 +673,16 @@ func (c *Controller) handleCommand(command interface{}) {
 		parameters := command.(CommandBan)
 		peerHash := parameters.PeerHash
 		c.applicationPeerUpdate(BannedQualityScore, peerHash)
+		connection, present := c.connections.GetByHash(peerHash)
+		if present {
+			c.reputation.Ban(connection.peer.Address, "banned by application", 0)
+			delete(c.anchors, connection.peer.Address)
+		}
+	case CommandBanAddress:
+		parameters := command.(CommandBanAddress)
+		c.reputation.Ban(parameters.Address, parameters.Reason, parameters.Duration)
+		delete(c.anchors, parameters.Address)
+		c.disconnectAddress(parameters.Address)
 	case CommandDisconnect:
 		parameters := command.(CommandDisconnect)
 		connection, present := c.connections.GetByHash(parameters.PeerHash)
@@ -553,6 +694,15 @@ func (c *Controller) handleCommand(command interface{}) {
 	}
 }
 
+// disconnectAddress shuts down all connections to the given address
+func (c *Controller) disconnectAddress(address string) {
+	for _, connection := range c.connections.All() {
+		if connection.peer.Address == address {
+			BlockFreeChannelSend(connection.SendChannel, ConnectionCommand{Command: ConnectionShutdownNow})
+		}
+	}
+}
+
 func (c *Controller) handleNewConnection(connection *Connection) {
 	oldConnection, alreadyConnected := c.connections.GetByHash(connection.peer.Hash)
 	if alreadyConnected {
@@ -588,17 +738,19 @@ func (c *Controller) managePeers() {
 			c.discovery.DiscoverPeersFromSeed()
 			c.logger.Debug("back from c.discovery.DiscoverPeersFromSeed()")
 		}
-		outgoingCount := c.connections.outgoingCount
-		c.logger.Debugf("managePeers() NumberPeersToConnect: %d outgoing: %d", NumberPeersToConnect, outgoingCount)
-		if NumberPeersToConnect > outgoingCount {
+		c.redialAnchors()
+		openSlots := c.regularOutgoingSlots()
+		c.logger.Debugf("managePeers() NumberPeersToConnect: %d outgoing: %d anchors: %d", NumberPeersToConnect, c.connections.outgoingCount, len(c.anchors))
+		if openSlots > 0 {
 			// Get list of peers ordered by quality from discovery
-			c.fillOutgoingSlots(NumberPeersToConnect - outgoingCount)
+			c.fillOutgoingSlots(openSlots)
 		}
 		duration := time.Since(c.discovery.lastPeerSave)
 		// Every so often, tell the discovery service to save peers.
 		if PeerSaveInterval < duration {
 			c.logger.Debug("Saving peers")
 			c.discovery.SavePeers()
+			c.saveAnchors()
 		}
 		duration = time.Since(c.lastPeerRequest)
 		if PeerRequestInterval < duration {
@@ -616,10 +768,23 @@ func (c *Controller) fillOutgoingSlots(openSlots int) {
 
 	// To avoid dialing "too many" peers, we are keeping a count and only dialing the number of peers we need to add.
 	newPeers := 0
+	subnets := c.connections.CountSubnets(true)
 	for _, peer := range peers {
+		if c.reputation.IsBanned(peer.Address) {
+			continue
+		}
+		// The anchors are dialed in their own slots
+		if _, anchor := c.anchors[peer.Address]; anchor {
+			continue
+		}
+		// Keep our outgoing connections spread over many networks
+		if !peer.IsSpecial() && !subnets.Allows(peer.Address, MaxOutgoingPerWideSubnet, MaxOutgoingPerNarrowSubnet) {
+			continue
+		}
 		if !c.connections.ConnectedTo(peer.Address) && newPeers < openSlots {
 			c.logger.Debugf("newPeers: %d < openSlots: %d We think we are not already connected to: %s so dialing.", newPeers, openSlots, peer.AddressPort())
 			newPeers = newPeers + 1
+			subnets.Add(peer.Address)
 			c.DialPeer(peer, false)
 		}
 	}
@@ -653,6 +818,7 @@ func (c *Controller) updateMetrics() {
 
 func (c *Controller) shutdown() {
 	c.logger.Debug("Controller.shutdown()")
+	c.saveAnchors()
 	c.connections.SendToAll(ConnectionCommand{Command: ConnectionShutdownNow})
 	c.keepRunning = false
 }
@@ -9,8 +9,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"math/rand"
-	"net"
-	"net/http"
 	"os"
 	"sort"
 	"strconv"
@@ -28,7 +26,9 @@ type Discovery struct {
 	peersFilePath string     // the path to the peers.
 	lastPeerSave  time.Time  // Last time we saved known peers.
 	rng           *rand.Rand // RNG = random number generator
-	seedURL       string     // URL to the source of a list of peers
+	seedSources   []string   // URLs of the sources of a list of peers, see seeds.go
+	seedPublicKey []byte     // if set, seed lists must be signed with this key
+	seedStatuses  *seedStatuses
 
 	// logging
 	logger *log.Entry
@@ -42,14 +42,16 @@ var UpdateKnownPeers sync.Mutex
 // Controller and its routines are called from the Controllers runloop()
 // This ensures that all shared memory is accessed from that goroutine.
 
-func (d *Discovery) Init(peersFile string, seed string) *Discovery {
+func (d *Discovery) Init(peersFile string, seeds string, seedPublicKey []byte) *Discovery {
 	d.logger = discoLogger
 	UpdateKnownPeers.Lock()
 	d.knownPeers = map[string]Peer{}
 	UpdateKnownPeers.Unlock()
 	d.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 	d.peersFilePath = peersFile
-	d.seedURL = seed
+	d.seedSources = ParseSeedSources(seeds)
+	d.seedPublicKey = seedPublicKey
+	d.seedStatuses = new(seedStatuses)
 	//d.LoadPeers()
 	d.DiscoverPeersFromSeed()
 	return d
@@ -98,11 +100,14 @@ func (d *Discovery) LoadPeers() {
 		return
 	}
 	dec := json.NewDecoder(bufio.NewReader(file))
+	// The peers file is keyed by address:port, while known peers are indexed by address.
+	filePeers := map[string]Peer{}
+	dec.Decode(&filePeers)
 	UpdateKnownPeers.Lock()
-	dec.Decode(&d.knownPeers)
 	// since this is run at startup, reset quality scores.
-	for _, peer := range d.knownPeers {
+	for _, peer := range filePeers {
 		peer.QualityScore = 0
+		peer.Address = NormalizeAddress(peer.Address)
 		peer.Location = peer.LocationFromAddress()
 		d.knownPeers[peer.Address] = peer
 	}
@@ -161,6 +166,7 @@ func (d *Discovery) LearnPeers(parcel Parcel) {
 	filteredArray := d.filterPeersFromOtherNetworks(peerArray)
 	for _, value := range filteredArray {
 		value.QualityScore = 0
+		value.Address = NormalizeAddress(value.Address)
 		switch d.isPeerPresent(value) {
 		case true:
 			alreadyKnownPeer := d.getPeer(value.Address)
@@ -195,13 +201,35 @@ func (d *Discovery) filterPeersFromOtherNetworks(peers []Peer) (filtered []Peer)
 	return
 }
 
+// filterForUniqueIPAdresses keeps one peer per IP address.  Addresses are compared in their
+// normalized form, so an IPv4 address and its IPv4-mapped IPv6 form count as the same host.
 func (d *Discovery) filterForUniqueIPAdresses(peers []Peer) (filtered []Peer) {
 	unique := map[string]Peer{}
 	for _, peer := range peers {
-		_, present := unique[peer.Address]
+		address := NormalizeAddress(peer.Address)
+		_, present := unique[address]
 		if !present {
 			filtered = append(filtered, peer)
-			unique[peer.Address] = peer
+			unique[address] = peer
+		}
+	}
+	return
+}
+
+// filterForSubnetDiversity limits the number of candidates from a single subnet, so a
+// network range that announced many peers can not crowd out everyone else.
+// Peers are shuffled first, so different peers of a crowded subnet get a chance over time.
+func (d *Discovery) filterForSubnetDiversity(peers []Peer) (filtered []Peer) {
+	shuffled := make([]Peer, len(peers))
+	copy(shuffled, peers)
+	shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	subnets := new(SubnetCounter).Init()
+	for _, peer := range shuffled {
+		if peer.IsSpecial() || subnets.Allows(peer.Address, MaxOutgoingPerWideSubnet, MaxOutgoingPerNarrowSubnet) {
+			filtered = append(filtered, peer)
+			subnets.Add(peer.Address)
 		}
 	}
 	return
@@ -231,7 +259,8 @@ func (d *Discovery) GetOutgoingPeers() []Peer {
 	}
 	UpdateKnownPeers.Unlock()
 	secondPass := d.filterPeersFromOtherNetworks(firstPassPeers)
-	peerPool := d.filterForUniqueIPAdresses(secondPass)
+	thirdPass := d.filterForUniqueIPAdresses(secondPass)
+	peerPool := d.filterForSubnetDiversity(thirdPass)
 	sort.Sort(PeerDistanceSort(peerPool))
 	// Get four times as many as who knows how many will be online
 	desiredQuantity := NumberPeersToConnect * 4
@@ -280,6 +309,7 @@ func (d *Discovery) getPeerSelection() []byte {
 	selectedPeers := []Peer{}
 	firstPassPeers := []Peer{}
 	specialPeersByLocation := map[uint32]Peer{}
+	specialIPv6Peers := map[string]Peer{}
 	UpdateKnownPeers.Lock()
 	for _, peer := range d.knownPeers {
 		if peer.QualityScore > MinumumSharingQualityScore { // Only share peers that have earned positive reputation
@@ -292,13 +322,23 @@ func (d *Discovery) getPeerSelection() []byte {
 	// Pull out special peers by location.  Use location because it should more accurately reflect IP address.
 	// we check by location to keep from sharing special peers when they dial into us (in which case we wouldn't realize
 	// they were special by the flag.)
+	// IPv6 locations only hold the routing prefix, so those are matched by address instead.
 	for _, peer := range peerPool {
-		if peer.IsSpecial() && peer.Location != 0 { // only include special peers that have IP address
+		switch {
+		case !peer.IsSpecial():
+		case peer.IsIPv6():
+			specialIPv6Peers[peer.Address] = peer
+		case peer.Location != 0: // only include special peers that have IP address
 			specialPeersByLocation[peer.Location] = peer
 		}
 	}
 	for _, peer := range peerPool {
-		_, present := specialPeersByLocation[peer.Location]
+		var present bool
+		if peer.IsIPv6() {
+			_, present = specialIPv6Peers[peer.Address]
+		} else {
+			_, present = specialPeersByLocation[peer.Location]
+		}
 		switch {
 		case peer.IsSpecial():
 			break
@@ -319,33 +359,3 @@ func (d *Discovery) getPeerSelection() []byte {
 	d.logger.Debugf("peers we are sharing: %+v", string(json))
 	return json
 }
-
-// DiscoverPeers gets a set of peers from a DNS Seed
-func (d *Discovery) DiscoverPeersFromSeed() {
-	d.logger.Info("Contacting seed URL to get peers")
-	resp, err := http.Get(d.seedURL)
-	if nil != err {
-		d.logger.Errorf("DiscoverPeersFromSeed getting peers from %s produced error %+v", d.seedURL, err)
-		return
-	}
-	defer resp.Body.Close()
-	var lines []string
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	bad := 0
-	for _, line := range lines {
-		address, port, err := net.SplitHostPort(line)
-		if err == nil {
-			peerp := new(Peer).Init(address, port, 0, RegularPeer, 0)
-			peer := *peerp
-			peer.LastContact = time.Now()
-			d.updatePeer(d.updatePeerSource(peer, "DNS-Seed"))
-		} else {
-			bad++
-			d.logger.Errorf("Bad peer in " + d.seedURL + " [" + line + "]")
-		}
-	}
-	d.logger.Debugf("DiscoverPeersFromSeed got peers: %+v", lines)
-}
@@ -34,10 +34,11 @@ type ParcelHeader struct {
 	PartNo      uint16            // 2 bytes - in case of multipart parcels, indicates which part this corresponds to, otherwise should be 0
 	PartsTotal  uint16            // 2 bytes - in case of multipart parcels, indicates the total number of parts that the receiver should expect
 	NodeID      uint64
-	PeerAddress string // address of the peer set by connection to know who sent message (for tracking source of other peers)
-	PeerPort    string // port of the peer , or we are listening on
-	AppHash     string // Application specific message hash, for tracing
-	AppType     string // Application specific message type, for tracing
+	PeerAddress string       // address of the peer set by connection to know who sent message (for tracking source of other peers)
+	PeerPort    string       // port of the peer , or we are listening on
+	AppHash     string       // Application specific message hash, for tracing
+	AppType     string       // Application specific message type, for tracing
+	Trace       *ParcelTrace // Origin and hop count of the message when tracing is on, nil otherwise
 }
 
 type ParcelCommandType uint16
@@ -120,6 +121,9 @@ func ReassembleParcel(parcels []*Parcel) *Parcel {
 	assembledParcel.Header.TargetPeer = origHeader.TargetPeer
 	assembledParcel.Header.PeerAddress = origHeader.PeerAddress
 	assembledParcel.Header.PeerPort = origHeader.PeerPort
+	assembledParcel.Header.AppHash = origHeader.AppHash
+	assembledParcel.Header.AppType = origHeader.AppType
+	assembledParcel.Header.Trace = origHeader.Trace
 
 	return assembledParcel
 }
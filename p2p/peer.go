@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -19,7 +20,7 @@ var peerLogger = packageLogger.WithField("subpack", "peer")
 
 type Peer struct {
 	QualityScore int32     // 0 is neutral quality, negative is a bad peer.
-	Address      string    // Must be in form of x.x.x.x
+	Address      string    // IP address, either IPv4 (x.x.x.x) or IPv6 (x:x::x) without brackets
 	Port         string    // Must be in form of xxxx
 	NodeID       uint64    // a nonce to distinguish multiple nodes behind one IP address
 	Hash         string    // This is more of a connection ID than hash right now.
@@ -57,7 +58,7 @@ func (p *Peer) Init(address string, port string, quality int32, peerType uint8,
 		}
 	}
 
-	p.Address = address
+	p.Address = NormalizeAddress(address)
 	p.Port = port
 	p.QualityScore = quality
 	p.generatePeerHash()
@@ -68,21 +69,39 @@ func (p *Peer) Init(address string, port string, quality int32, peerType uint8,
 	return p
 }
 
+// NormalizeAddress returns the canonical form of an IP address, so the same host is always
+// known by the same string (eg: "::ffff:1.2.3.4" becomes "1.2.3.4", and IPv6 addresses
+// lose their brackets and leading zeros). Host names are returned unchanged.
+func NormalizeAddress(address string) string {
+	ip := net.ParseIP(strings.Trim(address, "[]"))
+	if ip == nil {
+		return address
+	}
+	return ip.String()
+}
+
 func (p *Peer) generatePeerHash() {
-	p.Hash = fmt.Sprintf("%s:%s %x", p.Address, p.Port, rand.Int63())
+	p.Hash = fmt.Sprintf("%s %x", p.AddressPort(), rand.Int63())
 }
 
+// AddressPort returns the address in host:port form, with brackets around IPv6 addresses
 func (p *Peer) AddressPort() string {
-	return p.Address + ":" + p.Port
+	return net.JoinHostPort(p.Address, p.Port)
 }
 
 func (p *Peer) PeerIdent() string {
-	return p.Hash[0:12] + "-" + p.Address + ":" + p.Port
+	return p.Hash[0:12] + "-" + p.AddressPort()
 }
 
 func (p *Peer) PeerFixedIdent() string {
-	address := fmt.Sprintf("%16s", p.Address)
-	return p.Hash[0:12] + "-" + address + ":" + p.Port
+	address := fmt.Sprintf("%16s", p.AddressPort())
+	return p.Hash[0:12] + "-" + address
+}
+
+// IsIPv6 returns true if the peer's address is an IPv6 address
+func (p *Peer) IsIPv6() bool {
+	ip := net.ParseIP(p.Address)
+	return ip != nil && ip.To4() == nil
 }
 
 func (p *Peer) PeerLogFields() log.Fields {
@@ -107,18 +126,11 @@ func (p *Peer) LastSource() (result string) {
 	return
 }
 
-// TODO Hadn't considered IPV6 address support.
-// TODO Need to audit all the net code to check IPv6 addresses
-// Here's an IPv6 conversion:
-// Ref: http://stackoverflow.com/questions/23297141/golang-net-ip-to-ipv6-from-mysql-as-decimal39-0-conversion
-// func ipv6ToInt(IPv6Addr net.IP) *big.Int {
-//     IPv6Int := big.NewInt(0)
-//     IPv6Int.SetBytes(IPv6Addr)
-//     return IPv6Int
-// }
 // Problem is we're working with string addresses, may never have made a connection.
 // TODO - we might have a DNS address, not iP address and need to resolve it!
 // locationFromAddress converts the peers address into a uint32 "location" numeric
+// For IPv4 this is the address itself.  For IPv6 the location is the top 32 bits of the
+// address (the routing prefix), which is what matters for sorting peers by network distance.
 func (p *Peer) LocationFromAddress() (location uint32) {
 	location = 0
 	// Split the IPv4 octets
@@ -131,11 +143,11 @@ func (p *Peer) LocationFromAddress() (location uint32) {
 			p.logger.Debugf("Peer: %s has Location: %d", p.Hash, location)
 			return 0 // We use location on 0 to say invalid
 		}
-		p.Address = ipAddress[0]
+		p.Address = NormalizeAddress(ipAddress[0])
 		ip = net.ParseIP(p.Address)
 	}
-	if len(ip) == 16 { // If we got back an IP6 (16 byte) address, use the last 4 byte
-		ip = ip[12:]
+	if ip4 := ip.To4(); ip4 != nil { // IPv4, or IPv4 mapped into IPv6 (16 byte) form
+		ip = ip4
 	}
 	// Turn into uint32
 	location += uint32(ip[0]) << 24
@@ -151,7 +163,7 @@ func (p *Peer) IsSamePeerAs(netAddress net.Addr) bool {
 	if err != nil {
 		return false
 	}
-	return address == p.Address
+	return NormalizeAddress(address) == p.Address
 }
 
 // merit increases a peers reputation
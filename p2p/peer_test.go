@@ -0,0 +1,89 @@
+package p2p
+
+import (
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3.4":                 "1.2.3.4",
+		"::ffff:1.2.3.4":          "1.2.3.4",
+		"[2001:db8::1]":           "2001:db8::1",
+		"2001:0db8:0000::0001":    "2001:db8::1",
+		"seed.factom.example.com": "seed.factom.example.com",
+	}
+	for address, expected := range tests {
+		if normalized := NormalizeAddress(address); normalized != expected {
+			t.Errorf("NormalizeAddress(%s) = %s, expected %s", address, normalized, expected)
+		}
+	}
+}
+
+func TestPeerIPv6AddressPort(t *testing.T) {
+	peer := newPeer("2001:db8::1", "8108", RegularPeer)
+	if peer.AddressPort() != "[2001:db8::1]:8108" {
+		t.Errorf("Wrong IPv6 address:port %s", peer.AddressPort())
+	}
+	if !peer.IsIPv6() {
+		t.Error("IPv6 peer not detected as IPv6")
+	}
+
+	peer = newPeer("1.2.3.4", "8108", RegularPeer)
+	if peer.AddressPort() != "1.2.3.4:8108" {
+		t.Errorf("Wrong IPv4 address:port %s", peer.AddressPort())
+	}
+	if peer.IsIPv6() {
+		t.Error("IPv4 peer detected as IPv6")
+	}
+}
+
+func TestPeerLocationFromAddress(t *testing.T) {
+	peer := newPeer("1.2.3.4", "8108", RegularPeer)
+	if peer.Location != 0x01020304 {
+		t.Errorf("Wrong IPv4 location %x", peer.Location)
+	}
+
+	peer = newPeer("::ffff:1.2.3.4", "8108", RegularPeer)
+	if peer.Location != 0x01020304 {
+		t.Errorf("Wrong location %x for an IPv4 mapped address", peer.Location)
+	}
+
+	peer = newPeer("2001:db8::1", "8108", RegularPeer)
+	if peer.Location != 0x20010db8 {
+		t.Errorf("Wrong IPv6 location %x", peer.Location)
+	}
+}
+
+func TestFilterForUniqueIPAddresses(t *testing.T) {
+	d := new(Discovery).Init("", "", nil)
+	peers := []Peer{
+		{Address: "1.2.3.4", Port: "8108"},
+		{Address: "::ffff:1.2.3.4", Port: "8108"},
+		{Address: "2001:db8::1", Port: "8108"},
+		{Address: "2001:0db8::0001", Port: "8109"},
+	}
+	filtered := d.filterForUniqueIPAdresses(peers)
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 unique addresses, got %d: %+v", len(filtered), filtered)
+	}
+}
+
+func TestControllerListenAddresses(t *testing.T) {
+	c := &Controller{listenPort: "8108"}
+	addresses := c.listenAddresses()
+	if len(addresses) != 1 || addresses[0] != ":8108" {
+		t.Errorf("Expected to listen on all interfaces, got %v", addresses)
+	}
+
+	c.listeners = []string{"127.0.0.1", "[::1]:9000", "::1", "0.0.0.0:8200"}
+	expected := []string{"127.0.0.1:8108", "[::1]:9000", "[::1]:8108", "0.0.0.0:8200"}
+	addresses = c.listenAddresses()
+	if len(addresses) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, addresses)
+	}
+	for i := range expected {
+		if addresses[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], addresses[i])
+		}
+	}
+}
@@ -59,6 +59,11 @@ var (
 	NumberPeersToConnect                = 32
 	NumberPeersToBroadcast              = 8 // This gets overwritten by command line flag!
 	MaxNumberIncomingConnections        = 150
+	MaxIncomingPerWideSubnet            = 16 // Max incoming connections from one IPv4 /16
+	MaxIncomingPerNarrowSubnet          = 4  // Max incoming connections from one IPv4 /24 or IPv6 /48
+	MaxOutgoingPerWideSubnet            = 2  // Max outgoing connections to one IPv4 /16
+	MaxOutgoingPerNarrowSubnet          = 1  // Max outgoing connections to one IPv4 /24 or IPv6 /48
+	NumberAnchorPeers                   = 4  // Number of outgoing connections saved, and kept in reserved slots after a restart
 	MaxNumberOfRedialAttempts           = 5 // How many missing pings (and other) before we give up and close.
 	StandardChannelSize                 = 5000
 	NetworkStatusInterval               = time.Second * 9
@@ -68,6 +73,8 @@ var (
 	PeerSaveInterval                    = time.Second * 30
 	PeerRequestInterval                 = time.Second * 180
 	PeerDiscoveryInterval               = time.Hour * 4
+	BanDuration                         = time.Hour * 24 // Default duration of a ban, overridden by the config file
+	MaxTracedMessages                   = 10000          // Number of propagation records kept when message tracing is on
 
 	// Testing metrics
 	TotalMessagesReceived       uint64
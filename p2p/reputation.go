@@ -0,0 +1,205 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var reputationLogger = packageLogger.WithField("subpack", "reputation")
+
+// BanEntry records why and until when a peer address is banned.
+type BanEntry struct {
+	Address string    // IP address of the banned peer
+	Reason  string    // Human readable reason for the ban
+	Banned  time.Time // When the ban was put in place
+	Expires time.Time // When the ban is lifted
+}
+
+func (b BanEntry) IsExpired() bool {
+	return time.Now().After(b.Expires)
+}
+
+// Reputation keeps track of banned peer addresses and persists them to disk,
+// so bans survive a restart of the node. Unlike the Discovery, the reputation
+// store is accessed from outside of the controller runloop (debug API, control
+// panel), so all access is guarded by a mutex.
+type Reputation struct {
+	sync.RWMutex
+	bans      map[string]BanEntry // bans indexed by peer address
+	filePath  string              // the path to the bans file
+	saveMutex sync.Mutex          // one Save writes the file at a time
+
+	// logging
+	logger *log.Entry
+}
+
+func (r *Reputation) Init(filePath string) *Reputation {
+	r.logger = reputationLogger
+	r.bans = make(map[string]BanEntry)
+	r.filePath = filePath
+	return r
+}
+
+// ReputationFilePath derives the path of the bans file from the path of the peers file,
+// eg: "main-peers.json" becomes "main-peers-bans.json"
+func ReputationFilePath(peersFile string) string {
+	return peersFileVariant(peersFile, "bans")
+}
+
+// peersFileVariant derives the path of a file that lives next to the peers file
+func peersFileVariant(peersFile string, suffix string) string {
+	if peersFile == "" {
+		return ""
+	}
+	ext := filepath.Ext(peersFile)
+	return strings.TrimSuffix(peersFile, ext) + "-" + suffix + ext
+}
+
+// Ban bans the address for the given duration. A duration of 0 or less uses the
+// default BanDuration. Banning an already banned address replaces the old ban.
+func (r *Reputation) Ban(address string, reason string, duration time.Duration) BanEntry {
+	if duration <= 0 {
+		duration = BanDuration
+	}
+	now := time.Now()
+	entry := BanEntry{
+		Address: address,
+		Reason:  reason,
+		Banned:  now,
+		Expires: now.Add(duration),
+	}
+	r.Lock()
+	r.bans[address] = entry
+	r.Unlock()
+	r.logger.WithFields(log.Fields{
+		"address": address,
+		"reason":  reason,
+		"expires": entry.Expires}).Info("Banned peer")
+	r.Save()
+	return entry
+}
+
+// Unban lifts the ban on an address. Returns false if the address was not banned.
+func (r *Reputation) Unban(address string) bool {
+	r.Lock()
+	_, present := r.bans[address]
+	delete(r.bans, address)
+	r.Unlock()
+	if present {
+		r.logger.WithField("address", address).Info("Unbanned peer")
+		r.Save()
+	}
+	return present
+}
+
+// IsBanned returns true if the address has a ban that has not expired yet.
+func (r *Reputation) IsBanned(address string) bool {
+	r.RLock()
+	entry, present := r.bans[address]
+	r.RUnlock()
+	return present && !entry.IsExpired()
+}
+
+// GetBans returns all the current (non expired) bans sorted by address.
+func (r *Reputation) GetBans() []BanEntry {
+	r.pruneExpired()
+	r.RLock()
+	bans := make([]BanEntry, 0, len(r.bans))
+	for _, entry := range r.bans {
+		bans = append(bans, entry)
+	}
+	r.RUnlock()
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Address < bans[j].Address
+	})
+	return bans
+}
+
+// pruneExpired drops all bans that have expired.
+func (r *Reputation) pruneExpired() {
+	r.Lock()
+	defer r.Unlock()
+	for address, entry := range r.bans {
+		if entry.IsExpired() {
+			r.logger.WithField("address", address).Debug("Ban expired")
+			delete(r.bans, address)
+		}
+	}
+}
+
+// Load loads the bans from disk OVERWRITING PREVIOUS VALUES
+func (r *Reputation) Load() {
+	if r.filePath == "" {
+		return
+	}
+	file, err := os.Open(r.filePath)
+	if nil != err {
+		if !os.IsNotExist(err) {
+			r.logger.Errorf("Reputation.Load() File read error on file: %s, Error: %+v", r.filePath, err)
+		}
+		return
+	}
+	defer file.Close()
+	bans := make(map[string]BanEntry)
+	dec := json.NewDecoder(bufio.NewReader(file))
+	if err := dec.Decode(&bans); err != nil {
+		r.logger.Errorf("Reputation.Load() Decode error on file: %s, Error: %+v", r.filePath, err)
+		return
+	}
+	r.Lock()
+	r.bans = bans
+	r.Unlock()
+	r.pruneExpired()
+	r.logger.Debugf("Load() found %d bans in %s", len(r.bans), r.filePath)
+}
+
+// Save writes the non expired bans out to disk.  The bans are written to a temporary file that
+// then replaces the bans file, so a crash while saving leaves the previous bans in place.
+func (r *Reputation) Save() {
+	if r.filePath == "" {
+		return
+	}
+	r.saveMutex.Lock()
+	defer r.saveMutex.Unlock()
+	r.pruneExpired()
+	tmpPath := r.filePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if nil != err {
+		r.logger.Errorf("Reputation.Save() File write error on file: %s, Error: %+v", tmpPath, err)
+		return
+	}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	r.RLock()
+	err = encoder.Encode(r.bans)
+	r.RUnlock()
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		r.logger.Errorf("Reputation.Save() Encode error on file: %s, Error: %+v", tmpPath, err)
+		os.Remove(tmpPath)
+		return
+	}
+	if err := os.Rename(tmpPath, r.filePath); err != nil {
+		r.logger.Errorf("Reputation.Save() Rename error on file: %s, Error: %+v", r.filePath, err)
+	}
+}
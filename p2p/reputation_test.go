@@ -0,0 +1,126 @@
+package p2p
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReputationFilePath(t *testing.T) {
+	if path := ReputationFilePath("/home/factom/main-peers.json"); path != "/home/factom/main-peers-bans.json" {
+		t.Errorf("Wrong reputation file path: %s", path)
+	}
+	if path := ReputationFilePath(""); path != "" {
+		t.Errorf("Empty peers file should give an empty reputation file path, got %s", path)
+	}
+}
+
+func TestReputationBanAndUnban(t *testing.T) {
+	r := new(Reputation).Init("")
+
+	if r.IsBanned("1.2.3.4") {
+		t.Error("Empty reputation store reports a ban")
+	}
+
+	r.Ban("1.2.3.4", "misbehaving", time.Hour)
+	if !r.IsBanned("1.2.3.4") {
+		t.Error("Banned address is not reported as banned")
+	}
+	if r.IsBanned("2.3.4.5") {
+		t.Error("Address that was not banned is reported as banned")
+	}
+
+	bans := r.GetBans()
+	if len(bans) != 1 || bans[0].Address != "1.2.3.4" || bans[0].Reason != "misbehaving" {
+		t.Errorf("Unexpected bans: %+v", bans)
+	}
+
+	if !r.Unban("1.2.3.4") {
+		t.Error("Unban did not find the banned address")
+	}
+	if r.IsBanned("1.2.3.4") {
+		t.Error("Unbanned address is still reported as banned")
+	}
+	if r.Unban("1.2.3.4") {
+		t.Error("Unban of an address that is not banned returned true")
+	}
+}
+
+func TestReputationBanExpires(t *testing.T) {
+	r := new(Reputation).Init("")
+
+	r.Ban("1.2.3.4", "short ban", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if r.IsBanned("1.2.3.4") {
+		t.Error("Expired ban is still reported as banned")
+	}
+	if bans := r.GetBans(); len(bans) != 0 {
+		t.Errorf("Expired ban is still listed: %+v", bans)
+	}
+}
+
+func TestReputationDefaultDuration(t *testing.T) {
+	r := new(Reputation).Init("")
+
+	entry := r.Ban("1.2.3.4", "default", 0)
+	if entry.Expires.Sub(entry.Banned) != BanDuration {
+		t.Errorf("Ban with no duration should use BanDuration %s, got %s", BanDuration, entry.Expires.Sub(entry.Banned))
+	}
+}
+
+func TestReputationPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers-bans.json")
+
+	r := new(Reputation).Init(path)
+	r.Ban("1.2.3.4", "persisted", time.Hour)
+	r.Ban("2.3.4.5", "expired", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	loaded := new(Reputation).Init(path)
+	loaded.Load()
+	if !loaded.IsBanned("1.2.3.4") {
+		t.Error("Ban was not persisted across a reload")
+	}
+	bans := loaded.GetBans()
+	if len(bans) != 1 || bans[0].Reason != "persisted" {
+		t.Errorf("Unexpected bans after reload: %+v", bans)
+	}
+}
+
+func TestReputationConcurrentSaves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers-bans.json")
+
+	r := new(Reputation).Init(path)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.Ban(fmt.Sprintf("10.0.0.%d", i), "concurrent", time.Hour)
+		}(i)
+	}
+	wg.Wait()
+
+	loaded := new(Reputation).Init(path)
+	loaded.Load()
+	if bans := loaded.GetBans(); len(bans) != 20 {
+		t.Errorf("Found %d bans after concurrent saves, expected 20", len(bans))
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("The temporary bans file was left behind")
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// Seeds are the sources we bootstrap our known peers from.  The seed configuration is a
+// comma (or whitespace) separated list of sources, tried in order until one of them gives us peers:
+//	https://example.com/seed.txt	-- a list of host:port lines fetched over HTTP(S)
+//	dns://seed.example.com		-- TXT records holding host:port lines, or else the A/AAAA
+//					   records of the host combined with our network port
+//
+// If a seed public key is configured, a seed list must be signed: one of its lines (or TXT records)
+// must be "signature:<hex ed25519 signature>", signing the sorted peer lines joined by newlines.
+// Unsigned sources, such as plain A/AAAA records, are rejected.
+
+const (
+	SeedTypeHTTP = "http"
+	SeedTypeDNS  = "dns"
+
+	seedSignaturePrefix = "signature:"
+	dnsSeedScheme       = "dns://"
+)
+
+// Resolvers used for DNS seeds. Variables so they can be replaced in tests.
+var (
+	lookupTXT  = net.LookupTXT
+	lookupHost = net.LookupHost
+)
+
+// SeedStatus is the result of the last attempt to get peers from a seed source
+type SeedStatus struct {
+	Source      string
+	Type        string
+	LastAttempt time.Time
+	Success     bool
+	Signed      bool // true if the list carried a valid signature
+	Peers       int  // number of valid peers in the list
+	Error       string
+}
+
+// seedStatuses holds the status of every seed, read by the debug API from outside the runloop.
+type seedStatuses struct {
+	sync.RWMutex
+	statuses map[string]SeedStatus
+}
+
+func (s *seedStatuses) set(status SeedStatus) {
+	s.Lock()
+	defer s.Unlock()
+	if s.statuses == nil {
+		s.statuses = make(map[string]SeedStatus)
+	}
+	s.statuses[status.Source] = status
+}
+
+func (s *seedStatuses) get(sources []string) []SeedStatus {
+	s.RLock()
+	defer s.RUnlock()
+	result := make([]SeedStatus, 0, len(sources))
+	for _, source := range sources {
+		status, present := s.statuses[source]
+		if !present {
+			status = SeedStatus{Source: source, Type: seedType(source)}
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+// ParseSeedSources splits a seed configuration into its individual sources
+func ParseSeedSources(seeds string) []string {
+	return strings.FieldsFunc(seeds, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+func seedType(source string) string {
+	if strings.HasPrefix(source, dnsSeedScheme) {
+		return SeedTypeDNS
+	}
+	return SeedTypeHTTP
+}
+
+// fetchSeed returns the raw lines provided by a seed source, and whether those lines can carry
+// a signature at all (A/AAAA records can't)
+func fetchSeed(source string) (lines []string, signable bool, err error) {
+	if seedType(source) == SeedTypeDNS {
+		return fetchDNSSeed(strings.TrimPrefix(source, dnsSeedScheme))
+	}
+	lines, err = fetchHTTPSeed(source)
+	return lines, true, err
+}
+
+func fetchHTTPSeed(url string) ([]string, error) {
+	resp, err := http.Get(url)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// fetchDNSSeed prefers the TXT records of the host, which hold host:port lines and
+// can be signed. Without TXT records we fall back to the addresses of the host.
+func fetchDNSSeed(host string) ([]string, bool, error) {
+	records, err := lookupTXT(host)
+	if err == nil && len(records) > 0 {
+		return records, true, nil
+	}
+	addresses, err := lookupHost(host)
+	if err != nil {
+		return nil, false, err
+	}
+	lines := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lines = append(lines, net.JoinHostPort(address, NetworkListenPort))
+	}
+	return lines, false, nil
+}
+
+// splitSeedList separates the peer lines of a seed list from its signature.  Blank lines and
+// comments (starting with #) are ignored.
+func splitSeedList(lines []string) (peers []string, signature []byte, err error) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, seedSignaturePrefix):
+			signature, err = hex.DecodeString(strings.TrimSpace(strings.TrimPrefix(line, seedSignaturePrefix)))
+			if err != nil {
+				return nil, nil, fmt.Errorf("malformed seed list signature: %v", err)
+			}
+		default:
+			peers = append(peers, line)
+		}
+	}
+	return
+}
+
+// SeedListMessage is the message that is signed for a seed list: the peer lines sorted and
+// joined by newlines.  Sorting makes the signature independent of the order of DNS records.
+func SeedListMessage(peers []string) []byte {
+	sorted := make([]string, len(peers))
+	copy(sorted, peers)
+	sort.Strings(sorted)
+	return []byte(strings.Join(sorted, "\n"))
+}
+
+// verifySeedList checks the signature of a seed list against the seed public key
+func verifySeedList(peers []string, signature []byte, publicKey []byte) error {
+	if len(signature) == 0 {
+		return fmt.Errorf("seed list is not signed")
+	}
+	return primitives.VerifySignature(SeedListMessage(peers), publicKey, signature)
+}
+
+// DiscoverPeersFromSeed gets a set of peers from the seed sources, trying them in order
+// until one of them gives us peers.
+func (d *Discovery) DiscoverPeersFromSeed() {
+	d.logger.Info("Contacting seeds to get peers")
+	for _, source := range d.seedSources {
+		if d.discoverPeersFromSource(source) {
+			return
+		}
+	}
+	if len(d.seedSources) > 0 {
+		d.logger.Errorf("DiscoverPeersFromSeed none of the %d seeds provided any peers", len(d.seedSources))
+	}
+}
+
+// discoverPeersFromSource adds the peers of one seed to the known peers. Returns true
+// if the seed provided at least one peer.
+func (d *Discovery) discoverPeersFromSource(source string) bool {
+	status := SeedStatus{Source: source, Type: seedType(source), LastAttempt: time.Now()}
+	defer func() { d.seedStatuses.set(status) }()
+
+	lines, signable, err := fetchSeed(source)
+	if nil != err {
+		d.logger.Errorf("DiscoverPeersFromSeed getting peers from %s produced error %+v", source, err)
+		status.Error = err.Error()
+		return false
+	}
+	peers, signature, err := splitSeedList(lines)
+	if nil != err {
+		d.logger.Errorf("DiscoverPeersFromSeed bad seed list from %s: %+v", source, err)
+		status.Error = err.Error()
+		return false
+	}
+	if len(d.seedPublicKey) > 0 {
+		if !signable {
+			status.Error = "seed cannot be signed but a seed public key is configured"
+		} else if err := verifySeedList(peers, signature, d.seedPublicKey); err != nil {
+			status.Error = err.Error()
+		}
+		if status.Error != "" {
+			d.logger.Errorf("DiscoverPeersFromSeed rejecting seed list from %s: %s", source, status.Error)
+			return false
+		}
+		status.Signed = true
+	}
+
+	for _, line := range peers {
+		address, port, err := net.SplitHostPort(line)
+		if err == nil {
+			peerp := new(Peer).Init(address, port, 0, RegularPeer, 0)
+			peer := *peerp
+			peer.LastContact = time.Now()
+			d.updatePeer(d.updatePeerSource(peer, "DNS-Seed"))
+			status.Peers++
+		} else {
+			d.logger.Errorf("Bad peer in " + source + " [" + line + "]")
+		}
+	}
+	d.logger.Debugf("DiscoverPeersFromSeed got peers from %s: %+v", source, peers)
+	status.Success = status.Peers > 0
+	return status.Success
+}
+
+// GetSeedStatus returns the status of every seed source, in configuration order
+func (d *Discovery) GetSeedStatus() []SeedStatus {
+	return d.seedStatuses.get(d.seedSources)
+}
@@ -0,0 +1,155 @@
+package p2p
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+func signSeedList(key *primitives.PrivateKey, peers []string) string {
+	return seedSignaturePrefix + hex.EncodeToString(key.Sign(SeedListMessage(peers)).Bytes())
+}
+
+func TestParseSeedSources(t *testing.T) {
+	sources := ParseSeedSources("https://a.example.com/seed.txt, dns://seed.example.com\thttps://b.example.com/seed.txt")
+	expected := []string{"https://a.example.com/seed.txt", "dns://seed.example.com", "https://b.example.com/seed.txt"}
+	if len(sources) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, sources)
+	}
+	for i := range expected {
+		if sources[i] != expected[i] {
+			t.Errorf("Expected %s, got %s", expected[i], sources[i])
+		}
+	}
+	if len(ParseSeedSources("")) != 0 {
+		t.Error("Empty seed configuration should have no sources")
+	}
+}
+
+func TestSplitSeedList(t *testing.T) {
+	peers, signature, err := splitSeedList([]string{"# comment", "1.2.3.4:8108", "", "  [2001:db8::1]:8108 ", "signature:0102"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 || peers[0] != "1.2.3.4:8108" || peers[1] != "[2001:db8::1]:8108" {
+		t.Errorf("Unexpected peers %v", peers)
+	}
+	if len(signature) != 2 || signature[0] != 1 || signature[1] != 2 {
+		t.Errorf("Unexpected signature %x", signature)
+	}
+
+	if _, _, err := splitSeedList([]string{"signature:zz"}); err == nil {
+		t.Error("Malformed signature should be an error")
+	}
+}
+
+func TestVerifySeedList(t *testing.T) {
+	key := primitives.RandomPrivateKey()
+	peers := []string{"1.2.3.4:8108", "5.6.7.8:8108"}
+	_, signature, _ := splitSeedList([]string{signSeedList(key, peers)})
+
+	if err := verifySeedList(peers, signature, key.Public()); err != nil {
+		t.Errorf("Valid signature rejected: %v", err)
+	}
+	// the signature does not depend on the order of the peers
+	if err := verifySeedList([]string{peers[1], peers[0]}, signature, key.Public()); err != nil {
+		t.Errorf("Valid signature rejected for reordered peers: %v", err)
+	}
+	if err := verifySeedList(append(peers, "6.6.6.6:8108"), signature, key.Public()); err == nil {
+		t.Error("Signature accepted for a tampered list")
+	}
+	if err := verifySeedList(peers, nil, key.Public()); err == nil {
+		t.Error("Unsigned list accepted")
+	}
+	if err := verifySeedList(peers, signature, primitives.RandomPrivateKey().Public()); err == nil {
+		t.Error("Signature accepted for the wrong key")
+	}
+}
+
+func TestDNSSeed(t *testing.T) {
+	defer func(txt func(string) ([]string, error), host func(string) ([]string, error)) {
+		lookupTXT, lookupHost = txt, host
+	}(lookupTXT, lookupHost)
+
+	key := primitives.RandomPrivateKey()
+	peers := []string{"1.2.3.4:8108", "5.6.7.8:8108"}
+	lookupTXT = func(host string) ([]string, error) {
+		if host == "txt.example.com" {
+			return append(peers, signSeedList(key, peers)), nil
+		}
+		return nil, fmt.Errorf("no TXT records")
+	}
+	lookupHost = func(host string) ([]string, error) {
+		if host == "a.example.com" {
+			return []string{"9.9.9.9"}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	d := new(Discovery).Init("", "", nil)
+	if !d.discoverPeersFromSource("dns://a.example.com") {
+		t.Error("Unsigned A record seed rejected without a seed public key")
+	}
+	if _, present := d.knownPeers["9.9.9.9"]; !present {
+		t.Error("Peer from A record seed not learned")
+	}
+
+	d = new(Discovery).Init("", "", key.Public())
+	if d.discoverPeersFromSource("dns://a.example.com") {
+		t.Error("Unsigned A record seed accepted with a seed public key")
+	}
+	if !d.discoverPeersFromSource("dns://txt.example.com") {
+		t.Error("Signed TXT record seed rejected")
+	}
+	if len(d.knownPeers) != 2 {
+		t.Errorf("Expected 2 peers from the TXT seed, got %d", len(d.knownPeers))
+	}
+}
+
+func TestHTTPSeedFallback(t *testing.T) {
+	key := primitives.RandomPrivateKey()
+	peers := []string{"1.2.3.4:8108"}
+	signed := strings.Join(append(peers, signSeedList(key, peers)), "\n")
+	unsigned := "6.6.6.6:8108"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/signed.txt":
+			fmt.Fprint(w, signed)
+		case "/unsigned.txt":
+			fmt.Fprint(w, unsigned)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	seeds := strings.Join([]string{server.URL + "/missing.txt", server.URL + "/unsigned.txt", server.URL + "/signed.txt"}, ",")
+	d := new(Discovery).Init("", seeds, key.Public())
+
+	if len(d.knownPeers) != 1 {
+		t.Fatalf("Expected only the peer from the signed seed, got %+v", d.knownPeers)
+	}
+	if _, present := d.knownPeers["1.2.3.4"]; !present {
+		t.Error("Peer from the signed seed not learned")
+	}
+
+	statuses := d.GetSeedStatus()
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 seed statuses, got %d", len(statuses))
+	}
+	if statuses[0].Success || statuses[0].Error == "" {
+		t.Errorf("Missing seed should have failed: %+v", statuses[0])
+	}
+	if statuses[1].Success || statuses[1].Error == "" {
+		t.Errorf("Unsigned seed should have been rejected: %+v", statuses[1])
+	}
+	if !statuses[2].Success || !statuses[2].Signed || statuses[2].Peers != 1 {
+		t.Errorf("Signed seed should have succeeded: %+v", statuses[2])
+	}
+}
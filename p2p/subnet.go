@@ -0,0 +1,121 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"net"
+)
+
+// Subnet diversity rules protect the node against eclipse attacks, where an attacker
+// controlling a single network range fills up all of our connection slots.
+// IPv4 addresses are grouped by their /16 and /24 networks, IPv6 addresses by their /48.
+
+// SubnetGroup identifies the network range an address belongs to, eg "10.1.0.0/16"
+type SubnetGroup string
+
+var (
+	ipv4Mask16 = net.CIDRMask(16, 32)
+	ipv4Mask24 = net.CIDRMask(24, 32)
+	ipv6Mask48 = net.CIDRMask(48, 128)
+)
+
+func subnetOf(ip net.IP, mask net.IPMask) SubnetGroup {
+	network := net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return SubnetGroup(network.String())
+}
+
+// wideSubnet returns the widest subnet used for diversity rules: the /16 for IPv4 and the /48 for IPv6.
+// Returns "" if the address is not an IP address.
+func wideSubnet(address string) SubnetGroup {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return subnetOf(ip.To4(), ipv4Mask16)
+	default:
+		return subnetOf(ip, ipv6Mask48)
+	}
+}
+
+// narrowSubnet returns the /24 for IPv4 addresses. IPv6 addresses only use a single
+// grouping, so this returns the /48 for them.  Returns "" if the address is not an IP address.
+func narrowSubnet(address string) SubnetGroup {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return subnetOf(ip.To4(), ipv4Mask24)
+	default:
+		return subnetOf(ip, ipv6Mask48)
+	}
+}
+
+// isLocalAddress returns true for loopback and private addresses, which are exempt
+// from subnet diversity rules (eg: a local testnet where all nodes are on 127.0.0.1)
+func isLocalAddress(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, private := range privateNetworks {
+		if private.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var privateNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"} {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// SubnetCounter counts addresses per subnet and enforces the limits on them.
+type SubnetCounter struct {
+	wide   map[SubnetGroup]int
+	narrow map[SubnetGroup]int
+}
+
+func (s *SubnetCounter) Init() *SubnetCounter {
+	s.wide = make(map[SubnetGroup]int)
+	s.narrow = make(map[SubnetGroup]int)
+	return s
+}
+
+// Add counts the address
+func (s *SubnetCounter) Add(address string) {
+	if wide := wideSubnet(address); wide != "" {
+		s.wide[wide]++
+	}
+	if narrow := narrowSubnet(address); narrow != "" {
+		s.narrow[narrow]++
+	}
+}
+
+// Allows checks if one more address can be added without exceeding the given limits.
+// Local and unparseable addresses are always allowed.  For IPv6 addresses, where the
+// wide and narrow subnets are the same, the narrow limit applies.
+func (s *SubnetCounter) Allows(address string, maxWide int, maxNarrow int) bool {
+	if isLocalAddress(address) {
+		return true
+	}
+	wide, narrow := wideSubnet(address), narrowSubnet(address)
+	if wide == "" {
+		return true
+	}
+	if wide == narrow {
+		return s.narrow[narrow] < maxNarrow
+	}
+	return s.wide[wide] < maxWide && s.narrow[narrow] < maxNarrow
+}
@@ -0,0 +1,99 @@
+package p2p
+
+import (
+	"testing"
+)
+
+func TestSubnetGroups(t *testing.T) {
+	tests := []struct {
+		address string
+		wide    SubnetGroup
+		narrow  SubnetGroup
+	}{
+		{"1.2.3.4", "1.2.0.0/16", "1.2.3.0/24"},
+		{"1.2.200.4", "1.2.0.0/16", "1.2.200.0/24"},
+		{"2001:db8:1234:5678::1", "2001:db8:1234::/48", "2001:db8:1234::/48"},
+		{"::ffff:1.2.3.4", "1.2.0.0/16", "1.2.3.0/24"},
+		{"not an ip", "", ""},
+	}
+	for _, test := range tests {
+		if wide := wideSubnet(test.address); wide != test.wide {
+			t.Errorf("wideSubnet(%s) = %s, expected %s", test.address, wide, test.wide)
+		}
+		if narrow := narrowSubnet(test.address); narrow != test.narrow {
+			t.Errorf("narrowSubnet(%s) = %s, expected %s", test.address, narrow, test.narrow)
+		}
+	}
+}
+
+func TestSubnetCounterLimits(t *testing.T) {
+	counter := new(SubnetCounter).Init()
+
+	counter.Add("1.2.3.4")
+	if !counter.Allows("1.2.4.4", 2, 1) {
+		t.Error("A second address in another /24 of the same /16 should be allowed")
+	}
+	if counter.Allows("1.2.3.5", 2, 1) {
+		t.Error("A second address in the same /24 should not be allowed")
+	}
+
+	counter.Add("1.2.4.4")
+	if counter.Allows("1.2.5.4", 2, 1) {
+		t.Error("A third address in the same /16 should not be allowed")
+	}
+	if !counter.Allows("5.6.7.8", 2, 1) {
+		t.Error("An address in an unrelated subnet should be allowed")
+	}
+
+	counter.Add("2001:db8:1234:5678::1")
+	if counter.Allows("2001:db8:1234:ffff::1", 2, 1) {
+		t.Error("A second address in the same IPv6 /48 should not be allowed")
+	}
+	if !counter.Allows("2001:db8:4321::1", 2, 1) {
+		t.Error("An address in another IPv6 /48 should be allowed")
+	}
+}
+
+func TestSubnetCounterLocalAddresses(t *testing.T) {
+	counter := new(SubnetCounter).Init()
+	for i := 0; i < 10; i++ {
+		counter.Add("127.0.0.1")
+		counter.Add("192.168.1.10")
+	}
+	if !counter.Allows("127.0.0.1", 2, 1) {
+		t.Error("Loopback addresses should be exempt from subnet limits")
+	}
+	if !counter.Allows("192.168.1.11", 2, 1) {
+		t.Error("Private addresses should be exempt from subnet limits")
+	}
+}
+
+func TestFilterForSubnetDiversity(t *testing.T) {
+	d := new(Discovery).Init("", "", nil)
+	peers := []Peer{}
+	for _, address := range []string{"1.2.3.1", "1.2.3.2", "1.2.3.3", "1.2.4.1", "1.2.5.1", "5.6.7.8"} {
+		peers = append(peers, *newPeer(address, "8108", RegularPeer))
+	}
+	special := newPeer("1.2.3.9", "8108", SpecialPeerConfig)
+	peers = append(peers, *special)
+
+	filtered := d.filterForSubnetDiversity(peers)
+	counts := map[SubnetGroup]int{}
+	foundSpecial := false
+	for _, peer := range filtered {
+		if peer.IsSpecial() {
+			foundSpecial = true
+			continue
+		}
+		counts[wideSubnet(peer.Address)]++
+	}
+	if !foundSpecial {
+		t.Error("Special peers should never be filtered out")
+	}
+	if counts["1.2.0.0/16"] != MaxOutgoingPerWideSubnet {
+		t.Errorf("Expected %d peers from 1.2.0.0/16, got %d", MaxOutgoingPerWideSubnet, counts["1.2.0.0/16"])
+	}
+	if counts["5.6.0.0/16"] != 1 {
+		t.Errorf("Expected 1 peer from 5.6.0.0/16, got %d", counts["5.6.0.0/16"])
+	}
+}
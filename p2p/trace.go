@@ -0,0 +1,220 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Message tracing follows application messages as they are gossiped through the network.
+// The node that first sends a message attaches a ParcelTrace to the parcel header. Every node
+// that relays the message keeps the origin information and increments the hop count, so any
+// node can tell who originated a message, when, and how many relays it took to reach it.
+// Nodes running without tracing (or older versions) simply ignore and drop the trace.
+
+// ParcelTrace is the trace context carried in the parcel header
+type ParcelTrace struct {
+	OriginNodeID uint64 // NodeID of the node that first sent the message
+	OriginName   string // Name of the node that first sent the message
+	OriginTime   int64  // When the origin first sent the message, in unix nanoseconds
+	Hops         uint16 // How many times the message was relayed before it was sent to us
+}
+
+// PropagationRecord is what a node knows about the propagation of a single message
+type PropagationRecord struct {
+	AppHash      string
+	AppType      string
+	OriginNodeID uint64
+	OriginName   string
+	OriginTime   time.Time
+	FirstSeen    time.Time     // When we first received (or sent, if we are the origin) the message
+	FirstPeer    string        // The peer we first received the message from, empty if we are the origin
+	FirstHops    uint16        // Hop count of the first copy we received
+	Latency      time.Duration // FirstSeen - OriginTime, subject to the clock difference between the nodes
+	Receipts     int           // How many copies of the message we received
+	LastSeen     time.Time     // When we last received a copy
+}
+
+// TraceLatencyStats summarizes the gossip latency of the traced messages
+type TraceLatencyStats struct {
+	AppType string
+	Count   int
+	Min     time.Duration
+	Max     time.Duration
+	Mean    time.Duration
+	P50     time.Duration
+	P90     time.Duration
+	P99     time.Duration
+	Hops    map[uint16]int // number of messages by the hop count they first arrived with
+}
+
+// MessageTracer keeps the propagation records of the most recent messages.  It is written from
+// the controller runloop and read by the debug API, so all access is guarded by a mutex.
+type MessageTracer struct {
+	sync.RWMutex
+	nodeName   string
+	records    map[string]*PropagationRecord // records indexed by application hash
+	order      []string                      // application hashes in the order we first saw them, for eviction
+	maxRecords int
+}
+
+func (t *MessageTracer) Init(nodeName string, maxRecords int) *MessageTracer {
+	t.nodeName = nodeName
+	t.records = make(map[string]*PropagationRecord)
+	t.maxRecords = maxRecords
+	return t
+}
+
+// isTraceable returns true for the parcels carrying application messages
+func isTraceable(parcel *Parcel) bool {
+	return (parcel.Header.Type == TypeMessage || parcel.Header.Type == TypeMessagePart) &&
+		parcel.Header.AppHash != "" && parcel.Header.AppHash != "NetworkMessage"
+}
+
+// Received records a message received from a peer. Multipart messages are only counted
+// once, by their first part.
+func (t *MessageTracer) Received(parcel *Parcel, peer string) {
+	if !isTraceable(parcel) || parcel.Header.PartNo != 0 {
+		return
+	}
+	now := time.Now()
+	t.Lock()
+	defer t.Unlock()
+
+	record, present := t.records[parcel.Header.AppHash]
+	if present {
+		record.Receipts++
+		record.LastSeen = now
+		return
+	}
+	record = &PropagationRecord{
+		AppHash:   parcel.Header.AppHash,
+		AppType:   parcel.Header.AppType,
+		FirstSeen: now,
+		FirstPeer: peer,
+		Receipts:  1,
+		LastSeen:  now,
+	}
+	if trace := parcel.Header.Trace; trace != nil {
+		record.OriginNodeID = trace.OriginNodeID
+		record.OriginName = trace.OriginName
+		record.OriginTime = time.Unix(0, trace.OriginTime)
+		record.FirstHops = trace.Hops
+		record.Latency = now.Sub(record.OriginTime)
+	}
+	t.add(record)
+}
+
+// Outgoing attaches the trace context to a message we are sending.  Messages we received
+// before are relays and keep their origin, other messages originate from us.
+func (t *MessageTracer) Outgoing(parcel *Parcel) {
+	if !isTraceable(parcel) {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	record, present := t.records[parcel.Header.AppHash]
+	if !present {
+		now := time.Now()
+		record = &PropagationRecord{
+			AppHash:      parcel.Header.AppHash,
+			AppType:      parcel.Header.AppType,
+			OriginNodeID: NodeID,
+			OriginName:   t.nodeName,
+			OriginTime:   now,
+			FirstSeen:    now,
+			LastSeen:     now,
+		}
+		t.add(record)
+	}
+	if record.OriginTime.IsZero() {
+		// We received the message from a node that does not trace, so we do not know the origin
+		return
+	}
+	hops := record.FirstHops
+	if record.FirstPeer != "" {
+		hops++ // we are relaying the message
+	}
+	parcel.Header.Trace = &ParcelTrace{
+		OriginNodeID: record.OriginNodeID,
+		OriginName:   record.OriginName,
+		OriginTime:   record.OriginTime.UnixNano(),
+		Hops:         hops,
+	}
+}
+
+// add stores a new record, evicting the oldest one if we are full.  Must hold the lock.
+func (t *MessageTracer) add(record *PropagationRecord) {
+	t.records[record.AppHash] = record
+	t.order = append(t.order, record.AppHash)
+	for len(t.order) > t.maxRecords {
+		delete(t.records, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+// GetRecords returns the propagation records, newest first. Empty appHash and appType match
+// any message, a limit of 0 or less returns all matching records.
+func (t *MessageTracer) GetRecords(appHash string, appType string, limit int) []PropagationRecord {
+	t.RLock()
+	defer t.RUnlock()
+
+	records := []PropagationRecord{}
+	for i := len(t.order) - 1; i >= 0; i-- {
+		record := t.records[t.order[i]]
+		if (appHash != "" && record.AppHash != appHash) || (appType != "" && record.AppType != appType) {
+			continue
+		}
+		records = append(records, *record)
+		if 0 < limit && limit <= len(records) {
+			break
+		}
+	}
+	return records
+}
+
+// GetLatencyStats computes the latency distribution of the messages we received from other
+// traced nodes, optionally only for one application message type.
+func (t *MessageTracer) GetLatencyStats(appType string) TraceLatencyStats {
+	stats := TraceLatencyStats{AppType: appType, Hops: make(map[uint16]int)}
+	var latencies []time.Duration
+	var total time.Duration
+
+	t.RLock()
+	for _, record := range t.records {
+		if record.FirstPeer == "" || record.OriginTime.IsZero() || (appType != "" && record.AppType != appType) {
+			continue
+		}
+		latencies = append(latencies, record.Latency)
+		total += record.Latency
+		stats.Hops[record.FirstHops]++
+	}
+	t.RUnlock()
+
+	stats.Count = len(latencies)
+	if stats.Count == 0 {
+		return stats
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.Min = latencies[0]
+	stats.Max = latencies[len(latencies)-1]
+	stats.Mean = total / time.Duration(stats.Count)
+	stats.P50 = percentile(latencies, 50)
+	stats.P90 = percentile(latencies, 90)
+	stats.P99 = percentile(latencies, 99)
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,115 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func newTracedParcel(appHash string, appType string) *Parcel {
+	parcel := NewParcel(CurrentNetwork, []byte("payload"))
+	parcel.Header.AppHash = appHash
+	parcel.Header.AppType = appType
+	return parcel
+}
+
+func TestTracerOriginatesMessages(t *testing.T) {
+	tracer := new(MessageTracer).Init("origin", 10)
+
+	parcel := newTracedParcel("aa", "EOM")
+	tracer.Outgoing(parcel)
+	if parcel.Header.Trace == nil {
+		t.Fatal("Outgoing message has no trace")
+	}
+	if parcel.Header.Trace.OriginNodeID != NodeID || parcel.Header.Trace.OriginName != "origin" || parcel.Header.Trace.Hops != 0 {
+		t.Errorf("Unexpected trace for an originated message: %+v", parcel.Header.Trace)
+	}
+
+	network := newTracedParcel("NetworkMessage", "Network")
+	tracer.Outgoing(network)
+	if network.Header.Trace != nil {
+		t.Error("Network messages should not be traced")
+	}
+}
+
+func TestTracerRelaysMessages(t *testing.T) {
+	tracer := new(MessageTracer).Init("relay", 10)
+
+	origin := time.Now().Add(-time.Second)
+	received := newTracedParcel("bb", "Ack")
+	received.Header.Trace = &ParcelTrace{OriginNodeID: 42, OriginName: "origin", OriginTime: origin.UnixNano(), Hops: 2}
+	tracer.Received(received, "1.2.3.4:8108")
+	tracer.Received(received, "5.6.7.8:8108")
+
+	relayed := newTracedParcel("bb", "Ack")
+	tracer.Outgoing(relayed)
+	if relayed.Header.Trace == nil || relayed.Header.Trace.OriginNodeID != 42 || relayed.Header.Trace.Hops != 3 {
+		t.Errorf("Relayed message should keep its origin and add a hop: %+v", relayed.Header.Trace)
+	}
+
+	records := tracer.GetRecords("bb", "", 0)
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(records))
+	}
+	record := records[0]
+	if record.FirstPeer != "1.2.3.4:8108" || record.Receipts != 2 || record.FirstHops != 2 {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.Latency < time.Second {
+		t.Errorf("Latency %s should be at least the time since the origin sent the message", record.Latency)
+	}
+}
+
+func TestTracerUntracedSender(t *testing.T) {
+	tracer := new(MessageTracer).Init("relay", 10)
+
+	tracer.Received(newTracedParcel("cc", "Ack"), "1.2.3.4:8108")
+	relayed := newTracedParcel("cc", "Ack")
+	tracer.Outgoing(relayed)
+	if relayed.Header.Trace != nil {
+		t.Error("A message with an unknown origin should not get a trace")
+	}
+	if stats := tracer.GetLatencyStats(""); stats.Count != 0 {
+		t.Errorf("Messages with an unknown origin should not count for latency: %+v", stats)
+	}
+}
+
+func TestTracerEvictsOldest(t *testing.T) {
+	tracer := new(MessageTracer).Init("node", 2)
+	for _, hash := range []string{"1", "2", "3"} {
+		tracer.Outgoing(newTracedParcel(hash, "EOM"))
+	}
+	records := tracer.GetRecords("", "", 0)
+	if len(records) != 2 || records[0].AppHash != "3" || records[1].AppHash != "2" {
+		t.Errorf("Expected the newest 2 records, got %+v", records)
+	}
+	if len(tracer.GetRecords("", "", 1)) != 1 {
+		t.Error("Limit not applied")
+	}
+}
+
+func TestTracerLatencyStats(t *testing.T) {
+	tracer := new(MessageTracer).Init("node", 10)
+	now := time.Now()
+	for i, hash := range []string{"1", "2", "3", "4"} {
+		parcel := newTracedParcel(hash, "EOM")
+		parcel.Header.Trace = &ParcelTrace{OriginNodeID: 1, OriginTime: now.Add(-time.Duration(i+1) * time.Second).UnixNano(), Hops: uint16(i % 2)}
+		tracer.Received(parcel, "1.2.3.4:8108")
+	}
+	other := newTracedParcel("5", "Ack")
+	other.Header.Trace = &ParcelTrace{OriginNodeID: 1, OriginTime: now.UnixNano()}
+	tracer.Received(other, "1.2.3.4:8108")
+
+	stats := tracer.GetLatencyStats("EOM")
+	if stats.Count != 4 {
+		t.Fatalf("Expected 4 EOM latencies, got %d", stats.Count)
+	}
+	if stats.Min > stats.P50 || stats.P50 > stats.P90 || stats.P90 > stats.Max || stats.Min < time.Second {
+		t.Errorf("Inconsistent latency distribution %+v", stats)
+	}
+	if stats.Hops[0] != 2 || stats.Hops[1] != 2 {
+		t.Errorf("Unexpected hop distribution %v", stats.Hops)
+	}
+	if all := tracer.GetLatencyStats(""); all.Count != 5 {
+		t.Errorf("Expected 5 latencies for all types, got %d", all.Count)
+	}
+}
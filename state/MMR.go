@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/primitives"
 )
 
 // This identifies a specific process list slot
@@ -147,7 +148,7 @@ func (s *State) makeMMRs(asks <-chan askRef, adds <-chan plRef, dbheights <-chan
 
 	// Postpone asking for the first 5 seconds so simulations get a chance to get started. Doesn't break things but
 	// there is a flurry of unhelpful MMR activity on start up of simulations with followers
-	time.Sleep(5 * time.Second)
+	primitives.Sleep(5 * time.Second)
 
 	// tick ever second to check the  pending MMRs
 	go func() {
@@ -158,7 +159,7 @@ func (s *State) makeMMRs(asks <-chan askRef, adds <-chan plRef, dbheights <-chan
 
 			ticker <- s.GetTimestamp().GetTimeMilli()
 			askDelay := int64(s.DirectoryBlockInSeconds*1000) / 50
-			time.Sleep(time.Duration(askDelay) * time.Millisecond)
+			primitives.Sleep(time.Duration(askDelay) * time.Millisecond)
 		}
 	}()
 
@@ -0,0 +1,299 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+)
+
+// ChainHeadChange is an entry chain whose head did not match its last entry block
+type ChainHeadChange struct {
+	ChainID  string
+	Found    string // head in the database, empty if there was none
+	Expected string // KeyMR of the last entry block of the chain
+	Height   uint32 // height of the last entry block of the chain
+	Fixed    bool   // false for a dry run, or if saving the head failed
+}
+
+// ChainHeadRepair verifies, and unless it is a dry run repairs, the heads of the entry chains
+// while the node runs.  It replaces running the node with -checkheads -fixheads or the
+// CorrectChainHeads tool against a stopped node.
+//
+// A full scan walks the directory blocks down from the highest saved block, the first entry
+// block found for a chain being its head.  With ChainIDs set, only those chains are checked,
+// from the entry blocks indexed under each chain.  Heads the node saved after the repair started
+// are left alone.
+type ChainHeadRepair struct {
+	ID       int
+	ChainIDs []string // chains to check, empty for a full scan
+	DryRun   bool
+	Top      uint32 // highest saved block when the repair started
+	Started  time.Time
+	Finished time.Time `json:",omitempty"`
+	Done     bool
+	Error    string `json:",omitempty"`
+
+	Height        uint32            // height the full scan is on
+	ChainsChecked int               // chains whose head was compared
+	Missing       []string          // chains of ChainIDs without any entry block
+	Changes       []ChainHeadChange // every head found wrong, in the order found
+	From          int               // index of the first change of Changes, see Report
+
+	state *State
+	db    *databaseOverlay.Overlay
+	mutex sync.Mutex
+}
+
+var chainHeadRepairMutex sync.Mutex
+
+// RepairChainHeads starts verifying the chain heads in the background and returns the new
+// *ChainHeadRepair.  Only one repair can run at a time.
+func (s *State) RepairChainHeads(chainIDs []string, dryRun bool) (interface{}, error) {
+	db, ok := s.DB.(*databaseOverlay.Overlay)
+	if !ok {
+		return nil, fmt.Errorf("the database does not support repairing chain heads")
+	}
+	for _, id := range chainIDs {
+		if _, err := primitives.HexToHash(id); err != nil {
+			return nil, fmt.Errorf("invalid chain ID %s: %v", id, err)
+		}
+	}
+	head, err := db.FetchDBlockHead()
+	if err != nil {
+		return nil, err
+	}
+	if head == nil {
+		return nil, fmt.Errorf("the database holds no blocks")
+	}
+
+	chainHeadRepairMutex.Lock()
+	defer chainHeadRepairMutex.Unlock()
+	id := 1
+	if s.ChainHeadRepair != nil {
+		if !s.ChainHeadRepair.isDone() {
+			return nil, fmt.Errorf("chain head repair %d is still running", s.ChainHeadRepair.ID)
+		}
+		id = s.ChainHeadRepair.ID + 1
+	}
+
+	r := new(ChainHeadRepair)
+	r.ID = id
+	r.ChainIDs = chainIDs
+	r.DryRun = dryRun
+	r.Top = head.GetDatabaseHeight()
+	r.Height = r.Top
+	r.Started = time.Now()
+	r.Changes = []ChainHeadChange{}
+	r.state = s
+	r.db = db
+	s.ChainHeadRepair = r
+
+	go r.run()
+	return r.Report(0), nil
+}
+
+// GetChainHeadRepair returns the *ChainHeadRepair of the last chain head repair with the changes
+// found from index from on, or nil if no repair was started.  Polling with from set to the
+// number of changes already read streams the changes as they are found.
+func (s *State) GetChainHeadRepair(from int) interface{} {
+	chainHeadRepairMutex.Lock()
+	r := s.ChainHeadRepair
+	chainHeadRepairMutex.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Report(from)
+}
+
+func (r *ChainHeadRepair) isDone() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.Done
+}
+
+// Report returns a copy of the repair, holding the changes from index from on
+func (r *ChainHeadRepair) Report(from int) *ChainHeadRepair {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if from < 0 {
+		from = 0
+	}
+	if from > len(r.Changes) {
+		from = len(r.Changes)
+	}
+
+	report := new(ChainHeadRepair)
+	report.ID = r.ID
+	report.ChainIDs = r.ChainIDs
+	report.DryRun = r.DryRun
+	report.Top = r.Top
+	report.Started = r.Started
+	report.Finished = r.Finished
+	report.Done = r.Done
+	report.Error = r.Error
+	report.Height = r.Height
+	report.ChainsChecked = r.ChainsChecked
+	report.Missing = append([]string{}, r.Missing...)
+	report.Changes = append([]ChainHeadChange{}, r.Changes[from:]...)
+	report.From = from
+	return report
+}
+
+func (r *ChainHeadRepair) run() {
+	var err error
+	if len(r.ChainIDs) == 0 {
+		err = r.scan()
+	} else {
+		err = r.checkChains()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Done = true
+	r.Finished = time.Now()
+	r.state.LogPrintf("chainheads", "Chain head repair %d done, %d chains checked, %d heads wrong, error: %v",
+		r.ID, r.ChainsChecked, len(r.Changes), err)
+}
+
+// scan walks the directory blocks down from the top, checking the head of every chain the
+// first time one of its entry blocks is found
+func (r *ChainHeadRepair) scan() error {
+	seen := map[[32]byte]bool{}
+	for h := int64(r.Top); h >= 0; h-- {
+		dblock, err := r.db.FetchDBlockByHeight(uint32(h))
+		if err != nil {
+			return err
+		}
+		if dblock == nil {
+			return fmt.Errorf("directory block %d is missing", h)
+		}
+
+		for _, e := range dblock.GetEBlockDBEntries() {
+			if seen[e.GetChainID().Fixed()] {
+				continue
+			}
+			seen[e.GetChainID().Fixed()] = true
+			err = r.check(e.GetChainID(), e.GetKeyMR(), uint32(h))
+			if err != nil {
+				return err
+			}
+		}
+
+		r.mutex.Lock()
+		r.Height = uint32(h)
+		r.mutex.Unlock()
+	}
+	return nil
+}
+
+// checkChains checks the heads of the chains of ChainIDs, the head being the entry block of the
+// chain with the highest sequence that was saved by the top
+func (r *ChainHeadRepair) checkChains() error {
+	for _, id := range r.ChainIDs {
+		chainID, err := primitives.HexToHash(id)
+		if err != nil {
+			return err
+		}
+		eblocks, err := r.db.FetchAllEBlocksByChain(chainID)
+		if err != nil {
+			return err
+		}
+
+		var last interfaces.IEntryBlock
+		for _, eblock := range eblocks {
+			if eblock == nil || eblock.GetDatabaseHeight() > r.Top {
+				continue
+			}
+			if last == nil || eblock.GetHeader().GetEBSequence() > last.GetHeader().GetEBSequence() {
+				last = eblock
+			}
+		}
+		if last == nil {
+			r.mutex.Lock()
+			r.Missing = append(r.Missing, id)
+			r.mutex.Unlock()
+			continue
+		}
+
+		keyMR, err := last.KeyMR()
+		if err != nil {
+			return err
+		}
+		err = r.check(chainID, keyMR, last.GetDatabaseHeight())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check compares the head of a chain with the KeyMR of its last entry block, and sets the head
+// unless this is a dry run
+func (r *ChainHeadRepair) check(chainID interfaces.IHash, keyMR interfaces.IHash, height uint32) error {
+	head, err := r.db.FetchHeadIndexByChainID(chainID)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	r.ChainsChecked++
+	r.mutex.Unlock()
+	if head != nil && head.IsSameAs(keyMR) {
+		return nil
+	}
+
+	change := ChainHeadChange{ChainID: chainID.String(), Expected: keyMR.String(), Height: height}
+	if head != nil {
+		change.Found = head.String()
+		// The node moves the head forward when it saves a block, which is not a corruption
+		eblock, err := r.db.FetchEBlock(head)
+		if err == nil && eblock != nil && eblock.GetChainID().IsSameAs(chainID) && eblock.GetDatabaseHeight() > r.Top {
+			return nil
+		}
+	}
+
+	if !r.DryRun {
+		moved, err := r.setHead(chainID, head, keyMR)
+		if moved {
+			return nil
+		}
+		if err == nil {
+			change.Fixed = true
+		} else {
+			r.state.LogPrintf("chainheads", "Setting the head of chain %s failed: %v", change.ChainID, err)
+		}
+	}
+	r.state.LogPrintf("chainheads", "Chain %s head %s, expected %s at height %d, fixed %v",
+		change.ChainID, change.Found, change.Expected, change.Height, change.Fixed)
+
+	r.mutex.Lock()
+	r.Changes = append(r.Changes, change)
+	r.mutex.Unlock()
+	return nil
+}
+
+// setHead replaces the head of a chain, unless the node moved it since it was read.  The node saves
+// blocks under the BatchSemaphore of the database, so holding it keeps the head from moving between
+// reading it again and writing it.  Returns true if the head moved.
+func (r *ChainHeadRepair) setHead(chainID, found, keyMR interfaces.IHash) (bool, error) {
+	r.db.BatchSemaphore.Lock()
+	defer r.db.BatchSemaphore.Unlock()
+	head, err := r.db.FetchHeadIndexByChainID(chainID)
+	if err != nil {
+		return false, err
+	}
+	if (head == nil) != (found == nil) || (head != nil && !head.IsSameAs(found)) {
+		return true, nil
+	}
+	return false, r.db.SetChainHeads([]interfaces.IHash{keyMR}, []interfaces.IHash{chainID})
+}
@@ -0,0 +1,95 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func waitForChainHeadRepair(t *testing.T, s *State) *ChainHeadRepair {
+	for i := 0; i < 500; i++ {
+		r := s.GetChainHeadRepair(0).(*ChainHeadRepair)
+		if r.Done {
+			if r.Error != "" {
+				t.Fatalf("%v", r.Error)
+			}
+			return r
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("The chain head repair did not finish")
+	return nil
+}
+
+func TestRepairChainHeads(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	db := s.DB.(*databaseOverlay.Overlay)
+
+	chainIDs, err := db.FetchAllEBlockChainIDs()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(chainIDs) == 0 {
+		t.Fatalf("No entry chains in the test database")
+	}
+	chainID := chainIDs[0]
+	head, err := db.FetchHeadIndexByChainID(chainID)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Nothing to change in a clean database
+	_, err = s.RepairChainHeads(nil, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	r := waitForChainHeadRepair(t, s)
+	if len(r.Changes) != 0 {
+		t.Fatalf("Expected no changes, found %v", r.Changes)
+	}
+	if r.ChainsChecked != len(chainIDs) {
+		t.Errorf("Checked %d chains, expected %d", r.ChainsChecked, len(chainIDs))
+	}
+
+	err = db.SetChainHeads([]interfaces.IHash{primitives.RandomHash()}, []interfaces.IHash{chainID})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// A dry run reports the wrong head without fixing it
+	_, err = s.RepairChainHeads([]string{chainID.String()}, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	r = waitForChainHeadRepair(t, s)
+	if len(r.Changes) != 1 || r.Changes[0].Fixed || r.Changes[0].Expected != head.String() {
+		t.Fatalf("Wrong changes %v", r.Changes)
+	}
+	if found, _ := db.FetchHeadIndexByChainID(chainID); found.IsSameAs(head) {
+		t.Errorf("A dry run changed the head")
+	}
+
+	_, err = s.RepairChainHeads(nil, false)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	r = waitForChainHeadRepair(t, s)
+	if len(r.Changes) != 1 || !r.Changes[0].Fixed || r.Changes[0].ChainID != chainID.String() {
+		t.Fatalf("Wrong changes %v", r.Changes)
+	}
+	if found, _ := db.FetchHeadIndexByChainID(chainID); !found.IsSameAs(head) {
+		t.Errorf("The head was not fixed")
+	}
+	if r := s.GetChainHeadRepair(1).(*ChainHeadRepair); len(r.Changes) != 0 || r.From != 1 {
+		t.Errorf("Reading from the end returned %v changes from %d", len(r.Changes), r.From)
+	}
+}
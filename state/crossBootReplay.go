@@ -9,6 +9,7 @@ import (
 
 	"github.com/FactomProject/factomd/common/constants"
 	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/mapdb"
 )
@@ -74,7 +75,7 @@ func NewCrossReplayFilter(path string) *CrossReplayFilter {
 	c.oldSaltCache = make(map[[8]byte]bool)
 	// Load the old salts into the map
 	c.loadOldSalts()
-	c.bootTime = time.Now()
+	c.bootTime = primitives.Now()
 
 	var m MarshalableUint32
 	c.db.Get(heightBucket, lowest, &m)
@@ -134,7 +135,7 @@ func (c *CrossReplayFilter) ExistSalt(salt [8]byte) (bool, error) {
 func (c *CrossReplayFilter) Run() {
 	for {
 		time.Sleep(time.Second * 5)
-		if time.Now().Before(c.bootTime.Add(constants.CROSSBOOT_SALT_REPLAY_DURATION * -1)) {
+		if primitives.Now().Before(c.bootTime.Add(constants.CROSSBOOT_SALT_REPLAY_DURATION * -1)) {
 			// We no longer need to add salts
 			c.stopAddingSalts = true
 			return
@@ -750,6 +750,25 @@ func containsServer(haystack []interfaces.IServer, needle interfaces.IServer) bo
 	return false
 }
 
+// grantChainReady loads the grant schedules of the governance chain before a payout height, and
+// returns false if entries that could hold grants paid at the height have not synced yet
+func (list *DBStateList) grantChainReady(currentDBHeight uint32) bool {
+	if globals.Params.GrantChain == "" || currentDBHeight <= constants.COINBASE_ACTIVATION || currentDBHeight%constants.COINBASE_PAYOUT_FREQUENCY != 1 {
+		return true
+	}
+	next, err := list.State.LoadGrantChain(currentDBHeight - 1)
+	if err != nil {
+		list.State.LogPrintf("grants", "Reading the grant chain for height %d failed: %v", currentDBHeight, err)
+		return false
+	}
+	// A schedule recorded at a height only pays COINBASE_PAYOUT_FREQUENCY blocks above it
+	if next+constants.COINBASE_PAYOUT_FREQUENCY <= currentDBHeight {
+		list.State.LogPrintf("grants", "Waiting for the grant chain entries of height %d to pay height %d", next, currentDBHeight)
+		return false
+	}
+	return true
+}
+
 // p is previous, d is current
 func (list *DBStateList) FixupLinks(p *DBState, d *DBState) (progress bool) {
 	// If this block is new, then make sure all hashes are fully computed.
@@ -776,6 +795,12 @@ func (list *DBStateList) FixupLinks(p *DBState, d *DBState) (progress bool) {
 	d.EntryCreditBlock.GetHeader().SetPrevFullHash(hash)
 	d.EntryCreditBlock.GetHeader().SetDBHeight(currentDBHeight)
 
+	// The grant payouts need the schedules of the governance chain, so wait for the entries of
+	// the chain to sync before changing the admin block
+	if !list.grantChainReady(currentDBHeight) {
+		return false
+	}
+
 	// Admin Block Fixup
 	//previousPL := list.State.ProcessLists.Get(cur)
 	currentPL := list.State.ProcessLists.Get(currentDBHeight)
@@ -860,7 +885,8 @@ func (list *DBStateList) FixupLinks(p *DBState, d *DBState) (progress bool) {
 
 	// every 25 blocks +1 we add grant payouts
 	if currentDBHeight > constants.COINBASE_ACTIVATION && currentDBHeight%constants.COINBASE_PAYOUT_FREQUENCY == 1 {
-		// Add the grants to the list
+		// Add the grants to the list, with the schedules recorded in the governance chain so far,
+		// which grantChainReady loaded
 		grantPayouts := GetGrantPayoutsFor(currentDBHeight)
 		if len(grantPayouts) > 0 {
 			err := d.AdminBlock.AddCoinbaseDescriptor(grantPayouts)
@@ -1424,8 +1450,16 @@ func (list *DBStateList) SaveDBStateToDB(d *DBState) (progress bool) {
 		list.State.DB.Trim()
 	}
 
-	// Save
+	// Save.  Everything at this height goes into one multi batch that is written atomically, so a
+	// crash part way through leaves the database at the previous height rather than with a partial
+	// set of blocks.
 	list.State.DB.StartMultiBatch()
+	executed := false
+	defer func() {
+		if !executed {
+			list.State.DB.AbortMultiBatch()
+		}
+	}()
 
 	if err := list.State.DB.ProcessABlockMultiBatch(d.AdminBlock); err != nil {
 		panic(err.Error())
@@ -1538,6 +1572,7 @@ func (list *DBStateList) SaveDBStateToDB(d *DBState) (progress bool) {
 		panic(err.Error())
 	}
 
+	executed = true
 	if err := list.State.DB.ExecuteMultiBatch(); err != nil {
 		panic(err.Error())
 	}
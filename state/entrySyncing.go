@@ -12,6 +12,7 @@ import (
 	"github.com/FactomProject/factomd/common/constants"
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/database/databaseOverlay"
 )
 
@@ -50,7 +51,7 @@ func (s *State) MakeMissingEntryRequests() {
 	MissingEntryMap := make(map[[32]byte]*MissingEntry)
 
 	for {
-		now := time.Now()
+		now := primitives.Now()
 
 		newrequest := 0
 
@@ -125,7 +126,7 @@ func (s *State) MakeMissingEntryRequests() {
 
 			}
 		} else {
-			time.Sleep(20 * time.Second)
+			primitives.Sleep(20 * time.Second)
 		}
 
 		// Insert the entries we have found into the database.
@@ -156,12 +157,12 @@ func (s *State) MakeMissingEntryRequests() {
 		}
 		if sent == 0 {
 			if s.GetHighestKnownBlock()-s.GetHighestSavedBlk() > 100 {
-				time.Sleep(10 * time.Second)
+				primitives.Sleep(10 * time.Second)
 			} else {
-				time.Sleep(100 * time.Millisecond)
+				primitives.Sleep(100 * time.Millisecond)
 			}
 			if s.EntryDBHeightComplete == s.GetHighestSavedBlk() {
-				time.Sleep(20 * time.Second)
+				primitives.Sleep(20 * time.Second)
 			}
 		}
 	}
@@ -252,7 +253,7 @@ func (s *State) GoSyncEntries() {
 					}
 
 					// Only update the replay hashes in the last 24 hours.
-					if time.Now().Unix()-db.GetTimestamp().GetTimeSeconds() < 24*60*60 {
+					if primitives.Now().Unix()-db.GetTimestamp().GetTimeSeconds() < 24*60*60 {
 						ueh := new(EntryUpdate)
 						ueh.Hash = entryhash
 						ueh.Timestamp = db.GetTimestamp()
@@ -307,10 +308,10 @@ func (s *State) GoSyncEntries() {
 			s.EntryDBHeightComplete = s.GetHighestSavedBlk()
 			s.LogPrintf("EntrySync", "firstMissing EntryDBHeightComplete = %d", s.EntryDBHeightComplete)
 
-			time.Sleep(500 * time.Millisecond)
+			primitives.Sleep(500 * time.Millisecond)
 		}
 
-		time.Sleep(100 * time.Millisecond)
+		primitives.Sleep(100 * time.Millisecond)
 
 	}
 }
@@ -8,7 +8,6 @@ import (
 	"encoding/binary"
 	"fmt"
 	"reflect"
-	"time"
 
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
@@ -90,22 +89,29 @@ func markFault(pl *ProcessList, vmIndex int, faultReason int) {
 		return
 	}
 
-	now := time.Now().Unix()
+	now := primitives.Now().Unix()
 	vm := pl.VMs[vmIndex]
 
+	c := pl.State.CurrentMinute
+	if c > 9 {
+		c = 9
+	}
+	index := pl.ServerMap[c][vmIndex]
+
 	if vm.WhenFaulted == 0 {
 		// if we did not previously consider this VM faulted
 		// we simply mark it as faulted (by assigning it a nonzero WhenFaulted time)
 		// and keep track of the ProcessList height it has faulted at
 		vm.WhenFaulted = now
 		vm.FaultFlag = faultReason
-	}
 
-	c := pl.State.CurrentMinute
-	if c > 9 {
-		c = 9
+		var leader interfaces.IHash
+		if index < len(pl.FedServers) {
+			leader = pl.FedServers[index].GetChainID()
+		}
+		pl.State.GetFaultHistory().Fault(pl.DBHeight, pl.State.CurrentMinute, vmIndex, leader, faultReason)
 	}
-	index := pl.ServerMap[c][vmIndex]
+
 	if index < len(pl.FedServers) {
 		pl.FedServers[index].SetOnline(false)
 	}
@@ -114,6 +120,9 @@ func markFault(pl *ProcessList, vmIndex int, faultReason int) {
 func markNoFault(pl *ProcessList, vmIndex int) {
 	vm := pl.VMs[vmIndex]
 
+	if vm.WhenFaulted != 0 {
+		pl.State.GetFaultHistory().Cleared(vmIndex)
+	}
 	vm.WhenFaulted = 0
 	vm.FaultFlag = -1
 
@@ -133,7 +142,7 @@ func markNoFault(pl *ProcessList, vmIndex int) {
 }
 
 func FaultCheck(pl *ProcessList) {
-	now := time.Now().Unix()
+	now := primitives.Now().Unix()
 
 	for i := 0; i < len(pl.FedServers); i++ {
 		if i == pl.State.LeaderVMIndex {
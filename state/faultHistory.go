@@ -0,0 +1,330 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+)
+
+// The kinds of FaultRecord
+const (
+	FaultKindFault    = "fault"    // a VM of the process list was marked faulted
+	FaultKindElection = "election" // an election to replace a leader
+)
+
+// The outcomes of a FaultRecord
+const (
+	FaultOutcomeOpen      = "open"      // still going on
+	FaultOutcomeCleared   = "cleared"   // the faulted VM caught up
+	FaultOutcomeElected   = "elected"   // an audit server replaced the leader
+	FaultOutcomeAbandoned = "abandoned" // the network moved on without electing a replacement
+)
+
+// faultHistoryMemory is the number of records kept in memory when there is no database to save them to
+const faultHistoryMemory = 1000
+
+// faultHistoryRetention is the number of records kept in the database, the older ones are pruned
+// every faultHistoryPruneEvery new records
+const (
+	faultHistoryRetention  = 10000
+	faultHistoryPruneEvery = 100
+)
+
+// faultHistoryPage is the number of records a query reads from the database at a time
+const faultHistoryPage = 500
+
+// FaultRecord is a server fault or an election in the fault history
+type FaultRecord struct {
+	ID         uint64
+	Kind       string
+	DBHeight   uint32
+	Minute     int
+	VMIndex    int
+	SigType    string           `json:",omitempty"` // EOM or DBSig, the message an election is for
+	Leader     string           // chain ID of the faulted leader
+	Reason     int              `json:",omitempty"` // the FaultFlag of a fault
+	Rounds     int              `json:",omitempty"` // rounds of an election
+	Volunteers []FaultVolunteer `json:",omitempty"`
+	Outcome    string
+	Elected    string `json:",omitempty"` // chain ID of the audit server that replaced the leader
+	Started    int64  // unix milliseconds
+	Ended      int64  `json:",omitempty"`
+}
+
+// FaultVolunteer is an audit server that volunteered in a round of an election
+type FaultVolunteer struct {
+	Round       int
+	AuditServer string
+}
+
+// FaultHistory records the server faults and the elections of a node.  The records are saved in
+// the database, so the history survives restarts.
+type FaultHistory struct {
+	mutex    sync.Mutex
+	state    *State
+	db       *databaseOverlay.Overlay // nil if the database does not support the history
+	loaded   bool
+	next     uint64
+	memory   []*FaultRecord       // the last records, when there is no database
+	faults   map[int]*FaultRecord // open faults by VM index
+	election *FaultRecord         // the open election
+}
+
+var faultHistoryMutex sync.Mutex
+
+// GetFaultHistory returns the fault history of the state
+func (s *State) GetFaultHistory() *FaultHistory {
+	faultHistoryMutex.Lock()
+	defer faultHistoryMutex.Unlock()
+	if s.FaultHistory == nil {
+		h := new(FaultHistory)
+		h.state = s
+		h.db, _ = s.DB.(*databaseOverlay.Overlay)
+		h.faults = map[int]*FaultRecord{}
+		s.FaultHistory = h
+	}
+	return s.FaultHistory
+}
+
+// save writes a new or changed record.  The history is best effort, it never stops the node.
+func (h *FaultHistory) save(r *FaultRecord) {
+	if !h.loaded {
+		h.loaded = true
+		if h.db != nil {
+			next, err := h.db.FetchNextFaultRecordID()
+			if err != nil {
+				h.state.LogPrintf("faulting", "Fault history: %v", err)
+			}
+			h.next = next
+		}
+	}
+	isNew := r.Started == 0
+	if isNew {
+		r.ID = h.next
+		h.next++
+		r.Started = time.Now().UnixNano() / int64(time.Millisecond)
+		if h.db == nil {
+			h.memory = append(h.memory, r)
+			if len(h.memory) > faultHistoryMemory {
+				h.memory = append([]*FaultRecord{}, h.memory[len(h.memory)-faultHistoryMemory:]...)
+			}
+		}
+	}
+	if h.db == nil {
+		return
+	}
+	if isNew && r.ID >= faultHistoryRetention && r.ID%faultHistoryPruneEvery == 0 {
+		err := h.db.PruneFaultRecords(r.ID - faultHistoryRetention)
+		if err != nil {
+			h.state.LogPrintf("faulting", "Fault history: pruning failed: %v", err)
+		}
+	}
+	data, err := json.Marshal(r)
+	if err == nil {
+		err = h.db.SaveFaultRecord(r.ID, data)
+	}
+	if err != nil {
+		h.state.LogPrintf("faulting", "Fault history: record %d not saved: %v", r.ID, err)
+	}
+}
+
+func (h *FaultHistory) end(r *FaultRecord, outcome string) {
+	r.Outcome = outcome
+	r.Ended = time.Now().UnixNano() / int64(time.Millisecond)
+	h.save(r)
+}
+
+// Fault records a VM marked faulted
+func (h *FaultHistory) Fault(dbheight uint32, minute int, vmIndex int, leader interfaces.IHash, reason int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if open := h.faults[vmIndex]; open != nil {
+		h.end(open, FaultOutcomeAbandoned)
+	}
+	r := &FaultRecord{Kind: FaultKindFault, DBHeight: dbheight, Minute: minute, VMIndex: vmIndex, Reason: reason, Outcome: FaultOutcomeOpen}
+	if leader != nil {
+		r.Leader = leader.String()
+	}
+	h.save(r)
+	h.faults[vmIndex] = r
+}
+
+// Cleared records a faulted VM that is no longer faulted
+func (h *FaultHistory) Cleared(vmIndex int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if open := h.faults[vmIndex]; open != nil {
+		h.end(open, FaultOutcomeCleared)
+		delete(h.faults, vmIndex)
+	}
+}
+
+// ElectionStarted records the start of an election to replace leader
+func (h *FaultHistory) ElectionStarted(dbheight uint32, minute int, vmIndex int, sigType bool, leader interfaces.IHash) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.election != nil {
+		h.end(h.election, FaultOutcomeAbandoned)
+	}
+	r := &FaultRecord{Kind: FaultKindElection, DBHeight: dbheight, Minute: minute, VMIndex: vmIndex, SigType: "DBSig", Outcome: FaultOutcomeOpen}
+	if sigType {
+		r.SigType = "EOM"
+	}
+	if leader != nil {
+		r.Leader = leader.String()
+	}
+	h.save(r)
+	h.election = r
+}
+
+// ElectionRound records a new round of the open election
+func (h *FaultHistory) ElectionRound(round int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.election != nil && round > h.election.Rounds {
+		h.election.Rounds = round
+		h.save(h.election)
+	}
+}
+
+// ElectionVolunteer records an audit server volunteering in a round of the open election
+func (h *FaultHistory) ElectionVolunteer(round int, auditServer interfaces.IHash) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.election == nil || auditServer == nil {
+		return
+	}
+	for _, v := range h.election.Volunteers {
+		if v.Round == round && v.AuditServer == auditServer.String() {
+			return
+		}
+	}
+	h.election.Volunteers = append(h.election.Volunteers, FaultVolunteer{round, auditServer.String()})
+	h.save(h.election)
+}
+
+// ElectionEnded records the end of the open election, with the audit server elected or nil if the
+// network moved on without electing one
+func (h *FaultHistory) ElectionEnded(elected interfaces.IHash) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.election == nil {
+		return
+	}
+	if elected != nil {
+		h.election.Elected = elected.String()
+		h.end(h.election, FaultOutcomeElected)
+	} else {
+		h.end(h.election, FaultOutcomeAbandoned)
+	}
+	h.election = nil
+}
+
+// FaultHistoryReport is the answer to a query of the fault history
+type FaultHistoryReport struct {
+	Records []*FaultRecord
+	Leaders map[string]*LeaderReliability // by chain ID, over the records returned
+}
+
+// LeaderReliability sums up the records of a server
+type LeaderReliability struct {
+	Faults    int // times its VM was marked faulted
+	Elections int // elections to replace it
+	Replaced  int // elections that replaced it
+	Elected   int // elections it won as an audit server
+}
+
+// Query returns the records between heights start and end (0 for no limit), of leader if not
+// empty and of kind if not empty, the newest limit records if limit is above 0.  The database
+// keeps the last faultHistoryRetention records.
+func (h *FaultHistory) Query(start, end uint32, leader string, kind string, limit int) (*FaultHistoryReport, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Read the records newest first, until limit records match
+	report := new(FaultHistoryReport)
+	report.Records = []*FaultRecord{}
+	match := func(r *FaultRecord) bool {
+		if r.DBHeight < start || (end > 0 && r.DBHeight > end) {
+			return false
+		}
+		if (leader != "" && r.Leader != leader && r.Elected != leader) || (kind != "" && r.Kind != kind) {
+			return false
+		}
+		report.Records = append(report.Records, r)
+		return limit > 0 && len(report.Records) >= limit
+	}
+	if h.db != nil {
+		from := uint64(math.MaxUint64)
+	pages:
+		for {
+			records, err := h.db.FetchFaultRecords(from, faultHistoryPage, true)
+			if err != nil {
+				return nil, err
+			}
+			for _, data := range records {
+				r := new(FaultRecord)
+				if err := json.Unmarshal(data, r); err != nil {
+					return nil, err
+				}
+				if match(r) {
+					break pages
+				}
+				from = r.ID
+			}
+			if len(records) < faultHistoryPage || from == 0 {
+				break
+			}
+			from--
+		}
+	} else {
+		for i := len(h.memory) - 1; i >= 0; i-- {
+			c := *h.memory[i]
+			if match(&c) {
+				break
+			}
+		}
+	}
+	// Oldest first
+	for i, j := 0, len(report.Records)-1; i < j; i, j = i+1, j-1 {
+		report.Records[i], report.Records[j] = report.Records[j], report.Records[i]
+	}
+
+	report.Leaders = map[string]*LeaderReliability{}
+	get := func(chainID string) *LeaderReliability {
+		if report.Leaders[chainID] == nil {
+			report.Leaders[chainID] = new(LeaderReliability)
+		}
+		return report.Leaders[chainID]
+	}
+	for _, r := range report.Records {
+		if r.Leader == "" {
+			continue
+		}
+		switch r.Kind {
+		case FaultKindFault:
+			get(r.Leader).Faults++
+		case FaultKindElection:
+			get(r.Leader).Elections++
+			if r.Outcome == FaultOutcomeElected {
+				get(r.Leader).Replaced++
+				get(r.Elected).Elected++
+			}
+		}
+	}
+	return report, nil
+}
+
+// QueryFaultHistory returns the *FaultHistoryReport of the records of the fault history matching
+// the query, as FaultHistory.Query
+func (s *State) QueryFaultHistory(start, end uint32, leader string, kind string, limit int) (interface{}, error) {
+	return s.GetFaultHistory().Query(start, end, leader, kind, limit)
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestFaultHistory(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	leader := primitives.Sha([]byte("leader"))
+	audit := primitives.Sha([]byte("audit"))
+
+	h := s.GetFaultHistory()
+	h.Fault(10, 3, 1, leader, 2)
+	h.Cleared(1)
+	h.Fault(11, 4, 1, leader, 2)
+	h.ElectionStarted(11, 4, 1, true, leader)
+	h.ElectionRound(1)
+	h.ElectionVolunteer(1, audit)
+	h.ElectionVolunteer(1, audit)
+	h.ElectionEnded(audit)
+
+	r, err := s.QueryFaultHistory(0, 0, leader.String(), "", 0)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	report := r.(*FaultHistoryReport)
+	if len(report.Records) != 3 {
+		t.Fatalf("Found %d records, expected 3", len(report.Records))
+	}
+	if report.Records[0].Outcome != FaultOutcomeCleared || report.Records[1].Outcome != FaultOutcomeOpen {
+		t.Errorf("Wrong fault outcomes %s and %s", report.Records[0].Outcome, report.Records[1].Outcome)
+	}
+	election := report.Records[2]
+	if election.Kind != FaultKindElection || election.SigType != "EOM" || election.Rounds != 1 {
+		t.Errorf("Wrong election %v", election)
+	}
+	if election.Outcome != FaultOutcomeElected || election.Elected != audit.String() || len(election.Volunteers) != 1 {
+		t.Errorf("Wrong election outcome %v", election)
+	}
+	if l := report.Leaders[leader.String()]; l == nil || l.Faults != 2 || l.Elections != 1 || l.Replaced != 1 {
+		t.Errorf("Wrong reliability of the leader %v", l)
+	}
+	if a := report.Leaders[audit.String()]; a == nil || a.Elected != 1 {
+		t.Errorf("Wrong reliability of the audit server %v", a)
+	}
+
+	// Filters
+	r, _ = s.QueryFaultHistory(11, 11, leader.String(), FaultKindFault, 0)
+	if records := r.(*FaultHistoryReport).Records; len(records) != 1 || records[0].DBHeight != 11 {
+		t.Errorf("Wrong faults at height 11 %v", records)
+	}
+	r, _ = s.QueryFaultHistory(0, 0, leader.String(), "", 1)
+	if records := r.(*FaultHistoryReport).Records; len(records) != 1 || records[0].Kind != FaultKindElection {
+		t.Errorf("Wrong last record %v", records)
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/globals"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// GrantScheduleVersion is the version of the grant schedule format
+const GrantScheduleVersion = 1
+
+// GrantSchedule is a round of grants, signed by the grant authority.  A schedule is read from
+// the file given by -grantschedule, or from the entries of the governance chain given by
+// -grantchain, each entry holding one schedule.  Files are local to a node, so they are only
+// read off MAIN, for testing.
+//
+// Scheduled grants are paid in addition to the hard-coded grants, and only above the highest
+// hard-coded grant height, so the history of the hard-coded grants never changes.
+type GrantSchedule struct {
+	Version   int
+	Network   string // network the grants are paid on
+	Round     string // unique name of the round, a round is only loaded once
+	Grants    []ScheduledGrant
+	PublicKey string `json:",omitempty"` // hex ed25519 key of the grant authority
+	Signature string `json:",omitempty"` // hex signature of SigningData
+}
+
+// ScheduledGrant is a grant of a GrantSchedule
+type ScheduledGrant struct {
+	Height  uint32 // height of the coinbase descriptor, as HardGrant.DBh
+	Amount  uint64 // in factoshis
+	Address string // human readable FA address
+	Note    string `json:",omitempty"`
+}
+
+// SigningData is the data the grant authority signs, the schedule without its signature
+func (gs *GrantSchedule) SigningData() ([]byte, error) {
+	unsigned := *gs
+	unsigned.PublicKey = ""
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Sign signs the schedule with the private key of the grant authority
+func (gs *GrantSchedule) Sign(priv *primitives.PrivateKey) error {
+	data, err := gs.SigningData()
+	if err != nil {
+		return err
+	}
+	gs.PublicKey = priv.PublicKeyString()
+	gs.Signature = hex.EncodeToString(primitives.Sign(priv.Key[:], data))
+	return nil
+}
+
+// Check validates the schedule for a network: its version, the signature of the grant authority
+// with the hex public key authorityKey, and every grant.
+func (gs *GrantSchedule) Check(network string, authorityKey string) error {
+	if gs.Version != GrantScheduleVersion {
+		return fmt.Errorf("grant schedule version %d is not supported, expected %d", gs.Version, GrantScheduleVersion)
+	}
+	if gs.Network != network {
+		return fmt.Errorf("grant schedule %s is for network %s, not %s", gs.Round, gs.Network, network)
+	}
+	if gs.Round == "" {
+		return fmt.Errorf("grant schedule has no round")
+	}
+
+	if authorityKey == "" {
+		return fmt.Errorf("no grant authority key is configured")
+	}
+	if gs.PublicKey != authorityKey {
+		return fmt.Errorf("grant schedule %s is signed by %s, not the grant authority", gs.Round, gs.PublicKey)
+	}
+	pub, err := hex.DecodeString(gs.PublicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := hex.DecodeString(gs.Signature)
+	if err != nil {
+		return err
+	}
+	data, err := gs.SigningData()
+	if err != nil {
+		return err
+	}
+	err = primitives.VerifySignature(data, pub, sig)
+	if err != nil {
+		return fmt.Errorf("grant schedule %s: %v", gs.Round, err)
+	}
+
+	last := lastHardCodedGrantHeight()
+	for i, g := range gs.Grants {
+		if g.Height%constants.COINBASE_PAYOUT_FREQUENCY != 1 {
+			return fmt.Errorf("bad grant[%d] payout height %d in grant schedule %s", i, g.Height, gs.Round)
+		}
+		if g.Height <= last {
+			return fmt.Errorf("grant[%d] of grant schedule %s is at height %d, not above the hard-coded grants at %d", i, gs.Round, g.Height, last)
+		}
+		if g.Amount == 0 {
+			return fmt.Errorf("grant[%d] of grant schedule %s has no amount", i, gs.Round)
+		}
+		if !primitives.ValidateFUserStr(g.Address) {
+			return fmt.Errorf("bad addr(%s) in grant schedule %s", g.Address, gs.Round)
+		}
+	}
+	return nil
+}
+
+// LoadGrantScheduleFile reads a grant schedule from a JSON file
+func LoadGrantScheduleFile(filename string) (*GrantSchedule, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	gs := new(GrantSchedule)
+	err = json.Unmarshal(data, gs)
+	if err != nil {
+		return nil, fmt.Errorf("grant schedule %s: %v", filename, err)
+	}
+	return gs, nil
+}
+
+func lastHardCodedGrantHeight() uint32 {
+	last := uint32(0)
+	for _, g := range GetHardCodedGrants() {
+		if g.DBh > last {
+			last = g.DBh
+		}
+	}
+	return last
+}
+
+// scheduledGrant is a loaded grant, sorted by round and position to keep the payouts in the
+// same order on every node, whatever order the rounds were loaded in
+type scheduledGrant struct {
+	HardGrant
+	round string
+	index int
+}
+
+type byRound []scheduledGrant
+
+func (g byRound) Len() int      { return len(g) }
+func (g byRound) Swap(i, j int) { g[i], g[j] = g[j], g[i] }
+func (g byRound) Less(i, j int) bool {
+	if g[i].round != g[j].round {
+		return g[i].round < g[j].round
+	}
+	return g[i].index < g[j].index
+}
+
+type byEBSequence []interfaces.IEntryBlock
+
+func (b byEBSequence) Len() int      { return len(b) }
+func (b byEBSequence) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byEBSequence) Less(i, j int) bool {
+	return b[i].GetHeader().GetEBSequence() < b[j].GetHeader().GetEBSequence()
+}
+
+// grantSchedules are the loaded rounds.  They are shared by the simulated nodes, which all load
+// the same rounds.
+var grantSchedules struct {
+	sync.Mutex
+	rounds map[string][]byte // signing data by round
+	grants []scheduledGrant
+	// progress of the governance chain scan
+	scanned map[[32]byte]bool // KeyMRs of the entry blocks read
+}
+
+// AddGrantSchedule checks a schedule and adds its grants to the payouts.  Adding a round again
+// does nothing, but a different schedule for a round already loaded is an error.
+func AddGrantSchedule(gs *GrantSchedule) error {
+	err := gs.Check(globals.Params.NetworkName, globals.Params.GrantAuthority)
+	if err != nil {
+		return err
+	}
+	data, err := gs.SigningData()
+	if err != nil {
+		return err
+	}
+
+	grantSchedules.Lock()
+	defer grantSchedules.Unlock()
+	if grantSchedules.rounds == nil {
+		grantSchedules.rounds = map[string][]byte{}
+	}
+	if loaded, ok := grantSchedules.rounds[gs.Round]; ok {
+		if !bytes.Equal(loaded, data) {
+			return fmt.Errorf("grant schedule %s is already loaded with different grants", gs.Round)
+		}
+		return nil
+	}
+	grantSchedules.rounds[gs.Round] = data
+
+	for i, g := range gs.Grants {
+		address := validateAddress(g.Address)
+		grantSchedules.grants = append(grantSchedules.grants, scheduledGrant{HardGrant{g.Height, g.Amount, address}, gs.Round, i})
+	}
+	sort.Sort(byRound(grantSchedules.grants))
+	return nil
+}
+
+// GetScheduledGrants returns the grants of the loaded schedules
+func GetScheduledGrants() []HardGrant {
+	grantSchedules.Lock()
+	defer grantSchedules.Unlock()
+	grants := make([]HardGrant, 0, len(grantSchedules.grants))
+	for _, g := range grantSchedules.grants {
+		grants = append(grants, g.HardGrant)
+	}
+	return grants
+}
+
+// LoadGrantChain reads the grant schedules of the governance chain recorded up to maxHeight.
+// A schedule only counts if every one of its grants is at least COINBASE_PAYOUT_FREQUENCY
+// blocks above the block it was recorded in, so every leader has it before it is paid.
+// Entries that are not valid schedules are skipped, as anyone can write to the chain.
+//
+// Entries that have not synced yet stop the scan, so the rounds are always loaded in the order
+// they were recorded.  Returns the height of the first entry block with entries missing, to read
+// again at a later call, or maxHeight+1 if the chain is read up to maxHeight.
+func (s *State) LoadGrantChain(maxHeight uint32) (uint32, error) {
+	chainID, err := primitives.HexToHash(globals.Params.GrantChain)
+	if err != nil {
+		return 0, err
+	}
+	eblocks, err := s.DB.FetchAllEBlocksByChain(chainID)
+	if err != nil {
+		return 0, err
+	}
+	// Load the rounds in the order they were recorded
+	sort.Sort(byEBSequence(eblocks))
+
+	for _, eblock := range eblocks {
+		recorded := eblock.GetDatabaseHeight()
+		if recorded > maxHeight {
+			break
+		}
+		keyMR, err := eblock.KeyMR()
+		if err != nil {
+			return 0, err
+		}
+		grantSchedules.Lock()
+		scanned := grantSchedules.scanned[keyMR.Fixed()]
+		grantSchedules.Unlock()
+		if scanned {
+			continue
+		}
+
+		var entries []interfaces.IEBEntry
+		for _, hash := range eblock.GetEntryHashes() {
+			if hash.IsMinuteMarker() {
+				continue
+			}
+			entry, err := s.DB.FetchEntry(hash)
+			if err != nil {
+				return 0, err
+			}
+			if entry == nil {
+				s.LogPrintf("grants", "Entry %s of the grant chain at height %d has not synced yet", hash.String(), recorded)
+				return recorded, nil
+			}
+			entries = append(entries, entry)
+		}
+		for _, entry := range entries {
+			err = addGrantChainEntry(entry, recorded)
+			if err != nil {
+				s.LogPrintf("grants", "Skipping grant chain entry %s: %v", entry.GetHash().String(), err)
+			}
+		}
+
+		grantSchedules.Lock()
+		if grantSchedules.scanned == nil {
+			grantSchedules.scanned = map[[32]byte]bool{}
+		}
+		grantSchedules.scanned[keyMR.Fixed()] = true
+		grantSchedules.Unlock()
+	}
+	return maxHeight + 1, nil
+}
+
+func addGrantChainEntry(entry interfaces.IEBEntry, recorded uint32) error {
+	gs := new(GrantSchedule)
+	err := json.Unmarshal(entry.GetContent(), gs)
+	if err != nil {
+		return err
+	}
+	for i, g := range gs.Grants {
+		if g.Height < recorded+constants.COINBASE_PAYOUT_FREQUENCY {
+			return fmt.Errorf("grant[%d] at height %d is too close to height %d the schedule was recorded at", i, g.Height, recorded)
+		}
+	}
+	return AddGrantSchedule(gs)
+}
+
+// LoadGrantSchedules loads the grant schedule file and checks the grant configuration, once
+// at startup
+func LoadGrantSchedules() error {
+	if globals.Params.GrantChain != "" {
+		if _, err := primitives.HexToHash(globals.Params.GrantChain); err != nil {
+			return fmt.Errorf("bad grant chain %s: %v", globals.Params.GrantChain, err)
+		}
+	}
+	if globals.Params.GrantAuthority != "" {
+		if key, err := hex.DecodeString(globals.Params.GrantAuthority); err != nil || len(key) != 32 {
+			return fmt.Errorf("bad grant authority key %s", globals.Params.GrantAuthority)
+		}
+	}
+	if globals.Params.GrantScheduleFile == "" {
+		return nil
+	}
+	// A file is local to a node, and nodes paying different grants would fork
+	if globals.Params.NetworkName == "MAIN" || globals.Params.NetworkName == "main" {
+		return fmt.Errorf("grant schedule files can't be used on MAIN, record the schedule in the grant chain")
+	}
+	gs, err := LoadGrantScheduleFile(globals.Params.GrantScheduleFile)
+	if err != nil {
+		return err
+	}
+	return AddGrantSchedule(gs)
+}
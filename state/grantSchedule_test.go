@@ -0,0 +1,107 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/globals"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+func newTestGrantSchedule(t *testing.T, round string, priv *primitives.PrivateKey, grants ...ScheduledGrant) *GrantSchedule {
+	gs := &GrantSchedule{Version: GrantScheduleVersion, Network: "LOCAL", Round: round, Grants: grants}
+	if err := gs.Sign(priv); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return gs
+}
+
+func TestAddGrantSchedule(t *testing.T) {
+	globals.Params.NetworkName = "LOCAL"
+	constants.SetLocalCoinBaseConstants()
+	priv := primitives.RandomPrivateKey()
+	globals.Params.GrantAuthority = priv.PublicKeyString()
+	defer func() { globals.Params.GrantAuthority = "" }()
+
+	grantSchedules.Lock()
+	grantSchedules.rounds = nil
+	grantSchedules.grants = nil
+	grantSchedules.Unlock()
+	defer func() {
+		grantSchedules.Lock()
+		grantSchedules.rounds = nil
+		grantSchedules.grants = nil
+		grantSchedules.Unlock()
+	}()
+
+	bill := "FA3GH7VEFKqTdJcmwGgDrcY4Xh9njQ4EWiJxhJeim6BCA7QuB388"
+	bob := "FA3Ga2XcaheS5NgQ3q22gBpLgE6tXmPu1GhjdU2FsdN2QPMzKJET"
+
+	// Rounds are paid in round order, whatever order they are loaded in
+	err := AddGrantSchedule(newTestGrantSchedule(t, "round-2", priv, ScheduledGrant{Height: 41, Amount: 7, Address: bob}))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = AddGrantSchedule(newTestGrantSchedule(t, "round-1", priv, ScheduledGrant{Height: 41, Amount: 5, Address: bill}, ScheduledGrant{Height: 46, Amount: 6, Address: bob}))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	payouts := GetGrantPayoutsFor(41)
+	if len(payouts) != 2 || payouts[0].GetAmount() != 5 || payouts[1].GetAmount() != 7 {
+		t.Errorf("Wrong payouts at 41: %v", payouts)
+	}
+	if payouts := GetGrantPayoutsFor(46); len(payouts) != 1 || payouts[0].GetAmount() != 6 {
+		t.Errorf("Wrong payouts at 46: %v", payouts)
+	}
+	// The hard-coded grants are still paid
+	if payouts := GetGrantPayoutsFor(31); len(payouts) != 1 || payouts[0].GetAmount() != 4 {
+		t.Errorf("Wrong payouts at 31: %v", payouts)
+	}
+
+	// Loading a round again does nothing
+	err = AddGrantSchedule(newTestGrantSchedule(t, "round-2", priv, ScheduledGrant{Height: 41, Amount: 7, Address: bob}))
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	if n := len(GetScheduledGrants()); n != 3 {
+		t.Errorf("Expected 3 scheduled grants, found %d", n)
+	}
+
+	bad := []*GrantSchedule{
+		// a round loaded with different grants
+		newTestGrantSchedule(t, "round-2", priv, ScheduledGrant{Height: 41, Amount: 8, Address: bob}),
+		// not above the hard-coded grants
+		newTestGrantSchedule(t, "round-3", priv, ScheduledGrant{Height: 31, Amount: 1, Address: bob}),
+		// not a payout height
+		newTestGrantSchedule(t, "round-3", priv, ScheduledGrant{Height: 42, Amount: 1, Address: bob}),
+		// signed by another key
+		newTestGrantSchedule(t, "round-3", primitives.RandomPrivateKey(), ScheduledGrant{Height: 41, Amount: 1, Address: bob}),
+	}
+	// a schedule changed after it was signed
+	changed := newTestGrantSchedule(t, "round-3", priv, ScheduledGrant{Height: 41, Amount: 1, Address: bob})
+	changed.Grants[0].Amount = 1000
+	bad = append(bad, changed)
+
+	for i, gs := range bad {
+		if err := AddGrantSchedule(gs); err == nil {
+			t.Errorf("Bad grant schedule %d was accepted", i)
+		}
+	}
+	if n := len(GetScheduledGrants()); n != 3 {
+		t.Errorf("Expected 3 scheduled grants, found %d", n)
+	}
+}
+
+func TestGrantScheduleFileNotOnMain(t *testing.T) {
+	globals.Params.NetworkName = "MAIN"
+	globals.Params.GrantScheduleFile = "grants.json"
+	defer func() {
+		globals.Params.NetworkName = "LOCAL"
+		globals.Params.GrantScheduleFile = ""
+	}()
+
+	if err := LoadGrantSchedules(); err == nil {
+		t.Errorf("A grant schedule file was accepted on MAIN")
+	}
+}
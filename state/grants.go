@@ -300,6 +300,10 @@ func validateAddress(a string) interfaces.IAddress {
 }
 
 func CheckGrants() {
+	// The grant schedules are checked as they are loaded
+	if err := LoadGrantSchedules(); err != nil {
+		panic(fmt.Sprintf("Bad grant schedule: %v", err))
+	}
 
 	hardcodegrants := GetHardCodedGrants()
 	// this used to be in an init block but it turns out COINBASE_PAYOUT_FREQUENCY isn't so
@@ -322,7 +326,8 @@ func GetGrantPayoutsFor(currentDBHeight uint32) []interfaces.ITransAddress {
 	// I opted for one list knowing it will have to be different for testnet vs mainnet because making it
 	// network sensitive just add complexity to the code.
 	// there is no need for activation height because the grants have inherent activation heights per grant
-	for _, g := range GetHardCodedGrants() { // check every hardcoded grant
+	// the scheduled grants follow the hardcoded ones, in the same order on every node
+	for _, g := range append(GetHardCodedGrants(), GetScheduledGrants()...) { // check every grant
 		if g.DBh == currentDBHeight { // if it's ready {...
 			o := factoid.NewOutAddress(g.Address, g.Amount) // Create a payout
 			outputs = append(outputs, o)                    // and add it to the list
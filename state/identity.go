@@ -542,3 +542,14 @@ func (st *State) LookupIdentityInBlockchainByChainID(cid interfaces.IHash) error
 	}
 	return nil
 }
+
+// GetIdentityParserNetwork returns the identities the network of the state starts with, to parse the
+// identities outside factomd with an identity.Parser
+func (st *State) GetIdentityParserNetwork() ParserNetwork {
+	return ParserNetwork{
+		BootstrapIdentity: st.GetNetworkBootStrapIdentity(),
+		BootstrapKey:      st.GetNetworkBootStrapKey(),
+		SkeletonIdentity:  st.GetNetworkSkeletonIdentity(),
+		RegistrationChain: st.GetNetworkIdentityRegistrationChain(),
+	}
+}
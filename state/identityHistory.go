@@ -0,0 +1,258 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/FactomProject/factomd/common/adminBlock"
+	"github.com/FactomProject/factomd/common/constants"
+	. "github.com/FactomProject/factomd/common/identity"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// IdentityHistory is everything the node knows about an identity: its current state in the
+// identity manager, the pending coinbase cancels it voted for, and the admin block entries
+// that changed it.
+type IdentityHistory struct {
+	ChainID string
+	Status  string
+
+	// From the identity chains
+	IdentityCreated      uint32   `json:",omitempty"`
+	IdentityRegistered   uint32   `json:",omitempty"`
+	ManagementChainID    string   `json:",omitempty"`
+	ManagementCreated    uint32   `json:",omitempty"`
+	ManagementRegistered uint32   `json:",omitempty"`
+	Keys                 []string `json:",omitempty"` // the 4 levels of identity keys
+
+	// The authority set, as changed by the admin blocks
+	MatryoshkaHash  string             `json:",omitempty"`
+	SigningKey      string             `json:",omitempty"`
+	KeyHistory      []IdentityKey      `json:",omitempty"` // the signing keys that were replaced
+	AnchorKeys      []AnchorSigningKey `json:",omitempty"`
+	Efficiency      uint16
+	CoinbaseAddress string
+
+	PendingCancels []IdentityCoinbaseCancel
+	AdminChanges   []IdentityAdminChange
+	Start, End     uint32 // admin block heights searched for changes
+}
+
+// IdentityKey is a signing key and the height it became active at
+type IdentityKey struct {
+	Height     uint32
+	SigningKey string
+}
+
+// IdentityCoinbaseCancel is a coinbase cancel proposal the identity voted for
+type IdentityCoinbaseCancel struct {
+	DescriptorHeight uint32
+	DescriptorIndex  uint32
+	Votes            int  // votes of all the identities for the output
+	Cancelled        bool // if the majority is reached and the cancel is not recorded yet
+	Recorded         bool // if the cancel is recorded in an admin block
+}
+
+// IdentityAdminChange is an admin block entry that changed the identity
+type IdentityAdminChange struct {
+	Height uint32 // height of the admin block
+	Type   string
+	Entry  interfaces.IABEntry
+}
+
+var identityAdminEntryNames = map[byte]string{
+	constants.TYPE_ADD_MATRYOSHKA:         "AddMatryoshkaHash",
+	constants.TYPE_ADD_FED_SERVER:         "AddFedServer",
+	constants.TYPE_ADD_AUDIT_SERVER:       "AddAuditServer",
+	constants.TYPE_REMOVE_FED_SERVER:      "RemoveFedServer",
+	constants.TYPE_ADD_FED_SERVER_KEY:     "AddFedServerKey",
+	constants.TYPE_ADD_BTC_ANCHOR_KEY:     "AddBTCAnchorKey",
+	constants.TYPE_ADD_FACTOID_ADDRESS:    "AddFactoidAddress",
+	constants.TYPE_ADD_FACTOID_EFFICIENCY: "AddEfficiency",
+}
+
+// adminEntryIdentity returns the identity chain an admin block entry changes, or nil
+func adminEntryIdentity(entry interfaces.IABEntry) interfaces.IHash {
+	switch e := entry.(type) {
+	case *adminBlock.AddReplaceMatryoshkaHash:
+		return e.IdentityChainID
+	case *adminBlock.AddFederatedServer:
+		return e.IdentityChainID
+	case *adminBlock.AddAuditServer:
+		return e.IdentityChainID
+	case *adminBlock.RemoveFederatedServer:
+		return e.IdentityChainID
+	case *adminBlock.AddFederatedServerSigningKey:
+		return e.IdentityChainID
+	case *adminBlock.AddFederatedServerBitcoinAnchorKey:
+		return e.IdentityChainID
+	case *adminBlock.AddFactoidAddress:
+		return e.IdentityChainID
+	case *adminBlock.AddEfficiency:
+		return e.IdentityChainID
+	}
+	return nil
+}
+
+// identityHistoryWindow is the most admin blocks a request for the history of an identity reads
+const identityHistoryWindow = 1000
+
+// GetIdentityHistory returns the *IdentityHistory of an identity chain, searching the admin
+// blocks from start to end for its changes.  An end of 0 searches up to the highest saved block.
+// At most identityHistoryWindow blocks are searched: the last ones up to end if start is 0, else
+// the first ones from start.  Start and End of the history are the heights searched, earlier or
+// later changes are found by paging from there.
+func (s *State) GetIdentityHistory(chainID string, start, end uint32) (interface{}, error) {
+	id, err := primitives.HexToHash(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain ID %s: %v", chainID, err)
+	}
+	if start > end && end != 0 {
+		return nil, fmt.Errorf("start %d is above end %d", start, end)
+	}
+	head, err := s.DB.FetchDBlockHead()
+	if err != nil {
+		return nil, err
+	}
+	var top uint32
+	if head != nil {
+		top = head.GetDatabaseHeight()
+	}
+	if end == 0 || end > top {
+		end = top
+	}
+	if start > end {
+		return nil, fmt.Errorf("start %d is above the highest saved block %d", start, end)
+	}
+	if end-start >= identityHistoryWindow {
+		if start == 0 {
+			start = end - identityHistoryWindow + 1
+		} else {
+			end = start + identityHistoryWindow - 1
+		}
+	}
+
+	h := new(IdentityHistory)
+	h.ChainID = id.String()
+	h.Start = start
+	h.End = end
+	found := s.identityHistoryFromManager(id, h)
+
+	for height := start; height <= end; height++ {
+		ablock, err := s.DB.FetchABlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		if ablock == nil {
+			continue
+		}
+		for _, entry := range ablock.GetABEntries() {
+			if changed := adminEntryIdentity(entry); changed != nil && changed.IsSameAs(id) {
+				h.AdminChanges = append(h.AdminChanges, IdentityAdminChange{height, identityAdminEntryNames[entry.Type()], entry})
+			}
+		}
+	}
+
+	if !found && len(h.AdminChanges) == 0 {
+		return nil, fmt.Errorf("identity %s not found", h.ChainID)
+	}
+	return h, nil
+}
+
+// identityHistoryFromManager fills in the state of the identity in the identity manager, and
+// returns false if the manager does not know the identity
+func (s *State) identityHistoryFromManager(chainID interfaces.IHash, h *IdentityHistory) bool {
+	im := s.IdentityControl
+	im.Init()
+	im.Mutex.RLock()
+	defer im.Mutex.RUnlock()
+
+	h.Status = constants.IdentityStatusString(constants.IDENTITY_UNASSIGNED)
+	h.CoinbaseAddress = "No Address"
+	h.Efficiency = 10000
+
+	id, idOk := im.Identities[chainID.Fixed()]
+	if idOk {
+		h.Status = constants.IdentityStatusString(id.Status)
+		h.IdentityCreated = id.IdentityCreated
+		h.IdentityRegistered = id.IdentityRegistered
+		h.ManagementChainID = id.ManagementChainID.String()
+		h.ManagementCreated = id.ManagementCreated
+		h.ManagementRegistered = id.ManagementRegistered
+		for _, k := range id.Keys {
+			h.Keys = append(h.Keys, k.String())
+		}
+		h.MatryoshkaHash = id.MatryoshkaHash.String()
+		h.SigningKey = id.SigningKey.String()
+		h.AnchorKeys = append(h.AnchorKeys, id.AnchorKeys...)
+		h.Efficiency = id.Efficiency
+		h.CoinbaseAddress = id.GetCoinbaseHumanReadable()
+	}
+
+	// The authority is what the admin blocks set, so it overrides the identity chains
+	auth, authOk := im.Authorities[chainID.Fixed()]
+	if authOk {
+		h.Status = constants.IdentityStatusString(auth.Status)
+		h.ManagementChainID = auth.ManagementChainID.String()
+		h.MatryoshkaHash = auth.MatryoshkaHash.String()
+		h.SigningKey = auth.SigningKey.String()
+		for _, k := range auth.KeyHistory {
+			h.KeyHistory = append(h.KeyHistory, IdentityKey{k.ActiveDBHeight, k.SigningKey.String()})
+		}
+		h.AnchorKeys = append([]AnchorSigningKey{}, auth.AnchorKeys...)
+		h.Efficiency = auth.Efficiency
+		h.CoinbaseAddress = auth.GetCoinbaseHumanReadable()
+	}
+
+	if cm := im.CancelManager; cm != nil {
+		// The majority of CoinbaseCancelManager.IsCoinbaseCancelled, which takes the lock we hold
+		servers := 0
+		for _, a := range im.Authorities {
+			if a.Status == constants.IDENTITY_FEDERATED_SERVER || a.Status == constants.IDENTITY_AUDIT_SERVER {
+				servers++
+			}
+		}
+		maj := servers/2 + 1
+
+		for descriptorHeight, outputs := range cm.Proposals {
+			for index, votes := range outputs {
+				if _, ok := votes[chainID.Fixed()]; !ok {
+					continue
+				}
+				authVotes := 0
+				for voter := range votes {
+					if _, ok := im.Authorities[voter]; ok {
+						authVotes++
+					}
+				}
+				recorded := cm.IsAdminBlockRecorded(descriptorHeight, index)
+				h.PendingCancels = append(h.PendingCancels, IdentityCoinbaseCancel{
+					DescriptorHeight: descriptorHeight,
+					DescriptorIndex:  index,
+					Votes:            len(votes),
+					Cancelled:        !recorded && authVotes >= maj,
+					Recorded:         recorded,
+				})
+			}
+		}
+		sort.Sort(identityCancelSort(h.PendingCancels))
+	}
+
+	return idOk || authOk
+}
+
+type identityCancelSort []IdentityCoinbaseCancel
+
+func (p identityCancelSort) Len() int      { return len(p) }
+func (p identityCancelSort) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p identityCancelSort) Less(i, j int) bool {
+	if p[i].DescriptorHeight != p[j].DescriptorHeight {
+		return p[i].DescriptorHeight < p[j].DescriptorHeight
+	}
+	return p[i].DescriptorIndex < p[j].DescriptorIndex
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestGetIdentityHistory(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+
+	// The first test admin block adds this federated server and its signing key
+	chainID := "38bab1455b7bd7e5efd15c53c777c79d0c988e9210f1da49a99d95b3a6417be9"
+	r, err := s.GetIdentityHistory(chainID, 0, 0)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	h := r.(*IdentityHistory)
+	if h.ChainID != chainID {
+		t.Errorf("Wrong chain ID %s", h.ChainID)
+	}
+	if h.End == 0 {
+		t.Errorf("The admin blocks were not searched up to the highest block")
+	}
+	types := map[string]bool{}
+	for _, c := range h.AdminChanges {
+		if c.Height != 0 {
+			t.Errorf("Unexpected change %s at height %d", c.Type, c.Height)
+		}
+		types[c.Type] = true
+	}
+	if !types["AddFedServer"] || !types["AddFedServerKey"] {
+		t.Errorf("Wrong admin changes %v", h.AdminChanges)
+	}
+
+	// Unknown identities are an error
+	if _, err := s.GetIdentityHistory(primitives.RandomHash().String(), 0, 0); err == nil {
+		t.Errorf("Found the history of an unknown identity")
+	}
+	if _, err := s.GetIdentityHistory("not a chain", 0, 0); err == nil {
+		t.Errorf("Accepted a bad chain ID")
+	}
+	if _, err := s.GetIdentityHistory(chainID, 5, 4); err == nil {
+		t.Errorf("Accepted a start above the end")
+	}
+}
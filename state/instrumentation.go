@@ -261,6 +261,20 @@ var (
 		Name: "factomd_state_execute_msg_time",
 		Help: "Time spent in executeMsg",
 	})
+
+	// Integrity verifier
+	IntegrityCheckedHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factomd_state_integrity_checked_height",
+		Help: "Last height checked by the database integrity verifier",
+	})
+	IntegrityCorruptions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_state_integrity_corruptions",
+		Help: "Corrupted or missing blocks and entries found by the database integrity verifier",
+	}, []string{"block"})
+	IntegrityRepairs = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_state_integrity_repairs",
+		Help: "Corrupted blocks and entries repaired with data from peers",
+	})
 )
 
 var registered bool = false
@@ -352,4 +366,9 @@ func RegisterPrometheus() {
 	prometheus.MustRegister(TotalEmptyLoopTime)
 	prometheus.MustRegister(TotalAckLoopTime)
 	prometheus.MustRegister(TotalExecuteMsgTime)
+
+	// Integrity verifier
+	prometheus.MustRegister(IntegrityCheckedHeight)
+	prometheus.MustRegister(IntegrityCorruptions)
+	prometheus.MustRegister(IntegrityRepairs)
 }
@@ -0,0 +1,482 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/factoid"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+)
+
+// maxCorruptions is the number of corruptions kept for the report
+const maxCorruptions = 1000
+
+// Corruption is a problem found in the database by the integrity verifier
+type Corruption struct {
+	Height   uint32
+	Block    string // DBlock, FBlock, EBlock or Entry
+	Hash     string
+	Problem  string
+	Found    time.Time
+	Repaired bool
+	Unread   bool // the database returned an error, the data itself may be intact
+}
+
+// IntegrityReport is the state of the integrity verifier, as returned by the debug API
+type IntegrityReport struct {
+	NextHeight     uint32
+	HighestSaved   uint32
+	Passes         int
+	HeightsChecked uint64
+	Refetch        bool
+	PendingRepairs []uint32
+	Corruptions    []Corruption // most recent last
+}
+
+// pendingRepair is a height whose blocks were requested from peers
+type pendingRepair struct {
+	keyMR     interfaces.IHash // trusted KeyMR of the directory block
+	dblock    bool
+	fblock    bool
+	eblocks   map[[32]byte]bool // KeyMRs of the corrupted entry blocks
+	requested time.Time
+	attempts  int
+}
+
+// IntegrityVerifier walks the saved heights in the background, recomputing the KeyMRs and body
+// Merkle roots of the directory, factoid and entry blocks, checking the PrevKeyMR links and that
+// every entry is present.  It pauses between heights so it does not compete with the node, and
+// starts over once it reaches the highest saved block.
+//
+// With Refetch set, corrupted blocks are requested again from peers with a DBStateMissing, and
+// corrupted entries are dropped and requested through the missing entry requests.  Data the
+// database fails to read is only reported, as the error may be transient.
+type IntegrityVerifier struct {
+	state     *State
+	Delay     time.Duration // pause between two heights
+	PassDelay time.Duration // pause before starting over
+	Refetch   bool
+
+	mutex       sync.Mutex
+	next        uint32
+	passes      int
+	checked     uint64
+	corruptions []Corruption
+	repairs     map[uint32]*pendingRepair
+}
+
+func NewIntegrityVerifier(s *State, delay time.Duration, refetch bool) *IntegrityVerifier {
+	v := new(IntegrityVerifier)
+	v.state = s
+	v.Delay = delay
+	v.PassDelay = 10 * time.Minute
+	v.Refetch = refetch
+	v.repairs = make(map[uint32]*pendingRepair)
+	return v
+}
+
+// StartIntegrityVerifier starts the background integrity verifier of the node
+func (s *State) StartIntegrityVerifier(delay time.Duration, refetch bool) {
+	s.IntegrityVerifier = NewIntegrityVerifier(s, delay, refetch)
+	go s.IntegrityVerifier.Run()
+}
+
+// GetIntegrityReport returns the *IntegrityReport of the integrity verifier, or nil if it is not running
+func (s *State) GetIntegrityReport() interface{} {
+	if s.IntegrityVerifier == nil {
+		return nil
+	}
+	return s.IntegrityVerifier.Report()
+}
+
+func (v *IntegrityVerifier) Run() {
+	for {
+		v.mutex.Lock()
+		h := v.next
+		v.mutex.Unlock()
+
+		if h > v.state.GetHighestSavedBlk() || v.state.GetHighestSavedBlk() == 0 {
+			v.mutex.Lock()
+			v.next = 0
+			v.passes++
+			v.mutex.Unlock()
+			time.Sleep(v.PassDelay)
+			continue
+		}
+
+		found, keyMR, err := v.CheckHeight(h)
+		if err != nil {
+			// A database error is not a corruption, try this height again later
+			v.state.LogPrintf("integrity", "Checking height %d failed: %v", h, err)
+			time.Sleep(time.Minute)
+			continue
+		}
+		v.record(h, found, keyMR)
+		v.retryRepairs()
+
+		IntegrityCheckedHeight.Set(float64(h))
+		time.Sleep(v.Delay)
+	}
+}
+
+// CheckHeight checks the blocks of a height.  Returns the corruptions found and the KeyMR the
+// directory block is indexed under.
+func (v *IntegrityVerifier) CheckHeight(h uint32) ([]Corruption, interfaces.IHash, error) {
+	db := v.state.DB
+	found := []Corruption{}
+	report := func(block string, hash interfaces.IHash, format string, args ...interface{}) {
+		c := Corruption{Height: h, Block: block, Problem: fmt.Sprintf(format, args...), Found: time.Now()}
+		if hash != nil {
+			c.Hash = hash.String()
+		}
+		found = append(found, c)
+	}
+	unread := func(block string, hash interfaces.IHash, err error) {
+		report(block, hash, "can't be read: %v", err)
+		found[len(found)-1].Unread = true
+	}
+
+	keyMR, err := db.FetchDBKeyMRByHeight(h)
+	if err != nil {
+		return nil, nil, err
+	}
+	if keyMR == nil {
+		report("DBlock", nil, "no directory block is indexed at this height")
+		return found, nil, nil
+	}
+	dblock, err := db.FetchDBlock(keyMR)
+	if err != nil {
+		unread("DBlock", keyMR, err)
+		return found, keyMR, nil
+	}
+	if dblock == nil {
+		report("DBlock", keyMR, "directory block is missing")
+		return found, keyMR, nil
+	}
+
+	storedBodyMR := dblock.GetHeader().GetBodyMR().Copy()
+	bodyMR, err := dblock.BuildBodyMR()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bodyMR.IsSameAs(storedBodyMR) {
+		report("DBlock", keyMR, "body Merkle root is %s, the header has %s", bodyMR.String(), storedBodyMR.String())
+	}
+	if !dblock.GetKeyMR().IsSameAs(keyMR) {
+		report("DBlock", keyMR, "KeyMR recomputes to %s", dblock.GetKeyMR().String())
+	}
+	if h == 0 {
+		if !dblock.GetHeader().GetPrevKeyMR().IsZero() {
+			report("DBlock", keyMR, "the genesis block has a PrevKeyMR")
+		}
+	} else {
+		prevKeyMR, err := db.FetchDBKeyMRByHeight(h - 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if prevKeyMR != nil && !dblock.GetHeader().GetPrevKeyMR().IsSameAs(prevKeyMR) {
+			report("DBlock", keyMR, "PrevKeyMR %s does not match directory block %d %s", dblock.GetHeader().GetPrevKeyMR().String(), h-1, prevKeyMR.String())
+		}
+	}
+	if len(found) > 0 {
+		// The blocks a corrupted directory block references can't be trusted
+		return found, keyMR, nil
+	}
+
+	for _, e := range dblock.GetDBEntries() {
+		chainID := e.GetChainID().Bytes()
+		switch {
+		case bytes.Equal(chainID, constants.ADMIN_CHAINID), bytes.Equal(chainID, constants.EC_CHAINID):
+			continue
+		case bytes.Equal(chainID, constants.FACTOID_CHAINID):
+			err = v.checkFBlock(h, e.GetKeyMR(), report, unread)
+		default:
+			err = v.checkEBlock(h, e.GetKeyMR(), report, unread)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return found, keyMR, nil
+}
+
+func (v *IntegrityVerifier) checkFBlock(h uint32, keyMR interfaces.IHash, report func(string, interfaces.IHash, string, ...interface{}), unread func(string, interfaces.IHash, error)) error {
+	db := v.state.DB
+	fblock, err := db.FetchFBlock(keyMR)
+	if err != nil {
+		unread("FBlock", keyMR, err)
+		return nil
+	}
+	if fblock == nil {
+		report("FBlock", keyMR, "factoid block is missing")
+		return nil
+	}
+
+	if fb, ok := fblock.(*factoid.FBlock); ok && fb.BodyMR != nil {
+		storedBodyMR := fb.BodyMR.Copy()
+		fb.CalculateHashes()
+		if !fb.BodyMR.IsSameAs(storedBodyMR) {
+			report("FBlock", keyMR, "body Merkle root is %s, the header has %s", fb.BodyMR.String(), storedBodyMR.String())
+		}
+	}
+	if !fblock.GetKeyMR().IsSameAs(keyMR) {
+		report("FBlock", keyMR, "KeyMR recomputes to %s", fblock.GetKeyMR().String())
+	}
+
+	if h == 0 {
+		return nil
+	}
+	prev, err := db.FetchFBlock(fblock.GetPrevKeyMR())
+	if err != nil {
+		unread("FBlock", fblock.GetPrevKeyMR(), err)
+	} else if prev == nil || prev.GetDBHeight() != h-1 {
+		report("FBlock", keyMR, "PrevKeyMR %s is not the factoid block of height %d", fblock.GetPrevKeyMR().String(), h-1)
+	}
+	return nil
+}
+
+func (v *IntegrityVerifier) checkEBlock(h uint32, keyMR interfaces.IHash, report func(string, interfaces.IHash, string, ...interface{}), unread func(string, interfaces.IHash, error)) error {
+	db := v.state.DB
+	eblock, err := db.FetchEBlock(keyMR)
+	if err != nil {
+		unread("EBlock", keyMR, err)
+		return nil
+	}
+	if eblock == nil {
+		report("EBlock", keyMR, "entry block is missing")
+		return nil
+	}
+
+	storedBodyMR := eblock.GetHeader().GetBodyMR().Copy()
+	bodyMR := eblock.GetBody().MR()
+	if !bodyMR.IsSameAs(storedBodyMR) {
+		report("EBlock", keyMR, "body Merkle root is %s, the header has %s", bodyMR.String(), storedBodyMR.String())
+	}
+	computed, err := eblock.KeyMR()
+	if err != nil {
+		return err
+	}
+	if !computed.IsSameAs(keyMR) {
+		report("EBlock", keyMR, "KeyMR recomputes to %s", computed.String())
+	}
+
+	prevKeyMR := eblock.GetHeader().GetPrevKeyMR()
+	if !prevKeyMR.IsZero() {
+		prev, err := db.FetchEBlock(prevKeyMR)
+		if err != nil {
+			unread("EBlock", prevKeyMR, err)
+		} else if prev == nil {
+			report("EBlock", keyMR, "previous entry block %s is missing", prevKeyMR.String())
+		} else if !prev.GetChainID().IsSameAs(eblock.GetChainID()) || prev.GetHeader().GetEBSequence()+1 != eblock.GetHeader().GetEBSequence() {
+			report("EBlock", keyMR, "PrevKeyMR %s is not the previous entry block of the chain", prevKeyMR.String())
+		}
+	}
+
+	for _, hash := range eblock.GetEntryHashes() {
+		if hash.IsMinuteMarker() {
+			continue
+		}
+		entry, err := db.FetchEntry(hash)
+		if err != nil {
+			unread("Entry", hash, err)
+		} else if entry == nil {
+			report("Entry", hash, "entry of entry block %s is missing", keyMR.String())
+		} else if !entry.GetHash().IsSameAs(hash) {
+			report("Entry", hash, "entry hashes to %s", entry.GetHash().String())
+		}
+	}
+	return nil
+}
+
+// record saves the corruptions found at a height, and requests the corrupted data from peers
+func (v *IntegrityVerifier) record(h uint32, found []Corruption, keyMR interfaces.IHash) {
+	for _, c := range found {
+		v.state.LogPrintf("integrity", "Corruption at height %d: %s %s %s", c.Height, c.Block, c.Hash, c.Problem)
+		IntegrityCorruptions.WithLabelValues(c.Block).Inc()
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.next = h + 1
+	v.checked++
+	v.corruptions = append(v.corruptions, found...)
+	if len(v.corruptions) > maxCorruptions {
+		v.corruptions = v.corruptions[len(v.corruptions)-maxCorruptions:]
+	}
+
+	if !v.Refetch || len(found) == 0 {
+		return
+	}
+	var repair *pendingRepair
+	for _, c := range found {
+		if c.Unread {
+			// A read error is not a corruption to repair, refetching could destroy good data
+			continue
+		}
+		if c.Block == "Entry" {
+			v.refetchEntry(c)
+			continue
+		}
+		if keyMR == nil {
+			// Without the KeyMR of the directory block, a block from a peer can't be verified
+			continue
+		}
+		if repair == nil {
+			repair = &pendingRepair{keyMR: keyMR, eblocks: map[[32]byte]bool{}}
+		}
+		switch c.Block {
+		case "DBlock":
+			repair.dblock = true
+		case "FBlock":
+			repair.fblock = true
+		case "EBlock":
+			hash, err := primitives.NewShaHashFromStr(c.Hash)
+			if err == nil {
+				repair.eblocks[hash.Fixed()] = true
+			}
+		}
+	}
+	if repair != nil {
+		v.repairs[h] = repair
+		v.requestDBState(h, repair)
+	}
+}
+
+// refetchEntry adds a missing or corrupted entry to the missing entries, dropping it if it does
+// not match its hash.  Must hold the lock.
+func (v *IntegrityVerifier) refetchEntry(c Corruption) {
+	hash, err := primitives.NewShaHashFromStr(c.Hash)
+	if err != nil {
+		return
+	}
+	// Check again, so only an entry that is read and found wrong is dropped
+	entry, err := v.state.DB.FetchEntry(hash)
+	if err != nil {
+		return
+	}
+	if entry != nil {
+		if entry.GetHash().IsSameAs(hash) {
+			return
+		}
+		if db, ok := v.state.DB.(*databaseOverlay.Overlay); ok {
+			db.Delete(databaseOverlay.ENTRY, hash.Bytes())
+		}
+	}
+	select {
+	case v.state.MissingEntries <- &MissingEntry{DBHeight: c.Height, EntryHash: hash}:
+	default:
+		// The missing entry requests are backed up, the next pass will find it again
+	}
+}
+
+// requestDBState asks peers for the DBState of a height.  Must hold the lock.
+func (v *IntegrityVerifier) requestDBState(h uint32, repair *pendingRepair) {
+	repair.requested = time.Now()
+	repair.attempts++
+	msg := messages.NewDBStateMissing(v.state, h, h)
+	msg.SendOut(v.state, msg)
+}
+
+// retryRepairs requests the DBStates that did not arrive again, giving up after a few attempts
+func (v *IntegrityVerifier) retryRepairs() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for h, repair := range v.repairs {
+		if time.Since(repair.requested) < time.Minute {
+			continue
+		}
+		if repair.attempts >= 10 {
+			delete(v.repairs, h)
+			continue
+		}
+		v.requestDBState(h, repair)
+	}
+}
+
+// Repair saves the corrupted blocks of a height from a DBState sent by a peer.  DBStates below
+// the highest saved block are otherwise dropped, so this returns true if the DBState was taken.
+func (v *IntegrityVerifier) Repair(msg *messages.DBStateMsg) bool {
+	if msg.DirectoryBlock == nil {
+		return false
+	}
+	h := msg.DirectoryBlock.GetDatabaseHeight()
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	repair, ok := v.repairs[h]
+	if !ok || !msg.DirectoryBlock.GetKeyMR().IsSameAs(repair.keyMR) {
+		return false
+	}
+	db, ok := v.state.DB.(*databaseOverlay.Overlay)
+	if !ok {
+		return false
+	}
+
+	// The directory block matches the KeyMR it is indexed under, so the KeyMRs it holds can be trusted
+	var err error
+	if repair.dblock {
+		err = db.ProcessDBlockBatchWithoutHead(msg.DirectoryBlock)
+	}
+	for _, e := range msg.DirectoryBlock.GetDBEntries() {
+		if err != nil {
+			break
+		}
+		switch {
+		case bytes.Equal(e.GetChainID().Bytes(), constants.FACTOID_CHAINID):
+			if repair.fblock && msg.FactoidBlock != nil && msg.FactoidBlock.GetKeyMR().IsSameAs(e.GetKeyMR()) {
+				err = db.ProcessFBlockBatchWithoutHead(msg.FactoidBlock)
+			}
+		case repair.eblocks[e.GetKeyMR().Fixed()]:
+			for _, eblock := range msg.EBlocks {
+				keyMR, _ := eblock.KeyMR()
+				if keyMR != nil && keyMR.IsSameAs(e.GetKeyMR()) {
+					err = db.ProcessEBlockBatchWithoutHead(eblock, true)
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		v.state.LogPrintf("integrity", "Repairing height %d failed: %v", h, err)
+		return true
+	}
+
+	delete(v.repairs, h)
+	for i := range v.corruptions {
+		if v.corruptions[i].Height == h && v.corruptions[i].Block != "Entry" {
+			v.corruptions[i].Repaired = true
+		}
+	}
+	IntegrityRepairs.Inc()
+	v.state.LogPrintf("integrity", "Repaired height %d from a peer", h)
+	return true
+}
+
+// Report returns the state of the verifier
+func (v *IntegrityVerifier) Report() *IntegrityReport {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	r := new(IntegrityReport)
+	r.NextHeight = v.next
+	r.HighestSaved = v.state.GetHighestSavedBlk()
+	r.Passes = v.passes
+	r.HeightsChecked = v.checked
+	r.Refetch = v.Refetch
+	r.PendingRepairs = []uint32{}
+	for h := range v.repairs {
+		r.PendingRepairs = append(r.PendingRepairs, h)
+	}
+	r.Corruptions = append([]Corruption{}, v.corruptions...)
+	return r
+}
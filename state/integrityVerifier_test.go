@@ -0,0 +1,107 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestIntegrityVerifierCleanDatabase(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	v := NewIntegrityVerifier(s, 0, false)
+
+	for h := uint32(0); h < uint32(testHelper.BlockCount); h++ {
+		found, keyMR, err := v.CheckHeight(h)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if keyMR == nil {
+			t.Errorf("No KeyMR returned for height %d", h)
+		}
+		if len(found) > 0 {
+			t.Errorf("Found corruptions at height %d of a clean database: %v", h, found)
+		}
+	}
+}
+
+func TestIntegrityVerifierMissingEntry(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	db := s.DB.(*databaseOverlay.Overlay)
+	v := NewIntegrityVerifier(s, 0, false)
+
+	// Delete the first entry of an entry block at height 1
+	dblock, err := db.FetchDBlockByHeight(1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var deleted interfaces.IHash
+	for _, e := range dblock.GetDBEntries() {
+		eblock, err := db.FetchEBlock(e.GetKeyMR())
+		if err != nil || eblock == nil {
+			continue
+		}
+		for _, hash := range eblock.GetEntryHashes() {
+			if !hash.IsMinuteMarker() {
+				deleted = hash
+				break
+			}
+		}
+		if deleted != nil {
+			err = db.Delete(eblock.GetChainID().Bytes(), deleted.Bytes())
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			break
+		}
+	}
+	if deleted == nil {
+		t.Fatalf("No entry found at height 1")
+	}
+
+	found, _, err := v.CheckHeight(1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(found) != 1 {
+		t.Fatalf("Expected 1 corruption, found %v", found)
+	}
+	if found[0].Block != "Entry" || found[0].Hash != deleted.String() {
+		t.Errorf("Wrong corruption reported: %v", found[0])
+	}
+}
+
+// failingEntryDB fails to read the entries, as a database with a transient error
+type failingEntryDB struct {
+	interfaces.DBOverlaySimple
+}
+
+func (db failingEntryDB) FetchEntry(interfaces.IHash) (interfaces.IEBEntry, error) {
+	return nil, errors.New("read error")
+}
+
+func TestIntegrityVerifierReadError(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	s.DB = failingEntryDB{s.DB}
+	v := NewIntegrityVerifier(s, 0, true)
+
+	found, _, err := v.CheckHeight(1)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(found) == 0 {
+		t.Fatalf("The read errors were not reported")
+	}
+	for _, c := range found {
+		if c.Block != "Entry" || !c.Unread {
+			t.Errorf("A read error is reported as a corruption: %v", c)
+		}
+	}
+}
@@ -44,9 +44,10 @@ func LoadDatabase(s *State) {
 	// prevent MMR processing from happening for blocks being loaded from the database
 	s.DBHeightAtBoot = blkCnt
 
+	// The speed of the load is that of the machine, measured in real time
 	first := time.Now()
 	last := first
-	time.Sleep(time.Second)
+	primitives.Sleep(time.Second)
 
 	//msg, err := s.LoadDBState(blkCnt)
 	start := s.GetDBHeightComplete()
@@ -93,7 +94,7 @@ func LoadDatabase(s *State) {
 			s.InMsgQueue().Enqueue(msg)
 			if s.InMsgQueue().Length() > 200 || len(s.DBStatesReceived) > 50 {
 				for s.InMsgQueue().Length() > 50 || len(s.DBStatesReceived) > 50 {
-					time.Sleep(100 * time.Millisecond)
+					primitives.Sleep(100 * time.Millisecond)
 				}
 			}
 		} else {
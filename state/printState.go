@@ -40,10 +40,13 @@ func PrintState(state *State) {
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LogPath", state.LogPath)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LdbPath", state.LdbPath)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "BoltDBPath", state.BoltDBPath)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "BadgerDBPath", state.BadgerDBPath)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LogLevel", state.LogLevel)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "ConsoleLogLevel", state.ConsoleLogLevel)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "NodeMode", state.NodeMode)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "DBType", state.DBType)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "DBCacheSize", state.DBCacheSize)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "SecureDBPassphraseFile", state.SecureDBPassphraseFile)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CloneDBType", state.CloneDBType)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "ExportData", state.ExportData)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "ExportDataSubpath", state.ExportDataSubpath)
@@ -60,15 +63,19 @@ func PrintState(state *State) {
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "MainNetworkPort", state.MainNetworkPort)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "PeersFile", state.PeersFile)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "MainSeedURL", state.MainSeedURL)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "MainSeedPublicKey", state.MainSeedPublicKey)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "MainSpecialPeers", state.MainSpecialPeers)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "TestNetworkPort", state.TestNetworkPort)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "TestSeedURL", state.TestSeedURL)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "TestSeedPublicKey", state.TestSeedPublicKey)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "TestSpecialPeers", state.TestSpecialPeers)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LocalNetworkPort", state.LocalNetworkPort)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LocalSeedURL", state.LocalSeedURL)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "LocalSeedPublicKey", state.LocalSeedPublicKey)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LocalSpecialPeers", state.LocalSpecialPeers)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomNetworkPort", state.CustomNetworkPort)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomSeedURL", state.CustomSeedURL)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomSeedPublicKey", state.CustomSeedPublicKey)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomSpecialPeers", state.CustomSpecialPeers)
 	str = fmt.Sprintf("%s %35s = %+v(%s)\n", str, "CustomNetworkID", state.CustomNetworkID, globals.Params.CustomNetName)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "IdentityChainID", state.IdentityChainID)
@@ -0,0 +1,106 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/badgerdb"
+	"github.com/FactomProject/factomd/database/boltdb"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/leveldb"
+	"github.com/FactomProject/factomd/database/securedb"
+)
+
+// MigrateSchema runs the pending schema migrations of the database.  With dryRun the pending
+// migrations are listed and the node exits.  If backupDir is set, the database is copied into a
+// new database of the same type there before any migration runs.
+func (s *State) MigrateSchema(dryRun bool, backupDir string) error {
+	db, ok := s.DB.(*databaseOverlay.Overlay)
+	if !ok {
+		return nil
+	}
+
+	options := databaseOverlay.MigrationOptions{DryRun: dryRun}
+	if dryRun {
+		pending, err := db.MigrateSchema(options)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d database schema migrations pending, this binary is at schema version %d\n", len(pending), databaseOverlay.SchemaVersion())
+		for _, m := range pending {
+			fmt.Printf("  %d: %s\n", m.Version, m.Description)
+		}
+		os.Exit(0)
+	}
+
+	if backupDir != "" {
+		version, err := db.FetchSchemaVersion()
+		if err != nil {
+			return err
+		}
+		if len(databaseOverlay.PendingMigrations(version)) > 0 {
+			backup, err := s.openBackupDB(backupDir)
+			if err != nil {
+				return fmt.Errorf("opening the backup database failed: %v", err)
+			}
+			defer backup.Close()
+			options.Backup = backup
+		}
+	}
+
+	migrated, err := db.MigrateSchema(options)
+	if err != nil {
+		return err
+	}
+	if len(migrated) > 0 {
+		fmt.Fprintf(os.Stderr, "%20s Database migrated to schema version %d\n", s.FactomNodeName, databaseOverlay.SchemaVersion())
+	}
+	return nil
+}
+
+// openBackupDB creates a new database of the node's database type in dir.  The backup of an
+// encrypted database is encrypted with the same passphrase.
+func (s *State) openBackupDB(dir string) (interfaces.IDatabase, error) {
+	dbtype, secure := securedb.SecureDBTypes[s.DBType]
+	if !secure {
+		dbtype = s.DBType
+	}
+
+	var path string
+	switch dbtype {
+	case "LDB":
+		path = dir + "/factoid_level.db"
+	case "Bolt":
+		path = dir + "/FactomBolt.db"
+	case "Badger":
+		path = dir + "/factoid_badger.db"
+	default:
+		return nil, fmt.Errorf("can't back up a %s database", s.DBType)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%s already exists", path)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if secure {
+		passphrase, err := securedb.LoadPassphrase(s.SecureDBPassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return securedb.NewEncryptedDB(path, dbtype, passphrase)
+	}
+	switch dbtype {
+	case "LDB":
+		return leveldb.NewLevelDB(path, true)
+	case "Bolt":
+		return boltdb.NewBoltDB(nil, path), nil
+	}
+	return badgerdb.NewBadgerDB(path, true)
+}
@@ -0,0 +1,191 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/snapshot"
+)
+
+// SnapshotExport is a snapshot of the database being written in the background, in a directory
+// of the snapshot path of the node
+type SnapshotExport struct {
+	ID       int
+	Name     string // directory of the snapshot in the snapshot path
+	Height   uint32
+	Started  time.Time
+	Finished time.Time `json:",omitempty"`
+	Done     bool
+	Error    string             `json:",omitempty"`
+	Manifest *snapshot.Manifest `json:",omitempty"`
+
+	mutex sync.Mutex
+}
+
+var snapshotExportMutex sync.Mutex
+
+// CreateSnapshot starts writing a snapshot of the database up to height in the background, in
+// the directory name of the snapshot path, and returns the new *SnapshotExport.  A height of 0
+// takes the snapshot at the highest saved block.  Only one export can run at a time.
+//
+// With fastboot enabled the snapshot holds the fastboot file, read under the lock of the state
+// saver.  The snapshot is then taken at the height of the fastboot file, and any other height
+// is refused.
+func (s *State) CreateSnapshot(name string, height uint32) (interface{}, error) {
+	if s.SnapshotPath == "" {
+		return nil, fmt.Errorf("no snapshot path is configured")
+	}
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid snapshot name %q, it must be a directory of the snapshot path", name)
+	}
+
+	var fb *snapshot.FastBoot
+	if s.StateSaverStruct.FastBoot {
+		fb = s.snapshotFastBoot()
+	}
+	highest := s.GetHighestSavedBlk()
+	if fb != nil {
+		if height != 0 && height != fb.Height {
+			return nil, fmt.Errorf("the fastboot file is at height %d, the snapshot must be taken at that height", fb.Height)
+		}
+		height = fb.Height
+	}
+	if height == 0 {
+		height = highest
+	}
+	if height > highest {
+		return nil, fmt.Errorf("height %d is above the highest saved block %d", height, highest)
+	}
+
+	snapshotExportMutex.Lock()
+	defer snapshotExportMutex.Unlock()
+	id := 1
+	if s.SnapshotExport != nil {
+		if !s.SnapshotExport.Report().Done {
+			return nil, fmt.Errorf("snapshot export %d is still running", s.SnapshotExport.ID)
+		}
+		id = s.SnapshotExport.ID + 1
+	}
+
+	e := new(SnapshotExport)
+	e.ID = id
+	e.Name = name
+	e.Height = height
+	e.Started = time.Now()
+	s.SnapshotExport = e
+
+	go e.run(s, filepath.Join(s.SnapshotPath, name), fb)
+	return e.Report(), nil
+}
+
+// GetSnapshotExport returns the *SnapshotExport of the last snapshot export, or nil if none was
+// started
+func (s *State) GetSnapshotExport() interface{} {
+	snapshotExportMutex.Lock()
+	e := s.SnapshotExport
+	snapshotExportMutex.Unlock()
+	if e == nil {
+		return nil
+	}
+	return e.Report()
+}
+
+func (e *SnapshotExport) run(s *State, dir string, fb *snapshot.FastBoot) {
+	m, err := snapshot.Create(s.DB, dir, s.Network, e.Height, fb)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.Manifest = m
+	e.Finished = time.Now()
+	e.Done = true
+}
+
+// Report returns a copy of the export
+func (e *SnapshotExport) Report() *SnapshotExport {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	report := new(SnapshotExport)
+	report.ID = e.ID
+	report.Name = e.Name
+	report.Height = e.Height
+	report.Started = e.Started
+	report.Finished = e.Finished
+	report.Done = e.Done
+	report.Error = e.Error
+	report.Manifest = e.Manifest
+	return report
+}
+
+// snapshotFastBoot returns the fastboot file if it is valid, or nil.  The file is read under the
+// lock of the state saver, so it can't be caught while it is being rewritten.
+func (s *State) snapshotFastBoot() *snapshot.FastBoot {
+	filename := NetworkIDToFilename(s.Network, s.StateSaverStruct.FastBootLocation)
+	s.StateSaverStruct.Mutex.Lock()
+	b, err := ioutil.ReadFile(filename)
+	s.StateSaverStruct.Mutex.Unlock()
+	if err != nil {
+		return nil
+	}
+	h := primitives.NewZeroHash()
+	data, err := h.UnmarshalBinaryData(b)
+	if err != nil || !h.IsSameAs(primitives.Sha(data)) {
+		return nil
+	}
+
+	// The height of the fastboot file is the height of the last DBState it saved
+	statelist := new(DBStateList)
+	statelist.State = s
+	if err := statelist.UnmarshalBinary(data); err != nil {
+		return nil
+	}
+	for i := len(statelist.DBStates) - 1; i >= 0; i-- {
+		d := statelist.DBStates[i]
+		if d.SaveStruct == nil || d.DirectoryBlock == nil {
+			continue
+		}
+		fbHeight := d.DirectoryBlock.GetHeader().GetDBHeight()
+		return &snapshot.FastBoot{Filename: filepath.Base(filename), Height: fbHeight, Data: b}
+	}
+	return nil
+}
+
+// ImportSnapshot verifies the snapshot in dir and imports its blocks into the database, which
+// must be empty.  If trustedKeyMR is set the snapshot must end at that directory block.
+func (s *State) ImportSnapshot(dir string, trustedKeyMR string) error {
+	m, err := snapshot.ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+	if m.Network != s.Network {
+		return fmt.Errorf("the snapshot is for network %s, the node is on %s", m.Network, s.Network)
+	}
+
+	fmt.Fprintf(os.Stderr, "%20s Importing snapshot %s\n", s.FactomNodeName, dir)
+	m, err = snapshot.Import(s.DB, dir, trustedKeyMR)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%20s Imported snapshot up to directory block %d %s\n", s.FactomNodeName, m.Height, m.HeadKeyMR)
+
+	if m.FastBootFile == "" || !s.StateSaverStruct.FastBoot {
+		return nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, m.FastBootFile))
+	if err != nil {
+		return err
+	}
+	return SaveToFile(s, m.FastBootHeight, b, NetworkIDToFilename(s.Network, s.StateSaverStruct.FastBootLocation))
+}
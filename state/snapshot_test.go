@@ -0,0 +1,69 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/database/snapshot"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func waitForSnapshotExport(t *testing.T, s *State) *SnapshotExport {
+	for i := 0; i < 500; i++ {
+		e := s.GetSnapshotExport().(*SnapshotExport)
+		if e.Done {
+			if e.Error != "" {
+				t.Fatalf("%v", e.Error)
+			}
+			return e
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("The snapshot export did not finish")
+	return nil
+}
+
+func TestCreateSnapshot(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	s.StateSaverStruct.FastBoot = false
+
+	if _, err := s.CreateSnapshot("snap", 0); err == nil {
+		t.Errorf("Snapshots should be refused without a snapshot path")
+	}
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+	s.SnapshotPath = dir
+
+	for _, name := range []string{"", ".", "..", "../snap", "a/b", dir} {
+		if _, err := s.CreateSnapshot(name, 0); err == nil {
+			t.Errorf("The snapshot name %q should be refused", name)
+		}
+	}
+	if s.GetSnapshotExport() != nil {
+		t.Errorf("A refused snapshot should not start an export")
+	}
+
+	_, err = s.CreateSnapshot("snap", 0)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	e := waitForSnapshotExport(t, s)
+	if e.Height != s.GetHighestSavedBlk() || e.Manifest == nil || e.Manifest.Height != e.Height {
+		t.Errorf("Wrong export %+v", e)
+	}
+	if _, err := snapshot.ReadManifest(filepath.Join(dir, "snap")); err != nil {
+		t.Errorf("The snapshot was not written in the snapshot path: %v", err)
+	}
+}
@@ -27,10 +27,12 @@ import (
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/messages"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/databaseOverlay"
 	"github.com/FactomProject/factomd/database/leveldb"
 	"github.com/FactomProject/factomd/database/mapdb"
+	"github.com/FactomProject/factomd/database/securedb"
 	"github.com/FactomProject/factomd/p2p"
 	"github.com/FactomProject/factomd/util"
 	"github.com/FactomProject/factomd/util/atomic"
@@ -51,6 +53,8 @@ type State struct {
 	Logger            *log.Entry
 	IsRunning         bool
 	NetworkController *p2p.Controller
+	LoadGenerator     interfaces.ILoadGenerator // of the simulation, nil if there is none
+	SimLinks          interfaces.ISimLinks      // of the simulation, nil if there are none
 	Salt              interfaces.IHash
 	Cfg               interfaces.IFactomConfig
 	ConfigFilePath    string // $HOME/.factom/m2/factomd.conf by default
@@ -61,10 +65,13 @@ type State struct {
 	LogPath         string
 	LdbPath         string
 	BoltDBPath      string
+	BadgerDBPath    string
 	LogLevel        string
 	ConsoleLogLevel string
 	NodeMode        string
 	DBType          string
+	DBCacheSize     int    // megabytes
+	SnapshotPath    string // directory of the snapshots created through the API, empty to disable them
 	CheckChainHeads struct {
 		CheckChainHeads bool
 		Fix             bool
@@ -73,6 +80,8 @@ type State struct {
 	ExportData        bool
 	ExportDataSubpath string
 
+	SecureDBPassphraseFile string // passphrase of the Secure* database types, empty to read it from the environment
+
 	LogBits int64 // Bit zero is for logging the Directory Block on DBSig [5]
 
 	DBStatesSent            []*interfaces.DBStateSent
@@ -99,19 +108,25 @@ type State struct {
 	MainNetworkPort         string
 	PeersFile               string
 	MainSeedURL             string
+	MainSeedPublicKey       string
 	MainSpecialPeers        string
 	TestNetworkPort         string
 	TestSeedURL             string
+	TestSeedPublicKey       string
 	TestSpecialPeers        string
 	LocalNetworkPort        string
 	LocalSeedURL            string
+	LocalSeedPublicKey      string
 	LocalSpecialPeers       string
 	CustomNetworkPort       string
 	CustomSeedURL           string
+	CustomSeedPublicKey     string
 	CustomSpecialPeers      string
 	CustomNetworkID         []byte
 	CustomBootstrapIdentity string
 	CustomBootstrapKey      string
+	BanDuration             time.Duration // How long to ban misbehaving peers
+	P2PListeners            []string      // Interface/port addresses the p2p network listens on
 
 	IdentityChainID interfaces.IHash // If this node has an identity, this is it
 	//Identities      []*Identity      // Identities of all servers in management chain
@@ -290,6 +305,15 @@ type State struct {
 	DB     interfaces.DBOverlaySimple
 	Anchor interfaces.IAnchor
 
+	// Background verifier of the saved blocks, nil unless it was started
+	IntegrityVerifier *IntegrityVerifier
+	// Last chain head repair started through the debug API, nil if there was none
+	ChainHeadRepair *ChainHeadRepair
+	// Last snapshot export started through the debug API, nil if there was none
+	SnapshotExport *SnapshotExport
+	// Server faults and elections, created by GetFaultHistory
+	FaultHistory *FaultHistory
+
 	// Directory Block State
 	DBStates *DBStateList // Holds all DBStates not yet processed.
 
@@ -463,11 +487,15 @@ func (s *State) Clone(cloneNumber int) interfaces.IState {
 	newState.JournalFile = s.LogPath + "/journal" + number + ".log"
 	newState.Journaling = s.Journaling
 	newState.BoltDBPath = s.BoltDBPath + "/Sim" + number
+	newState.BadgerDBPath = s.BadgerDBPath + "/Sim" + number
 	newState.LogLevel = s.LogLevel
 	newState.ConsoleLogLevel = s.ConsoleLogLevel
 	newState.NodeMode = "FULL"
 	newState.CloneDBType = s.CloneDBType
 	newState.DBType = s.CloneDBType
+	newState.DBCacheSize = s.DBCacheSize
+	newState.SnapshotPath = s.SnapshotPath
+	newState.SecureDBPassphraseFile = s.SecureDBPassphraseFile
 	newState.CheckChainHeads = s.CheckChainHeads
 	newState.ExportData = s.ExportData
 	newState.ExportDataSubpath = s.ExportDataSubpath + "sim-" + number
@@ -475,16 +503,22 @@ func (s *State) Clone(cloneNumber int) interfaces.IState {
 	newState.MainNetworkPort = s.MainNetworkPort
 	newState.PeersFile = s.PeersFile
 	newState.MainSeedURL = s.MainSeedURL
+	newState.MainSeedPublicKey = s.MainSeedPublicKey
 	newState.MainSpecialPeers = s.MainSpecialPeers
 	newState.TestNetworkPort = s.TestNetworkPort
 	newState.TestSeedURL = s.TestSeedURL
+	newState.TestSeedPublicKey = s.TestSeedPublicKey
 	newState.TestSpecialPeers = s.TestSpecialPeers
 	newState.LocalNetworkPort = s.LocalNetworkPort
 	newState.LocalSeedURL = s.LocalSeedURL
+	newState.LocalSeedPublicKey = s.LocalSeedPublicKey
 	newState.LocalSpecialPeers = s.LocalSpecialPeers
 	newState.CustomNetworkPort = s.CustomNetworkPort
 	newState.CustomSeedURL = s.CustomSeedURL
+	newState.CustomSeedPublicKey = s.CustomSeedPublicKey
 	newState.CustomSpecialPeers = s.CustomSpecialPeers
+	newState.BanDuration = s.BanDuration
+	newState.P2PListeners = s.P2PListeners
 	newState.StartDelayLimit = s.StartDelayLimit
 	newState.CustomNetworkID = s.CustomNetworkID
 	newState.CustomBootstrapIdentity = s.CustomBootstrapIdentity
@@ -538,14 +572,18 @@ func (s *State) Clone(cloneNumber int) interfaces.IState {
 	newState.FastSaveRate = s.FastSaveRate
 	newState.CorsDomains = s.CorsDomains
 	switch newState.DBType {
-	case "LDB":
+	case "LDB", "SecureLDB":
 		newState.StateSaverStruct.FastBoot = s.StateSaverStruct.FastBoot
 		newState.StateSaverStruct.FastBootLocation = newState.LdbPath
 		break
-	case "Bolt":
+	case "Bolt", "SecureBolt":
 		newState.StateSaverStruct.FastBoot = s.StateSaverStruct.FastBoot
 		newState.StateSaverStruct.FastBootLocation = newState.BoltDBPath
 		break
+	case "Badger", "SecureBadger":
+		newState.StateSaverStruct.FastBoot = s.StateSaverStruct.FastBoot
+		newState.StateSaverStruct.FastBootLocation = newState.BadgerDBPath
+		break
 	}
 	if globals.Params.WriteProcessedDBStates {
 		path := filepath.Join(newState.LdbPath, newState.Network, "dbstates")
@@ -663,7 +701,7 @@ func (s *State) GetPreviousMinuteStartTime() int64 {
 }
 
 func (s *State) GetCurrentTime() int64 {
-	return time.Now().UnixNano()
+	return primitives.Now().UnixNano()
 }
 
 func (s *State) IsSyncing() bool {
@@ -730,6 +768,7 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		// TODO: improve the paths after milestone 1
 		cfg.App.LdbPath = cfg.App.HomeDir + networkName + cfg.App.LdbPath
 		cfg.App.BoltDBPath = cfg.App.HomeDir + networkName + cfg.App.BoltDBPath
+		cfg.App.BadgerDBPath = cfg.App.HomeDir + networkName + cfg.App.BadgerDBPath
 		cfg.App.DataStorePath = cfg.App.HomeDir + networkName + cfg.App.DataStorePath
 		cfg.Log.LogPath = cfg.App.HomeDir + networkName + cfg.Log.LogPath
 		cfg.App.ExportDataSubpath = cfg.App.HomeDir + networkName + cfg.App.ExportDataSubpath
@@ -739,28 +778,38 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		s.LogPath = cfg.Log.LogPath + s.Prefix
 		s.LdbPath = cfg.App.LdbPath + s.Prefix
 		s.BoltDBPath = cfg.App.BoltDBPath + s.Prefix
+		s.BadgerDBPath = cfg.App.BadgerDBPath + s.Prefix
 		s.LogLevel = cfg.Log.LogLevel
 		s.ConsoleLogLevel = cfg.Log.ConsoleLogLevel
 		s.NodeMode = cfg.App.NodeMode
 		s.DBType = cfg.App.DBType
+		s.DBCacheSize = cfg.App.DBCacheSize
+		s.SnapshotPath = cfg.App.SnapshotPath
+		s.SecureDBPassphraseFile = cfg.App.SecureDBPassphraseFile
 		s.ExportData = cfg.App.ExportData // bool
 		s.ExportDataSubpath = cfg.App.ExportDataSubpath
 		s.MainNetworkPort = cfg.App.MainNetworkPort
 		s.PeersFile = cfg.App.PeersFile
 		s.MainSeedURL = cfg.App.MainSeedURL
+		s.MainSeedPublicKey = cfg.App.MainSeedPublicKey
 		s.MainSpecialPeers = cfg.App.MainSpecialPeers
 		s.TestNetworkPort = cfg.App.TestNetworkPort
 		s.TestSeedURL = cfg.App.TestSeedURL
+		s.TestSeedPublicKey = cfg.App.TestSeedPublicKey
 		s.TestSpecialPeers = cfg.App.TestSpecialPeers
 		s.CustomBootstrapIdentity = cfg.App.CustomBootstrapIdentity
 		s.CustomBootstrapKey = cfg.App.CustomBootstrapKey
 		s.LocalNetworkPort = cfg.App.LocalNetworkPort
 		s.LocalSeedURL = cfg.App.LocalSeedURL
+		s.LocalSeedPublicKey = cfg.App.LocalSeedPublicKey
 		s.LocalSpecialPeers = cfg.App.LocalSpecialPeers
 		s.LocalServerPrivKey = cfg.App.LocalServerPrivKey
 		s.CustomNetworkPort = cfg.App.CustomNetworkPort
 		s.CustomSeedURL = cfg.App.CustomSeedURL
+		s.CustomSeedPublicKey = cfg.App.CustomSeedPublicKey
 		s.CustomSpecialPeers = cfg.App.CustomSpecialPeers
+		s.BanDuration = cfg.Peer.BanDuration
+		s.P2PListeners = cfg.Peer.Listeners
 		s.FactoshisPerEC = cfg.App.ExchangeRate
 		s.DirectoryBlockInSeconds = cfg.App.DirectoryBlockInSeconds
 		s.PortNumber = cfg.App.PortNumber
@@ -821,6 +870,7 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		s.LogPath = "database/"
 		s.LdbPath = "database/ldb"
 		s.BoltDBPath = "database/bolt"
+		s.BadgerDBPath = "database/badger"
 		s.LogLevel = "none"
 		s.ConsoleLogLevel = "standard"
 		s.NodeMode = "SERVER"
@@ -990,6 +1040,14 @@ func (s *State) Init() {
 		if err := s.InitBoltDB(); err != nil {
 			panic(fmt.Sprintf("Error initializing the database: %v", err))
 		}
+	case "Badger":
+		if err := s.InitBadgerDB(); err != nil {
+			panic(fmt.Sprintf("Error initializing the database: %v", err))
+		}
+	case "SecureLDB", "SecureBolt", "SecureBadger":
+		if err := s.InitSecureDB(); err != nil {
+			panic(fmt.Sprintf("Error initializing the database: %v", err))
+		}
 	case "Map":
 		if err := s.InitMapDB(); err != nil {
 			panic(fmt.Sprintf("Error initializing the database: %v", err))
@@ -998,6 +1056,16 @@ func (s *State) Init() {
 		panic("No Database type specified")
 	}
 
+	if err := s.MigrateSchema(globals.Params.SchemaDryRun, globals.Params.SchemaBackup); err != nil {
+		panic(fmt.Sprintf("Error migrating the database schema: %v", err))
+	}
+
+	if globals.Params.Snapshot != "" {
+		if err := s.ImportSnapshot(globals.Params.Snapshot, globals.Params.SnapshotKeyMR); err != nil {
+			panic(fmt.Sprintf("Error importing the snapshot: %v", err))
+		}
+	}
+
 	if s.CheckChainHeads.CheckChainHeads {
 		if s.CheckChainHeads.Fix {
 			// Set dblock head to 184 if 184 is present and head is not 184
@@ -1074,7 +1142,7 @@ func (s *State) Init() {
 		s.ExchangeRateAuthorityPublicKey = "3b6a27bcceb6a42d62a3a8d02a6f0d73653215771de243a63ac048a18b59da29"
 	}
 	// end of FER removal
-	s.Starttime = time.Now()
+	s.Starttime = primitives.Now()
 	// Allocate the MMR queues
 	s.asks = make(chan askRef, 1)
 	s.adds = make(chan plRef, 1)
@@ -1439,13 +1507,13 @@ func (s *State) LoadHoldingMap() map[[32]byte]interfaces.IMsg {
 func (s *State) fillHoldingMap() {
 	// once a second is often enough to rebuild the Ack list exposed to api
 
-	if s.HoldingLast < time.Now().Unix() {
+	if s.HoldingLast < primitives.Now().Unix() {
 
 		localMap := make(map[[32]byte]interfaces.IMsg)
 		for i, msg := range s.Holding {
 			localMap[i] = msg
 		}
-		s.HoldingLast = time.Now().Unix()
+		s.HoldingLast = primitives.Now().Unix()
 		s.HoldingMutex.Lock()
 		defer s.HoldingMutex.Unlock()
 		s.HoldingMap = localMap
@@ -1468,12 +1536,12 @@ func (s *State) LoadAcksMap() map[[32]byte]interfaces.IMsg {
 //  This is what fills the AcksMap requested in LoadAcksMap
 func (s *State) fillAcksMap() {
 	// once a second is often enough to rebuild the Ack list exposed to api
-	if s.AcksLast < time.Now().Unix() {
+	if s.AcksLast < primitives.Now().Unix() {
 		localMap := make(map[[32]byte]interfaces.IMsg)
 		for i, msg := range s.Acks {
 			localMap[i] = msg
 		}
-		s.AcksLast = time.Now().Unix()
+		s.AcksLast = primitives.Now().Unix()
 		s.AcksMutex.Lock()
 		defer s.AcksMutex.Unlock()
 		s.AcksMap = localMap
@@ -1798,9 +1866,9 @@ func (s *State) IsStalled() bool {
 
 	stalltime = float64(int64(s.GetDirectoryBlockInSeconds())) / 10
 	stalltime = stalltime * 1.5 * 1e9
-	//fmt.Println("STALL 2", s.CurrentMinuteStartTime/1e9, time.Now().UnixNano()/1e9, stalltime/1e9, (float64(time.Now().UnixNano())-stalltime)/1e9)
+	//fmt.Println("STALL 2", s.CurrentMinuteStartTime/1e9, primitives.Now().UnixNano()/1e9, stalltime/1e9, (float64(primitives.Now().UnixNano())-stalltime)/1e9)
 
-	if float64(s.CurrentMinuteStartTime) < float64(time.Now().UnixNano())-stalltime { //-90 seconds was arbitrary
+	if float64(s.CurrentMinuteStartTime) < float64(primitives.Now().UnixNano())-stalltime { //-90 seconds was arbitrary
 		return true
 	}
 
@@ -1917,7 +1985,7 @@ func (s *State) UpdateState() (progress bool) {
 	}
 
 	// Update our TPS every ~ 3 seconds at the earliest
-	if s.lasttime.Before(time.Now().Add(-3 * time.Second)) {
+	if s.lasttime.Before(primitives.Now().Add(-3 * time.Second)) {
 		s.CalculateTransactionRate()
 	}
 
@@ -2319,6 +2387,35 @@ func (s *State) GetNetworkName() string {
 	return "" // Shouldn't ever get here
 }
 
+// GetNetworkController returns the p2p network controller, or nil when the node
+// is not networked (eg: running a simulation without -enablenet)
+func (s *State) GetNetworkController() interface{} {
+	if s.NetworkController == nil {
+		return nil
+	}
+	return s.NetworkController
+}
+
+// GetLoadGenerator returns the load generator of the simulation, or nil
+func (s *State) GetLoadGenerator() interfaces.ILoadGenerator {
+	return s.LoadGenerator
+}
+
+// SetLoadGenerator lets the debug API reach the load generator of the simulation
+func (s *State) SetLoadGenerator(lg interfaces.ILoadGenerator) {
+	s.LoadGenerator = lg
+}
+
+// GetSimLinks returns the link models and partitions of the simulation, or nil
+func (s *State) GetSimLinks() interfaces.ISimLinks {
+	return s.SimLinks
+}
+
+// SetSimLinks lets the debug API reach the link models and partitions of the simulation
+func (s *State) SetSimLinks(links interfaces.ISimLinks) {
+	s.SimLinks = links
+}
+
 func (s *State) GetNetworkID() uint32 {
 	switch s.NetworkNumber {
 	case constants.NETWORK_MAIN:
@@ -2453,7 +2550,7 @@ func (s *State) InitLevelDB() error {
 		}
 	}
 
-	s.DB = databaseOverlay.NewOverlay(dbase)
+	s.DB = databaseOverlay.NewOverlayWithCache(dbase, s.DBCacheSize*1024*1024)
 	return nil
 }
 
@@ -2469,10 +2566,66 @@ func (s *State) InitBoltDB() error {
 
 	dbase := new(boltdb.BoltDB)
 	dbase.Init(nil, path+"FactomBolt.db")
-	s.DB = databaseOverlay.NewOverlay(dbase)
+	s.DB = databaseOverlay.NewOverlayWithCache(dbase, s.DBCacheSize*1024*1024)
+	return nil
+}
+
+func (s *State) InitBadgerDB() error {
+	if s.DB != nil {
+		return nil
+	}
+
+	path := s.BadgerDBPath + "/" + s.Network + "/" + "factoid_badger.db"
+
+	s.Println("Database:", path)
+	fmt.Fprintln(os.Stderr, "Database:", path)
+
+	dbase, err := badgerdb.NewBadgerDB(path, true)
+	if err != nil {
+		return err
+	}
+
+	s.DB = databaseOverlay.NewOverlayWithCache(dbase, s.DBCacheSize*1024*1024)
 	return nil
 }
 
+// InitSecureDB opens the encrypted database, with the passphrase from SecureDBPassphraseFile or
+// the environment
+func (s *State) InitSecureDB() error {
+	if s.DB != nil {
+		return nil
+	}
+
+	path := s.SecureDBPath()
+	s.Println("Database:", path)
+	fmt.Fprintln(os.Stderr, "Database:", path)
+	os.MkdirAll(filepath.Dir(path), 0777)
+
+	passphrase, err := securedb.LoadPassphrase(s.SecureDBPassphraseFile)
+	if err != nil {
+		return err
+	}
+	dbase, err := securedb.NewEncryptedDB(path, securedb.SecureDBTypes[s.DBType], passphrase)
+	if err != nil {
+		return err
+	}
+
+	s.DB = databaseOverlay.NewOverlayWithCache(dbase, s.DBCacheSize*1024*1024)
+	return nil
+}
+
+// SecureDBPath returns the path of the encrypted database, next to the plain database of the
+// same type
+func (s *State) SecureDBPath() string {
+	switch s.DBType {
+	case "SecureBolt":
+		return s.BoltDBPath + "/" + s.Network + "/" + "FactomBolt_secure.db"
+	case "SecureBadger":
+		return s.BadgerDBPath + "/" + s.Network + "/" + "factoid_badger_secure.db"
+	}
+	return s.LdbPath + "/" + s.Network + "/" + "factoid_level_secure.db"
+}
+
 func (s *State) InitMapDB() error {
 	if s.DB != nil {
 		return nil
@@ -2560,15 +2713,15 @@ func (s *State) SetStringConsensus() {
 //		totalTPS	: Transaction rate over life of node (totaltime / totaltrans)
 //		instantTPS	: Transaction rate weighted over last 3 seconds
 func (s *State) CalculateTransactionRate() (totalTPS float64, instantTPS float64) {
-	runtime := time.Since(s.Starttime)
+	runtime := primitives.Now().Sub(s.Starttime)
 	total := s.FactoidTrans + s.NewEntryChains + s.NewEntries
 	tps := float64(total) / float64(runtime.Seconds())
 	TotalTransactionPerSecond.Set(tps) // Prometheus
-	shorttime := time.Since(s.lasttime)
+	shorttime := primitives.Now().Sub(s.lasttime)
 	if shorttime >= time.Second*3 {
 		delta := (s.FactoidTrans + s.NewEntryChains + s.NewEntries) - s.transCnt
 		s.tps = ((float64(delta) / float64(shorttime.Seconds())) + 2*s.tps) / 3
-		s.lasttime = time.Now()
+		s.lasttime = primitives.Now()
 		s.transCnt = total                     // transactions accounted for
 		InstantTransactionPerSecond.Set(s.tps) // Prometheus
 	}
@@ -118,6 +118,14 @@ func (s *State) executeMsg(vm *VM, msg interfaces.IMsg) (ret bool) {
 		}
 	}
 
+	// DBStates below the highest saved block fail to validate, so the integrity verifier takes
+	// the ones that repair a corrupted height first
+	if msg.Type() == constants.DBSTATE_MSG && s.IntegrityVerifier != nil {
+		if dbstate, ok := msg.(*messages.DBStateMsg); ok && s.IntegrityVerifier.Repair(dbstate) {
+			return true
+		}
+	}
+
 	valid := msg.Validate(s)
 	if valid == 1 {
 		// Sometimes we think the LoadDatabase() thread starts before the boot time gets set -- hack to be fixed
@@ -666,7 +674,7 @@ func (s *State) MoveStateToHeight(dbheight uint32, newMinute int) {
 		}
 		s.dbheights <- int(dbheight) // Notify MMR process we have moved on...
 
-		s.CurrentMinuteStartTime = time.Now().UnixNano()
+		s.CurrentMinuteStartTime = primitives.Now().UnixNano()
 		s.CurrentBlockStartTime = s.CurrentMinuteStartTime
 
 		// If an we added or removed servers or elections tool place in minute 9, our lists will be unsorted. Fix that
@@ -684,7 +692,7 @@ func (s *State) MoveStateToHeight(dbheight uint32, newMinute int) {
 		s.EOMDone = false
 		s.EOMProcessed = 0
 
-		s.CurrentMinuteStartTime = time.Now().UnixNano()
+		s.CurrentMinuteStartTime = primitives.Now().UnixNano()
 		// If an election took place, our lists will be unsorted. Fix that
 		s.LeaderPL.SortAuditServers()
 		s.LeaderPL.SortFedServers()
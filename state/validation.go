@@ -11,6 +11,7 @@ import (
 	"github.com/FactomProject/factomd/common/constants"
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/util/atomic"
 	log "github.com/sirupsen/logrus"
 )
@@ -24,7 +25,7 @@ func (state *State) ValidatorLoop() {
 		s := state
 		if state.DebugExec() {
 			status := ""
-			now := time.Now()
+			now := primitives.Now()
 			if now.Sub(prev).Minutes() > 1 {
 				state.LogPrintf("executeMsg", "Timestamp DBh/VMh/h %d/%d/%d", state.LLeaderHeight, state.LeaderVMIndex, state.CurrentMinute)
 				pendingEBs := 0
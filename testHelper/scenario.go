@@ -0,0 +1,402 @@
+package testHelper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/engine"
+	"github.com/FactomProject/factomd/state"
+)
+
+// Scenario is a multi-node simulation written as a script, run by RunScenario.  A scenario file
+// has one statement per line, and # starts a comment:
+//
+//	nodes LLLAAF              # the nodes and their roles, as SetupSim: Leader, Audit or Follower
+//	option --blktime 10       # a factomd flag
+//	net tree                  # the network topology, as --net (or a file, as --fnet)
+//	blocks 20                 # the height expected at the end, for the timeout
+//	elections 1               # elections and rounds expected, for the timeout
+//	rounds 2
+//	at 3:2 offline 5          # at block 3 minute 2, take node 5 off the network
+//	at 4 online 5             # at block 4 minute 0, bring it back
+//	at 5 partition 0,1,2 3,4  # cut the links between the groups of nodes
+//	at 7 heal                 # restore all the links
+//	at 5 link 0 * latency normal:100:20 drop 5  # the fault model of the links from node 0, as Xl
+//	at 5 load 5               # entries per second written by the LoadGenerator, 0 to stop
+//	at 5 delay 100            # maximum delay of the messages, in milliseconds
+//	at 5 drop 10 2            # drop rate, in tenths of a percent, of a node or of all the nodes
+//	at 6 cmd g1               # a simControl command
+//	at 10 expect height >= 10
+//	at 10 expect authorities 3 2 1
+//	at 10 expect balance FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q >= 1
+//	end 12                    # the block to stop at, after the last event if not given
+//
+// Times and heights count from the block the network is set up at, so a scenario waits on the
+// blocks and minutes of the network rather than on the clock.
+type Scenario struct {
+	Nodes     string
+	Options   map[string]string
+	Blocks    int
+	Elections int
+	Rounds    int
+	Events    []*ScenarioEvent // in the order of their time
+	End       int
+}
+
+// ScenarioEvent is an action of a scenario, at a block and a minute
+type ScenarioEvent struct {
+	Line   int
+	Block  int
+	Minute int
+	Action string
+	Args   []string
+}
+
+func (e *ScenarioEvent) String() string {
+	return fmt.Sprintf("line %d, at %d:%d %s %s", e.Line, e.Block, e.Minute, e.Action, strings.Join(e.Args, " "))
+}
+
+// scenarioActions are the number of arguments each action takes, -1 for any number
+var scenarioActions = map[string]int{
+	"offline":   1,
+	"online":    1,
+	"partition": -1,
+	"heal":      0,
+	"load":      1,
+	"delay":     1,
+	"drop":      -1,
+	"link":      -1,
+	"cmd":       -1,
+	"expect":    -1,
+}
+
+type eventSort []*ScenarioEvent
+
+func (s eventSort) Len() int      { return len(s) }
+func (s eventSort) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s eventSort) Less(i, j int) bool {
+	return s[i].Block*10+s[i].Minute < s[j].Block*10+s[j].Minute
+}
+
+// ReadScenario reads a scenario file
+func ReadScenario(r io.Reader) (*Scenario, error) {
+	sc := new(Scenario)
+	sc.Options = map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		err := sc.parse(line, fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if sc.Nodes == "" {
+		return nil, fmt.Errorf("the scenario has no nodes")
+	}
+	sort.Stable(eventSort(sc.Events))
+	if sc.Blocks == 0 {
+		sc.Blocks = sc.End + 10 // the setup takes about 5 blocks
+		if n := len(sc.Events); n > 0 && sc.Events[n-1].Block > sc.End {
+			sc.Blocks = sc.Events[n-1].Block + 10
+		}
+	}
+	return sc, nil
+}
+
+func (sc *Scenario) parse(line int, fields []string) error {
+	number := func() (int, error) {
+		if len(fields) != 2 {
+			return 0, fmt.Errorf("%s takes a number", fields[0])
+		}
+		return strconv.Atoi(fields[1])
+	}
+
+	var err error
+	switch fields[0] {
+	case "nodes":
+		if len(fields) != 2 || strings.Trim(strings.ToUpper(fields[1]), "LAF") != "" {
+			return fmt.Errorf("nodes takes roles L, A or F")
+		}
+		sc.Nodes = fields[1]
+	case "option":
+		if len(fields) != 3 || !strings.HasPrefix(fields[1], "--") {
+			return fmt.Errorf("option takes a --flag and a value")
+		}
+		sc.Options[fields[1]] = fields[2]
+	case "net":
+		if len(fields) != 2 {
+			return fmt.Errorf("net takes a topology or a file")
+		}
+		if _, err := os.Stat(fields[1]); err == nil {
+			sc.Options["--fnet"] = fields[1]
+		} else {
+			sc.Options["--net"] = fields[1]
+		}
+	case "blocks":
+		sc.Blocks, err = number()
+	case "elections":
+		sc.Elections, err = number()
+	case "rounds":
+		sc.Rounds, err = number()
+	case "end":
+		sc.End, err = number()
+	case "at":
+		if len(fields) < 3 {
+			return fmt.Errorf("at takes a time and an action")
+		}
+		e := &ScenarioEvent{Line: line, Action: fields[2], Args: fields[3:]}
+		when := strings.Split(fields[1], ":")
+		e.Block, err = strconv.Atoi(when[0])
+		if err == nil && len(when) > 1 {
+			e.Minute, err = strconv.Atoi(when[1])
+		}
+		if err != nil || len(when) > 2 || e.Block < 0 || e.Minute < 0 || e.Minute > 9 {
+			return fmt.Errorf("bad time %s, as block or block:minute", fields[1])
+		}
+		args, ok := scenarioActions[e.Action]
+		if !ok {
+			return fmt.Errorf("unknown action %s", e.Action)
+		}
+		if args >= 0 && len(e.Args) != args {
+			return fmt.Errorf("%s takes %d arguments", e.Action, args)
+		}
+		sc.Events = append(sc.Events, e)
+	default:
+		return fmt.Errorf("unknown statement %s", fields[0])
+	}
+	return err
+}
+
+// LoadScenario reads a scenario file by name
+func LoadScenario(name string) (*Scenario, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadScenario(file)
+}
+
+// RunScenarioFile runs a scenario file, from go test
+func RunScenarioFile(name string, t *testing.T) {
+	sc, err := LoadScenario(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	RunScenario(sc, t)
+}
+
+// RunScenario sets up the nodes of a scenario, takes its actions when the network reaches their
+// time, then shuts everything down.  A failed expectation fails the test and the scenario goes on.
+func RunScenario(sc *Scenario, t *testing.T) {
+	state0 := SetupSim(sc.Nodes, sc.Options, sc.Blocks, sc.Elections, sc.Rounds, t)
+	start := int(state0.LLeaderHeight)
+
+	for _, e := range sc.Events {
+		waitForScenario(state0, start+e.Block, e.Minute)
+		fmt.Printf("Scenario %s\n", e.String())
+		if err := runScenarioEvent(e, start); err != nil {
+			t.Errorf("%s: %v", e.String(), err)
+		}
+	}
+	if sc.End > 0 {
+		waitForScenario(state0, start+sc.End, 0)
+	}
+	engine.HealSimPartition("")
+	engine.SetSimLinkModel(-1, -1, nil)
+	ShutDownEverything(t)
+}
+
+// waitForScenario waits for a block and minute, unless the network is already past them
+func waitForScenario(s *state.State, block int, minute int) {
+	if block*10+minute <= int(s.LLeaderHeight)*10+s.CurrentMinute {
+		return
+	}
+	WaitForQuiet(s, block, minute)
+}
+
+func scenarioNode(arg string) (*engine.FactomNode, error) {
+	n, err := strconv.Atoi(arg)
+	fnodes := engine.GetFnodes()
+	if err != nil || n < 0 || n >= len(fnodes) {
+		return nil, fmt.Errorf("%s is not a node", arg)
+	}
+	return fnodes[n], nil
+}
+
+func runScenarioEvent(e *ScenarioEvent, start int) error {
+	switch e.Action {
+	case "offline", "online":
+		node, err := scenarioNode(e.Args[0])
+		if err != nil {
+			return err
+		}
+		node.State.SetNetStateOff(e.Action == "offline")
+	case "partition":
+		var groups [][]int
+		for _, arg := range e.Args {
+			var group []int
+			for _, n := range strings.Split(arg, ",") {
+				if _, err := scenarioNode(n); err != nil {
+					return err
+				}
+				i, _ := strconv.Atoi(n)
+				group = append(group, i)
+			}
+			groups = append(groups, group)
+		}
+		engine.ApplySimPartition("scenario", groups)
+	case "heal":
+		engine.HealSimPartition("")
+	case "load":
+		RunCmd("R" + e.Args[0])
+	case "delay":
+		if _, err := strconv.Atoi(e.Args[0]); err != nil {
+			return fmt.Errorf("bad delay %s", e.Args[0])
+		}
+		RunCmd("F" + e.Args[0])
+	case "drop":
+		if len(e.Args) < 1 || len(e.Args) > 2 {
+			return fmt.Errorf("drop takes a rate and a node")
+		}
+		rate, err := strconv.Atoi(e.Args[0])
+		if err != nil || rate < 0 || rate > 999 {
+			return fmt.Errorf("bad drop rate %s", e.Args[0])
+		}
+		nodes := engine.GetFnodes()
+		if len(e.Args) == 2 {
+			node, err := scenarioNode(e.Args[1])
+			if err != nil {
+				return err
+			}
+			nodes = []*engine.FactomNode{node}
+		}
+		for _, node := range nodes {
+			node.State.DropRate = rate
+		}
+	case "link":
+		_, err := engine.SimLinkCommand(append([]string{"Xl"}, e.Args...))
+		return err
+	case "cmd":
+		RunCmd(strings.Join(e.Args, " "))
+	case "expect":
+		return scenarioExpect(e.Args, start)
+	}
+	return nil
+}
+
+func compare(a int64, op string, b int64) (bool, error) {
+	switch op {
+	case "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	case ">=":
+		return a >= b, nil
+	}
+	return false, fmt.Errorf("unknown comparison %s", op)
+}
+
+// scenarioExpect checks an expectation on the nodes on the network
+func scenarioExpect(args []string, start int) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expect what")
+	}
+	switch args[0] {
+	case "height":
+		if len(args) != 3 {
+			return fmt.Errorf("expect height takes a comparison and a height")
+		}
+		height, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return err
+		}
+		for _, node := range engine.GetFnodes() {
+			s := node.State
+			if s.GetNetStateOff() {
+				continue
+			}
+			ok, err := compare(int64(int(s.LLeaderHeight)-start), args[1], height)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s is at height %d", s.FactomNodeName, int(s.LLeaderHeight)-start)
+			}
+		}
+	case "authorities":
+		if len(args) != 4 {
+			return fmt.Errorf("expect authorities takes leaders, audits and followers")
+		}
+		var want [3]int
+		for i := range want {
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil {
+				return err
+			}
+			want[i] = n
+		}
+		leaders, audits, followers := CountAuthorities()
+		if leaders != want[0] || audits != want[1] || followers != want[2] {
+			return fmt.Errorf("found %d leaders, %d audits and %d followers", leaders, audits, followers)
+		}
+	case "balance":
+		if len(args) != 4 {
+			return fmt.Errorf("expect balance takes an address, a comparison and an amount")
+		}
+		address := args[1]
+		fct := primitives.ValidateFUserStr(address)
+		if !fct && !primitives.ValidateECUserStr(address) {
+			return fmt.Errorf("%s is not a factoid or an entry credit address", address)
+		}
+		var hash [32]byte
+		copy(hash[:], primitives.ConvertUserStrToAddress(address))
+		amount, err := strconv.ParseInt(args[3], 10, 64)
+		if err != nil {
+			return err
+		}
+		for _, node := range engine.GetFnodes() {
+			s := node.State
+			if s.GetNetStateOff() {
+				continue
+			}
+			balance := s.GetE(true, hash)
+			if fct {
+				balance = s.GetF(true, hash)
+			}
+			ok, err := compare(balance, args[2], amount)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s has a balance of %d", s.FactomNodeName, balance)
+			}
+		}
+	default:
+		return fmt.Errorf("cannot expect %s", args[0])
+	}
+	return nil
+}
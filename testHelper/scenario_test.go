@@ -0,0 +1,50 @@
+package testHelper_test
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/FactomProject/factomd/testHelper"
+)
+
+func TestReadScenario(t *testing.T) {
+	sc, err := ReadScenario(strings.NewReader(`
+nodes LLAF # two leaders
+option --blktime 10
+at 4 heal
+at 2:3 partition 0,1,2 3
+at 7 expect height >= 7
+end 8
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.Nodes != "LLAF" || sc.Options["--blktime"] != "10" || sc.End != 8 {
+		t.Errorf("Wrong scenario %v", sc)
+	}
+	if sc.Blocks != 18 {
+		t.Errorf("Expected 18 blocks, got %d", sc.Blocks)
+	}
+	if len(sc.Events) != 3 {
+		t.Fatalf("Found %d events", len(sc.Events))
+	}
+	e := sc.Events[0]
+	if e.Action != "partition" || e.Block != 2 || e.Minute != 3 || len(e.Args) != 2 {
+		t.Errorf("Events not in order of time, first %s", e.String())
+	}
+
+	bad := []string{
+		"at 2 heal",                  // no nodes
+		"nodes LLX",                  // bad role
+		"nodes LL\nat 2:10 heal",     // bad minute
+		"nodes LL\nat 2 jump",        // unknown action
+		"nodes LL\nat 2 offline",     // missing argument
+		"nodes LL\nspeed 3",          // unknown statement
+		"nodes LL\noption blktime 3", // not a flag
+	}
+	for _, s := range bad {
+		if _, err := ReadScenario(strings.NewReader(s)); err == nil {
+			t.Errorf("Accepted %q", s)
+		}
+	}
+}
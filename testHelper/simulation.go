@@ -332,10 +332,26 @@ func WaitForMinute(s *state.State, newMinute int) {
 }
 
 func CheckAuthoritySet(t *testing.T) {
-	leadercnt := 0
-	auditcnt := 0
-	followercnt := 0
+	leadercnt, auditcnt, followercnt := CountAuthorities()
 
+	if leadercnt != Leaders {
+		engine.PrintOneStatus(0, 0)
+		t.Fatalf("found %d leaders, expected %d", leadercnt, Leaders)
+	}
+	if auditcnt != Audits {
+		engine.PrintOneStatus(0, 0)
+		t.Fatalf("found %d audit servers, expected %d", auditcnt, Audits)
+		t.Fail()
+	}
+	if followercnt != Followers {
+		engine.PrintOneStatus(0, 0)
+		t.Fatalf("found %d followers, expected %d", followercnt, Followers)
+		t.Fail()
+	}
+}
+
+// CountAuthorities returns the number of leaders, audit servers and followers among the nodes
+func CountAuthorities() (leadercnt int, auditcnt int, followercnt int) {
 	for i, fn := range engine.GetFnodes() {
 		s := fn.State
 		if s.Leader {
@@ -354,21 +370,7 @@ func CheckAuthoritySet(t *testing.T) {
 			}
 		}
 	}
-
-	if leadercnt != Leaders {
-		engine.PrintOneStatus(0, 0)
-		t.Fatalf("found %d leaders, expected %d", leadercnt, Leaders)
-	}
-	if auditcnt != Audits {
-		engine.PrintOneStatus(0, 0)
-		t.Fatalf("found %d audit servers, expected %d", auditcnt, Audits)
-		t.Fail()
-	}
-	if followercnt != Followers {
-		engine.PrintOneStatus(0, 0)
-		t.Fatalf("found %d followers, expected %d", followercnt, Followers)
-		t.Fail()
-	}
+	return leadercnt, auditcnt, followercnt
 }
 
 func RunCmd(cmd string) {
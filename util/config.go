@@ -24,12 +24,16 @@ type FactomdConfig struct {
 		DBType                                 string
 		LdbPath                                string
 		BoltDBPath                             string
+		BadgerDBPath                           string
+		DBCacheSize                            int
+		SecureDBPassphraseFile                 string
 		DataStorePath                          string
 		DirectoryBlockInSeconds                int
 		ExportData                             bool
 		ExportDataSubpath                      string
 		FastBoot                               bool
 		FastBootLocation                       string
+		SnapshotPath                           string
 		NodeMode                               string
 		IdentityChainID                        string
 		LocalServerPrivKey                     string
@@ -46,15 +50,19 @@ type FactomdConfig struct {
 		MainNetworkPort         string
 		PeersFile               string
 		MainSeedURL             string
+		MainSeedPublicKey       string
 		MainSpecialPeers        string
 		TestNetworkPort         string
 		TestSeedURL             string
+		TestSeedPublicKey       string
 		TestSpecialPeers        string
 		LocalNetworkPort        string
 		LocalSeedURL            string
+		LocalSeedPublicKey      string
 		LocalSpecialPeers       string
 		CustomNetworkPort       string
 		CustomSeedURL           string
+		CustomSeedPublicKey     string
 		CustomSpecialPeers      string
 		CustomBootstrapIdentity string
 		CustomBootstrapKey      string
@@ -113,16 +121,23 @@ HomeDir                               = ""
 ; --------------- ControlPanel disabled | readonly | readwrite
 ControlPanelSetting                   = readonly
 ControlPanelPort                      = 8090
-; --------------- DBType: LDB | Bolt | Map
+; --------------- DBType: LDB | Bolt | Badger | Map | SecureLDB | SecureBolt | SecureBadger
 DBType                                = "LDB"
 LdbPath                               = "database/ldb"
 BoltDBPath                            = "database/bolt"
+BadgerDBPath                          = "database/badger"
+; --------------- DBCacheSize: megabytes of database records cached in memory, 0 to disable
+DBCacheSize                           = 0
+; --------------- SecureDBPassphraseFile: passphrase of the Secure* database types, else read from FACTOMD_DB_PASSPHRASE
+SecureDBPassphraseFile                = ""
 DataStorePath                         = "data/export"
 DirectoryBlockInSeconds               = 6
 ExportData                            = false
 ExportDataSubpath                     = "database/export/"
 FastBoot                              = true
 FastBootLocation                      = ""
+; --------------- SnapshotPath: directory the create-snapshot API writes snapshots in, empty to disable it
+SnapshotPath                          = ""
 ; --------------- Network: MAIN | TEST | LOCAL
 Network                               = MAIN
 PeersFile            = "peers.json"
@@ -220,23 +235,31 @@ func (s *FactomdConfig) String() string {
 	out.WriteString(fmt.Sprintf("\n    DBType                  %v", s.App.DBType))
 	out.WriteString(fmt.Sprintf("\n    LdbPath                 %v", s.App.LdbPath))
 	out.WriteString(fmt.Sprintf("\n    BoltDBPath              %v", s.App.BoltDBPath))
+	out.WriteString(fmt.Sprintf("\n    BadgerDBPath            %v", s.App.BadgerDBPath))
+	out.WriteString(fmt.Sprintf("\n    DBCacheSize             %v", s.App.DBCacheSize))
+	out.WriteString(fmt.Sprintf("\n    SecureDBPassphraseFile  %v", s.App.SecureDBPassphraseFile))
 	out.WriteString(fmt.Sprintf("\n    DataStorePath           %v", s.App.DataStorePath))
 	out.WriteString(fmt.Sprintf("\n    DirectoryBlockInSeconds %v", s.App.DirectoryBlockInSeconds))
 	out.WriteString(fmt.Sprintf("\n    ExportData              %v", s.App.ExportData))
 	out.WriteString(fmt.Sprintf("\n    ExportDataSubpath       %v", s.App.ExportDataSubpath))
+	out.WriteString(fmt.Sprintf("\n    SnapshotPath            %v", s.App.SnapshotPath))
 	out.WriteString(fmt.Sprintf("\n    Network                 %v", s.App.Network))
 	out.WriteString(fmt.Sprintf("\n    MainNetworkPort         %v", s.App.MainNetworkPort))
 	out.WriteString(fmt.Sprintf("\n    PeersFile               %v", s.App.PeersFile))
 	out.WriteString(fmt.Sprintf("\n    MainSeedURL             %v", s.App.MainSeedURL))
+	out.WriteString(fmt.Sprintf("\n    MainSeedPublicKey       %v", s.App.MainSeedPublicKey))
 	out.WriteString(fmt.Sprintf("\n    MainSpecialPeers        %v", s.App.MainSpecialPeers))
 	out.WriteString(fmt.Sprintf("\n    TestNetworkPort         %v", s.App.TestNetworkPort))
 	out.WriteString(fmt.Sprintf("\n    TestSeedURL             %v", s.App.TestSeedURL))
+	out.WriteString(fmt.Sprintf("\n    TestSeedPublicKey       %v", s.App.TestSeedPublicKey))
 	out.WriteString(fmt.Sprintf("\n    TestSpecialPeers        %v", s.App.TestSpecialPeers))
 	out.WriteString(fmt.Sprintf("\n    LocalNetworkPort        %v", s.App.LocalNetworkPort))
 	out.WriteString(fmt.Sprintf("\n    LocalSeedURL            %v", s.App.LocalSeedURL))
+	out.WriteString(fmt.Sprintf("\n    LocalSeedPublicKey      %v", s.App.LocalSeedPublicKey))
 	out.WriteString(fmt.Sprintf("\n    LocalSpecialPeers       %v", s.App.LocalSpecialPeers))
 	out.WriteString(fmt.Sprintf("\n    CustomNetworkPort       %v", s.App.CustomNetworkPort))
 	out.WriteString(fmt.Sprintf("\n    CustomSeedURL           %v", s.App.CustomSeedURL))
+	out.WriteString(fmt.Sprintf("\n    CustomSeedPublicKey     %v", s.App.CustomSeedPublicKey))
 	out.WriteString(fmt.Sprintf("\n    CustomSpecialPeers      %v", s.App.CustomSpecialPeers))
 	out.WriteString(fmt.Sprintf("\n    CustomBootstrapIdentity %v", s.App.CustomBootstrapIdentity))
 	out.WriteString(fmt.Sprintf("\n    CustomBootstrapKey      %v", s.App.CustomBootstrapKey))
@@ -11,11 +11,13 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/FactomProject/factomd/common/globals"
 
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/p2p"
 
 	"github.com/FactomProject/web"
 )
@@ -81,15 +83,27 @@ func HandleDebugRequest(
 	state.LogPrintf("apidebuglog", "request %v", j.String())
 
 	switch j.Method {
+	case "ban-peer":
+		resp, jsonError = HandleBanPeer(state, params)
+		break
 	case "audit-servers":
 		resp, jsonError = HandleAuditServers(state, params)
 		break
 	case "authorities":
 		resp, jsonError = HandleAuthorities(state, params)
 		break
+	case "chain-head-repair":
+		resp, jsonError = HandleChainHeadRepair(state, params)
+		break
 	case "configuration":
 		resp, jsonError = HandleConfig(state, params)
 		break
+	case "create-snapshot":
+		resp, jsonError = HandleCreateSnapshot(state, params)
+		break
+	case "snapshot-status":
+		resp, jsonError = HandleSnapshotStatus(state, params)
+		break
 	case "current-minute":
 		resp, jsonError = HandleCurrentMinute(state, params)
 		break
@@ -105,12 +119,30 @@ func HandleDebugRequest(
 	case "set-drop-rate":
 		resp, jsonError = HandleSetDropRate(state, params)
 		break
+	case "fault-history":
+		resp, jsonError = HandleFaultHistory(state, params)
+		break
 	case "federated-servers":
 		resp, jsonError = HandleFedServers(state, params)
 		break
 	case "holding-queue":
 		resp, jsonError = HandleHoldingQueue(state, params)
 		break
+	case "identity":
+		resp, jsonError = HandleIdentity(state, params)
+		break
+	case "integrity-report":
+		resp, jsonError = HandleIntegrityReport(state, params)
+		break
+	case "load-profile":
+		resp, jsonError = HandleLoadProfile(state, params)
+		break
+	case "load-stats":
+		resp, jsonError = HandleLoadStats(state, params)
+		break
+	case "message-traces":
+		resp, jsonError = HandleMessageTraces(state, params)
+		break
 	case "messages":
 		resp, jsonError = HandleMessages(state, params)
 		break
@@ -120,17 +152,29 @@ func HandleDebugRequest(
 	case "summary":
 		resp, jsonError = HandleSummary(state, params)
 		break
+	case "peer-bans":
+		resp, jsonError = HandlePeerBans(state, params)
+		break
 	case "predictive-fer":
 		resp, jsonError = HandlePredictiveFER(state, params)
 		break
 	case "process-list":
 		resp, jsonError = HandleProcessList(state, params)
 		break
+	case "repair-chain-heads":
+		resp, jsonError = HandleRepairChainHeads(state, params)
+		break
 	case "reload-configuration":
 		resp, jsonError = HandleReloadConfig(state, params)
 		break
 	case "sim-ctrl":
 		resp, jsonError = HandleSimControl(state, params)
+	case "trace-latency":
+		resp, jsonError = HandleTraceLatency(state, params)
+		break
+	case "unban-peer":
+		resp, jsonError = HandleUnbanPeer(state, params)
+		break
 	default:
 		jsonError = NewMethodNotFoundError()
 		break
@@ -287,6 +331,28 @@ func HandleSetDropRate(
 	return r, nil
 }
 
+func HandleFaultHistory(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(FaultHistoryRequest)
+	if params != nil {
+		err := MapToObject(params, req)
+		if err != nil || req.Limit < 0 {
+			return nil, NewInvalidParamsError()
+		}
+	}
+
+	report, err := state.QueryFaultHistory(req.Start, req.End, req.Leader, req.Kind, req.Limit)
+	if err != nil {
+		return nil, NewCustomInternalError(err.Error())
+	}
+	return report, nil
+}
+
 func HandleFedServers(
 	state interfaces.IState,
 	params interface{},
@@ -321,6 +387,63 @@ func HandleHoldingQueue(
 	return r, nil
 }
 
+func getLoadGenerator(state interfaces.IState) (interfaces.ILoadGenerator, *primitives.JSONError) {
+	lg := state.GetLoadGenerator()
+	if lg == nil {
+		return nil, NewCustomInternalError("The load generator only runs in a simulation")
+	}
+	return lg, nil
+}
+
+func HandleLoadProfile(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(LoadProfileRequest)
+	if params != nil {
+		err := MapToObject(params, req)
+		if err != nil || (req.Rate != nil && *req.Rate < 0) {
+			return nil, NewInvalidParamsError()
+		}
+	}
+
+	lg, jsonError := getLoadGenerator(state)
+	if jsonError != nil {
+		return nil, jsonError
+	}
+
+	if req.Profile != nil {
+		data, err := json.Marshal(req.Profile)
+		if err != nil {
+			return nil, NewInvalidParamsError()
+		}
+		if err := lg.SetLoadProfile(data); err != nil {
+			return nil, NewCustomInternalError(err.Error())
+		}
+	}
+	if req.Rate != nil {
+		lg.SetLoadRate(*req.Rate)
+	}
+	return lg.GetLoadProfile(), nil
+}
+
+func HandleLoadStats(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	lg, jsonError := getLoadGenerator(state)
+	if jsonError != nil {
+		return nil, jsonError
+	}
+	return lg.GetLoadStats(), nil
+}
+
 func HandleMessages(
 	state interfaces.IState,
 	params interface{},
@@ -349,14 +472,288 @@ func HandleNetworkInfo(
 		NetworkNumber int
 		NetworkName   string
 		NetworkID     uint32
+		Seeds         []p2p.SeedStatus `json:",omitempty"`
 	}
 	r := new(ret)
 	r.NetworkNumber = state.GetNetworkNumber()
 	r.NetworkName = state.GetNetworkName()
 	r.NetworkID = state.GetNetworkID()
+	if controller := getNetworkController(state); controller != nil {
+		r.Seeds = controller.GetSeedStatus()
+	}
+	return r, nil
+}
+
+// getNetworkController returns the p2p controller of the node, or nil if the node is not networked
+func getNetworkController(state interfaces.IState) *p2p.Controller {
+	controller, _ := state.GetNetworkController().(*p2p.Controller)
+	return controller
+}
+
+func HandlePeerBans(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	type ret struct {
+		Bans []p2p.BanEntry
+	}
+	r := new(ret)
+
+	controller := getNetworkController(state)
+	if controller == nil {
+		return nil, NewCustomInternalError("Network is not enabled")
+	}
+	r.Bans = controller.GetBans()
 	return r, nil
 }
 
+func HandleCreateSnapshot(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(CreateSnapshotRequest)
+	err := MapToObject(params, req)
+	if err != nil || req.Name == "" {
+		return nil, NewInvalidParamsError()
+	}
+
+	export, err := state.CreateSnapshot(req.Name, req.Height)
+	if err != nil {
+		return nil, NewCustomInternalError(err.Error())
+	}
+	return export, nil
+}
+
+func HandleSnapshotStatus(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	export := state.GetSnapshotExport()
+	if export == nil {
+		return nil, NewCustomInternalError("No snapshot export was started")
+	}
+	return export, nil
+}
+
+func HandleRepairChainHeads(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(RepairChainHeadsRequest)
+	if params != nil {
+		err := MapToObject(params, req)
+		if err != nil {
+			return nil, NewInvalidParamsError()
+		}
+	}
+
+	repair, err := state.RepairChainHeads(req.ChainIDs, req.DryRun)
+	if err != nil {
+		return nil, NewCustomInternalError(err.Error())
+	}
+	return repair, nil
+}
+
+func HandleChainHeadRepair(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(ChainHeadRepairRequest)
+	if params != nil {
+		err := MapToObject(params, req)
+		if err != nil {
+			return nil, NewInvalidParamsError()
+		}
+	}
+
+	repair := state.GetChainHeadRepair(req.From)
+	if repair == nil {
+		return nil, NewCustomInternalError("No chain head repair was started")
+	}
+	return repair, nil
+}
+
+func HandleIdentity(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(IdentityRequest)
+	err := MapToObject(params, req)
+	if err != nil || req.ChainID == "" {
+		return nil, NewInvalidParamsError()
+	}
+
+	history, err := state.GetIdentityHistory(req.ChainID, req.Start, req.End)
+	if err != nil {
+		return nil, NewCustomInternalError(err.Error())
+	}
+	return history, nil
+}
+
+func HandleIntegrityReport(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	report := state.GetIntegrityReport()
+	if report == nil {
+		return nil, NewCustomInternalError("The integrity verifier is not running")
+	}
+	return report, nil
+}
+
+func HandleBanPeer(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	ban := new(BanPeerRequest)
+	err := MapToObject(params, ban)
+	if err != nil || ban.Address == "" {
+		return nil, NewInvalidParamsError()
+	}
+	duration := time.Duration(0)
+	if ban.Duration != "" {
+		duration, err = time.ParseDuration(ban.Duration)
+		if err != nil || duration < time.Second {
+			return nil, NewInvalidParamsError()
+		}
+	}
+
+	controller := getNetworkController(state)
+	if controller == nil {
+		return nil, NewCustomInternalError("Network is not enabled")
+	}
+	if ban.Reason == "" {
+		ban.Reason = "banned through the debug API"
+	}
+	controller.BanAddress(ban.Address, ban.Reason, duration)
+
+	type ret struct {
+		Address string
+		Status  string
+	}
+	r := new(ret)
+	r.Address = ban.Address
+	r.Status = "Banned"
+	return r, nil
+}
+
+func HandleUnbanPeer(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	unban := new(UnbanPeerRequest)
+	err := MapToObject(params, unban)
+	if err != nil || unban.Address == "" {
+		return nil, NewInvalidParamsError()
+	}
+
+	controller := getNetworkController(state)
+	if controller == nil {
+		return nil, NewCustomInternalError("Network is not enabled")
+	}
+
+	type ret struct {
+		Address string
+		Removed bool
+	}
+	r := new(ret)
+	r.Address = unban.Address
+	r.Removed = controller.Unban(unban.Address)
+	return r, nil
+}
+
+// getTracingController returns the p2p controller if message tracing is on, or an error for the API caller
+func getTracingController(state interfaces.IState) (*p2p.Controller, *primitives.JSONError) {
+	controller := getNetworkController(state)
+	if controller == nil {
+		return nil, NewCustomInternalError("Network is not enabled")
+	}
+	if !controller.IsTracing() {
+		return nil, NewCustomInternalError("Message tracing is not enabled, start factomd with -tracemessages")
+	}
+	return controller, nil
+}
+
+func HandleMessageTraces(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(MessageTracesRequest)
+	if params != nil {
+		err := MapToObject(params, req)
+		if err != nil || req.Limit < 0 {
+			return nil, NewInvalidParamsError()
+		}
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	controller, jsonError := getTracingController(state)
+	if jsonError != nil {
+		return nil, jsonError
+	}
+
+	type ret struct {
+		Traces []p2p.PropagationRecord
+	}
+	r := new(ret)
+	r.Traces = controller.GetMessageTraces(req.AppHash, req.AppType, req.Limit)
+	return r, nil
+}
+
+func HandleTraceLatency(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(MessageTracesRequest)
+	if params != nil {
+		err := MapToObject(params, req)
+		if err != nil {
+			return nil, NewInvalidParamsError()
+		}
+	}
+
+	controller, jsonError := getTracingController(state)
+	if jsonError != nil {
+		return nil, jsonError
+	}
+	return controller.GetTraceLatencyStats(req.AppType), nil
+}
+
 func HandleSummary(
 	state interfaces.IState,
 	params interface{},
@@ -430,7 +827,18 @@ func HandleSimControl(state interfaces.IState, params interface{}) (interface{},
 		return nil, NewInvalidParamsError()
 	}
 
+	// The X commands run here, to return the status they print
+	status := ""
 	for i := 0; i < len(droprate.Commands); i++ {
+		cmd := strings.Fields(droprate.Commands[i])
+		if len(cmd) > 0 && cmd[0][0] == 'X' && state.GetSimLinks() != nil {
+			s, err := state.GetSimLinks().SimLinkCommand(cmd)
+			if err != nil {
+				return nil, NewCustomInternalError(err.Error())
+			}
+			status += s
+			continue
+		}
 		runCmd(string(droprate.Commands[i]))
 	}
 
@@ -440,6 +848,9 @@ func HandleSimControl(state interfaces.IState, params interface{}) (interface{},
 
 	r := new(Success)
 	r.Status = "Success!"
+	if status != "" {
+		r.Status = status
+	}
 	return r, nil
 }
 
@@ -451,6 +862,55 @@ type SetDropRateRequest struct {
 	DropRate int `json:"droprate"`
 }
 
+type BanPeerRequest struct {
+	Address  string `json:"address"`
+	Reason   string `json:"reason"`
+	Duration string `json:"duration"` // eg: "1h30m", empty for the default ban duration
+}
+
+type CreateSnapshotRequest struct {
+	Name   string `json:"name"`   // directory of the snapshot in the snapshot path, must not hold a snapshot
+	Height uint32 `json:"height"` // height of the last directory block, 0 for the highest saved block
+}
+
+type RepairChainHeadsRequest struct {
+	ChainIDs []string `json:"chainids"` // chains to check, empty for every chain
+	DryRun   bool     `json:"dryrun"`   // only report the heads that are wrong
+}
+
+type ChainHeadRepairRequest struct {
+	From int `json:"from"` // index of the first change to return, the number of changes already read
+}
+
+type FaultHistoryRequest struct {
+	Start  uint32 `json:"start"`  // first height of the records
+	End    uint32 `json:"end"`    // last height of the records, 0 for no limit
+	Leader string `json:"leader"` // only records of this server chain ID
+	Kind   string `json:"kind"`   // only records of this kind, fault or election
+	Limit  int    `json:"limit"`  // maximum number of records, the newest
+}
+
+type IdentityRequest struct {
+	ChainID string `json:"chainid"` // identity chain ID
+	Start   uint32 `json:"start"`   // first admin block height searched for changes, 0 for the last 1000 blocks up to end
+	End     uint32 `json:"end"`     // last admin block height searched, 0 for the highest saved block
+}
+
+type LoadProfileRequest struct {
+	Profile interface{} `json:"profile"` // profile to set, as in a profile file
+	Rate    *float64    `json:"rate"`    // transactions per second, 0 to stop the load
+}
+
+type MessageTracesRequest struct {
+	AppHash string `json:"apphash"` // only the trace of this message
+	AppType string `json:"apptype"` // only traces of this message type
+	Limit   int    `json:"limit"`   // maximum number of traces, newest first
+}
+
+type UnbanPeerRequest struct {
+	Address string `json:"address"`
+}
+
 type GetCommands struct {
 	Commands []string `json:"commands"`
 }
@@ -1397,7 +1397,7 @@ func HandleV2Diagnostics(state interfaces.IState, params interface{}) (interface
 
 	resp.LeaderHeight = state.GetLLeaderHeight()
 	resp.CurrentMinute = state.GetCurrentMinute()
-	resp.CurrentMinuteDuration = time.Now().UnixNano() - state.GetCurrentMinuteStartTime()
+	resp.CurrentMinuteDuration = primitives.Now().UnixNano() - state.GetCurrentMinuteStartTime()
 	resp.PrevMinuteDuration = state.GetCurrentMinuteStartTime() - state.GetPreviousMinuteStartTime()
 	resp.BalanceHash = state.GetFactoidState().GetBalanceHash(false).String()
 	resp.TempBalanceHash = state.GetFactoidState().GetBalanceHash(true).String()